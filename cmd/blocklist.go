@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/spf13/cobra"
+	"github.com/xortim/penny/pkg/blocklist"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/messages"
+)
+
+const (
+	// blocklistTimeout bounds an export, or an import of every feed.
+	blocklistTimeout = 5 * time.Minute
+	// blocklistFetchTimeout bounds fetching one feed.
+	blocklistFetchTimeout = 30 * time.Second
+)
+
+func newBlocklistCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "blocklist",
+		Short: "Share confirmed spammers with other communities",
+		Long:  `Share confirmed spammers with other communities`,
+	}
+	c.AddCommand(&cobra.Command{
+		Use:   "keygen",
+		Short: "Generate a key pair for signing feeds",
+		Long: `Generate a key pair for signing feeds
+
+Prints a new ed25519 key pair. Keep the private key as blocklist.private_key and
+give the public key to the communities importing your feed, for their
+blocklist.public_keys.`,
+		SilenceUsage: true,
+		RunE:         blocklistKeygen,
+	})
+
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Export confirmed spammers as a signed feed",
+		Long: `Export confirmed spammers as a signed feed
+
+Writes every author whose message Penny removed, unless an appeal overturned it,
+as a feed signed with blocklist.private_key. Emails and display names are looked
+up in Slack and hashed with blocklist.salt; they are never written as is.`,
+		SilenceUsage: true,
+		RunE:         blocklistExport,
+	}
+	export.Flags().StringP("output", "o", "-", "The file to write the feed to, or - for stdout.")
+	export.Flags().Duration("since", 0, "Only export removals this recent. All of them if 0.")
+	c.AddCommand(export)
+	return c
+}
+
+func blocklistKeygen(cmd *cobra.Command, args []string) error {
+	public, private, err := blocklist.GenerateKey()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "public_key: %s\nprivate_key: %s\n", public, private)
+	return nil
+}
+
+func blocklistExport(cmd *cobra.Command, args []string) error {
+	cfg := config.Current()
+	if cfg.Blocklist.PrivateKey == "" || cfg.Blocklist.Salt == "" {
+		return errors.New("blocklist.private_key and blocklist.salt are required to export")
+	}
+	key, err := blocklist.ParsePrivateKey(cfg.Blocklist.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("blocklist.private_key: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), blocklistTimeout)
+	defer cancel()
+
+	db, err := openDatabase(cfg.DB)
+	if err != nil {
+		return fmt.Errorf("failed to open the database: %w", err)
+	}
+	store, err := cases.NewGormStore(db)
+	if err != nil {
+		return fmt.Errorf("failed to migrate the cases tables: %w", err)
+	}
+	var since time.Time
+	if d, _ := cmd.Flags().GetDuration("since"); d > 0 {
+		since = time.Now().Add(-d)
+	}
+	authors, err := store.Authors(ctx, messages.VERDICT_REMOVED, since)
+	if err != nil {
+		return fmt.Errorf("failed to list removed authors: %w", err)
+	}
+
+	api := slack.New(cfg.Slack.BotOAuthToken)
+	feed := blocklist.Feed{Issuer: cfg.Blocklist.Issuer, Generated: time.Now().UTC()}
+	if feed.Issuer == "" {
+		auth, err := api.AuthTestContext(ctx)
+		if err != nil {
+			return fmt.Errorf("blocklist.issuer isn't set and the workspace couldn't be looked up: %w", err)
+		}
+		feed.Issuer = auth.Team
+	}
+	for _, uid := range authors {
+		id := blocklist.Identity{UserID: uid}
+		if user, err := api.GetUserInfoContext(ctx, uid); err != nil {
+			log.Warn().Err(err).Str("user", uid).Msg("failed to look up spammer, exporting their user ID only")
+		} else {
+			id = blocklist.IdentityOf(user)
+		}
+		feed.Entries = append(feed.Entries, blocklist.NewEntry(cfg.Blocklist.Salt, id))
+	}
+
+	data, err := blocklist.Sign(feed, key)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	if path, _ := cmd.Flags().GetString("output"); path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if _, err := out.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	log.Info().Int("entries", len(feed.Entries)).Str("issuer", feed.Issuer).Msg("exported blocklist feed")
+	return nil
+}
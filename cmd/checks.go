@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	gadget "github.com/gadget-bot/gadget/core"
+	"github.com/rs/zerolog/log"
+	"github.com/xortim/penny/gadgets/hallmonitor"
+	"github.com/xortim/penny/pkg/checks"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+const (
+	readinessTimeout = 5 * time.Second
+	readinessTTL     = 15 * time.Second
+)
+
+// gadgetScopes are the OAuth scopes each gadget needs on the bot and user tokens.
+var gadgetScopes = []struct {
+	gadget  string
+	enabled func() bool
+	bot     []string
+	user    []string
+}{
+	{
+		gadget:  "gadget",
+		enabled: func() bool { return true },
+		bot:     []string{"app_mentions:read", "chat:write", "commands", "users:read"},
+	},
+	{
+		gadget:  "hallmonitor",
+		enabled: func() bool { return config.Current().SpamFeed.Channel != "" },
+		bot: []string{
+			"channels:history", "channels:join", "channels:read", "chat:write",
+			"groups:history", "groups:read", "reactions:read", "reactions:write", "users:read",
+		},
+		user: []string{"chat:write", "search:read"},
+	},
+	{
+		gadget: "hallmonitor DM notices",
+		enabled: func() bool {
+			cfg := config.Current().SpamFeed
+			return cfg.Channel != "" && cfg.OpNoticeDelivery == hallmonitor.DELIVERY_DM
+		},
+		bot: []string{"im:write"},
+	},
+	{
+		gadget: "hallmonitor DM escalations",
+		enabled: func() bool {
+			cfg := config.Current().SpamFeed
+			notify := cfg.Escalation.Notify
+			return cfg.Channel != "" && cfg.Escalation.Band > 0 && len(cfg.Escalation.Moderators) != 0 &&
+				(notify == hallmonitor.ESCALATE_DM || notify == hallmonitor.ESCALATE_BOTH)
+		},
+		bot: []string{"im:write"},
+	},
+	{
+		gadget: "hallmonitor blocklist",
+		enabled: func() bool {
+			return config.Current().SpamFeed.Channel != "" && len(config.Current().Blocklist.Feeds) != 0
+		},
+		bot: []string{"users:read.email"},
+	},
+	{
+		gadget: "hallmonitor email domain",
+		enabled: func() bool {
+			return config.Current().SpamFeed.Channel != "" && config.Current().SpamFeed.AnomalyScores.EmailDomain > 0
+		},
+		bot: []string{"users:read.email"},
+	},
+	{
+		gadget:  "help",
+		enabled: func() bool { return true },
+		bot:     []string{"commands"},
+	},
+	{
+		gadget:  "whatsnew",
+		enabled: func() bool { return true },
+		bot:     []string{"app_mentions:read", "chat:write"},
+	},
+}
+
+// requiredScopes returns the bot and user token scopes needed by every enabled gadget.
+func requiredScopes() (bot []string, user []string) {
+	for _, g := range gadgetScopes {
+		if !g.enabled() {
+			continue
+		}
+		bot = append(bot, g.bot...)
+		user = append(user, g.user...)
+	}
+	slices.Sort(bot)
+	slices.Sort(user)
+	return slices.Compact(bot), slices.Compact(user)
+}
+
+// slackChecks verify both tokens and the spam-feed channel. They read the
+// config each time they run, so readiness follows a reload. Only the spam feed
+// needs a user token, so a missing one degrades readiness rather than failing it.
+func slackChecks(api slackclient.Client) []checks.Check {
+	return []checks.Check{
+		checks.SlackToken("bot_token", checks.DefaultSlackAPIURL,
+			func() string { return config.Current().Slack.BotOAuthToken },
+			func() []string { bot, _ := requiredScopes(); return bot },
+			false),
+		checks.SlackToken("user_token", checks.DefaultSlackAPIURL,
+			func() string { return config.Current().Slack.UserOAuthToken },
+			func() []string { _, user := requiredScopes(); return user },
+			true),
+		checks.ChannelMember(api,
+			func() string { return config.Current().SpamFeed.Channel },
+			func(ctx context.Context, name string) (string, error) {
+				return hallmonitor.FeedChannelID(ctx, name, api)
+			}),
+	}
+}
+
+// readinessChecks are the dependencies penny needs before it can moderate.
+func readinessChecks(bot *gadget.Gadget, api slackclient.Client) []checks.Check {
+	var db checks.Pinger
+	if bot.Router.DbConnection != nil {
+		if sqlDB, err := bot.Router.DbConnection.DB(); err != nil {
+			log.Error().Err(err).Msg("failed to get the database handle for readiness checks")
+		} else {
+			db = sqlDB
+		}
+	}
+
+	return append([]checks.Check{checks.Database(db)}, slackChecks(api)...)
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/xortim/penny/conf"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/secrets"
+)
+
+func newConfigCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+		Long:  `Inspect the configuration`,
+	}
+	c.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration",
+		Long: `Validate the configuration
+
+Loads the configuration exactly as serve would, from flags, PENNY_* environment
+variables and the config file, then reports every invalid value by its key.
+Unknown keys, which are usually typos, and settings that are valid but probably
+unintended are reported as warnings. Exits non-zero if anything is invalid.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          validateConfig,
+	})
+
+	show := &cobra.Command{
+		Use:   "show",
+		Short: "Show the effective configuration",
+		Long: `Show the effective configuration
+
+Prints every setting as penny sees it after combining flags, environment
+variables, the config file and defaults, along with where each value came from.
+Tokens and passwords are redacted.`,
+		SilenceUsage: true,
+		RunE:         showConfig,
+	}
+	show.Flags().StringP("output", "o", "yaml", "Output format: yaml or json.")
+	c.AddCommand(show)
+	return c
+}
+
+func validateConfig(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	if cfgFile != "" {
+		if err := viper.ReadInConfig(); err != nil {
+			fmt.Fprintf(out, "✗ %s\n", err)
+			return errors.New("configuration is invalid")
+		}
+	}
+
+	cfg := config.Current()
+	for _, key := range config.UnknownKeys(viper.GetViper()) {
+		fmt.Fprintf(out, "! %s: unknown key\n", key)
+	}
+	for _, w := range cfg.Warnings() {
+		fmt.Fprintf(out, "! %s\n", w)
+	}
+
+	var errs config.Errors
+	if err := cfg.Validate(); errors.As(err, &errs) {
+		for _, e := range errs {
+			fmt.Fprintf(out, "✗ %s\n", e)
+		}
+		return fmt.Errorf("configuration has %d error(s)", len(errs))
+	}
+	fmt.Fprintf(out, "✓ %s is valid\n", configSource())
+	return nil
+}
+
+func showConfig(cmd *cobra.Command, args []string) error {
+	settings := config.Settings(config.Current(), sourceOf)
+	switch output, _ := cmd.Flags().GetString("output"); output {
+	case "yaml":
+		return config.WriteYAML(cmd.OutOrStdout(), settings)
+	case "json":
+		return config.WriteJSON(cmd.OutOrStdout(), settings)
+	default:
+		return fmt.Errorf("unknown output format %q (want yaml or json)", output)
+	}
+}
+
+// sourceOf says where key's value came from, including the file or reference
+// a secret was read from.
+func sourceOf(key string) string {
+	source := viperSourceOf(key)
+	if !config.IsSecret(key) {
+		return source
+	}
+	if file := viper.GetString(key + "_file"); file != "" {
+		return viperSourceOf(key+"_file") + ", read from " + file
+	}
+	if ref, ok := secrets.Defaults().Parse(viper.GetString(key)); ok {
+		return source + ", resolved from " + ref.String()
+	}
+	return source
+}
+
+// viperSourceOf says where viper found key's value, in its order of precedence.
+func viperSourceOf(key string) string {
+	if f := flagKeys[key]; f != nil && f.Changed {
+		return config.SOURCE_FLAG + " --" + f.Name
+	}
+
+	env := strings.ToUpper(conf.Executable + "_" + strings.ReplaceAll(key, ".", "_"))
+	for _, name := range append([]string{env}, envKeys[key]...) {
+		if os.Getenv(name) != "" {
+			return config.SOURCE_ENV + " " + name
+		}
+	}
+
+	if viper.InConfig(key) {
+		return config.SOURCE_FILE + " " + viper.ConfigFileUsed()
+	}
+	return config.SOURCE_DEFAULT
+}
+
+// configSource names where the configuration came from.
+func configSource() string {
+	if f := viper.ConfigFileUsed(); f != "" {
+		return f
+	}
+	return "the configuration"
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gadget-bot/gadget/models"
+	"github.com/slack-go/slack"
+	"github.com/spf13/cobra"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/checks"
+	"github.com/xortim/penny/pkg/config"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const doctorTimeout = 30 * time.Second
+
+func newDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Verify the workspace setup",
+		Long: `Verify the workspace setup
+
+Checks both Slack tokens and their scopes against those needed by every enabled
+gadget, that the spam-feed channel exists and Penny is a member, that the
+timezone is valid, and that the database is reachable and migrated. Exits
+non-zero if anything fails.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          doctor,
+	}
+}
+
+func doctor(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithTimeout(cmd.Context(), doctorTimeout)
+	defer cancel()
+
+	report := checks.Run(ctx, doctorChecks())
+	printReport(cmd.OutOrStdout(), report)
+
+	failed := 0
+	for _, r := range report.Checks {
+		if !r.OK && !r.Degraded {
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("doctor found %d problem(s)", failed)
+	}
+	return nil
+}
+
+// doctorChecks opens its own database connection, since doctor runs without a
+// bot, and won't migrate anything.
+func doctorChecks() []checks.Check {
+	cfg := config.Current()
+	var (
+		pinger   checks.Pinger
+		migrator gorm.Migrator
+	)
+	db, err := openDatabase(cfg.DB)
+	if err == nil {
+		if sqlDB, err := db.DB(); err == nil {
+			pinger = sqlDB
+			migrator = db.Migrator()
+		}
+	}
+
+	api := slack.New(cfg.Slack.BotOAuthToken)
+	return append([]checks.Check{
+		checks.Database(pinger),
+		checks.Migrations(pinger, migrator, append([]any{&models.Group{}, &models.User{}}, cases.Models()...)...),
+		checks.Timezone(cfg.SpamFeed.LocalTimezone),
+	}, slackChecks(api)...)
+}
+
+// openDatabase connects to penny's database for commands that run without a
+// bot. It doesn't ping it.
+func openDatabase(cfg config.DB) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&timeout=5s",
+		cfg.Username, cfg.Password, cfg.Hostname, cfg.Name)
+	return gorm.Open(mysql.New(mysql.Config{DSN: dsn, SkipInitializeWithVersion: true}), &gorm.Config{
+		Logger:               gormlogger.Default.LogMode(gormlogger.Silent),
+		DisableAutomaticPing: true,
+	})
+}
+
+func printReport(out io.Writer, report checks.Report) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, r := range report.Checks {
+		mark, detail := "✓", r.Detail
+		switch {
+		case r.Degraded:
+			mark, detail = "!", r.Error
+		case !r.OK:
+			mark, detail = "✗", r.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", mark, r.Name, detail)
+		if r.Hint != "" {
+			fmt.Fprintf(w, "\t\t↳ %s\n", r.Hint)
+		}
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/spf13/viper"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// watchConfig reloads the configuration whenever the config file changes.
+// Only the spam_feed section is swapped in; see config.Reload.
+func watchConfig(api slackclient.Client) {
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		return
+	}
+	viper.OnConfigChange(func(fsnotify.Event) {
+		reloadConfig(file, api)
+	})
+	viper.WatchConfig()
+	log.Info().Str("config", file).Msg("watching config file for changes")
+}
+
+func reloadConfig(file string, api slackclient.Client) {
+	// viper has already re-read the file, but doesn't say whether that worked.
+	err := viper.ReadInConfig()
+	var changes []config.Change
+	if err == nil {
+		changes, err = config.Reload(viper.GetViper())
+	}
+	if err != nil {
+		log.Error().Err(err).Str("config", file).Msg("rejected config reload, keeping the previous configuration")
+		notifyAdmins(api, fmt.Sprintf(":warning: I rejected the change to `%s` and kept the previous configuration:\n```\n%s\n```", file, err))
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	lines := make([]string, len(changes))
+	for i, c := range changes {
+		log.Info().Str("key", c.Key).Str("old", c.Old).Str("new", c.New).Bool("restart", c.Restart).Msg("config changed")
+		lines[i] = "• " + c.String()
+	}
+	notifyAdmins(api, fmt.Sprintf(":gear: I reloaded `%s`:\n%s", file, strings.Join(lines, "\n")))
+}
+
+// notifyAdmins posts text to slack.admin_channel_id, if one is configured.
+func notifyAdmins(api slackclient.Client, text string) {
+	channelID := config.Current().Slack.AdminChannelID
+	if channelID == "" {
+		return
+	}
+	if _, _, err := api.PostMessage(channelID, slack.MsgOptionText(text, false)); err != nil {
+		log.Error().Err(err).Str("channel_id", channelID).Msg("failed to notify admins")
+	}
+}
@@ -3,13 +3,17 @@ package cmd
 import (
 	"os"
 	"strings"
+	"time"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/xortim/penny/conf"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/messages"
 )
 
 var cfgFile string
@@ -43,66 +47,323 @@ func setupFlags(c *cobra.Command) {
 	_ = c.MarkPersistentFlagFilename("config")
 
 	c.PersistentFlags().String("log_level", "info", "Log level (trace, debug, info, warn, error, fatal)")
-	_ = viper.BindPFlag("log.level", c.PersistentFlags().Lookup("log_level"))
+	bindFlag("log.level", c.PersistentFlags().Lookup("log_level"))
 
 	c.PersistentFlags().StringSlice("global_admins", []string{}, "A string list of global admin UUIDs.")
-	_ = viper.BindPFlag("slack.global_admins", c.PersistentFlags().Lookup("global_admins"))
+	bindFlag("slack.global_admins", c.PersistentFlags().Lookup("global_admins"))
+
+	c.PersistentFlags().String("admin_channel_id", "", "Slack channel ID where Penny posts notices for its admins, such as config reloads.")
+	bindFlag("slack.admin_channel_id", c.PersistentFlags().Lookup("admin_channel_id"))
+
+	c.PersistentFlags().String("db_hostname", "localhost", "The host for "+conf.Executable+"'s DB.")
+	bindFlag("db.hostname", c.PersistentFlags().Lookup("db_hostname"))
+	viper.SetDefault("db.hostname", "localhost")
+
+	c.PersistentFlags().String("db_name", conf.Executable, "The name for "+conf.Executable+"'s DB.")
+	bindFlag("db.name", c.PersistentFlags().Lookup("db_name"))
+	viper.SetDefault("db.name", conf.Executable)
+
+	c.PersistentFlags().String("db_username", "", "The username for "+conf.Executable+"'s DB.")
+	bindFlag("db.username", c.PersistentFlags().Lookup("db_username"))
+	viper.SetDefault("db.username", conf.Executable)
+
+	c.PersistentFlags().String("db_password", "", "The password for "+conf.Executable+"'s DB.")
+	bindFlag("db.password", c.PersistentFlags().Lookup("db_password"))
+
+	c.PersistentFlags().String("db_password_file", "", "A file holding the password for "+conf.Executable+"'s DB, such as a Docker secret.")
+	bindFlag("db.password_file", c.PersistentFlags().Lookup("db_password_file"))
 
 	c.PersistentFlags().String("slack_user_oauth_token", "", "Slack App's User OAuth token.")
-	_ = viper.BindPFlag("slack.user_oauth_token", c.PersistentFlags().Lookup("slack_user_oauth_token"))
-	_ = viper.BindEnv("slack.user_oauth_token", "SLACK_USER_OAUTH_TOKEN")
+	bindFlag("slack.user_oauth_token", c.PersistentFlags().Lookup("slack_user_oauth_token"))
+	bindEnv("slack.user_oauth_token", "SLACK_USER_OAUTH_TOKEN")
+
+	c.PersistentFlags().String("slack_user_oauth_token_file", "", "A file holding the Slack App's User OAuth token.")
+	bindFlag("slack.user_oauth_token_file", c.PersistentFlags().Lookup("slack_user_oauth_token_file"))
+	bindEnv("slack.user_oauth_token_file", "SLACK_USER_OAUTH_TOKEN_FILE")
 
 	c.PersistentFlags().String("slack_oauth_token", "", "Slack App OAuth token.")
-	_ = viper.BindPFlag("slack.bot_oauth_token", c.PersistentFlags().Lookup("slack_oauth_token"))
-	_ = viper.BindEnv("slack.bot_oauth_token", "SLACK_OAUTH_TOKEN")
+	bindFlag("slack.bot_oauth_token", c.PersistentFlags().Lookup("slack_oauth_token"))
+	bindEnv("slack.bot_oauth_token", "SLACK_OAUTH_TOKEN")
+
+	c.PersistentFlags().String("slack_oauth_token_file", "", "A file holding the Slack App OAuth token.")
+	bindFlag("slack.bot_oauth_token_file", c.PersistentFlags().Lookup("slack_oauth_token_file"))
+	bindEnv("slack.bot_oauth_token_file", "SLACK_OAUTH_TOKEN_FILE")
 
 	c.PersistentFlags().String("slack_signing_secret", "", "Slack secret used for message signing.")
-	_ = viper.BindPFlag("slack.signing_secret", c.PersistentFlags().Lookup("slack_signing_secret"))
-	_ = viper.BindEnv("slack.signing_secret", "SLACK_SIGNING_SECRET")
+	bindFlag("slack.signing_secret", c.PersistentFlags().Lookup("slack_signing_secret"))
+	bindEnv("slack.signing_secret", "SLACK_SIGNING_SECRET")
+
+	c.PersistentFlags().String("slack_signing_secret_file", "", "A file holding the Slack secret used for message signing.")
+	bindFlag("slack.signing_secret_file", c.PersistentFlags().Lookup("slack_signing_secret_file"))
+	bindEnv("slack.signing_secret_file", "SLACK_SIGNING_SECRET_FILE")
+
+	c.PersistentFlags().String("secrets_local_store", "", "A YAML file that stands in for Vault when resolving vault:// references, for development and tests.")
+	bindFlag("secrets.local_store", c.PersistentFlags().Lookup("secrets_local_store"))
+
+	c.PersistentFlags().String("locale", messages.DEFAULT_LOCALE, "The locale of Penny's messages in your workspace, such as en or es.")
+	bindFlag("messages.locale", c.PersistentFlags().Lookup("locale"))
+
+	c.PersistentFlags().Bool("user_locale", false, "Send the OP messages in their own Slack locale when Penny has messages for it.")
+	bindFlag("messages.user_locale", c.PersistentFlags().Lookup("user_locale"))
+
+	c.PersistentFlags().String("messages_dir", "", "A directory of <locale>.yaml message bundles laid over the built-in ones.")
+	bindFlag("messages.dir", c.PersistentFlags().Lookup("messages_dir"))
 
 	c.PersistentFlags().String("spam_feed_channel", "spam-feed", "Slack channel where Racji App reports SPAM posts.")
-	_ = viper.BindPFlag("spam_feed.channel", c.PersistentFlags().Lookup("spam_feed_channel"))
+	bindFlag("spam_feed.channel", c.PersistentFlags().Lookup("spam_feed_channel"))
 
 	c.PersistentFlags().String("spam_feed_assistance_channel_id", "", "Slack channel ID for assistance with SPAM posts.")
-	_ = viper.BindPFlag("spam_feed.assistance_channel_id", c.PersistentFlags().Lookup("spam_feed_assistance_channel_id"))
+	bindFlag("spam_feed.assistance_channel_id", c.PersistentFlags().Lookup("spam_feed_assistance_channel_id"))
 
 	c.PersistentFlags().String("spam_feed_emoji", "no_entry_sign", "Slack emoji configured for Racji App to report SPAM posts.")
-	_ = viper.BindPFlag("spam_feed.emoji", c.PersistentFlags().Lookup("spam_feed_emoji"))
+	bindFlag("spam_feed.emoji", c.PersistentFlags().Lookup("spam_feed_emoji"))
 
 	c.PersistentFlags().String("spam_feed_reaction_emoji_miss", "shrug", "The reaction Penny adds to the reported spam-feed post if below max_anomaly_score")
-	_ = viper.BindPFlag("spam_feed.reaction_emoji_miss", c.PersistentFlags().Lookup("spam_feed_reaction_emoji_miss"))
+	bindFlag("spam_feed.reaction_emoji_miss", c.PersistentFlags().Lookup("spam_feed_reaction_emoji_miss"))
 
 	c.PersistentFlags().String("spam_feed_reaction_emoji_hit", "no_good", "The reaction Penny adds to the reported spam-feed post if above max_anomaly_score.")
-	_ = viper.BindPFlag("spam_feed.reaction_emoji_hit", c.PersistentFlags().Lookup("spam_feed_reaction_emoji_hit"))
+	bindFlag("spam_feed.reaction_emoji_hit", c.PersistentFlags().Lookup("spam_feed_reaction_emoji_hit"))
 
 	c.PersistentFlags().String("reacji_response", "", "Threaded message response to the Reacji feed message. If empty, no thread is started.")
-	_ = viper.BindPFlag("spam_feed.reacji_response", c.PersistentFlags().Lookup("reacji_response"))
+	bindFlag("spam_feed.reacji_response", c.PersistentFlags().Lookup("reacji_response"))
 
 	c.PersistentFlags().String("op_warning", "This message has been flagged by our community as SPAM. The admins have been notified.", "Threaded message response to the OP as a warning when reported as SPAM. If empty, no thread is started.")
-	_ = viper.BindPFlag("spam_feed.op_warning", c.PersistentFlags().Lookup("op_warning"))
+	bindFlag("spam_feed.op_warning", c.PersistentFlags().Lookup("op_warning"))
+
+	c.PersistentFlags().String("op_notice_delivery", "thread", "How removal notices and op_warning reach the OP: thread (a public reply), ephemeral (only they see it) or dm. Ephemeral and DM notices of a removal include a copy of the removed message.")
+	bindFlag("spam_feed.op_notice_delivery", c.PersistentFlags().Lookup("op_notice_delivery"))
 
 	c.PersistentFlags().String("local_timezone", "", "The local timezone of your community. This is the 'TZ Database' (Region/City_Name) format. Leave empty to not enforce this.")
-	_ = viper.BindPFlag("spam_feed.local_timezone", c.PersistentFlags().Lookup("local_timezone"))
+	bindFlag("spam_feed.local_timezone", c.PersistentFlags().Lookup("local_timezone"))
 
 	c.PersistentFlags().Int("activity_low_watermark", 10, "The minimum number of posts before adding to the user's anomaly score. Set this to 0 to disable.")
-	_ = viper.BindPFlag("spam_feed.activity_low_watermark", c.PersistentFlags().Lookup("activity_low_watermark"))
+	bindFlag("spam_feed.activity_low_watermark", c.PersistentFlags().Lookup("activity_low_watermark"))
 
 	c.PersistentFlags().Int("max_anomaly_score", 5, "The max anomaly score a post can reach before it is deleted.")
-	_ = viper.BindPFlag("spam_feed.max_anomaly_score", c.PersistentFlags().Lookup("max_anomaly_score"))
+	bindFlag("spam_feed.max_anomaly_score", c.PersistentFlags().Lookup("max_anomaly_score"))
+
+	c.PersistentFlags().Duration("signal_deadline", 10*time.Second, "The overall time allowed for evaluating every anomaly signal of a reported post.")
+	bindFlag("spam_feed.signal_deadline", c.PersistentFlags().Lookup("signal_deadline"))
+
+	c.PersistentFlags().String("signal_timeout_policy", "zero", "How to score a signal that misses the deadline: zero (adds nothing), max (adds its full score) or defer (never remove, leave it for a human).")
+	bindFlag("spam_feed.signal_timeout_policy", c.PersistentFlags().Lookup("signal_timeout_policy"))
+
+	c.PersistentFlags().Int("queue_workers", 4, "The number of spam-feed reports processed at once.")
+	bindFlag("spam_feed.queue.workers", c.PersistentFlags().Lookup("queue_workers"))
+
+	c.PersistentFlags().Int("queue_capacity", 100, "The number of spam-feed reports that may wait for a worker.")
+	bindFlag("spam_feed.queue.capacity", c.PersistentFlags().Lookup("queue_capacity"))
+
+	c.PersistentFlags().Duration("queue_enqueue_timeout", 30*time.Second, "How long a spam-feed report waits for room in a full queue before it is dropped.")
+	bindFlag("spam_feed.queue.enqueue_timeout", c.PersistentFlags().Lookup("queue_enqueue_timeout"))
+
+	c.PersistentFlags().Duration("queue_coalesce_window", 10*time.Minute, "How long an author's signals and case are reused for further reports against them. Set this to 0 to disable.")
+	bindFlag("spam_feed.queue.coalesce_window", c.PersistentFlags().Lookup("queue_coalesce_window"))
+
+	c.PersistentFlags().Duration("breaker_window", time.Hour, "The window the removal limits apply to.")
+	bindFlag("spam_feed.breaker.window", c.PersistentFlags().Lookup("breaker_window"))
+
+	c.PersistentFlags().Int("breaker_max_removals", 20, "The max removals across all channels within the window before Penny switches to review-only mode. Set this to 0 to disable.")
+	bindFlag("spam_feed.breaker.max_removals", c.PersistentFlags().Lookup("breaker_max_removals"))
+
+	c.PersistentFlags().Int("breaker_max_channel_removals", 10, "The max removals in a single channel within the window before Penny switches to review-only mode. Set this to 0 to disable.")
+	bindFlag("spam_feed.breaker.max_channel_removals", c.PersistentFlags().Lookup("breaker_max_channel_removals"))
+
+	c.PersistentFlags().String("breaker_alert_channel_id", "", "Slack channel ID alerted when Penny switches to review-only mode. If empty, the global admins are sent a DM.")
+	bindFlag("spam_feed.breaker.alert_channel_id", c.PersistentFlags().Lookup("breaker_alert_channel_id"))
+
+	c.PersistentFlags().String("appeals_channel_id", "", "Slack channel ID where moderators review appeals. If set, removal notices and op_warning get an Appeal button.")
+	bindFlag("spam_feed.appeals.channel_id", c.PersistentFlags().Lookup("appeals_channel_id"))
+
+	c.PersistentFlags().Int("escalation_band", 0, "Escalate reports scoring within this much of max_anomaly_score that weren't removed. Set this to 0 to disable.")
+	bindFlag("spam_feed.escalation.band", c.PersistentFlags().Lookup("escalation_band"))
+
+	c.PersistentFlags().String("escalation_user_group_id", "", "Slack user group ID mentioned in the spam-feed thread when a report is escalated.")
+	bindFlag("spam_feed.escalation.user_group_id", c.PersistentFlags().Lookup("escalation_user_group_id"))
+
+	c.PersistentFlags().StringSlice("escalation_moderators", []string{}, "Slack user IDs of the moderators on call for escalated reports.")
+	bindFlag("spam_feed.escalation.moderators", c.PersistentFlags().Lookup("escalation_moderators"))
+
+	c.PersistentFlags().String("escalation_notify", "thread", "How escalations reach the moderators: thread (mentioned in the spam-feed thread), dm or both.")
+	bindFlag("spam_feed.escalation.notify", c.PersistentFlags().Lookup("escalation_notify"))
+
+	c.PersistentFlags().Int("escalation_max_pings", 5, "The max escalations within the window; further ones are held back and counted in the next ping. Set this to 0 to disable.")
+	bindFlag("spam_feed.escalation.max_pings", c.PersistentFlags().Lookup("escalation_max_pings"))
+
+	c.PersistentFlags().Duration("escalation_window", time.Hour, "The window the escalation limit applies to.")
+	bindFlag("spam_feed.escalation.window", c.PersistentFlags().Lookup("escalation_window"))
 
 	c.PersistentFlags().Int("reported_score", 2, "The anomaly score to add to the post when it is reported.")
-	_ = viper.BindPFlag("spam_feed.anomaly_scores.reported", c.PersistentFlags().Lookup("reported_score"))
+	bindFlag("spam_feed.anomaly_scores.reported", c.PersistentFlags().Lookup("reported_score"))
 
 	c.PersistentFlags().Int("low_activity_score", 1, "The anomaly score to add to the reported post from users that are below the activity low watermark.")
-	_ = viper.BindPFlag("spam_feed.anomaly_scores.low_activity", c.PersistentFlags().Lookup("low_activity_score"))
+	bindFlag("spam_feed.anomaly_scores.low_activity", c.PersistentFlags().Lookup("low_activity_score"))
 
 	c.PersistentFlags().Int("outside_tz_score", 2, "The anomaly score to add to the reported post when the user is outside of the configured time zone.")
-	_ = viper.BindPFlag("spam_feed.anomaly_scores.outside_tz", c.PersistentFlags().Lookup("outside_tz_score"))
+	bindFlag("spam_feed.anomaly_scores.outside_tz", c.PersistentFlags().Lookup("outside_tz_score"))
+
+	c.PersistentFlags().Int("blocklisted_score", 10, "The anomaly score to add to the reported post when its author is on a shared blocklist.")
+	bindFlag("spam_feed.anomaly_scores.blocklisted", c.PersistentFlags().Lookup("blocklisted_score"))
+
+	c.PersistentFlags().Int("blocklisted_name_score", 3, "The anomaly score to add instead when only its author's display name is on a shared blocklist.")
+	bindFlag("spam_feed.anomaly_scores.blocklisted_name", c.PersistentFlags().Lookup("blocklisted_name_score"))
+
+	c.PersistentFlags().Int("blocklisted_domain_score", 2, "The anomaly score to add instead when only its author's email domain is on a shared blocklist.")
+	bindFlag("spam_feed.anomaly_scores.blocklisted_domain", c.PersistentFlags().Lookup("blocklisted_domain_score"))
+
+	c.PersistentFlags().Int("duplicates_score", 0, "The anomaly score to add to the reported post when its author posted copies of it in several channels. 0 turns the signal off.")
+	bindFlag("spam_feed.anomaly_scores.duplicates", c.PersistentFlags().Lookup("duplicates_score"))
+
+	c.PersistentFlags().Duration("duplicates_window", time.Hour, "How long each author's posts are remembered to find copies.")
+	bindFlag("spam_feed.duplicates.window", c.PersistentFlags().Lookup("duplicates_window"))
+
+	c.PersistentFlags().Int("duplicates_min_channels", 3, "How many channels copies of a post must be in to count.")
+	bindFlag("spam_feed.duplicates.min_channels", c.PersistentFlags().Lookup("duplicates_min_channels"))
+
+	c.PersistentFlags().Int("duplicates_max_distance", 6, "How many bits apart, out of 64, two posts' fingerprints can be and still be copies.")
+	bindFlag("spam_feed.duplicates.max_distance", c.PersistentFlags().Lookup("duplicates_max_distance"))
+
+	c.PersistentFlags().Int("duplicates_min_length", 20, "The fewest letters and digits a post without links needs to be fingerprinted.")
+	bindFlag("spam_feed.duplicates.min_length", c.PersistentFlags().Lookup("duplicates_min_length"))
+
+	c.PersistentFlags().Int("impersonation_score", 0, "The anomaly score to add to the reported post when its author's name resembles an admin's, an owner's or a protected name. 0 turns the signal off.")
+	bindFlag("spam_feed.anomaly_scores.impersonation", c.PersistentFlags().Lookup("impersonation_score"))
+
+	c.PersistentFlags().StringSlice("impersonation_protected_users", []string{}, "User IDs whose names are protected from impersonation, as well as the admins' and owners'.")
+	bindFlag("spam_feed.impersonation.protected_users", c.PersistentFlags().Lookup("impersonation_protected_users"))
+
+	c.PersistentFlags().StringSlice("impersonation_protected_names", []string{}, "Names protected from impersonation as is, like the community's own.")
+	bindFlag("spam_feed.impersonation.protected_names", c.PersistentFlags().Lookup("impersonation_protected_names"))
+
+	c.PersistentFlags().Int("impersonation_max_distance", 2, "How many edits apart a name can be and still resemble a protected one. Short names allow fewer.")
+	bindFlag("spam_feed.impersonation.max_distance", c.PersistentFlags().Lookup("impersonation_max_distance"))
+
+	c.PersistentFlags().Int("email_domain_score", 0, "The anomaly score to add to the reported post when its author's email domain is disposable, recently spammed from or unexpected. 0 turns the signal off.")
+	bindFlag("spam_feed.anomaly_scores.email_domain", c.PersistentFlags().Lookup("email_domain_score"))
+
+	c.PersistentFlags().String("email_domain_disposable_list", "", "A file of disposable email domains, one per line, to check as well as the bundled list.")
+	bindFlag("spam_feed.email_domain.disposable_list", c.PersistentFlags().Lookup("email_domain_disposable_list"))
+
+	c.PersistentFlags().Int("email_domain_recent_removals", 2, "How many removals from an email domain within the recent window make it suspect. Set this to 0 to disable.")
+	bindFlag("spam_feed.email_domain.recent_removals", c.PersistentFlags().Lookup("email_domain_recent_removals"))
+
+	c.PersistentFlags().Duration("email_domain_recent_window", 7*24*time.Hour, "The window removals from an email domain are counted in.")
+	bindFlag("spam_feed.email_domain.recent_window", c.PersistentFlags().Lookup("email_domain_recent_window"))
+
+	c.PersistentFlags().StringSlice("email_domain_expected", []string{}, "The email domains members are expected to have, for corporate communities. Any other domain is suspect.")
+	bindFlag("spam_feed.email_domain.expected", c.PersistentFlags().Lookup("email_domain_expected"))
+
+	c.PersistentFlags().Bool("proactive", false, "Screen low-trust authors' new messages before anyone reports them.")
+	bindFlag("spam_feed.proactive.enabled", c.PersistentFlags().Lookup("proactive"))
+
+	c.PersistentFlags().StringSlice("proactive_channel_ids", []string{}, "Channel IDs to screen. Every channel Penny is in but the spam feed is screened if empty.")
+	bindFlag("spam_feed.proactive.channel_ids", c.PersistentFlags().Lookup("proactive_channel_ids"))
+
+	c.PersistentFlags().StringSlice("proactive_signals", []string{"low_activity", "email_domain", "impersonation", "duplicates", "blocklisted"}, "The signals screened messages are scored on.")
+	bindFlag("spam_feed.proactive.signals", c.PersistentFlags().Lookup("proactive_signals"))
+
+	c.PersistentFlags().Int("proactive_remove_score", 0, "The score at which a screened message is removed outright. 0 only posts it for review.")
+	bindFlag("spam_feed.proactive.remove_score", c.PersistentFlags().Lookup("proactive_remove_score"))
+
+	c.PersistentFlags().Int("proactive_review_score", 3, "The score at which a screened message is posted to the spam feed for review.")
+	bindFlag("spam_feed.proactive.review_score", c.PersistentFlags().Lookup("proactive_review_score"))
+
+	c.PersistentFlags().Int("proactive_max_messages", 5, "Authors with fewer public messages than this are screened. Set this to 0 to disable.")
+	bindFlag("spam_feed.proactive.max_messages", c.PersistentFlags().Lookup("proactive_max_messages"))
+
+	c.PersistentFlags().Duration("proactive_max_member_age", 72*time.Hour, "Members who joined within this long are screened. Set this to 0 to disable.")
+	bindFlag("spam_feed.proactive.max_member_age", c.PersistentFlags().Lookup("proactive_max_member_age"))
+
+	c.PersistentFlags().Bool("probation", false, "Put new members on probation, holding reports against them to a stricter threshold.")
+	bindFlag("spam_feed.probation.enabled", c.PersistentFlags().Lookup("probation"))
+
+	c.PersistentFlags().Int("probation_max_anomaly_score", 3, "The anomaly score at which reports against members on probation are removed.")
+	bindFlag("spam_feed.probation.max_anomaly_score", c.PersistentFlags().Lookup("probation_max_anomaly_score"))
+
+	c.PersistentFlags().Int("probation_messages", 5, "How many accepted messages lift a member's probation. Set this to 0 to only lift it after the duration.")
+	bindFlag("spam_feed.probation.messages", c.PersistentFlags().Lookup("probation_messages"))
+
+	c.PersistentFlags().Duration("probation_duration", 7*24*time.Hour, "How long after it starts probation is lifted. Set this to 0 to only lift it after enough messages.")
+	bindFlag("spam_feed.probation.duration", c.PersistentFlags().Lookup("probation_duration"))
+
+	c.PersistentFlags().Duration("probation_grace", time.Hour, "How long a message from a member on probation must stand without being reported before it counts, when there's no approval channel.")
+	bindFlag("spam_feed.probation.grace", c.PersistentFlags().Lookup("probation_grace"))
+
+	c.PersistentFlags().String("probation_approval_channel_id", "", "Slack channel ID where moderators approve or remove messages from members on probation. If empty, every message that stands for the grace period without being reported counts.")
+	bindFlag("spam_feed.probation.approval_channel_id", c.PersistentFlags().Lookup("probation_approval_channel_id"))
+
+	c.PersistentFlags().StringSlice("webhook_urls", []string{}, "URLs that moderation events are POSTed to as signed JSON.")
+	bindFlag("webhooks.urls", c.PersistentFlags().Lookup("webhook_urls"))
+
+	c.PersistentFlags().String("webhook_secret", "", "The secret webhook payloads are signed with (HMAC-SHA256).")
+	bindFlag("webhooks.secret", c.PersistentFlags().Lookup("webhook_secret"))
+
+	c.PersistentFlags().String("webhook_secret_file", "", "A file holding the secret webhook payloads are signed with.")
+	bindFlag("webhooks.secret_file", c.PersistentFlags().Lookup("webhook_secret_file"))
+
+	c.PersistentFlags().StringSlice("webhook_events", []string{}, "The events sent to webhooks: case.created, message.removed or case.overturned. All of them if empty.")
+	bindFlag("webhooks.events", c.PersistentFlags().Lookup("webhook_events"))
+
+	c.PersistentFlags().Int("webhook_max_attempts", 5, "How many times a webhook delivery is tried before it's written to the dead-letter log.")
+	bindFlag("webhooks.max_attempts", c.PersistentFlags().Lookup("webhook_max_attempts"))
+
+	c.PersistentFlags().Duration("webhook_backoff", time.Second, "The wait before retrying a webhook delivery, doubled after each retry.")
+	bindFlag("webhooks.backoff", c.PersistentFlags().Lookup("webhook_backoff"))
+
+	c.PersistentFlags().Duration("webhook_timeout", 10*time.Second, "How long each webhook delivery attempt may take.")
+	bindFlag("webhooks.timeout", c.PersistentFlags().Lookup("webhook_timeout"))
+
+	c.PersistentFlags().String("webhook_dead_letter", "", "A file that webhook deliveries are appended to, as JSON lines, when every attempt fails.")
+	bindFlag("webhooks.dead_letter", c.PersistentFlags().Lookup("webhook_dead_letter"))
+
+	c.PersistentFlags().StringSlice("blocklist_feeds", []string{}, "File paths or http(s) URLs of signed blocklist feeds shared by other communities.")
+	bindFlag("blocklist.feeds", c.PersistentFlags().Lookup("blocklist_feeds"))
+
+	c.PersistentFlags().StringSlice("blocklist_public_keys", []string{}, "The base64 ed25519 keys of the publishers whose blocklist feeds are trusted.")
+	bindFlag("blocklist.public_keys", c.PersistentFlags().Lookup("blocklist_public_keys"))
+
+	c.PersistentFlags().String("blocklist_salt", "", "The salt emails and display names are hashed with in blocklist feeds, shared by every community exchanging them.")
+	bindFlag("blocklist.salt", c.PersistentFlags().Lookup("blocklist_salt"))
+
+	c.PersistentFlags().String("blocklist_salt_file", "", "A file holding the blocklist salt.")
+	bindFlag("blocklist.salt_file", c.PersistentFlags().Lookup("blocklist_salt_file"))
+
+	c.PersistentFlags().String("blocklist_private_key", "", "The base64 ed25519 key that penny blocklist export signs feeds with.")
+	bindFlag("blocklist.private_key", c.PersistentFlags().Lookup("blocklist_private_key"))
+
+	c.PersistentFlags().String("blocklist_private_key_file", "", "A file holding the blocklist private key.")
+	bindFlag("blocklist.private_key_file", c.PersistentFlags().Lookup("blocklist_private_key_file"))
+
+	c.PersistentFlags().String("blocklist_issuer", "", "The name exported blocklist feeds are published under, usually the community's.")
+	bindFlag("blocklist.issuer", c.PersistentFlags().Lookup("blocklist_issuer"))
+
+	c.PersistentFlags().Duration("blocklist_refresh", time.Hour, "How often blocklist feeds are imported again. Set this to 0 to only import them at startup.")
+	bindFlag("blocklist.refresh", c.PersistentFlags().Lookup("blocklist_refresh"))
+}
+
+// flagKeys and envKeys record what each config key is bound to, so `config show`
+// can say where its value came from.
+var (
+	flagKeys = map[string]*pflag.Flag{}
+	envKeys  = map[string][]string{}
+)
+
+func bindFlag(key string, flag *pflag.Flag) {
+	flagKeys[key] = flag
+	_ = viper.BindPFlag(key, flag)
+}
+
+func bindEnv(key string, names ...string) {
+	envKeys[key] = append(envKeys[key], names...)
+	_ = viper.BindEnv(append([]string{key}, names...)...)
 }
 
 func addSubcommands(c *cobra.Command) {
 	c.AddCommand(newVersionCmd())
 	c.AddCommand(newServerCmd())
+	c.AddCommand(newDoctorCmd())
+	c.AddCommand(newConfigCmd())
+	c.AddCommand(newBlocklistCmd())
 }
 
 func initConfig() {
@@ -127,11 +388,21 @@ func initConfig() {
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 
+	for alias, key := range config.Aliases {
+		viper.RegisterAlias(alias, key)
+	}
+
 	if err := viper.ReadInConfig(); err == nil {
 		log.Info().Str("config", viper.ConfigFileUsed()).Msg("loaded config file")
 	}
 
-	level, err := zerolog.ParseLevel(viper.GetString("log.level"))
+	cfg, err := config.Load(viper.GetViper())
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load config")
+	}
+	config.Store(cfg)
+
+	level, err := zerolog.ParseLevel(cfg.Log.Level)
 	if err != nil {
 		level = zerolog.InfoLevel
 	}
@@ -1,11 +1,21 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	gadget "github.com/gadget-bot/gadget/core"
 	helpers "github.com/gadget-bot/gadget/plugins/helpers"
 	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -13,6 +23,16 @@ import (
 	"github.com/xortim/penny/gadgets/hallmonitor"
 	"github.com/xortim/penny/gadgets/help"
 	"github.com/xortim/penny/gadgets/whatsnew"
+	"github.com/xortim/penny/pkg/blocklist"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/checks"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/events"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/metrics"
+	"github.com/xortim/penny/pkg/slackclient"
+	"github.com/xortim/penny/pkg/tracing"
+	"github.com/xortim/penny/pkg/webhooks"
 )
 
 func newServerCmd() *cobra.Command {
@@ -30,27 +50,126 @@ func newServerCmd() *cobra.Command {
 }
 
 func server(cmd *cobra.Command, args []string) error {
+	cfg := config.Current()
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	for _, key := range config.UnknownKeys(viper.GetViper()) {
+		log.Warn().Str("key", key).Msg("unknown config key")
+	}
+	for _, w := range cfg.Warnings() {
+		log.Warn().Str("key", w.Path).Msg(w.Message)
+	}
+	catalog, err := messages.Load(cfg.Messages.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to load messages: %w", err)
+	}
+	messages.Use(catalog)
+
 	myBot, err := gadget.SetupWithConfig(gadget.Config{
-		SlackOAuthToken: viper.GetString("slack.bot_oauth_token"),
-		SigningSecret:   viper.GetString("slack.signing_secret"),
-		DBUser:          viper.GetString("db.username"),
-		DBPass:          viper.GetString("db.password"),
-		DBHost:          viper.GetString("db.hostname"),
-		DBName:          viper.GetString("db.name"),
-		ListenPort:      viper.GetString("server.port"),
-		GlobalAdmins:    viper.GetStringSlice("slack.global_admins"),
+		SlackOAuthToken: cfg.Slack.BotOAuthToken,
+		SlackUserToken:  cfg.Slack.UserOAuthToken,
+		SigningSecret:   cfg.Slack.SigningSecret,
+		DBUser:          cfg.DB.Username,
+		DBPass:          cfg.DB.Password,
+		DBHost:          cfg.DB.Hostname,
+		DBName:          cfg.DB.Name,
+		ListenPort:      strconv.Itoa(cfg.Server.Port),
+		GlobalAdmins:    cfg.Slack.GlobalAdmins,
+	})
+	if err != nil {
+		return err
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:       cfg.Tracing.Exporter,
+		Endpoint:       cfg.Tracing.Endpoint,
+		Insecure:       cfg.Tracing.Insecure,
+		SampleRatio:    cfg.Tracing.SampleRatio,
+		ServiceName:    conf.Executable,
+		ServiceVersion: conf.GitVersion,
 	})
 	if err != nil {
 		return err
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to flush traces")
+		}
+	}()
+
+	dispatcher := events.NewDispatcher()
+
+	// Share one rate limited client per token across all events so that
+	// a burst of reports can't exceed Slack's per-method budgets. Cache hits
+	// on the bot client don't count against those budgets at all.
+	rateLimits := slackclient.RateLimitOptions{OnAttempt: metrics.ObserveSlackAttempt}
+	api := slackclient.NewCachingClient(
+		slackclient.NewRateLimitedClient(myBot.Client, rateLimits),
+		slackclient.CacheOptions{},
+	)
+	invalidateOnChange(dispatcher, api)
+	dispatcher.On(string(slackevents.Message), func(ev slackevents.EventsAPIInnerEvent) {
+		if msg, ok := ev.Data.(*slackevents.MessageEvent); ok {
+			hallmonitor.ObserveMessage(msg)
+			hallmonitor.ScreenMessage(msg)
+			hallmonitor.ReviewProbation(msg)
+		}
+	})
+	dispatcher.On(string(slackevents.TeamJoin), func(ev slackevents.EventsAPIInnerEvent) {
+		if tj, ok := ev.Data.(*slackevents.TeamJoinEvent); ok && tj.User != nil {
+			hallmonitor.MemberJoined(tj.User.ID)
+		}
+	})
+	dispatcher.On(string(slackevents.MemberJoinedChannel), func(ev slackevents.EventsAPIInnerEvent) {
+		if mj, ok := ev.Data.(*slackevents.MemberJoinedChannelEvent); ok {
+			hallmonitor.MemberJoinedChannel(mj.User)
+		}
+	})
 
+	var userApi slackclient.Client
+	if myBot.UserClient != nil {
+		userApi = slackclient.NewRateLimitedClient(myBot.UserClient, rateLimits)
+	}
+	hallmonitor.UseClients(api, userApi)
+	watchAdmins(api)
+	watchConfig(api)
+	queue := hallmonitor.NewQueue(
+		cfg.SpamFeed.Queue.Workers,
+		cfg.SpamFeed.Queue.Capacity,
+		cfg.SpamFeed.Queue.EnqueueTimeout,
+	)
+	hallmonitor.UseQueue(queue)
+	caseStore, err := cases.NewGormStore(myBot.Router.DbConnection)
+	if err != nil {
+		return fmt.Errorf("failed to migrate the cases tables: %w", err)
+	}
+	hallmonitor.UseCases(caseStore)
+	if err := hallmonitor.RestoreBreaker(context.Background()); err != nil {
+		return fmt.Errorf("failed to restore the removal breaker: %w", err)
+	}
+	hooks, err := newWebhookSender(cfg.Webhooks)
+	if err != nil {
+		return err
+	}
+	hallmonitor.UseWebhooks(hooks)
+	if err := importBlocklist(cfg.Blocklist); err != nil {
+		return err
+	}
+	interactions := events.NewInteractions()
+	hallmonitor.RegisterAppeals(interactions)
+	hallmonitor.RegisterProbation(interactions)
+	registerServerMetrics(queue, api)
 	myBot.Router.AddChannelMessageRoutes(hallmonitor.GetChannelMessageRoutes())
 	myBot.Router.AddMentionRoutes(whatsnew.GetMentionRoutes(ChangelogRaw))
 	log.Debug().Int("changelog_bytes", len(ChangelogRaw)).Msg("registered what's new mention routes")
 
 	myBot.Router.AddSlashCommandRoutes(help.GetSlashCommandRoutes())
+	myBot.Router.AddSlashCommandRoutes(hallmonitor.GetSlashCommandRoutes())
 
-	channelName := viper.GetString("spam_feed.channel")
+	channelName := cfg.SpamFeed.Channel
 	if channelName == "" {
 		log.Debug().Msg("no spam-feed channel configured, skipping auto-join")
 	} else if err := helpers.JoinChannelByName(*myBot.Client, channelName); err != nil {
@@ -61,34 +180,260 @@ func server(cmd *cobra.Command, args []string) error {
 
 	log.Info().
 		Str("version", conf.GitVersion).
-		Int("port", viper.GetInt("server.port")).
-		Str("spam_feed_channel", viper.GetString("spam_feed.channel")).
+		Int("port", cfg.Server.Port).
+		Str("spam_feed_channel", cfg.SpamFeed.Channel).
 		Msg("starting penny")
 
-	return myBot.Run()
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:      newServerMux(myBot, dispatcher, interactions, readinessChecks(myBot, api)),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+	return serveUntilSignal(srv, queue, hooks)
+}
+
+// serveUntilSignal serves until SIGINT or SIGTERM, then stops taking events and
+// lets queued spam-feed reports, and the webhooks they send, finish within
+// server.shutdown_timeout.
+func serveUntilSignal(srv *http.Server, queue *hallmonitor.Queue, hooks *webhooks.Sender) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	timeout := config.Current().Server.ShutdownTimeout
+	log.Info().Dur("timeout", timeout).Interface("queue", queue.Stats()).Msg("shutting down, draining spam feed queue")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("failed to shut down http server cleanly")
+	}
+	if err := queue.Drain(shutdownCtx); err != nil {
+		return fmt.Errorf("spam feed queue did not drain: %w", err)
+	}
+	if err := hooks.Close(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("abandoned webhook deliveries still being retried")
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Info().Msg("penny stopped")
+	return nil
+}
+
+// newWebhookSender returns a Sender for cfg, or nil if there are no webhooks.
+// Undeliverable events are appended to cfg.DeadLetter, if it's set.
+func newWebhookSender(cfg config.Webhooks) (*webhooks.Sender, error) {
+	if len(cfg.URLs) == 0 {
+		return nil, nil
+	}
+	opts := webhooks.Options{
+		URLs:        cfg.URLs,
+		Secret:      cfg.Secret,
+		Events:      cfg.Events,
+		MaxAttempts: cfg.MaxAttempts,
+		Backoff:     cfg.Backoff,
+		Timeout:     cfg.Timeout,
+		OnDelivery:  metrics.ObserveWebhookDelivery,
+	}
+	if cfg.DeadLetter != "" {
+		// The file is left open for the life of the process.
+		f, err := os.OpenFile(cfg.DeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open webhook dead letter file: %w", err)
+		}
+		opts.DeadLetter = f
+	}
+	log.Info().Int("urls", len(cfg.URLs)).Strs("events", cfg.Events).Msg("mirroring moderation events to webhooks")
+	return webhooks.NewSender(opts), nil
+}
+
+// importBlocklist imports the shared blocklist feeds for hallmonitor, then
+// imports them again every blocklist.refresh. Feeds that can't be imported are
+// logged; Penny starts without them.
+func importBlocklist(cfg config.Blocklist) error {
+	if len(cfg.Feeds) == 0 {
+		return nil
+	}
+	src := blocklist.Source{Feeds: cfg.Feeds, Salt: cfg.Salt, Client: &http.Client{Timeout: blocklistFetchTimeout}}
+	for _, k := range cfg.PublicKeys {
+		key, err := blocklist.ParsePublicKey(k)
+		if err != nil {
+			return fmt.Errorf("blocklist.public_keys: %w", err)
+		}
+		src.Keys = append(src.Keys, key)
+	}
+	importer := blocklist.NewImporter(src)
+	metrics.GaugeFunc("blocklist_entries", "Spammers on the shared blocklist feeds.", func() float64 {
+		return float64(importer.List().Len())
+	})
+
+	refresh := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), blocklistTimeout)
+		defer cancel()
+		l, err := importer.Import(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to import blocklist feeds")
+		}
+		hallmonitor.UseBlocklist(l)
+		log.Info().Int("entries", l.Len()).Msg("imported blocklist feeds")
+	}
+	refresh()
+	if cfg.Refresh > 0 {
+		go func() {
+			for range time.Tick(cfg.Refresh) {
+				refresh()
+			}
+		}()
+	}
+	return nil
+}
+
+const (
+	// adminsRefresh is how often the admins and owners are listed again.
+	adminsRefresh = time.Hour
+	// adminsTimeout bounds listing them, which pages through every member.
+	adminsTimeout = 5 * time.Minute
+)
+
+// watchAdmins lists the workspace's admins and owners for the impersonation
+// signal in the background, and again every adminsRefresh. user_change events
+// keep the list current in between.
+func watchAdmins(api slackclient.Client) {
+	refresh := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), adminsTimeout)
+		defer cancel()
+		n, err := hallmonitor.RefreshAdmins(ctx, api)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to list admins and owners")
+			return
+		}
+		log.Info().Int("admins", n).Msg("listed admins and owners")
+	}
+	go func() {
+		refresh()
+		for range time.Tick(adminsRefresh) {
+			refresh()
+		}
+	}()
+}
+
+// newServerMux serves Gadget's handlers, tapping the events endpoint so that
+// dispatcher sees every event Slack sends, not only those Gadget routes.
+// Interactivity, which Gadget doesn't handle, goes to interactions.
+func newServerMux(bot *gadget.Gadget, dispatcher *events.Dispatcher, interactions *events.Interactions, readiness []checks.Check) *http.ServeMux {
+	gadgetHandler := bot.Handler()
+	secret := config.Current().Slack.SigningSecret
+
+	mux := http.NewServeMux()
+	mux.Handle("/gadget", dispatcher.Middleware(secret, gadgetHandler))
+	mux.Handle("/gadget/interactive", interactions.Handler(secret))
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/healthz", checks.LiveHandler())
+	mux.Handle("/readyz", checks.ReadyHandler(readiness, readinessTimeout, readinessTTL))
+	mux.Handle("/", gadgetHandler)
+	return mux
+}
+
+// registerServerMetrics exposes the queue's backpressure, the removal breaker
+// and the cache's hit rate, all read at scrape time.
+func registerServerMetrics(queue *hallmonitor.Queue, cache *slackclient.CachingClient) {
+	metrics.GaugeFunc("queue_depth", "Spam-feed reports waiting for a worker.", func() float64 {
+		return float64(queue.Stats().Depth)
+	})
+	metrics.GaugeFunc("queue_in_flight", "Spam-feed reports being processed.", func() float64 {
+		return float64(queue.Stats().InFlight)
+	})
+	metrics.CounterFunc("queue_rejected_total", "Spam-feed reports dropped because the queue was full or draining.", func() float64 {
+		return float64(queue.Stats().Rejected)
+	})
+	metrics.CounterFunc("queue_shed_total", "Message screenings and new channel member lookups dropped to keep room in the queue for reports.", func() float64 {
+		return float64(queue.Stats().Shed)
+	})
+	metrics.GaugeFunc("removals_paused", "1 while the removal breaker holds removals for review.", func() float64 {
+		if hallmonitor.RemovalsPaused() {
+			return 1
+		}
+		return 0
+	})
+	metrics.GaugeFunc("duplicate_posts_tracked", "Recent posts fingerprinted to find copies across channels.", func() float64 {
+		return float64(hallmonitor.TrackedPosts())
+	})
+	metrics.CounterFunc("slack_cache_hits_total", "Slack API lookups served from cache.", func() float64 {
+		var hits int64
+		for _, s := range cache.Stats() {
+			hits += s.Hits
+		}
+		return float64(hits)
+	})
+	metrics.CounterFunc("slack_cache_misses_total", "Slack API lookups the cache had to pass on to Slack.", func() float64 {
+		var misses int64
+		for _, s := range cache.Stats() {
+			misses += s.Misses
+		}
+		return float64(misses)
+	})
+}
+
+// invalidateOnChange drops cached users and channels when Slack reports they changed.
+func invalidateOnChange(dispatcher *events.Dispatcher, cache *slackclient.CachingClient) {
+	dispatcher.On(string(slackevents.UserChange), func(ev slackevents.EventsAPIInnerEvent) {
+		if uc, ok := ev.Data.(*slackevents.UserChangeEvent); ok {
+			cache.InvalidateUser(uc.User.ID)
+			hallmonitor.UpdateAdmin(userOf(uc.User))
+		}
+	})
+	dispatcher.On(string(slackevents.ChannelRename), func(ev slackevents.EventsAPIInnerEvent) {
+		if cr, ok := ev.Data.(*slackevents.ChannelRenameEvent); ok {
+			cache.InvalidateConversation(cr.Channel.ID)
+		}
+	})
+}
+
+// userOf is the parts of a user_change event's user that hallmonitor needs.
+func userOf(u slackevents.User) *slack.User {
+	user := &slack.User{
+		ID:             u.ID,
+		RealName:       u.RealName,
+		Deleted:        u.Deleted,
+		IsBot:          u.IsBot,
+		IsAdmin:        u.IsAdmin,
+		IsOwner:        u.IsOwner,
+		IsPrimaryOwner: u.IsPrimaryOwner,
+	}
+	user.Profile.DisplayName = u.Profile.DisplayName
+	user.Profile.RealName = u.Profile.RealName
+	return user
 }
 
 func setupServerFlags(c *cobra.Command) {
 	c.PersistentFlags().IntP("port", "p", 3000, "The port on which the bot should bind.")
-	_ = viper.BindPFlag("server.port", c.PersistentFlags().Lookup("port"))
-	viper.RegisterAlias("listen.port", "server.port")
+	bindFlag("server.port", c.PersistentFlags().Lookup("port"))
 	viper.SetDefault("server.port", 3000)
 
-	c.PersistentFlags().String("db_hostname", "localhost", "The host for "+conf.Executable+"'s DB.")
-	_ = viper.BindPFlag("db.hostname", c.PersistentFlags().Lookup("db_hostname"))
-	viper.RegisterAlias("db.host", "db.hostname")
-	viper.SetDefault("db.hostname", "localhost")
+	c.PersistentFlags().Duration("shutdown_timeout", 30*time.Second, "How long to wait for in-flight spam-feed reports to finish on shutdown.")
+	bindFlag("server.shutdown_timeout", c.PersistentFlags().Lookup("shutdown_timeout"))
+
+	c.PersistentFlags().String("tracing_exporter", tracing.EXPORTER_NONE, "Where to send traces: none, stdout or otlp.")
+	bindFlag("tracing.exporter", c.PersistentFlags().Lookup("tracing_exporter"))
 
-	c.PersistentFlags().String("db_name", conf.Executable, "The name for "+conf.Executable+"'s DB.")
-	_ = viper.BindPFlag("db.name", c.PersistentFlags().Lookup("db_name"))
-	viper.SetDefault("db.name", conf.Executable)
+	c.PersistentFlags().String("tracing_endpoint", "", "The OTLP/HTTP collector (host:port) for the otlp exporter. If empty, the OTEL_EXPORTER_OTLP_* environment variables are used.")
+	bindFlag("tracing.endpoint", c.PersistentFlags().Lookup("tracing_endpoint"))
 
-	c.PersistentFlags().String("db_username", "", "The username for "+conf.Executable+"'s DB.")
-	_ = viper.BindPFlag("db.username", c.PersistentFlags().Lookup("db_username"))
-	viper.RegisterAlias("db.user", "db.username")
-	viper.SetDefault("db.username", conf.Executable)
+	c.PersistentFlags().Bool("tracing_insecure", false, "Send OTLP traces over plain HTTP.")
+	bindFlag("tracing.insecure", c.PersistentFlags().Lookup("tracing_insecure"))
 
-	c.PersistentFlags().String("db_password", "", "The password for "+conf.Executable+"'s DB.")
-	_ = viper.BindPFlag("db.password", c.PersistentFlags().Lookup("db_password"))
-	viper.RegisterAlias("db.pass", "db.password")
+	c.PersistentFlags().Float64("tracing_sample_ratio", 1, "The fraction of events traced.")
+	bindFlag("tracing.sample_ratio", c.PersistentFlags().Lookup("tracing_sample_ratio"))
 }
@@ -0,0 +1,364 @@
+package hallmonitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/events"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+	"github.com/xortim/penny/pkg/webhooks"
+)
+
+// Action and view callback IDs of the appeal workflow.
+const (
+	ACTION_APPEAL   = "appeal.open"
+	ACTION_UPHOLD   = "appeal.uphold"
+	ACTION_OVERTURN = "appeal.overturn"
+	VIEW_APPEAL     = "appeal.submit"
+
+	// appealInputBlock and appealInputAction identify the justification in the
+	// appeal modal.
+	appealInputBlock  = "appeal.justification"
+	appealInputAction = "justification"
+
+	// maxSectionText is Block Kit's limit on a section's text.
+	maxSectionText = 3000
+)
+
+var caseStore cases.Store
+
+// UseCases records every report's verdict in s, which appeals are filed
+// against. Without it, or without spam_feed.appeals.channel_id, there are no
+// appeals. Call it before serving.
+func UseCases(s cases.Store) {
+	caseStore = s
+}
+
+// RegisterAppeals routes the appeal workflow's buttons and modal to
+// hallmonitor, using the bot client set by UseClients.
+func RegisterAppeals(i *events.Interactions) {
+	i.OnAction(ACTION_APPEAL, func(cb slack.InteractionCallback, action *slack.BlockAction) {
+		openAppeal(interactionContext(), cb, action, botClient)
+	})
+	i.OnViewSubmission(VIEW_APPEAL, func(cb slack.InteractionCallback) *slack.ViewSubmissionResponse {
+		return submitAppeal(interactionContext(), cb, botClient)
+	})
+	i.OnAction(ACTION_UPHOLD, func(cb slack.InteractionCallback, action *slack.BlockAction) {
+		decideAppeal(interactionContext(), cases.APPEAL_UPHELD, cb, action, botClient)
+	})
+	i.OnAction(ACTION_OVERTURN, func(cb slack.InteractionCallback, action *slack.BlockAction) {
+		decideAppeal(interactionContext(), cases.APPEAL_OVERTURNED, cb, action, botClient)
+	})
+}
+
+func interactionContext() context.Context {
+	return config.NewContext(context.Background(), config.Current())
+}
+
+// appealsEnabled reports whether notices should offer an Appeal button.
+func appealsEnabled(ctx context.Context) bool {
+	return caseStore != nil && config.FromContext(ctx).SpamFeed.Appeals.ChannelID != ""
+}
+
+// recordCase stores c, returning its ID, or 0 if it couldn't be stored.
+func recordCase(ctx context.Context, c *cases.Case) uint {
+	if caseStore == nil {
+		return 0
+	}
+	if err := caseStore.CreateCase(ctx, c); err != nil {
+		log.Error().Err(err).Str("op_user", c.Author).Msg("failed to record case")
+		return 0
+	}
+	return c.ID
+}
+
+// setVerdict changes case id's verdict, if it was recorded.
+func setVerdict(ctx context.Context, id uint, verdict string) {
+	if caseStore == nil || id == 0 {
+		return
+	}
+	if err := caseStore.SetVerdict(ctx, id, verdict); err != nil {
+		log.Error().Err(err).Uint("case", id).Str("verdict", verdict).Msg("failed to update case verdict")
+	}
+}
+
+// recordReport stores r under its burst's case.
+func recordReport(ctx context.Context, r *cases.Report) {
+	if caseStore == nil {
+		return
+	}
+	if err := caseStore.AddReport(ctx, r); err != nil {
+		log.Error().Err(err).Uint("case", r.CaseID).Msg("failed to record report")
+	}
+}
+
+// caseMessage is the OP's message as recorded in c.
+func caseMessage(c *cases.Case) slack.Message {
+	var msg slack.Message
+	msg.Channel = c.Channel
+	msg.Timestamp = c.Timestamp
+	msg.ThreadTimestamp = c.ThreadTimestamp
+	msg.User = c.Author
+	msg.Text = c.Text
+	return msg
+}
+
+// appealBlocks is notice with a button to appeal caseID.
+func appealBlocks(notice string, caseID uint, locale string) []slack.Block {
+	button := slack.NewButtonBlockElement(ACTION_APPEAL, strconv.FormatUint(uint64(caseID), 10),
+		plainText(messages.Text(locale, messages.APPEAL_BUTTON, messages.Data{})))
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, truncate(notice, maxSectionText), false, false), nil, nil),
+		slack.NewActionBlock("appeal", button),
+	}
+}
+
+// openAppeal opens the appeal modal for the case whose Appeal button was
+// clicked, if the one who clicked it is the case's author.
+func openAppeal(ctx context.Context, cb slack.InteractionCallback, action *slack.BlockAction, api slackclient.Client) {
+	logger := log.With().Str("user", cb.User.ID).Str("case", action.Value).Logger()
+	c, err := caseFor(ctx, action.Value)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to look up appealed case")
+		return
+	}
+	locale := userLocale(ctx, cb.User.ID, api)
+	if cb.User.ID != c.Author {
+		tell(cb, messages.Text(locale, messages.APPEAL_NOT_YOURS, messages.Data{}), api)
+		return
+	}
+
+	input := slack.NewPlainTextInputBlockElement(nil, appealInputAction)
+	input.Multiline = true
+	_, err = api.OpenView(cb.TriggerID, slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      VIEW_APPEAL,
+		PrivateMetadata: action.Value,
+		Title:           plainText(messages.Text(locale, messages.APPEAL_TITLE, messages.Data{})),
+		Submit:          plainText(messages.Text(locale, messages.APPEAL_SUBMIT, messages.Data{})),
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewInputBlock(appealInputBlock, plainText(messages.Text(locale, messages.APPEAL_LABEL, messages.Data{})), nil, input),
+		}},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to open appeal modal")
+	}
+}
+
+// submitAppeal files the appeal in a submitted modal and sends it to the
+// moderators. Problems the author can fix are shown in the modal.
+func submitAppeal(ctx context.Context, cb slack.InteractionCallback, api slackclient.Client) *slack.ViewSubmissionResponse {
+	logger := log.With().Str("user", cb.User.ID).Str("case", cb.View.PrivateMetadata).Logger()
+	c, err := caseFor(ctx, cb.View.PrivateMetadata)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to look up appealed case")
+		return appealError(err.Error())
+	}
+	locale := userLocale(ctx, cb.User.ID, api)
+	if cb.User.ID != c.Author {
+		return appealError(messages.Text(locale, messages.APPEAL_NOT_YOURS, messages.Data{}))
+	}
+
+	a := &cases.Appeal{
+		CaseID:        c.ID,
+		Author:        cb.User.ID,
+		Justification: cb.View.State.Values[appealInputBlock][appealInputAction].Value,
+	}
+	err = caseStore.CreateAppeal(ctx, a)
+	if errors.Is(err, cases.ErrAppealExists) {
+		return appealError(messages.Text(locale, messages.APPEAL_EXISTS, messages.Data{}))
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to file appeal")
+		return appealError(err.Error())
+	}
+	logger.Info().Uint("appeal", a.ID).Msg("appeal filed")
+	a.Case = *c
+	// Slack only waits a few seconds for the response; the moderators can wait.
+	go reviewAppeal(ctx, a, api)
+
+	return slack.NewUpdateViewSubmissionResponse(&slack.ModalViewRequest{
+		Type:  slack.VTModal,
+		Title: plainText(messages.Text(locale, messages.APPEAL_TITLE, messages.Data{})),
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, messages.Text(locale, messages.APPEAL_FILED, messages.Data{}), false, false), nil, nil),
+		}},
+	})
+}
+
+// reviewAppeal posts a to the moderators' channel with buttons to decide it.
+func reviewAppeal(ctx context.Context, a *cases.Appeal, api slackclient.Client) {
+	channel := config.FromContext(ctx).SpamFeed.Appeals.ChannelID
+	review := reviewText(ctx, a)
+	id := strconv.FormatUint(uint64(a.ID), 10)
+	uphold := slack.NewButtonBlockElement(ACTION_UPHOLD, id, plainText(text(ctx, messages.APPEAL_UPHOLD, messages.Data{})))
+	overturn := slack.NewButtonBlockElement(ACTION_OVERTURN, id, plainText(text(ctx, messages.APPEAL_OVERTURN, messages.Data{})))
+	overturn.Style = slack.StylePrimary
+
+	_, ts, err := api.PostMessage(channel,
+		slack.MsgOptionText(review, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, truncate(review, maxSectionText), false, false), nil, nil),
+			slack.NewActionBlock("appeal.decision", uphold, overturn),
+		),
+	)
+	if err != nil {
+		log.Error().Err(err).Uint("appeal", a.ID).Msg("failed to post appeal for review")
+		return
+	}
+	if err := caseStore.SetReview(ctx, a.ID, channel, ts); err != nil {
+		log.Error().Err(err).Uint("appeal", a.ID).Msg("failed to record appeal review")
+	}
+}
+
+// decideAppeal records a moderator's decision on the appeal whose button was
+// clicked, replaces the buttons with the decision and tells the author.
+func decideAppeal(ctx context.Context, status string, cb slack.InteractionCallback, action *slack.BlockAction, api slackclient.Client) {
+	logger := log.With().Str("user", cb.User.ID).Str("appeal", action.Value).Str("decision", status).Logger()
+	if cb.Channel.ID != config.FromContext(ctx).SpamFeed.Appeals.ChannelID {
+		logger.Warn().Str("channel", cb.Channel.ID).Msg("ignoring appeal decision from outside the appeals channel")
+		return
+	}
+	if !isModerator(ctx, cb.User.ID) {
+		tell(cb, text(ctx, messages.APPEAL_NOT_MODERATOR, messages.Data{}), api)
+		return
+	}
+	id, err := strconv.ParseUint(action.Value, 10, 0)
+	if err != nil {
+		logger.Error().Err(err).Msg("invalid appeal ID")
+		return
+	}
+	a, err := caseStore.Appeal(ctx, uint(id))
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to look up appeal")
+		return
+	}
+	if cb.User.ID == a.Author {
+		tell(cb, text(ctx, messages.APPEAL_OWN, messages.Data{}), api)
+		return
+	}
+
+	a, err = caseStore.DecideAppeal(ctx, a.ID, status, cb.User.ID)
+	if errors.Is(err, cases.ErrAlreadyDecided) {
+		tell(cb, text(ctx, messages.APPEAL_ALREADY_DECIDED, messages.Data{Moderator: mention(a.Moderator), Decision: a.Status}), api)
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to decide appeal")
+		return
+	}
+	logger.Info().Uint("case", a.CaseID).Msg("appeal decided")
+
+	review := reviewText(ctx, a)
+	decided := text(ctx, messages.APPEAL_DECIDED, messages.Data{Moderator: mention(cb.User.ID), Decision: status})
+	channel, ts := a.ReviewChannel, a.ReviewTimestamp
+	if channel == "" {
+		channel, ts = cb.Channel.ID, cb.Message.Timestamp
+	}
+	_, _, _, err = api.UpdateMessage(channel, ts,
+		slack.MsgOptionText(review+"\n"+decided, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, truncate(review, maxSectionText), false, false), nil, nil),
+			slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, decided, false, false)),
+		),
+	)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to update appeal review")
+	}
+
+	locale := userLocale(ctx, a.Author, api)
+	outcome := messages.Text(locale, messages.APPEAL_OUTCOME, messages.Data{Decision: status})
+	// An overturned removal comes with a copy of the message so it can be posted again.
+	repost := status == cases.APPEAL_OVERTURNED && a.Case.Verdict == messages.VERDICT_REMOVED
+	err = notifyOP(ctx, caseMessage(&a.Case), outcome, repost, locale, 0, api)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to tell the author about their appeal")
+	}
+	if status == cases.APPEAL_OVERTURNED {
+		actions := []string{webhooks.ACTION_OVERTURNED}
+		if err == nil {
+			actions = append(actions, webhooks.ACTION_NOTIFIED)
+		}
+		hooks.Send(webhooks.EVENT_CASE_OVERTURNED, storedWebhookCase(&a.Case, actions))
+	}
+}
+
+// reviewText is a's case and justification, for moderators.
+func reviewText(ctx context.Context, a *cases.Appeal) string {
+	return text(ctx, messages.APPEAL_REVIEW, messages.Data{
+		Case:          a.CaseID,
+		OP:            mention(a.Author),
+		Channel:       a.Case.Channel,
+		Text:          escape(a.Case.Text),
+		Score:         a.Case.Score,
+		Threshold:     a.Case.Threshold,
+		Verdict:       a.Case.Verdict,
+		Justification: escape(a.Justification),
+	})
+}
+
+// isModerator reports whether uid may decide on the moderators' behalf: a
+// global admin, an escalation moderator or a workspace admin.
+func isModerator(ctx context.Context, uid string) bool {
+	cfg := config.FromContext(ctx)
+	return slices.Contains(cfg.Slack.GlobalAdmins, uid) || slices.Contains(cfg.SpamFeed.Escalation.Moderators, uid) || admins.has(uid)
+}
+
+// caseFor looks up the case with the ID in value.
+func caseFor(ctx context.Context, value string) (*cases.Case, error) {
+	if caseStore == nil {
+		return nil, fmt.Errorf("appeals are not enabled")
+	}
+	id, err := strconv.ParseUint(value, 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid case ID %q", value)
+	}
+	return caseStore.Case(ctx, uint(id))
+}
+
+// tell shows msg to the user who triggered cb, where they triggered it.
+func tell(cb slack.InteractionCallback, msg string, api slackclient.Client) {
+	_, err := api.PostEphemeral(cb.Channel.ID, cb.User.ID, slack.MsgOptionText(msg, false))
+	if err != nil {
+		log.Error().Err(err).Str("user", cb.User.ID).Msg("failed to reply to interaction")
+	}
+}
+
+func appealError(msg string) *slack.ViewSubmissionResponse {
+	return slack.NewErrorsViewSubmissionResponse(map[string]string{appealInputBlock: msg})
+}
+
+func plainText(s string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.PlainTextType, s, false, false)
+}
+
+func mention(uid string) string {
+	return "<@" + uid + ">"
+}
+
+// mrkdwnEscaper escapes the characters Slack treats as control characters in
+// mrkdwn, so that text a member wrote can't mention, link or format.
+var mrkdwnEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escape makes s, written by a member, safe to render as mrkdwn.
+func escape(s string) string {
+	return mrkdwnEscaper.Replace(s)
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(r[:n-1])) + "…"
+}
@@ -0,0 +1,259 @@
+package hallmonitor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// useMemoryCases points hallmonitor at a fresh in-memory case store with one
+// removed case by U_OP, returning the store and the case.
+func useMemoryCases(t *testing.T) (*cases.MemoryStore, *cases.Case) {
+	t.Helper()
+	store := cases.NewMemoryStore()
+	c := &cases.Case{Channel: "C_OP", Timestamp: "1111.0001", Author: "U_OP", Text: "Buy cheap followers", Score: 3, Threshold: 2, Verdict: messages.VERDICT_REMOVED}
+	if err := store.CreateCase(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	UseCases(store)
+	t.Cleanup(func() { UseCases(nil) })
+	return store, c
+}
+
+func appealCallback(user string) slack.InteractionCallback {
+	var cb slack.InteractionCallback
+	cb.User.ID = user
+	cb.Channel.ID = "C_OP"
+	cb.TriggerID = "trigger"
+	return cb
+}
+
+// reviewCallback is user clicking a decision button in the C_MODS appeals channel.
+func reviewCallback(user string) slack.InteractionCallback {
+	cb := appealCallback(user)
+	cb.Channel.ID = "C_MODS"
+	return cb
+}
+
+func TestOpenAppeal(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      string
+		value     string
+		wantView  bool
+		wantError bool
+	}{
+		{name: "author gets the modal", user: "U_OP", value: "1", wantView: true},
+		{name: "someone else is turned away", user: "U_OTHER", value: "1", wantError: true},
+		{name: "unknown case", user: "U_OP", value: "99"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupViperConfig(t, map[string]interface{}{"spam_feed.appeals.channel_id": "C_MODS"})
+			useMemoryCases(t)
+
+			var view *slack.ModalViewRequest
+			var ephemeral string
+			mock := &slackclient.MockClient{
+				OpenViewFn: func(triggerID string, v slack.ModalViewRequest) (*slack.ViewResponse, error) {
+					view = &v
+					return &slack.ViewResponse{}, nil
+				},
+				PostEphemeralFn: func(channelID, userID string, options ...slack.MsgOption) (string, error) {
+					_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+					ephemeral = values.Get("text")
+					return "ts", nil
+				},
+			}
+
+			openAppeal(context.Background(), appealCallback(tt.user), &slack.BlockAction{ActionID: ACTION_APPEAL, Value: tt.value}, mock)
+
+			if got := view != nil; got != tt.wantView {
+				t.Fatalf("openAppeal() opened a view = %v, want %v", got, tt.wantView)
+			}
+			if view != nil && (view.CallbackID != VIEW_APPEAL || view.PrivateMetadata != tt.value) {
+				t.Errorf("openAppeal() view callback = %q metadata = %q, want %q and %q", view.CallbackID, view.PrivateMetadata, VIEW_APPEAL, tt.value)
+			}
+			if got := ephemeral != ""; got != tt.wantError {
+				t.Errorf("openAppeal() told the user %q, want a reply = %v", ephemeral, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestSubmitAppeal(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{"spam_feed.appeals.channel_id": "C_MODS"})
+	store, c := useMemoryCases(t)
+
+	reviewed := make(chan string, 1)
+	mock := &slackclient.MockClient{
+		PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+			_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+			if channelID != "C_MODS" {
+				t.Errorf("review posted to %s, want C_MODS", channelID)
+			}
+			reviewed <- values.Get("blocks")
+			return channelID, "2222.0001", nil
+		},
+	}
+
+	submit := func(user string) *slack.ViewSubmissionResponse {
+		cb := appealCallback(user)
+		cb.View.CallbackID = VIEW_APPEAL
+		cb.View.PrivateMetadata = "1"
+		cb.View.State = &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+			appealInputBlock: {appealInputAction: {Value: "It was a job post in #jobs"}},
+		}}
+		return submitAppeal(context.Background(), cb, mock)
+	}
+
+	if resp := submit("U_OTHER"); resp == nil || resp.ResponseAction != slack.RAErrors {
+		t.Fatalf("submitAppeal() by someone else = %+v, want errors", resp)
+	}
+
+	resp := submit("U_OP")
+	if resp == nil || resp.ResponseAction != slack.RAUpdate {
+		t.Fatalf("submitAppeal() = %+v, want the modal updated", resp)
+	}
+	select {
+	case blocks := <-reviewed:
+		for _, want := range []string{"It was a job post", c.Text, ACTION_UPHOLD, ACTION_OVERTURN} {
+			if !strings.Contains(blocks, want) {
+				t.Errorf("review blocks = %s, want %q", blocks, want)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("appeal was not posted for review")
+	}
+
+	// The review's location is saved right after it's posted.
+	deadline := time.Now().Add(time.Second)
+	for {
+		a, err := store.Appeal(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("Appeal() error = %v", err)
+		}
+		if a.ReviewTimestamp == "2222.0001" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("appeal review = %q/%q, want C_MODS/2222.0001", a.ReviewChannel, a.ReviewTimestamp)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if resp := submit("U_OP"); resp == nil || resp.ResponseAction != slack.RAErrors || !strings.Contains(resp.Errors[appealInputBlock], "already") {
+		t.Errorf("second submitAppeal() = %+v, want an already appealed error", resp)
+	}
+}
+
+func TestDecideAppeal(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.appeals.channel_id":    "C_MODS",
+		"spam_feed.op_notice_delivery":    DELIVERY_DM,
+		"slack.global_admins":             []string{"U_MOD", "U_OP"},
+		"spam_feed.escalation.moderators": []string{"U_MOD2"},
+	})
+	store, c := useMemoryCases(t)
+	ctx := config.NewContext(context.Background(), config.Current())
+	a := &cases.Appeal{CaseID: c.ID, Author: "U_OP", Justification: "It was a job post"}
+	if err := store.CreateAppeal(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetReview(ctx, a.ID, "C_MODS", "2222.0001"); err != nil {
+		t.Fatal(err)
+	}
+
+	var ephemeral, updated, notified string
+	mock := &slackclient.MockClient{
+		PostEphemeralFn: func(channelID, userID string, options ...slack.MsgOption) (string, error) {
+			_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+			ephemeral = values.Get("text")
+			return "ts", nil
+		},
+		UpdateMessageFn: func(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+			if channelID != "C_MODS" || timestamp != "2222.0001" {
+				t.Errorf("UpdateMessage() at %s/%s, want C_MODS/2222.0001", channelID, timestamp)
+			}
+			_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+			updated = values.Get("blocks")
+			return channelID, timestamp, "", nil
+		},
+		OpenConversationFn: func(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error) {
+			return &slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "D_OP"}}}, false, true, nil
+		},
+		PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+			_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+			notified = values.Get("text")
+			return channelID, "ts", nil
+		},
+	}
+	action := &slack.BlockAction{ActionID: ACTION_OVERTURN, Value: "1"}
+
+	decideAppeal(ctx, cases.APPEAL_OVERTURNED, reviewCallback("U_OP"), action, mock)
+	if ephemeral == "" || updated != "" {
+		t.Fatalf("author deciding their own appeal: told %q, updated %q; want only a reply", ephemeral, updated)
+	}
+
+	ephemeral = ""
+	decideAppeal(ctx, cases.APPEAL_OVERTURNED, reviewCallback("U_MEMBER"), action, mock)
+	if !strings.Contains(ephemeral, "Only moderators") || updated != "" {
+		t.Fatalf("member deciding an appeal: told %q, updated %q; want only a refusal", ephemeral, updated)
+	}
+
+	ephemeral = ""
+	decideAppeal(ctx, cases.APPEAL_OVERTURNED, appealCallback("U_MOD"), action, mock)
+	if ephemeral != "" || updated != "" {
+		t.Fatalf("decision from outside the appeals channel: told %q, updated %q; want it ignored", ephemeral, updated)
+	}
+
+	decideAppeal(ctx, cases.APPEAL_OVERTURNED, reviewCallback("U_MOD"), action, mock)
+	if ephemeral != "" {
+		t.Errorf("decideAppeal() told the moderator %q, want nothing", ephemeral)
+	}
+	if !strings.Contains(updated, `Overturned by \u003c@U_MOD\u003e`) {
+		t.Errorf("decideAppeal() updated the review to %s, want it overturned by U_MOD", updated)
+	}
+	if strings.Contains(updated, ACTION_UPHOLD) {
+		t.Errorf("decideAppeal() left the buttons on the review: %s", updated)
+	}
+	if !strings.Contains(notified, "overturned") || !strings.Contains(notified, c.Text) {
+		t.Errorf("decideAppeal() told the author %q, want the outcome and a copy of their message", notified)
+	}
+	decided, _ := store.Appeal(ctx, a.ID)
+	if decided.Status != cases.APPEAL_OVERTURNED || decided.Moderator != "U_MOD" {
+		t.Errorf("appeal = %+v, want overturned by U_MOD", decided)
+	}
+
+	decideAppeal(ctx, cases.APPEAL_UPHELD, reviewCallback("U_MOD2"), &slack.BlockAction{ActionID: ACTION_UPHOLD, Value: "1"}, mock)
+	if !strings.Contains(ephemeral, "<@U_MOD> already overturned") {
+		t.Errorf("second decision told the moderator %q, want who already decided it", ephemeral)
+	}
+}
+
+// TestReviewTextEscapes verifies the member's text and justification can't
+// mention or link from the moderators' channel.
+func TestReviewTextEscapes(t *testing.T) {
+	a := &cases.Appeal{CaseID: 1, Author: "U_OP", Justification: "ask <!channel> & <https://evil.example|them>",
+		Case: cases.Case{Channel: "C_OP", Text: "<@U_ADMIN> Buy cheap followers"}}
+	got := reviewText(context.Background(), a)
+	for _, raw := range []string{"<!channel>", "<@U_ADMIN>", "<https://"} {
+		if strings.Contains(got, raw) {
+			t.Errorf("reviewText() = %q, want %s escaped", got, raw)
+		}
+	}
+	if !strings.Contains(got, "&lt;@U_ADMIN&gt;") || !strings.Contains(got, "&amp;") {
+		t.Errorf("reviewText() = %q, want &, < and > escaped", got)
+	}
+	if !strings.Contains(got, "<@U_OP>") {
+		t.Errorf("reviewText() = %q, want the author still mentioned", got)
+	}
+}
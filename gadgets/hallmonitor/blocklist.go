@@ -0,0 +1,68 @@
+package hallmonitor
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/blocklist"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// blocklisted is the shared blocklist, swapped whenever the feeds are
+// refreshed. The blocklisted signal is only evaluated while it's set.
+var blocklisted atomic.Pointer[blocklist.List]
+
+// UseBlocklist scores authors on l. It's safe to call while serving, to swap
+// in freshly imported feeds.
+func UseBlocklist(l *blocklist.List) {
+	blocklisted.Store(l)
+}
+
+func blocklistSignal() signal {
+	return signal{
+		name: "blocklisted",
+		// The full weight is the strongest match's.
+		weight: func(cfg config.SpamFeed) int {
+			return max(cfg.AnomalyScores.Blocklisted, cfg.AnomalyScores.BlocklistedName, cfg.AnomalyScores.BlocklistedDomain)
+		},
+		describe: func(ctx context.Context, score int) string {
+			return text(ctx, messages.REASON_PREFIX+"blocklisted", messages.Data{Score: score})
+		},
+		evaluate: func(ctx context.Context, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client) (int, error) {
+			return blocklistScore(ctx, opMsg.User, api)
+		},
+	}
+}
+
+// blocklistScore returns the configured anomaly score if uid is on a shared
+// blocklist, by user ID, email, display name or email domain. A display name
+// or domain can be shared by strangers, so those matches score their own,
+// lower weights.
+func blocklistScore(ctx context.Context, uid string, api slackclient.Client) (int, error) {
+	l := blocklisted.Load()
+	if l.Len() == 0 {
+		return 0, nil
+	}
+	user, err := api.GetUserInfoContext(ctx, uid)
+	if err != nil {
+		return 0, err
+	}
+	m, ok := l.Match(blocklist.IdentityOf(user))
+	if !ok {
+		return 0, nil
+	}
+	log.Info().Str("op_user", uid).Str("field", m.Field).Str("issuer", m.Issuer).Msg("author is on a shared blocklist")
+	scores := config.FromContext(ctx).SpamFeed.AnomalyScores
+	switch m.Field {
+	case blocklist.FIELD_DISPLAY_NAME:
+		return scores.BlocklistedName, nil
+	case blocklist.FIELD_DOMAIN:
+		return scores.BlocklistedDomain, nil
+	default:
+		return scores.Blocklisted, nil
+	}
+}
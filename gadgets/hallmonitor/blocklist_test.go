@@ -0,0 +1,93 @@
+package hallmonitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/blocklist"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// useBlocklist swaps the shared blocklist for l for the duration of the test.
+func useBlocklist(t *testing.T, l *blocklist.List) {
+	t.Helper()
+	prev := blocklisted.Load()
+	UseBlocklist(l)
+	t.Cleanup(func() { UseBlocklist(prev) })
+}
+
+func TestBlocklistScore(t *testing.T) {
+	const salt = "shared-salt"
+	spammer := blocklist.Identity{UserID: "U_ELSEWHERE", Email: "spam@example.com", DisplayName: "Crypto Giveaway"}
+	feed := &blocklist.Feed{Issuer: "community-a", Entries: []blocklist.Entry{blocklist.NewEntry(salt, spammer)}}
+
+	tests := []struct {
+		name       string
+		list       *blocklist.List
+		email      string
+		display    string
+		getUserErr error
+		wantScore  int
+		wantErr    bool
+	}{
+		{name: "no blocklist returns 0", email: "spam@example.com"},
+		{name: "listed email returns score", list: blocklist.NewList(salt, feed), email: "Spam@Example.com", wantScore: 10},
+		{name: "listed display name returns its score", list: blocklist.NewList(salt, feed), email: "someone@example.org", display: "crypto giveaway", wantScore: 3},
+		{name: "listed domain returns its score", list: blocklist.NewList(salt, feed), email: "someone@example.com", wantScore: 2},
+		{name: "unlisted author returns 0", list: blocklist.NewList(salt, feed), email: "someone@example.org"},
+		{name: "GetUserInfo error returns 0 and error", list: blocklist.NewList(salt, feed), getUserErr: errors.New("user not found"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupViperConfig(t, map[string]interface{}{
+				"spam_feed.anomaly_scores.blocklisted":        10,
+				"spam_feed.anomaly_scores.blocklisted_name":   3,
+				"spam_feed.anomaly_scores.blocklisted_domain": 2,
+			})
+			useBlocklist(t, tt.list)
+
+			mock := &slackclient.MockClient{
+				GetUserInfoFn: func(user string) (*slack.User, error) {
+					if tt.getUserErr != nil {
+						return nil, tt.getUserErr
+					}
+					u := &slack.User{ID: user}
+					u.Profile.Email = tt.email
+					u.Profile.DisplayName = tt.display
+					return u, nil
+				},
+			}
+
+			got, err := blocklistScore(context.Background(), "U_OP", mock)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("blocklistScore() error = %v, want an error = %v", err, tt.wantErr)
+			}
+			if got != tt.wantScore {
+				t.Errorf("blocklistScore() = %d, want %d", got, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestSpamSignalsBlocklist(t *testing.T) {
+	has := func() bool {
+		for _, s := range spamSignals() {
+			if s.name == "blocklisted" {
+				return true
+			}
+		}
+		return false
+	}
+
+	useBlocklist(t, nil)
+	if has() {
+		t.Error("spamSignals() includes blocklisted without a blocklist")
+	}
+	useBlocklist(t, blocklist.NewList("salt"))
+	if !has() {
+		t.Error("spamSignals() is missing blocklisted with a blocklist loaded")
+	}
+}
@@ -0,0 +1,249 @@
+package hallmonitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+const defaultBreakerWindow = time.Hour
+
+// removalBreaker caps how many messages Penny removes per window, globally and
+// per channel. Once a cap is hit Penny stops removing (review-only mode) until a
+// global admin resumes it, so a misconfigured weight can't delete a channel's
+// worth of legitimate messages before anyone notices. Trips are recorded in the
+// case store, so a restart doesn't resume removals.
+type removalBreaker struct {
+	mu       sync.Mutex
+	removals []removalRecord
+	tripped  bool
+	trip     breakerTrip
+	now      func() time.Time
+}
+
+type removalRecord struct {
+	channel string
+	at      time.Time
+}
+
+// breakerTrip describes why the breaker tripped.
+type breakerTrip struct {
+	at      time.Time
+	channel string // empty when the global cap was hit
+	count   int
+	limit   int
+	window  time.Duration
+}
+
+func (t breakerTrip) String() string {
+	scope := "across all channels"
+	if t.channel != "" {
+		scope = fmt.Sprintf("in <#%s>", t.channel)
+	}
+	return fmt.Sprintf("%d removals %s within %s (limit %d)", t.count, scope, t.window, t.limit)
+}
+
+var breaker = newRemovalBreaker(time.Now)
+
+func newRemovalBreaker(now func() time.Time) *removalBreaker {
+	return &removalBreaker{now: now}
+}
+
+// breakerWindow is the sliding window the removal caps apply to.
+func breakerWindow() time.Duration {
+	if d := config.Current().SpamFeed.Breaker.Window; d > 0 {
+		return d
+	}
+	return defaultBreakerWindow
+}
+
+// allow reports whether a removal in channel may go ahead, and if so counts it
+// against the caps straight away, so concurrent removals can't all slip under
+// a cap. Call release if the removal then fails. tripped is true only for the
+// call that moved the breaker into review-only mode.
+func (b *removalBreaker) allow(channel string) (allowed bool, tripped bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tripped {
+		return false, false
+	}
+
+	now := b.now()
+	window := breakerWindow()
+	b.expire(now, window)
+	inChannel := 0
+	for _, r := range b.removals {
+		if r.channel == channel {
+			inChannel++
+		}
+	}
+
+	if limit := config.Current().SpamFeed.Breaker.MaxRemovals; limit > 0 && len(b.removals) >= limit {
+		b.tripped = true
+		b.trip = breakerTrip{at: now, count: len(b.removals), limit: limit, window: window}
+		return false, true
+	}
+	if limit := config.Current().SpamFeed.Breaker.MaxChannelRemovals; limit > 0 && inChannel >= limit {
+		b.tripped = true
+		b.trip = breakerTrip{at: now, channel: channel, count: inChannel, limit: limit, window: window}
+		return false, true
+	}
+	b.removals = append(b.removals, removalRecord{channel: channel, at: now})
+	return true, false
+}
+
+// release gives back the latest removal allow counted in channel, because it
+// failed.
+func (b *removalBreaker) release(channel string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := len(b.removals) - 1; i >= 0; i-- {
+		if b.removals[i].channel == channel {
+			b.removals = append(b.removals[:i], b.removals[i+1:]...)
+			return
+		}
+	}
+}
+
+// expire forgets removals older than window. b.mu must be held.
+func (b *removalBreaker) expire(now time.Time, window time.Duration) {
+	kept := b.removals[:0]
+	for _, r := range b.removals {
+		if now.Sub(r.at) < window {
+			kept = append(kept, r)
+		}
+	}
+	b.removals = kept
+}
+
+// resume leaves review-only mode and forgets past removals. It returns the trip
+// that was cleared and whether the breaker was tripped at all.
+func (b *removalBreaker) resume() (breakerTrip, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	trip, wasTripped := b.trip, b.tripped
+	b.tripped = false
+	b.trip = breakerTrip{}
+	b.removals = nil
+	return trip, wasTripped
+}
+
+// restore trips the breaker with trip, as recorded before a restart.
+func (b *removalBreaker) restore(trip breakerTrip) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tripped = true
+	b.trip = trip
+}
+
+// status returns the current trip, if any.
+func (b *removalBreaker) status() (breakerTrip, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.trip, b.tripped
+}
+
+// data is trip for a message, with Trip rendered in locale.
+func (t breakerTrip) data(locale string) messages.Data {
+	d := messages.Data{Removals: t.count, Channel: t.channel, Window: t.window, Limit: t.limit, Since: t.at}
+	d.Trip = messages.Text(locale, messages.BREAKER_TRIP, d)
+	return d
+}
+
+// alertAdmins tells the admins that removals are paused. The alert goes to
+// spam_feed.breaker.alert_channel_id when set, otherwise to each global admin by DM.
+func alertAdmins(trip breakerTrip, api slackclient.Client) {
+	locale := config.Current().Messages.Locale
+	text := messages.Text(locale, messages.BREAKER_ALERT, trip.data(locale))
+
+	targets := config.Current().Slack.GlobalAdmins
+	if channelID := config.Current().SpamFeed.Breaker.AlertChannelID; channelID != "" {
+		targets = []string{channelID}
+	}
+	if len(targets) == 0 {
+		log.Warn().Str("trip", trip.String()).Msg("removal breaker tripped but no one is configured to alert")
+		return
+	}
+
+	for _, target := range targets {
+		if _, _, err := api.PostMessage(target, slack.MsgOptionText(text, false)); err != nil {
+			log.Error().Err(err).Str("target", target).Msg("failed to alert admins that the removal breaker tripped")
+		}
+	}
+}
+
+// breakerCommand handles the breaker's `/penny` subcommands and returns the reply.
+func breakerCommand(args []string, user string) string {
+	locale := config.Current().Messages.Locale
+	switch {
+	case len(args) == 0 || args[0] == "status":
+		trip, tripped := breaker.status()
+		if !tripped {
+			return messages.Text(locale, messages.BREAKER_RUNNING, messages.Data{})
+		}
+		return messages.Text(locale, messages.BREAKER_PAUSED, trip.data(locale))
+	case args[0] == "resume":
+		trip, tripped := breaker.resume()
+		if caseStore != nil {
+			if err := caseStore.Resume(context.Background(), user); err != nil {
+				log.Error().Err(err).Str("user", user).Msg("failed to record that removals were resumed")
+			}
+		}
+		if !tripped {
+			return messages.Text(locale, messages.BREAKER_NOT_PAUSED, messages.Data{})
+		}
+		log.Info().Str("user", user).Str("trip", trip.String()).Msg("removal breaker resumed")
+		return messages.Text(locale, messages.BREAKER_RESUMED, messages.Data{})
+	default:
+		return messages.Text(locale, messages.COMMAND_UNKNOWN, messages.Data{Command: strings.Join(args, " ")})
+	}
+}
+
+// savePause records trip in the case store, so removals stay paused across
+// restarts until an admin resumes them.
+func savePause(ctx context.Context, trip breakerTrip) {
+	if caseStore == nil {
+		return
+	}
+	err := caseStore.Pause(ctx, &cases.Pause{Channel: trip.channel, Count: trip.count, Limit: trip.limit, Window: trip.window})
+	if err != nil {
+		log.Error().Err(err).Str("trip", trip.String()).Msg("failed to record that removals were paused")
+	}
+}
+
+// RestoreBreaker puts the removal breaker back into review-only mode if
+// removals were paused when Penny last stopped. Call it after UseCases.
+func RestoreBreaker(ctx context.Context) error {
+	if caseStore == nil {
+		return nil
+	}
+	p, err := caseStore.ActivePause(ctx)
+	if errors.Is(err, cases.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	trip := breakerTrip{at: p.CreatedAt, channel: p.Channel, count: p.Count, limit: p.Limit, window: p.Window}
+	breaker.restore(trip)
+	log.Warn().Str("trip", trip.String()).Msg("removals are still paused")
+	return nil
+}
+
+// RemovalsPaused reports whether the removal breaker is in review-only mode.
+func RemovalsPaused() bool {
+	_, tripped := breaker.status()
+	return tripped
+}
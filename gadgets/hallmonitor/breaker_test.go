@@ -0,0 +1,293 @@
+package hallmonitor
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gadget-bot/gadget/router"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/metrics"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// fakeClock is a manually advanced clock for window tests.
+type fakeClock struct{ t time.Time }
+
+func (f *fakeClock) now() time.Time          { return f.t }
+func (f *fakeClock) advance(d time.Duration) { f.t = f.t.Add(d) }
+
+// useBreaker swaps the package breaker for b for the duration of the test.
+func useBreaker(t *testing.T, b *removalBreaker) {
+	t.Helper()
+	prev := breaker
+	breaker = b
+	t.Cleanup(func() { breaker = prev })
+}
+
+func TestRemovalBreakerAllow(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      map[string]interface{}
+		channels    []string
+		wantAllowed []bool
+		wantTripped int // index of the call that trips, -1 for none
+	}{
+		{
+			name:        "disabled when no limits are set",
+			config:      map[string]interface{}{},
+			channels:    []string{"C1", "C1", "C1", "C1"},
+			wantAllowed: []bool{true, true, true, true},
+			wantTripped: -1,
+		},
+		{
+			name:        "global limit trips across channels",
+			config:      map[string]interface{}{"spam_feed.breaker.max_removals": 2},
+			channels:    []string{"C1", "C2", "C3", "C4"},
+			wantAllowed: []bool{true, true, false, false},
+			wantTripped: 2,
+		},
+		{
+			name:        "channel limit trips in one channel",
+			config:      map[string]interface{}{"spam_feed.breaker.max_channel_removals": 2},
+			channels:    []string{"C1", "C2", "C1", "C2", "C1"},
+			wantAllowed: []bool{true, true, true, true, false},
+			wantTripped: 4,
+		},
+		{
+			name:        "once tripped every channel is held",
+			config:      map[string]interface{}{"spam_feed.breaker.max_channel_removals": 1},
+			channels:    []string{"C1", "C1", "C2"},
+			wantAllowed: []bool{true, false, false},
+			wantTripped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupViperConfig(t, tt.config)
+			b := newRemovalBreaker(time.Now)
+
+			for i, ch := range tt.channels {
+				allowed, tripped := b.allow(ch)
+				if allowed != tt.wantAllowed[i] {
+					t.Errorf("allow(%q) call %d = %v, want %v", ch, i, allowed, tt.wantAllowed[i])
+				}
+				if tripped != (i == tt.wantTripped) {
+					t.Errorf("allow(%q) call %d tripped = %v, want %v", ch, i, tripped, i == tt.wantTripped)
+				}
+			}
+		})
+	}
+}
+
+func TestRemovalBreakerWindow(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.breaker.max_removals": 2,
+		"spam_feed.breaker.window":       "10m",
+	})
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	b := newRemovalBreaker(clock.now)
+
+	b.allow("C1")
+	b.allow("C1")
+	if allowed, _ := b.allow("C1"); allowed {
+		t.Fatalf("allow() at the cap = true, want false")
+	}
+	b.resume()
+	b.allow("C1")
+	b.allow("C1")
+	clock.advance(10 * time.Minute)
+	if allowed, _ := b.allow("C1"); !allowed {
+		t.Errorf("allow() after the window passed = false, want true")
+	}
+}
+
+func TestRemovalBreakerResume(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{"spam_feed.breaker.max_removals": 1})
+	useBreaker(t, newRemovalBreaker(time.Now))
+
+	if got := breakerCommand([]string{"resume"}, "U_ADMIN"); !strings.Contains(got, "weren't paused") {
+		t.Errorf("resume before tripping = %q", got)
+	}
+
+	breaker.allow("C1")
+	breaker.allow("C1")
+	if got := breakerCommand(nil, "U_ADMIN"); !strings.Contains(got, "paused") || !strings.Contains(got, "across all channels") {
+		t.Errorf("status while tripped = %q", got)
+	}
+
+	if got := breakerCommand([]string{"resume"}, "U_ADMIN"); got != "Removals are back on." {
+		t.Errorf("resume while tripped = %q", got)
+	}
+	if allowed, _ := breaker.allow("C1"); !allowed {
+		t.Errorf("allow() after resume = false, want true")
+	}
+	if got := breakerCommand([]string{"status"}, "U_ADMIN"); got != "Removals are running normally." {
+		t.Errorf("status after resume = %q", got)
+	}
+	if got := breakerCommand([]string{"explode"}, "U_ADMIN"); !strings.Contains(got, "`explode`") {
+		t.Errorf("unknown subcommand = %q", got)
+	}
+}
+
+// TestRemovalBreakerRelease verifies removals that were allowed but then
+// released, because the delete failed, don't count towards a cap.
+func TestRemovalBreakerRelease(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{"spam_feed.breaker.max_removals": 1})
+	b := newRemovalBreaker(time.Now)
+	for range 3 {
+		if allowed, _ := b.allow("C1"); !allowed {
+			t.Fatal("allow() = false with every earlier removal released")
+		}
+		b.release("C1")
+	}
+	b.allow("C1")
+	if allowed, tripped := b.allow("C1"); allowed || !tripped {
+		t.Errorf("allow() after a removal = %v, %v; want it to trip", allowed, tripped)
+	}
+}
+
+// TestRemovalBreakerConcurrent verifies concurrent removals never exceed a cap,
+// since each is counted as soon as it's allowed.
+func TestRemovalBreakerConcurrent(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{"spam_feed.breaker.max_removals": 5})
+	b := newRemovalBreaker(time.Now)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for range 50 {
+		wg.Go(func() {
+			<-start
+			if ok, _ := b.allow("C1"); ok {
+				allowed.Add(1)
+			}
+		})
+	}
+	close(start)
+	wg.Wait()
+
+	if got := allowed.Load(); got != 5 {
+		t.Errorf("%d of 50 concurrent removals allowed, want the cap of 5", got)
+	}
+}
+
+// TestRestoreBreaker verifies a trip survives a restart until it's resumed.
+func TestRestoreBreaker(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{"spam_feed.breaker.max_removals": 1})
+	useBreaker(t, newRemovalBreaker(time.Now))
+	store := cases.NewMemoryStore()
+	UseCases(store)
+	t.Cleanup(func() { UseCases(nil) })
+	ctx := context.Background()
+
+	breaker.allow("C1")
+	if removalAllowed(ctx, "C1", &slackclient.MockClient{}) {
+		t.Fatal("removalAllowed() at the cap = true, want false")
+	}
+
+	breaker = newRemovalBreaker(time.Now)
+	if err := RestoreBreaker(ctx); err != nil {
+		t.Fatalf("RestoreBreaker() error = %v", err)
+	}
+	if !RemovalsPaused() {
+		t.Fatal("RemovalsPaused() after a restart = false, want the trip restored")
+	}
+	if got := breakerCommand(nil, "U_ADMIN"); !strings.Contains(got, "across all channels") {
+		t.Errorf("status after a restart = %q, want the restored trip", got)
+	}
+
+	breakerCommand([]string{"resume"}, "U_ADMIN")
+	breaker = newRemovalBreaker(time.Now)
+	if err := RestoreBreaker(ctx); err != nil {
+		t.Fatalf("RestoreBreaker() error = %v", err)
+	}
+	if RemovalsPaused() {
+		t.Error("RemovalsPaused() after resuming and restarting = true, want false")
+	}
+}
+
+func TestPennyCommandPermissions(t *testing.T) {
+	route := pennyCommand()
+	if len(route.Permissions) != 1 || route.Permissions[0] != "globalAdmins" {
+		t.Errorf("Permissions = %v, want [globalAdmins]", route.Permissions)
+	}
+}
+
+// TestProcessSpamFeedMessageBreaker verifies removals past the cap are held for
+// review and the admins are alerted once.
+func TestProcessSpamFeedMessageBreaker(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.channel":                      "spam-feed",
+		"spam_feed.anomaly_scores.reported":      5,
+		"spam_feed.max_anomaly_score":            5,
+		"spam_feed.breaker.max_channel_removals": 1,
+		"spam_feed.breaker.alert_channel_id":     "C_ADMINS",
+	})
+	useBreaker(t, newRemovalBreaker(time.Now))
+
+	removedBefore := testutil.ToFloat64(metrics.Removals.WithLabelValues("spam-feed", metrics.RESULT_OK))
+	heldBefore := testutil.ToFloat64(metrics.Removals.WithLabelValues("spam-feed", metrics.RESULT_HELD))
+
+	const opChan = "C02BZ36790B"
+	var alerts, deletes int
+	var debug string
+	mock := &slackclient.MockClient{
+		GetConversationInfoFn: func(input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+			return &slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{NameNormalized: "spam-feed"}}}, nil
+		},
+		JoinConversationFn: noopJoin,
+		GetConversationHistoryFn: func(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+			msg := slack.Message{Msg: slack.Msg{Timestamp: params.Latest, Channel: params.ChannelID}}
+			if params.ChannelID == opChan {
+				msg.User = "U_OP" + params.Latest
+			}
+			return &slack.GetConversationHistoryResponse{Messages: []slack.Message{msg}}, nil
+		},
+		PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+			if channelID == "C_ADMINS" {
+				alerts++
+			}
+			if channelID == "C_SPAM" {
+				_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+				debug = values.Get("text")
+			}
+			return channelID, "ts", nil
+		},
+		AddReactionFn: func(name string, item slack.ItemRef) error { return nil },
+	}
+	userMock := &slackclient.MockClient{
+		DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+			deletes++
+			return channel, messageTimestamp, nil
+		},
+	}
+
+	for _, ts := range []string{"1639843883000100", "1639843884000100", "1639843885000100"} {
+		ev := slackevents.MessageEvent{SubType: BOT_MESSAGE_TYPE, Channel: "C_SPAM", TimeStamp: "1111111111.000100"}
+		ProcessSpamFeedMessage(router.Router{BotUID: "U_BOT"}, router.Route{}, mock, userMock, ev, "<https://orgname.slack.com/archives/C02BZ36790B/p"+ts+">")
+	}
+
+	if deletes != 1 {
+		t.Errorf("DeleteMessage called %d times, want 1", deletes)
+	}
+	if alerts != 1 {
+		t.Errorf("admins alerted %d times, want 1", alerts)
+	}
+	if !strings.Contains(debug, "removals are paused") {
+		t.Errorf("debug response = %q, want it to mention removals are paused", debug)
+	}
+	if got := testutil.ToFloat64(metrics.Removals.WithLabelValues("spam-feed", metrics.RESULT_OK)) - removedBefore; got != 1 {
+		t.Errorf("removals_total{result=ok} grew by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.Removals.WithLabelValues("spam-feed", metrics.RESULT_HELD)) - heldBefore; got != 2 {
+		t.Errorf("removals_total{result=held} grew by %v, want 2", got)
+	}
+}
@@ -0,0 +1,31 @@
+package hallmonitor
+
+import (
+	"strings"
+
+	"github.com/gadget-bot/gadget/router"
+	"github.com/slack-go/slack"
+)
+
+// pennyCommand is the `/penny` admin command. Only global admins may run it.
+func pennyCommand() *router.SlashCommandRoute {
+	var pluginRoute router.SlashCommandRoute
+	pluginRoute.Name = "hallmonitor.penny"
+	pluginRoute.Description = "Check on or resume Penny's removals"
+	pluginRoute.Help = "/penny [status|resume]"
+	pluginRoute.Command = "/penny"
+	pluginRoute.Permissions = []string{"globalAdmins"}
+	pluginRoute.Plugin = func(ctx router.HandlerContext, cmd slack.SlashCommand) {
+		reply := runPennyCommand(cmd)
+		err := slack.PostWebhook(cmd.ResponseURL, &slack.WebhookMessage{Text: reply, ResponseType: slack.ResponseTypeEphemeral})
+		if err != nil {
+			ctx.Logger.Error().Err(err).Str("user", cmd.UserID).Msg("failed to reply to /penny")
+		}
+	}
+	return &pluginRoute
+}
+
+// runPennyCommand dispatches a `/penny` invocation to its subcommand and returns the reply.
+func runPennyCommand(cmd slack.SlashCommand) string {
+	return breakerCommand(strings.Fields(cmd.Text), cmd.UserID)
+}
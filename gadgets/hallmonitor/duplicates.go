@@ -0,0 +1,107 @@
+package hallmonitor
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/fingerprint"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// recentPosts are the fingerprints of every author's recent channel messages.
+var recentPosts = fingerprint.NewTracker()
+
+// TrackedPosts is how many recent posts are fingerprinted, for metrics.
+func TrackedPosts() int {
+	return recentPosts.Len()
+}
+
+// ObserveMessage fingerprints a message event into its author's recent posts.
+// Edits, deletions, bots, DMs and messages too short to tell apart are
+// skipped. It's cheap enough to run before Slack is acknowledged.
+func ObserveMessage(ev *slackevents.MessageEvent) {
+	cfg := config.Current().SpamFeed
+	if cfg.AnomalyScores.Duplicates == 0 || !memberPost(ev) {
+		return
+	}
+	trackPost(cfg, ev.User, ev.Channel, ev.TimeStamp, ev.Text)
+}
+
+// memberPost reports whether ev is a new message from a member in a channel,
+// rather than an edit, a deletion, a bot's or one in a DM.
+func memberPost(ev *slackevents.MessageEvent) bool {
+	if ev.User == "" || ev.BotID != "" {
+		return false
+	}
+	switch ev.SubType {
+	case "", "thread_broadcast", "file_share":
+	default:
+		return false
+	}
+	return ev.ChannelType != slack.TYPE_IM && ev.ChannelType != "mpim"
+}
+
+func trackPost(cfg config.SpamFeed, author, channel, ts, text string) (fingerprint.Fingerprint, bool) {
+	f, ok := fingerprint.Of(text, cfg.Duplicates.MinLength)
+	if ok {
+		recentPosts.Add(author, fingerprint.Post{Channel: channel, Timestamp: ts, Fingerprint: f, At: tsTime(ts)}, cfg.Duplicates.Window)
+	}
+	return f, ok
+}
+
+// tsTime is when a message with Slack timestamp ts was posted, or now if ts
+// isn't one.
+func tsTime(ts string) time.Time {
+	secs, frac, _ := strings.Cut(ts, ".")
+	s, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	us, _ := strconv.ParseInt((frac + "000000")[:6], 10, 64)
+	return time.Unix(s, us*int64(time.Microsecond))
+}
+
+func duplicatesSignal() signal {
+	// See emailDomainSignal for why this needs no locking.
+	var copies, channels int
+	return signal{
+		name:       "duplicates",
+		detailed:   true,
+		perMessage: true,
+		weight:     func(cfg config.SpamFeed) int { return cfg.AnomalyScores.Duplicates },
+		describe: func(ctx context.Context, score int) string {
+			window := config.FromContext(ctx).SpamFeed.Duplicates.Window
+			return text(ctx, messages.REASON_PREFIX+"duplicates", messages.Data{Score: score, Copies: copies, Channels: channels, Window: window})
+		},
+		evaluate: func(ctx context.Context, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client) (int, error) {
+			copies, channels = duplicateScore(ctx, opMsg)
+			cfg := config.FromContext(ctx).SpamFeed
+			if cfg.AnomalyScores.Duplicates == 0 || channels < cfg.Duplicates.MinChannels {
+				return 0, nil
+			}
+			return cfg.AnomalyScores.Duplicates, nil
+		},
+	}
+}
+
+// duplicateScore counts the OP's recent posts that are copies of opMsg,
+// itself included, and how many channels they're in. The OP's message is
+// tracked first in case Penny missed its event, say across a restart.
+func duplicateScore(ctx context.Context, opMsg slack.Message) (copies, channels int) {
+	cfg := config.FromContext(ctx).SpamFeed
+	if cfg.AnomalyScores.Duplicates == 0 {
+		return 0, 0
+	}
+	f, ok := trackPost(cfg, opMsg.User, opMsg.Channel, opMsg.Timestamp, opMsg.Text)
+	if !ok {
+		return 0, 0
+	}
+	posts, channels := recentPosts.Copies(opMsg.User, f, cfg.Duplicates.MaxDistance, cfg.Duplicates.Window, time.Now())
+	return len(posts), channels
+}
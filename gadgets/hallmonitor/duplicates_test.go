@@ -0,0 +1,76 @@
+package hallmonitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/xortim/penny/pkg/fingerprint"
+)
+
+// useRecentPosts starts the test with no recent posts tracked.
+func useRecentPosts(t *testing.T) {
+	t.Helper()
+	prev := recentPosts
+	recentPosts = fingerprint.NewTracker()
+	t.Cleanup(func() { recentPosts = prev })
+}
+
+func TestDuplicatesSignal(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.anomaly_scores.duplicates": 3,
+		"spam_feed.duplicates.window":         time.Hour,
+		"spam_feed.duplicates.min_channels":   3,
+		"spam_feed.duplicates.max_distance":   6,
+		"spam_feed.duplicates.min_length":     20,
+	})
+	useRecentPosts(t)
+
+	const spam = "Free crypto for the first 100 members, claim yours now: <https://scam.example/claim>"
+	ts := func(offset time.Duration) string {
+		return fmt.Sprintf("%d.000100", time.Now().Add(offset).Unix())
+	}
+	for _, ev := range []slackevents.MessageEvent{
+		{User: "U_SPAM", Channel: "C1", ChannelType: "channel", TimeStamp: ts(-3 * time.Minute), Text: spam},
+		{User: "U_SPAM", Channel: "C2", ChannelType: "channel", TimeStamp: ts(-2 * time.Minute), Text: strings.ToUpper(spam)},
+		{User: "U_SPAM", Channel: "D1", ChannelType: "im", TimeStamp: ts(-2 * time.Minute), Text: spam},
+		{User: "U_SPAM", Channel: "C4", ChannelType: "channel", SubType: "message_changed", TimeStamp: ts(-time.Minute), Text: spam},
+		{User: "U_SPAM", Channel: "C5", ChannelType: "channel", BotID: "B1", TimeStamp: ts(-time.Minute), Text: spam},
+		{User: "U_SPAM", Channel: "C6", ChannelType: "channel", TimeStamp: ts(-2 * time.Hour), Text: spam},
+		{User: "U_OTHER", Channel: "C7", ChannelType: "channel", TimeStamp: ts(-time.Minute), Text: spam},
+	} {
+		ObserveMessage(&ev)
+	}
+	if n := TrackedPosts(); n != 3 {
+		t.Errorf("TrackedPosts() = %d, want C1, C2 and C7's", n)
+	}
+
+	evaluate := func(user, channel string) signalResult {
+		op := slack.Message{Msg: slack.Msg{User: user, Channel: channel, Timestamp: ts(0), Text: spam}}
+		return evaluateSignals(context.Background(), []signal{duplicatesSignal()}, op, nil, nil, zerolog.Nop()).results[0]
+	}
+	r := evaluate("U_SPAM", "C3")
+	if r.score != 3 || !r.detailed {
+		t.Fatalf("result = %+v, want a detailed score of 3", r)
+	}
+	if want := "posted 3 copies across 3 channels within 1h0m0s: 3"; r.reason != want {
+		t.Errorf("reason = %q, want %q", r.reason, want)
+	}
+	if r := evaluate("U_OTHER", "C8"); r.score != 0 {
+		t.Errorf("score for 2 channels = %d, want 0", r.score)
+	}
+}
+
+func TestTsTime(t *testing.T) {
+	if got, want := tsTime("1639843883.000100"), time.Unix(1639843883, 100*int64(time.Microsecond)); !got.Equal(want) {
+		t.Errorf("tsTime() = %v, want %v", got, want)
+	}
+	if got := tsTime("not a ts"); time.Since(got) > time.Minute {
+		t.Errorf("tsTime() of a bad ts = %v, want now", got)
+	}
+}
@@ -0,0 +1,154 @@
+package hallmonitor
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/emaildomains"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// Why an email domain is suspect, in order of precedence. Each is also the
+// suffix of its debug line's message ID.
+const (
+	DOMAIN_DISPOSABLE = "disposable"
+	DOMAIN_RECENT     = "recent"
+	DOMAIN_UNEXPECTED = "unexpected"
+)
+
+// domainFinding is what the email_domain signal found about an author.
+type domainFinding struct {
+	domain   string
+	kind     string // one of DOMAIN_*, or "" if the domain isn't suspect
+	removals int    // for DOMAIN_RECENT
+}
+
+// emailDomainSignal scores the author's email domain. Its debug line names the
+// domain, never the full address, so it's shown in the verdict as well.
+func emailDomainSignal() signal {
+	// A signal is built per report and described only after it's evaluated,
+	// so the finding can be handed over without locking.
+	var found domainFinding
+	return signal{
+		name:     "email_domain",
+		detailed: true,
+		weight:   func(cfg config.SpamFeed) int { return cfg.AnomalyScores.EmailDomain },
+		describe: func(ctx context.Context, score int) string {
+			return text(ctx, messages.REASON_PREFIX+"email_domain."+found.kind, messages.Data{Score: score, Domain: found.domain, Removals: found.removals})
+		},
+		evaluate: func(ctx context.Context, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client) (int, error) {
+			var err error
+			found, err = checkEmailDomain(ctx, opMsg.User, api)
+			if err != nil || found.kind == "" {
+				return 0, err
+			}
+			return config.FromContext(ctx).SpamFeed.AnomalyScores.EmailDomain, nil
+		},
+	}
+}
+
+// checkEmailDomain looks up uid's email domain, which needs the
+// users:read.email scope, and whether it's disposable, had messages removed
+// recently, or isn't one of the expected domains. Authors without an email,
+// like bots, aren't suspect.
+func checkEmailDomain(ctx context.Context, uid string, api slackclient.Client) (domainFinding, error) {
+	cfg := config.FromContext(ctx).SpamFeed
+	if cfg.AnomalyScores.EmailDomain == 0 {
+		return domainFinding{}, nil
+	}
+	user, err := api.GetUserInfoContext(ctx, uid)
+	if err != nil {
+		return domainFinding{}, err
+	}
+	f := domainFinding{domain: emaildomains.Of(user.Profile.Email)}
+	if f.domain == "" {
+		return f, nil
+	}
+
+	d := cfg.EmailDomain
+	if emaildomains.IsDisposable(f.domain) || disposableLists.contains(d.DisposableList, f.domain) {
+		f.kind = DOMAIN_DISPOSABLE
+		return f, nil
+	}
+	// Everyone shares the big providers, so their removals say nothing about
+	// the next author.
+	if caseStore != nil && d.RecentRemovals > 0 && !emaildomains.IsFree(f.domain) {
+		n, err := caseStore.CountByDomain(ctx, f.domain, messages.VERDICT_REMOVED, time.Now().Add(-d.RecentWindow))
+		if err != nil {
+			return f, err
+		}
+		if n >= d.RecentRemovals {
+			f.kind, f.removals = DOMAIN_RECENT, n
+			return f, nil
+		}
+	}
+	if len(d.Expected) != 0 && !expectedSet(d.Expected).Contains(f.domain) {
+		f.kind = DOMAIN_UNEXPECTED
+	}
+	return f, nil
+}
+
+func expectedSet(domains []string) emaildomains.Set {
+	s := make(emaildomains.Set, len(domains))
+	for _, d := range domains {
+		s[d] = true
+	}
+	return s
+}
+
+// authorDomain is uid's email domain for their case record, or "" if it's
+// unknown. Cases only need it for the email_domain signal's recent check, so
+// it's only looked up while that's on.
+func authorDomain(ctx context.Context, uid string, api slackclient.Client) string {
+	cfg := config.FromContext(ctx).SpamFeed
+	if caseStore == nil || cfg.AnomalyScores.EmailDomain == 0 || cfg.EmailDomain.RecentRemovals == 0 {
+		return ""
+	}
+	user, err := api.GetUserInfoContext(ctx, uid)
+	if err != nil {
+		log.Warn().Err(err).Str("op_user", uid).Msg("failed to look up email domain for case")
+		return ""
+	}
+	return emaildomains.Of(user.Profile.Email)
+}
+
+// disposableLists caches spam_feed.email_domain.disposable_list, reading it
+// again whenever it's modified, so the list can be updated without a restart.
+var disposableLists domainListCache
+
+type domainListCache struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	set     emaildomains.Set
+}
+
+// contains reports whether domain is on the list at path. A list that can't
+// be read is logged and treated as empty.
+func (c *domainListCache) contains(path, domain string) bool {
+	if path == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("failed to read disposable email domain list")
+		return false
+	}
+	if path != c.path || !info.ModTime().Equal(c.modTime) {
+		set, err := emaildomains.ReadFile(path)
+		if err != nil {
+			log.Error().Err(err).Str("path", path).Msg("failed to read disposable email domain list")
+			return false
+		}
+		c.path, c.modTime, c.set = path, info.ModTime(), set
+	}
+	return c.set.Contains(domain)
+}
@@ -0,0 +1,137 @@
+package hallmonitor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// userWithEmail returns a GetUserInfo stub for a user with email.
+func userWithEmail(email string) func(string) (*slack.User, error) {
+	return func(user string) (*slack.User, error) {
+		u := &slack.User{ID: user}
+		u.Profile.Email = email
+		return u, nil
+	}
+}
+
+func TestCheckEmailDomain(t *testing.T) {
+	extra := filepath.Join(t.TempDir(), "disposable.txt")
+	if err := os.WriteFile(extra, []byte("# ours\nthrowaway.example\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := cases.NewMemoryStore()
+	for _, c := range []*cases.Case{
+		{Author: "U1", Domain: "spam.example", Verdict: messages.VERDICT_REMOVED},
+		{Author: "U2", Domain: "spam.example", Verdict: messages.VERDICT_REMOVED},
+		{Author: "U3", Domain: "gmail.com", Verdict: messages.VERDICT_REMOVED},
+		{Author: "U4", Domain: "gmail.com", Verdict: messages.VERDICT_REMOVED},
+	} {
+		if err := store.CreateCase(context.Background(), c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	UseCases(store)
+	t.Cleanup(func() { UseCases(nil) })
+
+	tests := []struct {
+		name       string
+		disabled   bool
+		expected   []string
+		email      string
+		getUserErr error
+		want       domainFinding
+		wantErr    bool
+	}{
+		{name: "disposable", email: "spam@Mailinator.com", want: domainFinding{domain: "mailinator.com", kind: DOMAIN_DISPOSABLE}},
+		{name: "on the disposable list", email: "spam@mx.throwaway.example", want: domainFinding{domain: "mx.throwaway.example", kind: DOMAIN_DISPOSABLE}},
+		{name: "recently removed", email: "new@spam.example", want: domainFinding{domain: "spam.example", kind: DOMAIN_RECENT, removals: 2}},
+		{name: "free provider is never recent", email: "someone@gmail.com", want: domainFinding{domain: "gmail.com"}},
+		{name: "unexpected", expected: []string{"corp.example"}, email: "someone@other.example", want: domainFinding{domain: "other.example", kind: DOMAIN_UNEXPECTED}},
+		{name: "expected subdomain", expected: []string{"corp.example"}, email: "someone@eu.corp.example", want: domainFinding{domain: "eu.corp.example"}},
+		{name: "no email", expected: []string{"corp.example"}},
+		{name: "score 0 skips the lookup", disabled: true, getUserErr: errors.New("should not be called")},
+		{name: "GetUserInfo error", getUserErr: errors.New("user not found"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := 2
+			if tt.disabled {
+				score = 0
+			}
+			setupViperConfig(t, map[string]interface{}{
+				"spam_feed.anomaly_scores.email_domain":  score,
+				"spam_feed.email_domain.disposable_list": extra,
+				"spam_feed.email_domain.recent_removals": 2,
+				"spam_feed.email_domain.recent_window":   time.Hour,
+				"spam_feed.email_domain.expected":        tt.expected,
+			})
+			mock := &slackclient.MockClient{
+				GetUserInfoFn: func(user string) (*slack.User, error) {
+					if tt.getUserErr != nil {
+						return nil, tt.getUserErr
+					}
+					return userWithEmail(tt.email)(user)
+				},
+			}
+
+			got, err := checkEmailDomain(context.Background(), "U_OP", mock)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkEmailDomain() error = %v, want an error = %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("checkEmailDomain() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailDomainSignalNamesTheDomain(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{"spam_feed.anomaly_scores.email_domain": 2})
+	mock := &slackclient.MockClient{GetUserInfoFn: userWithEmail("someone@yopmail.com")}
+
+	eval := evaluateSignals(context.Background(), []signal{emailDomainSignal()}, slack.Message{Msg: slack.Msg{User: "U_OP"}}, mock, mock, zerolog.Nop())
+	r := eval.results[0]
+	if r.score != 2 || !r.detailed {
+		t.Fatalf("result = %+v, want a detailed score of 2", r)
+	}
+	if !strings.Contains(r.reason, "yopmail.com") || strings.Contains(r.reason, "someone") {
+		t.Errorf("reason = %q, want the domain but not the address", r.reason)
+	}
+}
+
+func TestDomainListCacheReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disposable.txt")
+	if err := os.WriteFile(path, []byte("first.example\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var c domainListCache
+	if !c.contains(path, "first.example") {
+		t.Fatal("contains() = false for a listed domain")
+	}
+
+	if err := os.WriteFile(path, []byte("second.example\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if c.contains(path, "first.example") || !c.contains(path, "second.example") {
+		t.Error("contains() didn't pick up the modified list")
+	}
+	if c.contains(filepath.Join(t.TempDir(), "missing.txt"), "second.example") {
+		t.Error("contains() = true for a list that doesn't exist")
+	}
+}
@@ -0,0 +1,142 @@
+package hallmonitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/conversations"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/metrics"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// How escalations reach the moderators, set by spam_feed.escalation.notify.
+const (
+	ESCALATE_THREAD = "thread" // mention the user group and moderators in the spam-feed thread
+	ESCALATE_DM     = "dm"     // a direct message to each moderator
+	ESCALATE_BOTH   = "both"
+)
+
+const defaultEscalationWindow = time.Hour
+
+// escalationLimiter caps how many escalations ping the moderators per window,
+// so a raid of borderline reports doesn't page them a hundred times. Held back
+// escalations are counted and reported with the next ping.
+type escalationLimiter struct {
+	mu         sync.Mutex
+	pings      []time.Time
+	suppressed int
+	now        func() time.Time
+}
+
+var escalations = newEscalationLimiter(time.Now)
+
+func newEscalationLimiter(now func() time.Time) *escalationLimiter {
+	return &escalationLimiter{now: now}
+}
+
+// allow reports whether an escalation may ping now and records it if so,
+// returning how many were held back since the last ping.
+func (l *escalationLimiter) allow(limit int, window time.Duration) (allowed bool, suppressed int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if window <= 0 {
+		window = defaultEscalationWindow
+	}
+	now := l.now()
+	kept := l.pings[:0]
+	for _, at := range l.pings {
+		if now.Sub(at) < window {
+			kept = append(kept, at)
+		}
+	}
+	l.pings = kept
+
+	if limit > 0 && len(l.pings) >= limit {
+		l.suppressed++
+		return false, 0
+	}
+	l.pings = append(l.pings, now)
+	suppressed, l.suppressed = l.suppressed, 0
+	return true, suppressed
+}
+
+// shouldEscalate reports whether a report that wasn't removed scored within
+// spam_feed.escalation.band of its threshold.
+func shouldEscalate(ctx context.Context, score, threshold int) bool {
+	e := config.FromContext(ctx).SpamFeed.Escalation
+	if e.Band <= 0 || (e.UserGroupID == "" && len(e.Moderators) == 0) {
+		return false
+	}
+	return score < threshold && score >= threshold-e.Band
+}
+
+// escalate asks the moderators to look at the report in spamFeedMsg's thread,
+// unless the escalation limit has been reached. It reports whether anyone was
+// pinged.
+func escalate(ctx context.Context, v verdict, spamFeedMsg slack.Message, feed string, api slackclient.Client) bool {
+	e := config.FromContext(ctx).SpamFeed.Escalation
+	allowed, suppressed := escalations.allow(e.MaxPings, e.Window)
+	if !allowed {
+		log.Info().Str("op_user", v.op.User).Int("score", v.eval.score).Msg("escalation held back, limit reached")
+		metrics.Escalations.WithLabelValues(feed, metrics.RESULT_SUPPRESSED).Inc()
+		return false
+	}
+
+	data := messages.Data{
+		Channel:    v.op.Channel,
+		Permalink:  v.permalink,
+		Score:      v.eval.score,
+		Threshold:  v.threshold,
+		Suppressed: suppressed,
+	}
+	var pinged, failed bool
+	if e.Notify != ESCALATE_DM {
+		if e.UserGroupID != "" {
+			data.Mentions = append(data.Mentions, fmt.Sprintf("<!subteam^%s>", e.UserGroupID))
+		}
+		for _, uid := range e.Moderators {
+			data.Mentions = append(data.Mentions, mention(uid))
+		}
+		if _, _, err := conversations.ThreadedReplyToMsg(spamFeedMsg, text(ctx, messages.ESCALATION, data), api); err != nil {
+			log.Error().Err(err).Msg("failed to escalate in the spam feed thread")
+			failed = true
+		} else {
+			pinged = true
+		}
+		data.Mentions = nil
+	}
+	if e.Notify == ESCALATE_DM || e.Notify == ESCALATE_BOTH {
+		for _, uid := range e.Moderators {
+			if err := directMessage(uid, text(ctx, messages.ESCALATION, data), api); err != nil {
+				log.Error().Err(err).Str("moderator", uid).Msg("failed to escalate by DM")
+				failed = true
+			} else {
+				pinged = true
+			}
+		}
+	}
+
+	if failed {
+		metrics.Escalations.WithLabelValues(feed, metrics.RESULT_ERROR).Inc()
+	} else {
+		metrics.Escalations.WithLabelValues(feed, metrics.RESULT_OK).Inc()
+	}
+	return pinged
+}
+
+// directMessage sends msg to uid in their DM with Penny.
+func directMessage(uid, msg string, api slackclient.Client) error {
+	channel, _, _, err := api.OpenConversation(&slack.OpenConversationParameters{Users: []string{uid}})
+	if err != nil {
+		return fmt.Errorf("opening a DM with %s: %w", uid, err)
+	}
+	_, _, err = api.PostMessage(channel.ID, slack.MsgOptionText(msg, false))
+	return err
+}
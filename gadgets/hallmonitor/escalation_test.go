@@ -0,0 +1,199 @@
+package hallmonitor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/metrics"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// useEscalations swaps the package escalation limiter for l for the duration of the test.
+func useEscalations(t *testing.T, l *escalationLimiter) {
+	t.Helper()
+	prev := escalations
+	escalations = l
+	t.Cleanup(func() { escalations = prev })
+}
+
+func TestEscalationLimiterAllow(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newEscalationLimiter(clock.now)
+
+	for i, want := range []bool{true, true, false, false} {
+		if allowed, _ := l.allow(2, time.Hour); allowed != want {
+			t.Errorf("allow() #%d = %v, want %v", i, allowed, want)
+		}
+	}
+
+	clock.advance(time.Hour)
+	allowed, suppressed := l.allow(2, time.Hour)
+	if !allowed || suppressed != 2 {
+		t.Errorf("allow() after the window = %v, %d, want true with 2 held back", allowed, suppressed)
+	}
+	if _, suppressed := l.allow(2, time.Hour); suppressed != 0 {
+		t.Errorf("allow() reported %d held back again, want 0", suppressed)
+	}
+
+	unlimited := newEscalationLimiter(clock.now)
+	for i := 0; i < 10; i++ {
+		if allowed, _ := unlimited.allow(0, time.Hour); !allowed {
+			t.Fatalf("allow() #%d with no limit = false, want true", i)
+		}
+	}
+}
+
+func TestShouldEscalate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		score  int
+		want   bool
+	}{
+		{name: "disabled", config: map[string]interface{}{"spam_feed.escalation.moderators": []string{"U0123ABCD"}}, score: 4},
+		{name: "no one to ping", config: map[string]interface{}{"spam_feed.escalation.band": 2}, score: 4},
+		{name: "just below the threshold", score: 4, want: true},
+		{name: "bottom of the band", score: 3, want: true},
+		{name: "below the band", score: 2},
+		{name: "at the threshold", score: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := map[string]interface{}{
+				"spam_feed.max_anomaly_score":        5,
+				"spam_feed.escalation.band":          2,
+				"spam_feed.escalation.user_group_id": "S0123ABCD",
+			}
+			if tt.config != nil {
+				cfg = map[string]interface{}{"spam_feed.max_anomaly_score": 5}
+				for k, v := range tt.config {
+					cfg[k] = v
+				}
+			}
+			setupViperConfig(t, cfg)
+			if got := shouldEscalate(context.Background(), tt.score, 5); got != tt.want {
+				t.Errorf("shouldEscalate(%d) = %v, want %v", tt.score, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscalate(t *testing.T) {
+	v := verdict{
+		outcome:   messages.VERDICT_KEPT,
+		eval:      evaluation{score: 4},
+		threshold: 5,
+		op:        slack.Message{Msg: slack.Msg{Channel: "C_OP", User: "U_OP"}},
+		permalink: "https://example.slack.com/archives/C_OP/p1",
+	}
+	spamFeedMsg := slack.Message{Msg: slack.Msg{Channel: "C_SPAM_FEED", Timestamp: "1111.0001"}}
+
+	tests := []struct {
+		name       string
+		notify     string
+		wantThread bool
+		wantDMs    int
+	}{
+		{name: "thread by default", wantThread: true},
+		{name: "dm only", notify: ESCALATE_DM, wantDMs: 2},
+		{name: "both", notify: ESCALATE_BOTH, wantThread: true, wantDMs: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupViperConfig(t, map[string]interface{}{
+				"spam_feed.max_anomaly_score":        5,
+				"spam_feed.escalation.band":          1,
+				"spam_feed.escalation.user_group_id": "S0123ABCD",
+				"spam_feed.escalation.moderators":    []string{"U0123ABCD", "U0456EFGH"},
+				"spam_feed.escalation.notify":        tt.notify,
+			})
+			useEscalations(t, newEscalationLimiter(time.Now))
+
+			var thread string
+			dms := 0
+			mock := &slackclient.MockClient{
+				OpenConversationFn: func(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error) {
+					return &slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "D_" + params.Users[0]}}}, false, true, nil
+				},
+				PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+					_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+					text := values.Get("text")
+					switch {
+					case channelID == "C_SPAM_FEED":
+						thread = text
+					case strings.HasPrefix(channelID, "D_"):
+						dms++
+						if strings.Contains(text, "<!subteam^") {
+							t.Errorf("DM %q mentions the user group", text)
+						}
+					}
+					return channelID, "ts", nil
+				},
+			}
+
+			escalate(context.Background(), v, spamFeedMsg, "spam-feed", mock)
+
+			if got := thread != ""; got != tt.wantThread {
+				t.Fatalf("escalate() posted in the thread = %v, want %v", got, tt.wantThread)
+			}
+			if tt.wantThread {
+				for _, want := range []string{"<!subteam^S0123ABCD>", "<@U0123ABCD>", "<@U0456EFGH>", "4/5", v.permalink} {
+					if !strings.Contains(thread, want) {
+						t.Errorf("escalation %q is missing %q", thread, want)
+					}
+				}
+			}
+			if dms != tt.wantDMs {
+				t.Errorf("escalate() sent %d DMs, want %d", dms, tt.wantDMs)
+			}
+		})
+	}
+}
+
+func TestEscalateRateLimited(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.max_anomaly_score":        5,
+		"spam_feed.escalation.band":          1,
+		"spam_feed.escalation.user_group_id": "S0123ABCD",
+		"spam_feed.escalation.max_pings":     1,
+		"spam_feed.escalation.window":        "1h",
+	})
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	useEscalations(t, newEscalationLimiter(clock.now))
+
+	var posts []string
+	mock := &slackclient.MockClient{
+		PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+			_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+			posts = append(posts, values.Get("text"))
+			return channelID, "ts", nil
+		},
+	}
+	v := verdict{eval: evaluation{score: 4}, threshold: 5, op: slack.Message{Msg: slack.Msg{Channel: "C_OP"}}}
+	spamFeedMsg := slack.Message{Msg: slack.Msg{Channel: "C_SPAM_FEED", Timestamp: "1111.0001"}}
+	suppressed := metrics.Escalations.WithLabelValues("raid-feed", metrics.RESULT_SUPPRESSED)
+	before := testutil.ToFloat64(suppressed)
+
+	for i := 0; i < 4; i++ {
+		escalate(context.Background(), v, spamFeedMsg, "raid-feed", mock)
+	}
+	if len(posts) != 1 {
+		t.Fatalf("escalate() pinged %d times during a raid, want 1", len(posts))
+	}
+	if got := testutil.ToFloat64(suppressed) - before; got != 3 {
+		t.Errorf("suppressed escalations = %v, want 3", got)
+	}
+
+	clock.advance(time.Hour)
+	escalate(context.Background(), v, spamFeedMsg, "raid-feed", mock)
+	if len(posts) != 2 || !strings.Contains(posts[1], "3 more held back") {
+		t.Errorf("escalate() after the window posted %q, want it to mention the 3 held back", posts)
+	}
+}
@@ -2,6 +2,13 @@ package hallmonitor
 
 import (
 	"github.com/gadget-bot/gadget/router"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+var (
+	botClient  slackclient.Client
+	userClient slackclient.Client
+	queue      *Queue
 )
 
 func GetChannelMessageRoutes() []router.ChannelMessageRoute {
@@ -9,3 +16,36 @@ func GetChannelMessageRoutes() []router.ChannelMessageRoute {
 		*monitorSpamFeedMessages(),
 	}
 }
+
+func GetSlashCommandRoutes() []router.SlashCommandRoute {
+	return []router.SlashCommandRoute{
+		*pennyCommand(),
+	}
+}
+
+// UseClients replaces the per-event Slack clients Gadget hands to hallmonitor's
+// routes. The server uses it so every event shares one set of decorated clients
+// (and therefore one set of rate limit budgets). Call it before serving.
+func UseClients(bot, user slackclient.Client) {
+	botClient = bot
+	userClient = user
+}
+
+// UseQueue hands spam-feed reports to q instead of processing them on Gadget's
+// per-event goroutine. Call it before serving.
+func UseQueue(q *Queue) {
+	queue = q
+}
+
+// clientsFor returns the clients configured with UseClients, falling back to
+// the ones on the handler context.
+func clientsFor(ctx router.HandlerContext) (slackclient.Client, slackclient.Client) {
+	var api, userApi slackclient.Client = botClient, userClient
+	if api == nil {
+		api = ctx.BotClient
+	}
+	if userApi == nil && ctx.UserClient != nil {
+		userApi = ctx.UserClient
+	}
+	return api, userApi
+}
@@ -0,0 +1,163 @@
+package hallmonitor
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/lookalike"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// admins are the names of the workspace's admins and owners, which the
+// impersonation signal protects.
+var admins = &roster{names: map[string][]string{}}
+
+// roster maps members to their display and real names.
+type roster struct {
+	mu    sync.RWMutex
+	names map[string][]string
+}
+
+// RefreshAdmins lists the workspace's members to find its admins and owners,
+// returning how many there are.
+func RefreshAdmins(ctx context.Context, api slackclient.Client) (int, error) {
+	users, err := api.GetUsersContext(ctx, slack.GetUsersOptionLimit(200))
+	if err != nil {
+		return 0, err
+	}
+	names := map[string][]string{}
+	for _, u := range users {
+		if isAdmin(&u) {
+			names[u.ID] = namesOf(&u)
+		}
+	}
+	admins.mu.Lock()
+	defer admins.mu.Unlock()
+	admins.names = names
+	return len(names), nil
+}
+
+// UpdateAdmin adds, renames or removes user in the admins as their
+// user_change event says, so the roster doesn't wait for the next refresh.
+func UpdateAdmin(user *slack.User) {
+	admins.mu.Lock()
+	defer admins.mu.Unlock()
+	if isAdmin(user) {
+		admins.names[user.ID] = namesOf(user)
+	} else {
+		delete(admins.names, user.ID)
+	}
+}
+
+// protectedName is a name the impersonation signal protects, and whose it is.
+type protectedName struct {
+	uid  string // empty for spam_feed.impersonation.protected_names
+	name string
+}
+
+// has reports whether uid is on the roster.
+func (r *roster) has(uid string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.names[uid]
+	return ok
+}
+
+func (r *roster) list() []protectedName {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []protectedName
+	for uid, names := range r.names {
+		for _, n := range names {
+			out = append(out, protectedName{uid, n})
+		}
+	}
+	return out
+}
+
+func isAdmin(u *slack.User) bool {
+	return !u.Deleted && !u.IsBot && (u.IsAdmin || u.IsOwner || u.IsPrimaryOwner)
+}
+
+// namesOf is user's display and real names, leaving out empty and repeated ones.
+func namesOf(user *slack.User) []string {
+	var names []string
+	for _, n := range []string{user.Profile.DisplayName, user.RealName, user.Profile.RealName} {
+		if n != "" && !slices.Contains(names, n) {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+func impersonationSignal() signal {
+	// See emailDomainSignal for why this needs no locking.
+	var resembles string
+	return signal{
+		name:     "impersonation",
+		detailed: true,
+		weight:   func(cfg config.SpamFeed) int { return cfg.AnomalyScores.Impersonation },
+		describe: func(ctx context.Context, score int) string {
+			return text(ctx, messages.REASON_PREFIX+"impersonation", messages.Data{Score: score, Name: resembles})
+		},
+		evaluate: func(ctx context.Context, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client) (int, error) {
+			var err error
+			resembles, err = impersonated(ctx, opMsg.User, api)
+			if err != nil || resembles == "" {
+				return 0, err
+			}
+			return config.FromContext(ctx).SpamFeed.AnomalyScores.Impersonation, nil
+		},
+	}
+}
+
+// impersonated returns the protected name that one of uid's names resembles,
+// or "" if none do. Names are compared by their lookalike skeletons, so
+// homoglyphs, case, spacing and emoji don't hide a match. Admins, owners and
+// bots aren't checked, nor is anyone against their own names.
+func impersonated(ctx context.Context, uid string, api slackclient.Client) (string, error) {
+	cfg := config.FromContext(ctx).SpamFeed
+	if cfg.AnomalyScores.Impersonation == 0 {
+		return "", nil
+	}
+	user, err := api.GetUserInfoContext(ctx, uid)
+	if err != nil {
+		return "", err
+	}
+	if user.IsBot || user.IsAdmin || user.IsOwner || user.IsPrimaryOwner {
+		return "", nil
+	}
+
+	protected := admins.list()
+	for _, id := range cfg.Impersonation.ProtectedUsers {
+		if id == uid {
+			return "", nil
+		}
+		p, err := api.GetUserInfoContext(ctx, id)
+		if err != nil {
+			log.Warn().Err(err).Str("user", id).Msg("failed to look up protected user")
+			continue
+		}
+		for _, n := range namesOf(p) {
+			protected = append(protected, protectedName{id, n})
+		}
+	}
+	for _, n := range cfg.Impersonation.ProtectedNames {
+		protected = append(protected, protectedName{name: n})
+	}
+
+	for _, name := range namesOf(user) {
+		for _, p := range protected {
+			if p.uid != uid && lookalike.Resembles(name, p.name, cfg.Impersonation.MaxDistance) {
+				log.Info().Str("op_user", uid).Str("protected_user", p.uid).Msg("author's name resembles a protected name")
+				return p.name, nil
+			}
+		}
+	}
+	return "", nil
+}
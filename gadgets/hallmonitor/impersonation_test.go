@@ -0,0 +1,121 @@
+package hallmonitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// useAdmins swaps the admins roster for names for the duration of the test.
+func useAdmins(t *testing.T, names map[string][]string) {
+	t.Helper()
+	admins.mu.Lock()
+	prev := admins.names
+	admins.names = names
+	admins.mu.Unlock()
+	t.Cleanup(func() {
+		admins.mu.Lock()
+		admins.names = prev
+		admins.mu.Unlock()
+	})
+}
+
+func TestImpersonated(t *testing.T) {
+	members := map[string]*slack.User{
+		"U_MOD": {ID: "U_MOD", RealName: "Grace Hopper"},
+	}
+	tests := []struct {
+		name       string
+		op         slack.User
+		getUserErr error
+		want       string
+		wantErr    bool
+	}{
+		{name: "admin homoglyphs", op: slack.User{RealName: "T1m Оrtiz 🛡️"}, want: "Tim Ortiz"},
+		{name: "protected user", op: slack.User{RealName: "grace hoper"}, want: "Grace Hopper"},
+		{name: "protected name", op: slack.User{Profile: slack.UserProfile{DisplayName: "Penny  Support"}}, want: "Penny Support"},
+		{name: "unrelated name", op: slack.User{RealName: "Ada Lovelace"}},
+		{name: "admins aren't checked", op: slack.User{RealName: "Tim Ortiz", IsAdmin: true}},
+		{name: "bots aren't checked", op: slack.User{RealName: "Penny Support", IsBot: true}},
+		{name: "GetUserInfo error", getUserErr: errors.New("user not found"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupViperConfig(t, map[string]interface{}{
+				"spam_feed.anomaly_scores.impersonation":  4,
+				"spam_feed.impersonation.protected_users": []string{"U_MOD"},
+				"spam_feed.impersonation.protected_names": []string{"Penny Support"},
+				"spam_feed.impersonation.max_distance":    2,
+			})
+			useAdmins(t, map[string][]string{"U_ADMIN": {"Tim Ortiz", "tim"}})
+			mock := &slackclient.MockClient{
+				GetUserInfoFn: func(user string) (*slack.User, error) {
+					if u, ok := members[user]; ok {
+						return u, nil
+					}
+					if tt.getUserErr != nil {
+						return nil, tt.getUserErr
+					}
+					op := tt.op
+					op.ID = user
+					return &op, nil
+				},
+			}
+
+			got, err := impersonated(context.Background(), "U_OP", mock)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("impersonated() error = %v, want an error = %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("impersonated() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImpersonatedSkipsOwnNames(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.anomaly_scores.impersonation": 4,
+		"spam_feed.impersonation.max_distance":   2,
+	})
+	useAdmins(t, map[string][]string{"U_FORMER": {"Tim Ortiz"}})
+	mock := &slackclient.MockClient{
+		GetUserInfoFn: func(user string) (*slack.User, error) {
+			return &slack.User{ID: user, RealName: "Tim Ortiz"}, nil
+		},
+	}
+	if got, _ := impersonated(context.Background(), "U_FORMER", mock); got != "" {
+		t.Errorf("impersonated() = %q for the name's own owner, want none", got)
+	}
+}
+
+func TestRefreshAndUpdateAdmins(t *testing.T) {
+	useAdmins(t, map[string][]string{})
+	mock := &slackclient.MockClient{
+		GetUsersContextFn: func(ctx context.Context, options ...slack.GetUsersOption) ([]slack.User, error) {
+			return []slack.User{
+				{ID: "U_OWNER", RealName: "Tim Ortiz", IsOwner: true, Profile: slack.UserProfile{DisplayName: "tim"}},
+				{ID: "U_GONE", RealName: "Former Admin", IsAdmin: true, Deleted: true},
+				{ID: "U_BOT", RealName: "Some Bot", IsAdmin: true, IsBot: true},
+				{ID: "U_MEMBER", RealName: "Ada Lovelace"},
+			}, nil
+		},
+	}
+	n, err := RefreshAdmins(context.Background(), mock)
+	if err != nil || n != 1 {
+		t.Fatalf("RefreshAdmins() = %d, %v, want 1 admin", n, err)
+	}
+	if got := admins.list(); len(got) != 2 {
+		t.Errorf("admins = %+v, want U_OWNER's display and real names", got)
+	}
+
+	UpdateAdmin(&slack.User{ID: "U_MEMBER", RealName: "Ada Lovelace", IsAdmin: true})
+	UpdateAdmin(&slack.User{ID: "U_OWNER", RealName: "Tim Ortiz"})
+	if got := admins.list(); len(got) != 1 || got[0] != (protectedName{"U_MEMBER", "Ada Lovelace"}) {
+		t.Errorf("admins = %+v, want only U_MEMBER after promoting them and demoting U_OWNER", got)
+	}
+}
@@ -0,0 +1,59 @@
+package hallmonitor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/conversations"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// How notices reach the OP, set by spam_feed.op_notice_delivery.
+const (
+	DELIVERY_THREAD    = "thread"    // a public reply in the OP's thread
+	DELIVERY_EPHEMERAL = "ephemeral" // a message only the OP sees, in the OP's channel
+	DELIVERY_DM        = "dm"        // a direct message from Penny
+)
+
+// notifyOP delivers notice about opMsg to its author. When the message is
+// being removed, private deliveries include a copy of it so a legitimate post
+// can be made again; a public thread never repeats it. If appeal isn't 0, the
+// notice has a button to appeal that case.
+func notifyOP(ctx context.Context, opMsg slack.Message, notice string, removing bool, locale string, appeal uint, api slackclient.Client) error {
+	delivery := config.FromContext(ctx).SpamFeed.OpNoticeDelivery
+	private := delivery == DELIVERY_EPHEMERAL || delivery == DELIVERY_DM
+	if removing && private && opMsg.Text != "" {
+		notice += "\n\n" + messages.Text(locale, messages.REMOVAL_COPY, messages.Data{Text: opMsg.Text})
+	}
+	var blocks []slack.Block
+	if appeal != 0 {
+		blocks = appealBlocks(notice, appeal, locale)
+	}
+
+	switch delivery {
+	case DELIVERY_EPHEMERAL:
+		_, err := api.PostEphemeral(opMsg.Channel, opMsg.User,
+			slack.MsgOptionTS(opMsg.ThreadTimestamp),
+			slack.MsgOptionText(notice, false),
+			slack.MsgOptionBlocks(blocks...),
+		)
+		return err
+	case DELIVERY_DM:
+		channel, _, _, err := api.OpenConversation(&slack.OpenConversationParameters{Users: []string{opMsg.User}})
+		if err != nil {
+			return fmt.Errorf("opening a DM with %s: %w", opMsg.User, err)
+		}
+		_, _, err = api.PostMessage(channel.ID, slack.MsgOptionText(notice, false), slack.MsgOptionBlocks(blocks...))
+		return err
+	default:
+		if blocks != nil {
+			_, _, err := conversations.ThreadedBlocksReplyToMsg(opMsg, notice, blocks, api)
+			return err
+		}
+		_, _, err := conversations.ThreadedReplyToMsg(opMsg, notice, api)
+		return err
+	}
+}
@@ -0,0 +1,97 @@
+package hallmonitor
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+func TestNotifyOP(t *testing.T) {
+	opMsg := slack.Message{
+		Msg: slack.Msg{
+			Channel:         "C_OP",
+			User:            "U_OP",
+			Timestamp:       "1111.0002",
+			ThreadTimestamp: "1111.0001",
+			Text:            "Buy cheap followers at example.com",
+		},
+	}
+
+	tests := []struct {
+		name        string
+		delivery    string
+		removing    bool
+		appeal      uint
+		openErr     error
+		wantMethod  string
+		wantChannel string
+		wantCopy    bool
+		wantErr     bool
+	}{
+		{name: "default is a thread reply", removing: true, wantMethod: "post", wantChannel: "C_OP"},
+		{name: "thread never repeats the message", delivery: DELIVERY_THREAD, removing: true, wantMethod: "post", wantChannel: "C_OP"},
+		{name: "ephemeral removal includes a copy", delivery: DELIVERY_EPHEMERAL, removing: true, wantMethod: "ephemeral", wantChannel: "C_OP", wantCopy: true},
+		{name: "ephemeral warning", delivery: DELIVERY_EPHEMERAL, wantMethod: "ephemeral", wantChannel: "C_OP"},
+		{name: "DM removal includes a copy", delivery: DELIVERY_DM, removing: true, wantMethod: "post", wantChannel: "D_OP", wantCopy: true},
+		{name: "thread with an appeal button", removing: true, appeal: 7, wantMethod: "post", wantChannel: "C_OP"},
+		{name: "DM with an appeal button", delivery: DELIVERY_DM, removing: true, appeal: 7, wantMethod: "post", wantChannel: "D_OP", wantCopy: true},
+		{name: "DM can't be opened", delivery: DELIVERY_DM, removing: true, openErr: errors.New("cannot_dm_bot"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupViperConfig(t, map[string]interface{}{"spam_feed.op_notice_delivery": tt.delivery})
+
+			var method, channel string
+			var values url.Values
+			mock := &slackclient.MockClient{
+				PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+					method, channel = "post", channelID
+					_, values, _ = slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+					return channelID, "ts", nil
+				},
+				PostEphemeralFn: func(channelID, userID string, options ...slack.MsgOption) (string, error) {
+					if userID != "U_OP" {
+						t.Errorf("PostEphemeral() user = %q, want U_OP", userID)
+					}
+					method, channel = "ephemeral", channelID
+					_, values, _ = slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+					return "ts", nil
+				},
+				OpenConversationFn: func(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error) {
+					if tt.openErr != nil {
+						return nil, false, false, tt.openErr
+					}
+					return &slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "D_OP"}}}, false, true, nil
+				},
+			}
+
+			err := notifyOP(context.Background(), opMsg, "I removed your post.", tt.removing, "", tt.appeal, mock)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("notifyOP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if method != tt.wantMethod || channel != tt.wantChannel {
+				t.Errorf("notifyOP() used %s in %s, want %s in %s", method, channel, tt.wantMethod, tt.wantChannel)
+			}
+			text := values.Get("text")
+			if !strings.HasPrefix(text, "I removed your post.") {
+				t.Errorf("notifyOP() text = %q, want the notice first", text)
+			}
+			if got := strings.Contains(text, opMsg.Text); got != tt.wantCopy {
+				t.Errorf("notifyOP() text = %q, includes a copy = %v, want %v", text, got, tt.wantCopy)
+			}
+			blocks := values.Get("blocks")
+			if got := strings.Contains(blocks, `"action_id":"`+ACTION_APPEAL+`","value":"7"`); got != (tt.appeal != 0) {
+				t.Errorf("notifyOP() blocks = %s, has an appeal button = %v, want %v", blocks, got, tt.appeal != 0)
+			}
+		})
+	}
+}
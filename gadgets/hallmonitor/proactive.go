@@ -0,0 +1,379 @@
+package hallmonitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/metrics"
+	"github.com/xortim/penny/pkg/slackclient"
+	"github.com/xortim/penny/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxTrusted caps how many trusted authors are remembered. Past it the
+// longest trusted are forgotten, one at a time, and checked again.
+const maxTrusted = 10000
+
+// untrustedTTL is how long an author found to have too few public messages is
+// remembered before they're searched for again.
+const untrustedTTL = 30 * time.Minute
+
+// members is what Penny knows about how long members have been around.
+var members = newMemberTracker()
+
+type memberTracker struct {
+	mu     sync.Mutex
+	joined map[string]time.Time
+	// trusted authors had enough public messages. Trust only grows, so
+	// they aren't searched for again unless they're forgotten; trustOrder
+	// is the order they were trusted in.
+	trusted    map[string]bool
+	trustOrder []string
+	// untrusted authors had too few public messages when last searched, at
+	// the time recorded.
+	untrusted map[string]time.Time
+	// looking are members whose public messages are being counted.
+	looking map[string]bool
+}
+
+func newMemberTracker() *memberTracker {
+	return &memberTracker{
+		joined:    make(map[string]time.Time),
+		trusted:   make(map[string]bool),
+		untrusted: make(map[string]time.Time),
+		looking:   make(map[string]bool),
+	}
+}
+
+// join records that uid joined at. Joins older than keep are forgotten.
+func (m *memberTracker) join(uid string, at time.Time, keep time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, t := range m.joined {
+		if at.Sub(t) >= keep {
+			delete(m.joined, id)
+		}
+	}
+	m.joined[uid] = at
+}
+
+// joinedWithin reports whether uid was seen joining within d of now.
+func (m *memberTracker) joinedWithin(uid string, d time.Duration, now time.Time) bool {
+	if d <= 0 {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	at, ok := m.joined[uid]
+	return ok && now.Sub(at) < d
+}
+
+func (m *memberTracker) isTrusted(uid string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.trusted[uid]
+}
+
+// trust remembers that uid had enough public messages, forgetting the longest
+// trusted authors past maxTrusted.
+func (m *memberTracker) trust(uid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.untrusted, uid)
+	if m.trusted[uid] {
+		return
+	}
+	for len(m.trustOrder) >= maxTrusted {
+		delete(m.trusted, m.trustOrder[0])
+		m.trustOrder = m.trustOrder[1:]
+	}
+	m.trusted[uid] = true
+	m.trustOrder = append(m.trustOrder, uid)
+}
+
+// distrust remembers that uid had too few public messages at now. Authors
+// searched for more than untrustedTTL ago are forgotten.
+func (m *memberTracker) distrust(uid string, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, at := range m.untrusted {
+		if now.Sub(at) >= untrustedTTL {
+			delete(m.untrusted, id)
+		}
+	}
+	m.untrusted[uid] = now
+}
+
+// startLookup marks uid's public messages as being counted, and reports
+// whether they weren't already.
+func (m *memberTracker) startLookup(uid string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.looking[uid] {
+		return false
+	}
+	m.looking[uid] = true
+	return true
+}
+
+// endLookup marks uid's public messages as counted.
+func (m *memberTracker) endLookup(uid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.looking, uid)
+}
+
+// isUntrusted reports whether uid had too few public messages within
+// untrustedTTL of now.
+func (m *memberTracker) isUntrusted(uid string, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	at, ok := m.untrusted[uid]
+	return ok && now.Sub(at) < untrustedTTL
+}
+
+// MemberJoined records that uid joined the workspace, so their messages are
+// screened for spam_feed.proactive.max_member_age, and puts them on probation.
+func MemberJoined(uid string) {
+	cfg := config.Current().SpamFeed
+	now := time.Now()
+	members.join(uid, now, cfg.Proactive.MaxMemberAge)
+	if cfg.Probation.Enabled && probation.start(uid, now, cfg.Probation) {
+		log.Info().Str("user", uid).Msg("probation started")
+	}
+}
+
+// ScreenMessage queues a screening of a member's new message if proactive
+// screening is on and its author may be low-trust. Like ObserveMessage it's
+// cheap enough to run before Slack is acknowledged.
+func ScreenMessage(ev *slackevents.MessageEvent) {
+	cfg := config.Current().SpamFeed.Proactive
+	if !cfg.Enabled || botClient == nil || !memberPost(ev) {
+		return
+	}
+	if len(cfg.ChannelIDs) != 0 && !slices.Contains(cfg.ChannelIDs, ev.Channel) {
+		return
+	}
+	if admins.has(ev.User) {
+		return
+	}
+	now := time.Now()
+	if !members.joinedWithin(ev.User, cfg.MaxMemberAge, now) && !probation.on(ev.User, config.Current().SpamFeed.Probation, now) &&
+		(cfg.MaxMessages == 0 || userClient == nil || members.isTrusted(ev.User)) {
+		return
+	}
+
+	j := job{api: botClient, userApi: userClient, ev: *ev, screen: true}
+	if queue == nil {
+		go processJob(j)
+		return
+	}
+	// Reports come first; a screening that doesn't fit is dropped.
+	if err := queue.Offer(j); err != nil {
+		log.Warn().Err(err).Str("channel_id", ev.Channel).Str("event_ts", ev.TimeStamp).Msg("shed message screening")
+	}
+}
+
+// screenMessage scores a low-trust author's message on the
+// spam_feed.proactive.signals. Messages scoring review_score or more are
+// posted to the spam feed and judged like a report, which removes them at
+// remove_score.
+func screenMessage(api slackclient.Client, userApi slackclient.Client, ev slackevents.MessageEvent) {
+	settings := config.Current()
+	cfg := settings.SpamFeed
+	logger := log.With().Str("channel_id", ev.Channel).Str("event_ts", ev.TimeStamp).Str("op_user", ev.User).Logger()
+
+	ctx, span := tracing.Tracer(tracerName).Start(context.Background(), "hallmonitor.screenMessage", trace.WithAttributes(
+		attribute.String("slack.channel", ev.Channel),
+		attribute.String("slack.event_ts", ev.TimeStamp),
+	))
+	defer span.End()
+	ctx = config.NewContext(ctx, settings)
+	logger = tracing.Logger(ctx, logger)
+	api = slackclient.NewTracingClient(ctx, api)
+	if userApi != nil {
+		userApi = slackclient.NewTracingClient(ctx, userApi)
+	}
+
+	// The spam feed's own messages are for the moderators.
+	if len(cfg.Proactive.ChannelIDs) == 0 {
+		channelInfo, err := api.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: ev.Channel})
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to get conversation info")
+			return
+		}
+		if channelInfo.NameNormalized == cfg.Channel {
+			return
+		}
+	}
+
+	low, err := lowTrust(ctx, ev.User, userApi)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to check the author's trust")
+		return
+	}
+	if !low {
+		return
+	}
+
+	var opMsg slack.Message
+	opMsg.Channel = ev.Channel
+	opMsg.Timestamp = ev.TimeStamp
+	opMsg.ThreadTimestamp = ev.ThreadTimeStamp
+	opMsg.User = ev.User
+	opMsg.Text = ev.Text
+
+	// Screenings and reports of the same author are handled one at a time.
+	burst := bursts.acquire(opMsg.User)
+	defer bursts.release(opMsg.User, burst)
+
+	eval := evaluateSignals(ctx, screeningSignals(cfg.Proactive.Signals, userApi != nil), opMsg, api, userApi, logger)
+	logger.Info().Int("anomaly_score", eval.score).Bool("deferred", eval.deferred).Msg("screened message")
+	if eval.score < cfg.Proactive.ReviewScore && !eval.deferred {
+		metrics.Screened.WithLabelValues(metrics.SCREEN_PASSED).Inc()
+		return
+	}
+	metrics.Screened.WithLabelValues(metrics.SCREEN_FLAGGED).Inc()
+
+	permalink, err := api.GetPermalinkContext(ctx, &slack.PermalinkParameters{Channel: opMsg.Channel, Ts: opMsg.Timestamp})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get the message's permalink")
+	}
+	feedMsg, err := postToFeed(ctx, text(ctx, messages.SCREENED, messages.Data{
+		OP:        mention(opMsg.User),
+		Channel:   opMsg.Channel,
+		Permalink: permalink,
+	}), api)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to post the screened message to the spam feed")
+		return
+	}
+
+	judge(ctx, report{
+		feed:      cfg.Channel,
+		feedMsg:   feedMsg,
+		op:        opMsg,
+		permalink: permalink,
+		threshold: screeningThreshold(cfg.Proactive),
+		screened:  true,
+	}, eval, api, userApi, logger)
+}
+
+// lowTrust reports whether uid joined within spam_feed.proactive.max_member_age,
+// is on probation or has fewer than max_messages public messages. Counting
+// them takes the user token, and a search, so the count is remembered: for
+// good once it's enough, and for untrustedTTL while it isn't.
+func lowTrust(ctx context.Context, uid string, userApi slackclient.Client) (bool, error) {
+	cfg := config.FromContext(ctx).SpamFeed.Proactive
+	now := time.Now()
+	if members.joinedWithin(uid, cfg.MaxMemberAge, now) || onProbation(ctx, uid) {
+		return true, nil
+	}
+	if cfg.MaxMessages == 0 || userApi == nil || members.isTrusted(uid) {
+		return false, nil
+	}
+	if members.isUntrusted(uid, now) {
+		return true, nil
+	}
+	count, err := publicMessages(ctx, uid, userApi)
+	if err != nil {
+		return false, err
+	}
+	if count < cfg.MaxMessages {
+		members.distrust(uid, now)
+		return true, nil
+	}
+	members.trust(uid)
+	return false, nil
+}
+
+// screeningSignals are the spam signals named in names, in display order.
+// Without a user token, the signals that need one are left out.
+func screeningSignals(names []string, userToken bool) []signal {
+	var signals []signal
+	for _, s := range spamSignals() {
+		if slices.Contains(names, s.name) && (userToken || !s.userToken) {
+			signals = append(signals, s)
+		}
+	}
+	return signals
+}
+
+// screeningThreshold is the score a screened message is judged against: the
+// removal score, or the review score if screened messages are never removed.
+func screeningThreshold(cfg config.Proactive) int {
+	if cfg.RemoveScore > 0 {
+		return cfg.RemoveScore
+	}
+	return cfg.ReviewScore
+}
+
+// errFeedNotFound is returned when Penny can't find the spam-feed channel.
+var errFeedNotFound = errors.New("spam-feed channel not found")
+
+// feedChannels caches the spam feed's channel ID by its name.
+var feedChannels = &channelResolver{ids: make(map[string]string)}
+
+type channelResolver struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+// id returns the ID of the channel named name, listing conversations the
+// first time it's asked.
+func (r *channelResolver) id(ctx context.Context, name string, api slackclient.Client) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, ok := r.ids[name]; ok {
+		return id, nil
+	}
+	params := &slack.GetConversationsParameters{Types: []string{"public_channel", "private_channel"}, ExcludeArchived: true}
+	for {
+		channels, cursor, err := api.GetConversations(params)
+		if err != nil {
+			return "", fmt.Errorf("listing conversations: %w", err)
+		}
+		for _, ch := range channels {
+			if ch.NameNormalized == name {
+				r.ids[name] = ch.ID
+				return ch.ID, nil
+			}
+		}
+		if cursor == "" || ctx.Err() != nil {
+			break
+		}
+		params.Cursor = cursor
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("#%s: %w", name, errFeedNotFound)
+}
+
+// FeedChannelID returns the ID of the spam-feed channel named name. The ID is
+// cached after the first lookup.
+func FeedChannelID(ctx context.Context, name string, api slackclient.Client) (string, error) {
+	return feedChannels.id(ctx, name, api)
+}
+
+// postToFeed posts msg to the spam feed and returns the posted message.
+func postToFeed(ctx context.Context, msg string, api slackclient.Client) (slack.Message, error) {
+	var posted slack.Message
+	channel, err := feedChannels.id(ctx, config.FromContext(ctx).SpamFeed.Channel, api)
+	if err != nil {
+		return posted, err
+	}
+	posted.Channel, posted.Timestamp, err = api.PostMessage(channel, slack.MsgOptionText(msg, false))
+	posted.Text = msg
+	return posted, err
+}
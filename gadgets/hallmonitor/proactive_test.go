@@ -0,0 +1,310 @@
+package hallmonitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// useMembers starts the test knowing of no joins, trusted authors or feed channels.
+func useMembers(t *testing.T) {
+	t.Helper()
+	prevMembers, prevFeeds := members, feedChannels
+	members = newMemberTracker()
+	feedChannels = &channelResolver{ids: make(map[string]string)}
+	t.Cleanup(func() { members, feedChannels = prevMembers, prevFeeds })
+}
+
+// searchCounting returns a search stub finding count messages, and how many
+// times it was called.
+func searchCounting(count int) (func(ctx context.Context, query string, params slack.SearchParameters) (*slack.SearchMessages, error), *int) {
+	var calls int
+	return func(ctx context.Context, query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+		calls++
+		return &slack.SearchMessages{Pagination: slack.Pagination{TotalCount: count}}, nil
+	}, &calls
+}
+
+func TestLowTrust(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.proactive.max_messages":   5,
+		"spam_feed.proactive.max_member_age": time.Hour,
+	})
+	useMembers(t)
+	ctx := config.NewContext(context.Background(), config.Current())
+
+	members.join("U_NEW", time.Now().Add(-time.Minute), time.Hour)
+	members.join("U_OLD", time.Now().Add(-2*time.Hour), time.Hour)
+
+	search, calls := searchCounting(100)
+	userApi := &slackclient.MockClient{SearchMessagesContextFn: search}
+	if low, err := lowTrust(ctx, "U_NEW", userApi); err != nil || !low {
+		t.Errorf("lowTrust(U_NEW) = %v, %v; want a member who just joined to be low-trust", low, err)
+	}
+	if *calls != 0 {
+		t.Errorf("searched %d times for a member who just joined", *calls)
+	}
+	for range 2 {
+		if low, err := lowTrust(ctx, "U_OLD", userApi); err != nil || low {
+			t.Errorf("lowTrust(U_OLD) = %v, %v; want an active member who joined long ago to be trusted", low, err)
+		}
+	}
+	if *calls != 1 {
+		t.Errorf("searched %d times, want trust remembered after the first", *calls)
+	}
+
+	search, calls = searchCounting(2)
+	userApi = &slackclient.MockClient{SearchMessagesContextFn: search}
+	for range 2 {
+		if low, err := lowTrust(ctx, "U_QUIET", userApi); err != nil || !low {
+			t.Errorf("lowTrust(U_QUIET) = %v, %v; want a member with 2 messages to be low-trust", low, err)
+		}
+	}
+	if *calls != 1 {
+		t.Errorf("searched %d times, want a low-trust author remembered after the first", *calls)
+	}
+	if members.isUntrusted("U_QUIET", time.Now().Add(untrustedTTL)) {
+		t.Error("low-trust author still remembered after untrustedTTL, want them searched again")
+	}
+
+	if low, err := lowTrust(ctx, "U_QUIET", nil); err != nil || low {
+		t.Errorf("lowTrust without a user token = %v, %v; want messages left uncounted", low, err)
+	}
+}
+
+func TestScreenMessage(t *testing.T) {
+	const (
+		feedID    = "C_SPAM_FEED"
+		opChan    = "C_GENERAL"
+		opUser    = "U_NEWBIE"
+		permalink = "https://orgname.slack.com/archives/C_GENERAL/p1639843883000100"
+	)
+	ev := slackevents.MessageEvent{User: opUser, Channel: opChan, ChannelType: "channel", TimeStamp: "1639843883.000100", Text: "claim your prize"}
+	baseConfig := map[string]interface{}{
+		"spam_feed.channel":                     "spam-feed",
+		"spam_feed.activity_low_watermark":      5,
+		"spam_feed.anomaly_scores.low_activity": 2,
+		"spam_feed.anomaly_scores.outside_tz":   2,
+		"spam_feed.reaction_emoji_hit":          "no_entry",
+		"spam_feed.reaction_emoji_miss":         "eyes",
+		"spam_feed.proactive.enabled":           true,
+		"spam_feed.proactive.channel_ids":       []string{opChan},
+		"spam_feed.proactive.signals":           []string{"low_activity"},
+		"spam_feed.proactive.max_messages":      5,
+		"spam_feed.proactive.review_score":      2,
+	}
+
+	// with is baseConfig with key set to value.
+	with := func(key string, value interface{}) map[string]interface{} {
+		cfg := map[string]interface{}{key: value}
+		for k, v := range baseConfig {
+			if k != key {
+				cfg[k] = v
+			}
+		}
+		return cfg
+	}
+
+	type posted struct{ channel, text string }
+	newMock := func(posts *[]posted, reactions *[]string) *slackclient.MockClient {
+		search, _ := searchCounting(1)
+		return &slackclient.MockClient{
+			SearchMessagesContextFn: search,
+			GetPermalinkContextFn: func(ctx context.Context, params *slack.PermalinkParameters) (string, error) {
+				return permalink, nil
+			},
+			GetConversationsFn: func(params *slack.GetConversationsParameters) ([]slack.Channel, string, error) {
+				feed := slack.Channel{}
+				feed.ID, feed.NameNormalized = feedID, "spam-feed"
+				return []slack.Channel{feed}, "", nil
+			},
+			PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+				_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+				*posts = append(*posts, posted{channelID, values.Get("text")})
+				return channelID, "2222222222.000100", nil
+			},
+			AddReactionFn: func(name string, item slack.ItemRef) error {
+				*reactions = append(*reactions, name)
+				return nil
+			},
+		}
+	}
+
+	t.Run("posts suspect messages for review", func(t *testing.T) {
+		setupViperConfig(t, baseConfig)
+		useMembers(t)
+		var posts []posted
+		var reactions []string
+		mock := newMock(&posts, &reactions)
+
+		// DeleteMessageFn is unset, so a removal would panic.
+		screenMessage(mock, mock, ev)
+
+		if len(posts) != 2 {
+			t.Fatalf("posted %+v, want the screened message and its verdict", posts)
+		}
+		if posts[0].channel != feedID || !strings.Contains(posts[0].text, "<@"+opUser+">") || !strings.Contains(posts[0].text, permalink) {
+			t.Errorf("feed post = %+v, want the author and permalink in %s", posts[0], feedID)
+		}
+		if posts[1].channel != feedID {
+			t.Errorf("verdict posted to %s, want the feed thread", posts[1].channel)
+		}
+		if len(reactions) != 1 || reactions[0] != "eyes" {
+			t.Errorf("reactions = %v, want [eyes]", reactions)
+		}
+	})
+
+	t.Run("removes at the remove score", func(t *testing.T) {
+		setupViperConfig(t, with("spam_feed.proactive.remove_score", 2))
+		useMembers(t)
+		useBreaker(t, newRemovalBreaker(time.Now))
+		var posts []posted
+		var reactions []string
+		mock := newMock(&posts, &reactions)
+		var deleted string
+		mock.DeleteMessageFn = func(channel, messageTimestamp string) (string, string, error) {
+			deleted = channel + "/" + messageTimestamp
+			return channel, messageTimestamp, nil
+		}
+
+		screenMessage(mock, mock, ev)
+
+		if deleted != opChan+"/"+ev.TimeStamp {
+			t.Errorf("deleted %q, want the screened message", deleted)
+		}
+		if len(reactions) != 1 || reactions[0] != "no_entry" {
+			t.Errorf("reactions = %v, want [no_entry]", reactions)
+		}
+	})
+
+	t.Run("leaves messages below the review score alone", func(t *testing.T) {
+		setupViperConfig(t, with("spam_feed.proactive.review_score", 3))
+		useMembers(t)
+		var posts []posted
+		var reactions []string
+		mock := newMock(&posts, &reactions)
+
+		screenMessage(mock, mock, ev)
+
+		if len(posts) != 0 || len(reactions) != 0 {
+			t.Errorf("posted %+v and reacted %v, want nothing", posts, reactions)
+		}
+	})
+
+	t.Run("without a user token its signals are left out", func(t *testing.T) {
+		setupViperConfig(t, with("spam_feed.proactive.max_member_age", time.Hour))
+		useMembers(t)
+		members.join(opUser, time.Now(), time.Hour)
+		var posts []posted
+		var reactions []string
+		mock := newMock(&posts, &reactions)
+
+		screenMessage(mock, nil, ev)
+
+		if len(posts) != 0 || len(reactions) != 0 {
+			t.Errorf("posted %+v and reacted %v, want nothing scored without low_activity", posts, reactions)
+		}
+	})
+
+	t.Run("trusted authors aren't scored", func(t *testing.T) {
+		setupViperConfig(t, baseConfig)
+		useMembers(t)
+		members.trust(opUser)
+		// No search, post or reaction stubs, so scoring would panic.
+		screenMessage(&slackclient.MockClient{}, &slackclient.MockClient{}, ev)
+	})
+}
+
+// TestScreeningSignals verifies signals that need the user token are left out
+// without one.
+func TestScreeningSignals(t *testing.T) {
+	names := []string{"low_activity", "outside_tz"}
+	var got []string
+	for _, s := range screeningSignals(names, false) {
+		got = append(got, s.name)
+	}
+	if len(got) != 1 || got[0] != "outside_tz" {
+		t.Errorf("screeningSignals() without a user token = %v, want [outside_tz]", got)
+	}
+	if got := screeningSignals(names, true); len(got) != 2 {
+		t.Errorf("screeningSignals() with a user token = %d signals, want 2", len(got))
+	}
+}
+
+// TestMemberTrackerForgetsLongestTrusted verifies trusted authors past
+// maxTrusted are forgotten one at a time, oldest first.
+func TestMemberTrackerForgetsLongestTrusted(t *testing.T) {
+	m := newMemberTracker()
+	m.distrust("U0", time.Now())
+	for i := range maxTrusted + 1 {
+		m.trust(fmt.Sprintf("U%d", i))
+	}
+	if m.isTrusted("U0") || !m.isTrusted("U1") || !m.isTrusted(fmt.Sprintf("U%d", maxTrusted)) {
+		t.Error("trusting past maxTrusted didn't forget just the longest trusted author")
+	}
+	if len(m.trusted) != maxTrusted {
+		t.Errorf("remembered %d trusted authors, want %d", len(m.trusted), maxTrusted)
+	}
+	if m.isUntrusted("U0", time.Now()) {
+		t.Error("trusted author still remembered as low-trust")
+	}
+}
+
+func TestScreenMessageQueuesLowTrustAuthors(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.proactive.enabled":        true,
+		"spam_feed.proactive.channel_ids":    []string{"C1", "C2"},
+		"spam_feed.proactive.max_member_age": time.Hour,
+	})
+	useMembers(t)
+	useAdmins(t, map[string][]string{"U_ADMIN": {"Ada"}})
+	prevBot, prevUser, prevQueue := botClient, userClient, queue
+	t.Cleanup(func() { botClient, userClient, queue = prevBot, prevUser, prevQueue })
+	UseClients(&slackclient.MockClient{}, nil)
+
+	var mu sync.Mutex
+	var screened []string
+	q := newQueue(1, 10, time.Second, func(j job) {
+		mu.Lock()
+		defer mu.Unlock()
+		if j.screen {
+			screened = append(screened, j.ev.User+"@"+j.ev.Channel)
+		}
+	})
+	UseQueue(q)
+
+	for _, uid := range []string{"U_NEW", "U_ADMIN"} {
+		MemberJoined(uid)
+	}
+	for _, ev := range []slackevents.MessageEvent{
+		{User: "U_NEW", Channel: "C1", ChannelType: "channel"},
+		{User: "U_NEW", Channel: "C3", ChannelType: "channel"},
+		{User: "U_NEW", Channel: "C2", ChannelType: "channel", SubType: "message_changed"},
+		{User: "U_NEW", Channel: "C2", ChannelType: "channel", BotID: "B1"},
+		{User: "U_ADMIN", Channel: "C1", ChannelType: "channel"},
+		{User: "U_OLD", Channel: "C1", ChannelType: "channel"},
+	} {
+		ScreenMessage(&ev)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for q.Stats().Submitted < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(screened) != 1 || screened[0] != "U_NEW@C1" {
+		t.Errorf("screened %v, want only the new member's message in a screened channel", screened)
+	}
+}
@@ -0,0 +1,461 @@
+package hallmonitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/conversations"
+	"github.com/xortim/penny/pkg/events"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/metrics"
+	"github.com/xortim/penny/pkg/slackclient"
+	"github.com/xortim/penny/pkg/webhooks"
+)
+
+// Action IDs of the probation approval buttons, and the decisions they make.
+const (
+	ACTION_APPROVE = "probation.approve"
+	ACTION_REMOVE  = "probation.remove"
+
+	PROBATION_APPROVED = "approved"
+	PROBATION_REMOVED  = "removed"
+)
+
+// probation is who's on probation, since when, and how many of their
+// messages were accepted. It's kept in memory only.
+var probation = newProbationTracker()
+
+type probationTracker struct {
+	mu      sync.Mutex
+	members map[string]*probationer
+	// decided are the approvals a moderator claimed, by approvalValue, and
+	// when, so each message is only decided once.
+	decided map[string]time.Time
+}
+
+// decisionMemory is how long a decided approval is remembered.
+const decisionMemory = 7 * 24 * time.Hour
+
+type probationer struct {
+	since    time.Time
+	accepted int
+	// pending are messages posted without approval, by timestamp, with when
+	// they were posted. They're accepted once they've stood for the grace
+	// period without being reported.
+	pending map[string]time.Time
+}
+
+func newProbationTracker() *probationTracker {
+	return &probationTracker{members: make(map[string]*probationer), decided: make(map[string]time.Time)}
+}
+
+// start puts uid on probation from at, unless they already are. Members
+// whose probation expired are forgotten.
+func (p *probationTracker) start(uid string, at time.Time, cfg config.Probation) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, m := range p.members {
+		if expired(m, cfg, at) {
+			delete(p.members, id)
+		}
+	}
+	if _, ok := p.members[uid]; ok {
+		return false
+	}
+	p.members[uid] = &probationer{since: at, pending: make(map[string]time.Time)}
+	return true
+}
+
+// on reports whether uid is on probation at now.
+func (p *probationTracker) on(uid string, cfg config.Probation, now time.Time) bool {
+	if !cfg.Enabled {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, ok := p.members[uid]
+	if ok && expired(m, cfg, now) {
+		delete(p.members, uid)
+		return false
+	}
+	return ok
+}
+
+// accept counts one of uid's messages as accepted, lifting their probation
+// if that's enough. It reports whether it was lifted.
+func (p *probationTracker) accept(uid string, cfg config.Probation, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, ok := p.members[uid]
+	if !ok {
+		return false
+	}
+	m.accepted++
+	if (cfg.Messages > 0 && m.accepted >= cfg.Messages) || expired(m, cfg, now) {
+		delete(p.members, uid)
+		return true
+	}
+	return false
+}
+
+// post records uid's message ts, posted at now without approval. It's
+// accepted once it has stood for cfg.Grace without being reported, which is
+// counted when uid next posts. It reports whether probation was lifted.
+func (p *probationTracker) post(uid, ts string, cfg config.Probation, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, ok := p.members[uid]
+	if !ok {
+		return false
+	}
+	m.pending[ts] = now
+	for ts, at := range m.pending {
+		if now.Sub(at) >= cfg.Grace {
+			delete(m.pending, ts)
+			m.accepted++
+		}
+	}
+	if (cfg.Messages > 0 && m.accepted >= cfg.Messages) || expired(m, cfg, now) {
+		delete(p.members, uid)
+		return true
+	}
+	return false
+}
+
+// reported stops uid's message ts from being accepted, since it was reported.
+func (p *probationTracker) reported(uid, ts string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m, ok := p.members[uid]; ok {
+		delete(m.pending, ts)
+	}
+}
+
+// reset forgets uid's accepted and pending messages, after one of theirs was
+// removed.
+func (p *probationTracker) reset(uid string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m, ok := p.members[uid]; ok {
+		m.accepted = 0
+		clear(m.pending)
+	}
+}
+
+// claim reserves the decision on the approval identified by value, and
+// reports whether nobody had claimed it yet.
+func (p *probationTracker) claim(value string, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for v, at := range p.decided {
+		if now.Sub(at) >= decisionMemory {
+			delete(p.decided, v)
+		}
+	}
+	if _, ok := p.decided[value]; ok {
+		return false
+	}
+	p.decided[value] = now
+	return true
+}
+
+// unclaim gives up a claim whose decision couldn't be carried out.
+func (p *probationTracker) unclaim(value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.decided, value)
+}
+
+func expired(m *probationer, cfg config.Probation, now time.Time) bool {
+	return cfg.Duration > 0 && now.Sub(m.since) >= cfg.Duration
+}
+
+// onProbation reports whether uid is on probation, given the event's settings.
+func onProbation(ctx context.Context, uid string) bool {
+	return probation.on(uid, config.FromContext(ctx).SpamFeed.Probation, time.Now())
+}
+
+// reportThreshold is the score at which a report against uid is removed:
+// the probation threshold while they're on probation, if it's stricter.
+func reportThreshold(ctx context.Context, uid string) (threshold int, probationary bool) {
+	cfg := config.FromContext(ctx).SpamFeed
+	if onProbation(ctx, uid) && cfg.Probation.MaxAnomalyScore < cfg.MaxAnomalyScore {
+		return cfg.Probation.MaxAnomalyScore, true
+	}
+	return cfg.MaxAnomalyScore, false
+}
+
+// liftProbation logs that uid's probation was lifted. It doesn't trust them:
+// proactive screening still goes by their public activity.
+func liftProbation(uid string) {
+	log.Info().Str("user", uid).Msg("probation lifted")
+}
+
+// MemberJoinedChannel puts uid on probation if they have fewer public messages
+// than spam_feed.probation.messages, so members who never posted are treated
+// like new ones. The search runs on the queue, like a screening, and only once
+// at a time per member, so a bulk add to a channel can't fan out.
+func MemberJoinedChannel(uid string) {
+	cfg := config.Current().SpamFeed.Probation
+	if !cfg.Enabled || cfg.Messages == 0 || userClient == nil {
+		return
+	}
+	if members.isTrusted(uid) || admins.has(uid) || probation.on(uid, cfg, time.Now()) {
+		return
+	}
+	if !members.startLookup(uid) {
+		return
+	}
+	j := job{userApi: userClient, ev: slackevents.MessageEvent{User: uid}, joined: true}
+	if queue == nil {
+		go processJob(j)
+		return
+	}
+	if err := queue.Offer(j); err != nil {
+		members.endLookup(uid)
+		log.Warn().Err(err).Str("user", uid).Msg("shed new channel member lookup")
+	}
+}
+
+// checkChannelMember counts uid's public messages, trusting them if there are
+// enough and putting them on probation otherwise.
+func checkChannelMember(userApi slackclient.Client, uid string) {
+	defer members.endLookup(uid)
+	settings := config.Current()
+	cfg := settings.SpamFeed.Probation
+	count, err := publicMessages(config.NewContext(context.Background(), settings), uid, userApi)
+	if err != nil {
+		log.Error().Err(err).Str("user", uid).Msg("failed to count a new channel member's messages")
+		return
+	}
+	if count >= cfg.Messages {
+		members.trust(uid)
+		return
+	}
+	if probation.start(uid, time.Now(), cfg) {
+		log.Info().Str("user", uid).Int("messages", count).Msg("probation started")
+	}
+}
+
+// ReviewProbation counts a message from a member on probation once it has
+// stood for spam_feed.probation.grace without being reported, or, with
+// spam_feed.probation.approval_channel_id, sends it to the moderators to
+// approve or remove. Like ObserveMessage it's cheap enough to run before Slack
+// is acknowledged.
+func ReviewProbation(ev *slackevents.MessageEvent) {
+	cfg := config.Current().SpamFeed.Probation
+	if !memberPost(ev) || !probation.on(ev.User, cfg, time.Now()) {
+		return
+	}
+	if cfg.ApprovalChannelID == "" {
+		if probation.post(ev.User, ev.TimeStamp, cfg, time.Now()) {
+			liftProbation(ev.User)
+		}
+		return
+	}
+	if botClient == nil {
+		return
+	}
+	var msg slack.Message
+	msg.Channel = ev.Channel
+	msg.Timestamp = ev.TimeStamp
+	msg.ThreadTimestamp = ev.ThreadTimeStamp
+	msg.User = ev.User
+	msg.Text = ev.Text
+	// Slack only waits a few seconds for the event; the moderators can wait.
+	go requestApproval(config.NewContext(context.Background(), config.Current()), msg, botClient)
+}
+
+// requestApproval posts msg to the approval channel with buttons to approve or
+// remove it.
+func requestApproval(ctx context.Context, msg slack.Message, api slackclient.Client) {
+	logger := log.With().Str("op_user", msg.User).Str("op_channel", msg.Channel).Str("op_ts", msg.Timestamp).Logger()
+	permalink, err := api.GetPermalinkContext(ctx, &slack.PermalinkParameters{Channel: msg.Channel, Ts: msg.Timestamp})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get the message's permalink")
+	}
+	review := approvalText(ctx, msg, permalink)
+	value := approvalValue(msg)
+	approve := slack.NewButtonBlockElement(ACTION_APPROVE, value, plainText(text(ctx, messages.PROBATION_APPROVE, messages.Data{})))
+	approve.Style = slack.StylePrimary
+	remove := slack.NewButtonBlockElement(ACTION_REMOVE, value, plainText(text(ctx, messages.PROBATION_REMOVE, messages.Data{})))
+	remove.Style = slack.StyleDanger
+
+	_, _, err = api.PostMessage(config.FromContext(ctx).SpamFeed.Probation.ApprovalChannelID,
+		slack.MsgOptionText(review, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, truncate(review, maxSectionText), false, false), nil, nil),
+			slack.NewActionBlock("probation.decision", approve, remove),
+		),
+	)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to post message for approval")
+	}
+}
+
+// approvalText is msg, by a member on probation, for moderators.
+func approvalText(ctx context.Context, msg slack.Message, permalink string) string {
+	return text(ctx, messages.PROBATION_REVIEW, messages.Data{
+		OP:        mention(msg.User),
+		Channel:   msg.Channel,
+		Permalink: permalink,
+		Text:      escape(msg.Text),
+	})
+}
+
+// approvalValue identifies msg in the approval buttons, so a decision can be
+// made after a restart: its author, channel, timestamp and thread.
+func approvalValue(msg slack.Message) string {
+	return strings.Join([]string{msg.User, msg.Channel, msg.Timestamp, msg.ThreadTimestamp}, " ")
+}
+
+// approvalMessage reverses approvalValue. Only the message's author, channel
+// and timestamps are filled in.
+func approvalMessage(value string) (slack.Message, error) {
+	var msg slack.Message
+	fields := strings.Split(value, " ")
+	if len(fields) != 4 || fields[0] == "" || fields[1] == "" || fields[2] == "" {
+		return msg, fmt.Errorf("invalid approval %q", value)
+	}
+	msg.User, msg.Channel, msg.Timestamp, msg.ThreadTimestamp = fields[0], fields[1], fields[2], fields[3]
+	return msg, nil
+}
+
+// RegisterProbation routes the probation approval buttons to hallmonitor,
+// using the clients set by UseClients.
+func RegisterProbation(i *events.Interactions) {
+	i.OnAction(ACTION_APPROVE, func(cb slack.InteractionCallback, action *slack.BlockAction) {
+		decideApproval(interactionContext(), PROBATION_APPROVED, cb, action, botClient, userClient)
+	})
+	i.OnAction(ACTION_REMOVE, func(cb slack.InteractionCallback, action *slack.BlockAction) {
+		decideApproval(interactionContext(), PROBATION_REMOVED, cb, action, botClient, userClient)
+	})
+}
+
+// decideApproval approves or removes the message whose button a moderator
+// clicked, and replaces the buttons with the decision. An approved message
+// counts towards lifting its author's probation; a removed one starts the
+// count over.
+func decideApproval(ctx context.Context, decision string, cb slack.InteractionCallback, action *slack.BlockAction, api slackclient.Client, userApi slackclient.Client) {
+	logger := log.With().Str("user", cb.User.ID).Str("message", action.Value).Str("decision", decision).Logger()
+	cfg := config.FromContext(ctx).SpamFeed
+	if cb.Channel.ID != cfg.Probation.ApprovalChannelID {
+		logger.Warn().Str("channel", cb.Channel.ID).Msg("ignoring probation decision from outside the approval channel")
+		return
+	}
+	if !isModerator(ctx, cb.User.ID) {
+		tell(cb, text(ctx, messages.PROBATION_NOT_MODERATOR, messages.Data{}), api)
+		return
+	}
+	ref, err := approvalMessage(action.Value)
+	if err != nil {
+		logger.Error().Err(err).Msg("invalid approval")
+		return
+	}
+	if cb.User.ID == ref.User {
+		tell(cb, text(ctx, messages.PROBATION_OWN, messages.Data{}), api)
+		return
+	}
+	// Claimed before acting, so a double click or two moderators deciding at
+	// once count the message only once.
+	if !probation.claim(action.Value, time.Now()) {
+		tell(cb, text(ctx, messages.PROBATION_ALREADY_DECIDED, messages.Data{}), api)
+		return
+	}
+
+	var msg slack.Message
+	if ref.ThreadTimestamp != "" && ref.ThreadTimestamp != ref.Timestamp {
+		msg, err = conversations.ThreadReplyToMessage(ref.Channel, ref.ThreadTimestamp, ref.Timestamp, api)
+	} else {
+		msg, err = conversations.MsgRefToMessage(slack.NewRefToMessage(ref.Channel, ref.Timestamp), api)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to look up the message to approve")
+		probation.unclaim(action.Value)
+		tell(cb, text(ctx, messages.PROBATION_GONE, messages.Data{}), api)
+		return
+	}
+	msg.Channel, msg.User = ref.Channel, ref.User
+
+	if decision == PROBATION_APPROVED {
+		if probation.accept(msg.User, cfg.Probation, time.Now()) {
+			liftProbation(msg.User)
+		}
+	} else if !removeOnProbation(ctx, msg, cb.User.ID, api, userApi) {
+		probation.unclaim(action.Value)
+		return
+	}
+	logger.Info().Str("op_user", msg.User).Msg("probation message decided")
+
+	review := approvalText(ctx, msg, "")
+	decided := text(ctx, messages.PROBATION_DECIDED, messages.Data{Moderator: mention(cb.User.ID), Decision: decision})
+	_, _, _, err = api.UpdateMessage(cb.Channel.ID, cb.Message.Timestamp,
+		slack.MsgOptionText(review+"\n"+decided, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, truncate(review, maxSectionText), false, false), nil, nil),
+			slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, decided, false, false)),
+		),
+	)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to update approval")
+	}
+}
+
+// removeOnProbation removes msg for the moderator, recording it as a case
+// reported by them, and reports whether it was removed.
+func removeOnProbation(ctx context.Context, msg slack.Message, moderator string, api slackclient.Client, userApi slackclient.Client) bool {
+	cfg := config.FromContext(ctx).SpamFeed
+	logger := log.With().Str("op_user", msg.User).Str("op_channel", msg.Channel).Str("op_ts", msg.Timestamp).Logger()
+	if userApi == nil {
+		logger.Error().Msg("can't remove a message without the user token")
+		return false
+	}
+
+	c := &cases.Case{
+		Channel:         msg.Channel,
+		Timestamp:       msg.Timestamp,
+		ThreadTimestamp: msg.ThreadTimestamp,
+		Author:          msg.User,
+		Domain:          authorDomain(ctx, msg.User, api),
+		Text:            msg.Text,
+		Reporters:       moderator,
+		Threshold:       cfg.Probation.MaxAnomalyScore,
+		Verdict:         messages.VERDICT_REMOVED,
+	}
+	c.ID = recordCase(ctx, c)
+	appeal := uint(0)
+	if appealsEnabled(ctx) {
+		appeal = c.ID
+	}
+
+	actions := []string{}
+	locale := userLocale(ctx, msg.User, api)
+	notice := messages.Text(locale, messages.PROBATION_REMOVAL, messages.Data{AssistanceChannel: cfg.AssistanceChannelID})
+	if err := notifyOP(ctx, msg, notice, true, locale, appeal, api); err != nil {
+		logger.Error().Err(err).Msg("failed to warn OP before removal")
+	} else {
+		actions = append(actions, webhooks.ACTION_NOTIFIED)
+	}
+	if _, _, err := userApi.DeleteMessage(msg.Channel, msg.Timestamp); err != nil {
+		logger.Error().Err(err).Msg("failed to delete message")
+		metrics.Removals.WithLabelValues(cfg.Channel, metrics.RESULT_ERROR).Inc()
+		setVerdict(ctx, c.ID, messages.VERDICT_HELD)
+		return false
+	}
+	metrics.Removals.WithLabelValues(cfg.Channel, metrics.RESULT_OK).Inc()
+	actions = append(actions, webhooks.ACTION_REMOVED)
+	probation.reset(msg.User)
+
+	mirrored := storedWebhookCase(c, actions)
+	hooks.Send(webhooks.EVENT_CASE_CREATED, mirrored)
+	hooks.Send(webhooks.EVENT_MESSAGE_REMOVED, mirrored)
+	return true
+}
@@ -0,0 +1,328 @@
+package hallmonitor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// useProbation starts the test with nobody on probation.
+func useProbation(t *testing.T) {
+	t.Helper()
+	prev := probation
+	probation = newProbationTracker()
+	t.Cleanup(func() { probation = prev })
+}
+
+func TestProbationTracker(t *testing.T) {
+	cfg := config.Probation{Enabled: true, Messages: 2, Duration: time.Hour}
+	p := newProbationTracker()
+	now := time.Now()
+
+	if !p.start("U1", now, cfg) || p.start("U1", now, cfg) {
+		t.Fatal("start() should only put a member on probation once")
+	}
+	if !p.on("U1", cfg, now) || p.on("U2", cfg, now) {
+		t.Error("on() should only report the member who started probation")
+	}
+	if p.on("U1", config.Probation{}, now) {
+		t.Error("on() with probation disabled = true, want false")
+	}
+
+	if p.accept("U1", cfg, now) {
+		t.Error("accept() lifted probation after 1 of 2 messages")
+	}
+	p.reset("U1")
+	if p.accept("U1", cfg, now) {
+		t.Error("accept() lifted probation after a removal started the count over")
+	}
+	if !p.accept("U1", cfg, now) {
+		t.Error("accept() didn't lift probation after 2 messages")
+	}
+	if p.on("U1", cfg, now) {
+		t.Error("on() = true after probation was lifted")
+	}
+
+	p.start("U3", now, cfg)
+	if p.on("U3", cfg, now.Add(time.Hour)) {
+		t.Error("on() = true once probation.duration passed")
+	}
+}
+
+func TestReportThreshold(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.max_anomaly_score":           5,
+		"spam_feed.probation.enabled":           true,
+		"spam_feed.probation.max_anomaly_score": 3,
+		"spam_feed.probation.messages":          5,
+	})
+	useProbation(t)
+	ctx := config.NewContext(context.Background(), config.Current())
+	probation.start("U_NEW", time.Now(), config.Current().SpamFeed.Probation)
+
+	if threshold, probationary := reportThreshold(ctx, "U_NEW"); threshold != 3 || !probationary {
+		t.Errorf("reportThreshold(U_NEW) = %d, %v; want 3, true", threshold, probationary)
+	}
+	if threshold, probationary := reportThreshold(ctx, "U_OLD"); threshold != 5 || probationary {
+		t.Errorf("reportThreshold(U_OLD) = %d, %v; want 5, false", threshold, probationary)
+	}
+}
+
+func TestReviewProbationWithoutApproval(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.probation.enabled":  true,
+		"spam_feed.probation.messages": 2,
+	})
+	useProbation(t)
+	useMembers(t)
+	MemberJoined("U_NEW")
+
+	ev := slackevents.MessageEvent{User: "U_NEW", Channel: "C1", ChannelType: "channel", TimeStamp: "1111.0001"}
+	ReviewProbation(&ev)
+	if !probation.on("U_NEW", config.Current().SpamFeed.Probation, time.Now()) {
+		t.Fatal("probation lifted after 1 of 2 messages")
+	}
+	ReviewProbation(&ev)
+	if probation.on("U_NEW", config.Current().SpamFeed.Probation, time.Now()) {
+		t.Error("probation not lifted after 2 messages")
+	}
+	if members.isTrusted("U_NEW") {
+		t.Error("member trusted once probation was lifted, want screening left to their activity")
+	}
+}
+
+// TestProbationGrace verifies messages posted without approval only count once
+// they've stood for the grace period without being reported, so a burst can't
+// lift probation.
+func TestProbationGrace(t *testing.T) {
+	cfg := config.Probation{Enabled: true, Messages: 3, Grace: time.Hour}
+	p := newProbationTracker()
+	start := time.Now()
+	p.start("U_NEW", start, cfg)
+
+	for i, ts := range []string{"1.1", "1.2", "1.3", "1.4"} {
+		if p.post("U_NEW", ts, cfg, start.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("probation lifted by a burst of %d messages", i+1)
+		}
+	}
+	p.reported("U_NEW", "1.2")
+	p.reset("U_OTHER")
+
+	// An hour on, three of the four stood unreported, which lifts probation.
+	if !p.post("U_NEW", "2.1", cfg, start.Add(time.Hour+5*time.Second)) {
+		t.Error("probation not lifted once 3 unreported messages stood for the grace period")
+	}
+
+	p.start("U_SPAM", start, cfg)
+	for _, ts := range []string{"1.1", "1.2", "1.3"} {
+		p.post("U_SPAM", ts, cfg, start)
+	}
+	p.reset("U_SPAM")
+	if p.post("U_SPAM", "2.1", cfg, start.Add(2*time.Hour)) {
+		t.Error("probation lifted by messages posted before a removal")
+	}
+}
+
+func TestApprovalValue(t *testing.T) {
+	var msg slack.Message
+	msg.User, msg.Channel, msg.Timestamp = "U_NEW", "C1", "1111.0001"
+	got, err := approvalMessage(approvalValue(msg))
+	if err != nil || got.User != msg.User || got.Channel != msg.Channel || got.Timestamp != msg.Timestamp || got.ThreadTimestamp != "" {
+		t.Errorf("approvalMessage(approvalValue(%+v)) = %+v, %v", msg, got, err)
+	}
+	if _, err := approvalMessage("U_NEW C1"); err == nil {
+		t.Error("approvalMessage() accepted an incomplete value")
+	}
+}
+
+// TestApprovalTextEscapes verifies a member on probation can't mention or link
+// from the approval channel.
+func TestApprovalTextEscapes(t *testing.T) {
+	var msg slack.Message
+	msg.User, msg.Channel, msg.Text = "U_NEW", "C1", "<!here> free <https://evil.example|gift cards> & more"
+	got := approvalText(context.Background(), msg, "")
+	if strings.Contains(got, "<!here>") || strings.Contains(got, "<https://") {
+		t.Errorf("approvalText() = %q, want the member's text escaped", got)
+	}
+	if !strings.Contains(got, "&lt;!here&gt;") || !strings.Contains(got, "&amp; more") || !strings.Contains(got, "<@U_NEW>") {
+		t.Errorf("approvalText() = %q, want &, < and > escaped and the author mentioned", got)
+	}
+}
+
+func TestDecideApproval(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.op_notice_delivery":            DELIVERY_DM,
+		"spam_feed.probation.enabled":             true,
+		"spam_feed.probation.max_anomaly_score":   2,
+		"spam_feed.probation.messages":            2,
+		"spam_feed.probation.approval_channel_id": "C_MODS",
+		"slack.global_admins":                     []string{"U_MOD", "U_NEW"},
+	})
+	useProbation(t)
+	useMembers(t)
+	useBreaker(t, newRemovalBreaker(time.Now))
+	ctx := config.NewContext(context.Background(), config.Current())
+	cfg := config.Current().SpamFeed.Probation
+	probation.start("U_NEW", time.Now(), cfg)
+
+	var ephemeral, updated, notified, deleted string
+	mock := &slackclient.MockClient{
+		PostEphemeralFn: func(channelID, userID string, options ...slack.MsgOption) (string, error) {
+			_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+			ephemeral = values.Get("text")
+			return "ts", nil
+		},
+		JoinConversationFn: func(channelID string) (*slack.Channel, string, []string, error) {
+			return &slack.Channel{}, "", nil, nil
+		},
+		GetConversationHistoryFn: func(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+			msg := slack.Message{}
+			msg.Timestamp, msg.User, msg.Text = params.Latest, "U_NEW", "Buy cheap followers"
+			return &slack.GetConversationHistoryResponse{Messages: []slack.Message{msg}}, nil
+		},
+		UpdateMessageFn: func(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+			if channelID != "C_MODS" || timestamp != "2222.0001" {
+				t.Errorf("UpdateMessage() at %s/%s, want C_MODS/2222.0001", channelID, timestamp)
+			}
+			_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+			updated = values.Get("blocks")
+			return channelID, timestamp, "", nil
+		},
+		OpenConversationFn: func(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error) {
+			return &slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "D_NEW"}}}, false, true, nil
+		},
+		PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+			_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+			notified = values.Get("text")
+			return channelID, "ts", nil
+		},
+		DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+			deleted = channel + "/" + messageTimestamp
+			return channel, messageTimestamp, nil
+		},
+	}
+	callback := func(user string) slack.InteractionCallback {
+		var cb slack.InteractionCallback
+		cb.User.ID = user
+		cb.Channel.ID = "C_MODS"
+		cb.Message.Timestamp = "2222.0001"
+		return cb
+	}
+	var msg slack.Message
+	msg.User, msg.Channel, msg.Timestamp = "U_NEW", "C1", "1111.0001"
+	action := &slack.BlockAction{ActionID: ACTION_APPROVE, Value: approvalValue(msg)}
+
+	decideApproval(ctx, PROBATION_APPROVED, callback("U_NEW"), action, mock, mock)
+	if !strings.Contains(ephemeral, "your own") || updated != "" {
+		t.Fatalf("author approving their own message: told %q, updated %q; want only a reply", ephemeral, updated)
+	}
+
+	decideApproval(ctx, PROBATION_APPROVED, callback("U_MEMBER"), action, mock, mock)
+	if !strings.Contains(ephemeral, "Only moderators") || updated != "" {
+		t.Fatalf("member approving a message: told %q, updated %q; want only a refusal", ephemeral, updated)
+	}
+
+	ephemeral = ""
+	outside := callback("U_MOD")
+	outside.Channel.ID = "C1"
+	decideApproval(ctx, PROBATION_APPROVED, outside, action, mock, mock)
+	if ephemeral != "" || updated != "" {
+		t.Fatalf("decision from outside the approval channel: told %q, updated %q; want it ignored", ephemeral, updated)
+	}
+
+	decideApproval(ctx, PROBATION_APPROVED, callback("U_MOD"), action, mock, mock)
+	if !strings.Contains(updated, `Approved by \u003c@U_MOD\u003e`) || strings.Contains(updated, ACTION_REMOVE) {
+		t.Errorf("decideApproval() updated the approval to %s, want it approved by U_MOD without buttons", updated)
+	}
+	if !probation.on("U_NEW", cfg, time.Now()) {
+		t.Fatal("probation lifted after 1 of 2 approvals")
+	}
+
+	// A second click on the same message doesn't count it again.
+	decideApproval(ctx, PROBATION_APPROVED, callback("U_MOD"), action, mock, mock)
+	if !strings.Contains(ephemeral, "already decided") {
+		t.Errorf("second approval told the moderator %q, want it already decided", ephemeral)
+	}
+	if !probation.on("U_NEW", cfg, time.Now()) {
+		t.Fatal("probation lifted by approving the same message twice")
+	}
+
+	// approve is another of U_NEW's messages.
+	approve := func(ts string) *slack.BlockAction {
+		var other slack.Message
+		other.User, other.Channel, other.Timestamp = "U_NEW", "C1", ts
+		return &slack.BlockAction{ActionID: ACTION_APPROVE, Value: approvalValue(other)}
+	}
+	decideApproval(ctx, PROBATION_REMOVED, callback("U_MOD"), &slack.BlockAction{ActionID: ACTION_REMOVE, Value: approve("1111.0002").Value}, mock, mock)
+	if deleted != "C1/1111.0002" {
+		t.Errorf("deleted %q, want C1/1111.0002", deleted)
+	}
+	if !strings.Contains(notified, "Buy cheap followers") {
+		t.Errorf("told the author %q, want a copy of their message", notified)
+	}
+	if !strings.Contains(updated, `Removed by \u003c@U_MOD\u003e`) {
+		t.Errorf("decideApproval() updated the approval to %s, want it removed by U_MOD", updated)
+	}
+
+	// The removal started the count over, so one more approval isn't enough.
+	decideApproval(ctx, PROBATION_APPROVED, callback("U_MOD"), approve("1111.0003"), mock, mock)
+	if !probation.on("U_NEW", cfg, time.Now()) {
+		t.Error("probation lifted by the first approval after a removal")
+	}
+}
+
+// TestMemberJoinedChannelLooksUpOnce verifies joins are looked up on the queue,
+// once per member however many channels they join meanwhile.
+func TestMemberJoinedChannelLooksUpOnce(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.probation.enabled":  true,
+		"spam_feed.probation.messages": 2,
+	})
+	useProbation(t)
+	useMembers(t)
+	useAdmins(t, map[string][]string{})
+	prevBot, prevUser, prevQueue := botClient, userClient, queue
+	t.Cleanup(func() { botClient, userClient, queue = prevBot, prevUser, prevQueue })
+	search, calls := searchCounting(0)
+	UseClients(&slackclient.MockClient{}, &slackclient.MockClient{SearchMessagesContextFn: search})
+
+	release := make(chan struct{})
+	q := newQueue(1, 10, time.Second, func(j job) {
+		<-release
+		processJob(j)
+	})
+	UseQueue(q)
+
+	for range 3 {
+		MemberJoinedChannel("U_NEW")
+	}
+	if stats := q.Stats(); stats.Submitted != 1 {
+		t.Errorf("Submitted = %d after 3 joins, want 1 lookup", stats.Submitted)
+	}
+	close(release)
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() unexpected error: %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("searched %d times, want 1", *calls)
+	}
+	if !probation.on("U_NEW", config.Current().SpamFeed.Probation, time.Now()) {
+		t.Error("member with no public messages not put on probation")
+	}
+
+	MemberJoinedChannel("U_OTHER")
+	if *calls != 1 {
+		t.Error("lookup ran after the queue drained")
+	}
+	if members.startLookup("U_NEW") {
+		members.endLookup("U_NEW")
+	} else {
+		t.Error("lookup still pending once it finished")
+	}
+}
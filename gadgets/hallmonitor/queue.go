@@ -0,0 +1,312 @@
+package hallmonitor
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/gadget-bot/gadget/router"
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// ErrQueueClosed is returned by Submit once the queue has started draining.
+var ErrQueueClosed = errors.New("hallmonitor queue is draining")
+
+// ErrQueueFull is returned by Submit when no slot frees up within the enqueue timeout.
+var ErrQueueFull = errors.New("hallmonitor queue is full")
+
+// job is a spam-feed report, a message to screen or a new channel member to
+// look up, waiting for a worker.
+type job struct {
+	router  router.Router
+	route   router.Route
+	api     slackclient.Client
+	userApi slackclient.Client
+	ev      slackevents.MessageEvent
+	message string
+	screen  bool // ev is a member's message to screen
+	joined  bool // ev.User joined a channel; count their public messages
+}
+
+// QueueStats is a snapshot of the queue's backpressure counters.
+type QueueStats struct {
+	Capacity  int
+	Depth     int // jobs waiting for a worker
+	InFlight  int // jobs being processed
+	Submitted int64
+	Rejected  int64 // jobs dropped because the queue was full or draining
+	Shed      int64 // screenings and lookups dropped to keep room for reports
+	Processed int64
+	Coalesced int64 // reports judged with the author signals of an earlier report
+	Waited    time.Duration
+}
+
+// Queue processes spam-feed reports on a fixed number of workers, so that a raid
+// of Reacji reposts can't fan out into hundreds of concurrent pipelines.
+type Queue struct {
+	jobs           chan job
+	quit           chan struct{}
+	enqueueTimeout time.Duration
+	handle         func(job)
+	wg             sync.WaitGroup
+
+	// closing holds submitters (read) off the jobs channel while Drain closes it (write).
+	// quit is closed first, so submitters waiting for space let go of it.
+	closing  sync.RWMutex
+	closed   bool
+	quitOnce sync.Once
+
+	mu    sync.Mutex
+	stats QueueStats
+}
+
+// NewQueue starts workers that process up to capacity queued reports.
+// Submit blocks for at most enqueueTimeout when the queue is full.
+func NewQueue(workers, capacity int, enqueueTimeout time.Duration) *Queue {
+	return newQueue(workers, capacity, enqueueTimeout, processJob)
+}
+
+func newQueue(workers, capacity int, enqueueTimeout time.Duration, handle func(job)) *Queue {
+	workers = max(workers, 1)
+	q := &Queue{
+		jobs:           make(chan job, capacity),
+		quit:           make(chan struct{}),
+		enqueueTimeout: enqueueTimeout,
+		handle:         handle,
+		stats:          QueueStats{Capacity: capacity},
+	}
+	q.wg.Add(workers)
+	for range workers {
+		go q.work()
+	}
+	return q
+}
+
+// Submit enqueues a report, waiting up to the enqueue timeout for space.
+func (q *Queue) Submit(j job) error {
+	q.closing.RLock()
+	defer q.closing.RUnlock()
+
+	start := time.Now()
+	err := ErrQueueClosed
+	if !q.closed {
+		timer := time.NewTimer(q.enqueueTimeout)
+		defer timer.Stop()
+		select {
+		case q.jobs <- j:
+			err = nil
+		case <-timer.C:
+			err = ErrQueueFull
+		case <-q.quit:
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.stats.Waited += time.Since(start)
+	if err != nil {
+		q.stats.Rejected++
+	} else {
+		q.stats.Submitted++
+	}
+	return err
+}
+
+// Offer enqueues a screening or lookup without waiting. They only get the
+// first half of the queue, so under load they're shed before they can crowd
+// out reports.
+func (q *Queue) Offer(j job) error {
+	q.closing.RLock()
+	defer q.closing.RUnlock()
+
+	err := ErrQueueClosed
+	if !q.closed {
+		err = ErrQueueFull
+		if len(q.jobs) < (cap(q.jobs)+1)/2 {
+			select {
+			case q.jobs <- j:
+				err = nil
+			default:
+			}
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err != nil {
+		q.stats.Shed++
+	} else {
+		q.stats.Submitted++
+	}
+	return err
+}
+
+// Drain stops accepting reports and waits for queued and in-flight reports to
+// finish, or for ctx to be done.
+func (q *Queue) Drain(ctx context.Context) error {
+	q.quitOnce.Do(func() { close(q.quit) })
+
+	done := make(chan struct{})
+	go func() {
+		q.closing.Lock()
+		if !q.closed {
+			q.closed = true
+			close(q.jobs)
+		}
+		q.closing.Unlock()
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the queue's counters.
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := q.stats
+	s.Depth = len(q.jobs)
+	s.Coalesced = bursts.coalescedCount()
+	return s
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for j := range q.jobs {
+		q.mu.Lock()
+		q.stats.InFlight++
+		q.mu.Unlock()
+
+		q.run(j)
+
+		q.mu.Lock()
+		q.stats.InFlight--
+		q.stats.Processed++
+		q.mu.Unlock()
+	}
+}
+
+// run handles a single job, containing any panic to that job.
+func (q *Queue) run(j job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Interface("panic", r).Bytes("stack", debug.Stack()).Str("event_ts", j.ev.TimeStamp).Msg("spam feed job panicked")
+		}
+	}()
+	q.handle(j)
+}
+
+func processJob(j job) {
+	switch {
+	case j.screen:
+		screenMessage(j.api, j.userApi, j.ev)
+		return
+	case j.joined:
+		checkChannelMember(j.userApi, j.ev.User)
+		return
+	}
+	ProcessSpamFeedMessage(j.router, j.route, j.api, j.userApi, j.ev, j.message)
+}
+
+// burstTracker remembers each author's most recent verdict for the coalescing
+// window, so that a burst of reports against one spammer only looks the author
+// up once and is recorded as one case.
+type burstTracker struct {
+	mu        sync.Mutex
+	authors   map[string]*authorBurst
+	coalesced int64
+}
+
+type authorBurst struct {
+	mu      sync.Mutex // serializes reports for the author
+	eval    evaluation
+	judged  time.Time
+	caseID  uint // the burst's case, which later reports are recorded under
+	pending int
+}
+
+var bursts = &burstTracker{authors: make(map[string]*authorBurst)}
+
+// coalesceWindow is how long an author's verdict is reused; 0 disables coalescing.
+func coalesceWindow() time.Duration {
+	return config.Current().SpamFeed.Queue.CoalesceWindow
+}
+
+// acquire serializes reports for author and returns the author's burst, which
+// must be handed back to release.
+func (b *burstTracker) acquire(author string) *authorBurst {
+	b.mu.Lock()
+	b.sweep()
+	ab, ok := b.authors[author]
+	if !ok {
+		ab = &authorBurst{}
+		b.authors[author] = ab
+	}
+	ab.pending++
+	b.mu.Unlock()
+
+	ab.mu.Lock()
+	return ab
+}
+
+func (b *burstTracker) release(author string, ab *authorBurst) {
+	ab.mu.Unlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ab.pending--
+	if ab.pending == 0 && time.Since(ab.judged) >= coalesceWindow() {
+		delete(b.authors, author)
+	}
+}
+
+// sweep forgets authors nobody is waiting on whose window has passed. b.mu must be held.
+func (b *burstTracker) sweep() {
+	window := coalesceWindow()
+	for author, ab := range b.authors {
+		if ab.pending == 0 && time.Since(ab.judged) >= window {
+			delete(b.authors, author)
+		}
+	}
+}
+
+// recent returns the author's last verdict and case if it's inside the
+// coalescing window. The caller must hold ab via acquire.
+func (b *burstTracker) recent(ab *authorBurst) (evaluation, uint, bool) {
+	window := coalesceWindow()
+	if window <= 0 || ab.judged.IsZero() || time.Since(ab.judged) >= window {
+		return evaluation{}, 0, false
+	}
+	b.mu.Lock()
+	b.coalesced++
+	b.mu.Unlock()
+	return ab.eval, ab.caseID, true
+}
+
+// remember records the verdict for the author. Deferred evaluations aren't
+// remembered; the next report gets a fresh look. The caller must hold ab via acquire.
+func (b *burstTracker) remember(ab *authorBurst, eval evaluation, caseID uint) {
+	if eval.deferred || coalesceWindow() <= 0 {
+		return
+	}
+	ab.eval = eval
+	ab.caseID = caseID
+	ab.judged = time.Now()
+}
+
+func (b *burstTracker) coalescedCount() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.coalesced
+}
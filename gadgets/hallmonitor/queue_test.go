@@ -0,0 +1,345 @@
+package hallmonitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gadget-bot/gadget/router"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// TestQueueBoundsConcurrency verifies no more than the configured workers run at once.
+func TestQueueBoundsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	q := newQueue(2, 10, time.Second, func(j job) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+	})
+
+	for range 8 {
+		if err := q.Submit(job{}); err != nil {
+			t.Fatalf("Submit() unexpected error: %v", err)
+		}
+	}
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() unexpected error: %v", err)
+	}
+
+	if got := peak.Load(); got > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", got)
+	}
+	stats := q.Stats()
+	if stats.Submitted != 8 || stats.Processed != 8 || stats.Rejected != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+// TestQueueBackpressure verifies a full queue rejects after the enqueue timeout.
+func TestQueueBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	q := newQueue(1, 1, 20*time.Millisecond, func(j job) { <-release })
+
+	// one job in flight, one queued
+	for range 2 {
+		if err := q.Submit(job{}); err != nil {
+			t.Fatalf("Submit() unexpected error: %v", err)
+		}
+	}
+	// let the worker pick up the first job so the second fills the buffer
+	deadline := time.Now().Add(time.Second)
+	for q.Stats().InFlight == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := q.Submit(job{}); err != nil && !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit() unexpected error: %v", err)
+	}
+	if err := q.Submit(job{}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit() on a full queue = %v, want ErrQueueFull", err)
+	}
+
+	stats := q.Stats()
+	if stats.Rejected == 0 || stats.Depth != 1 || stats.InFlight != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	close(release)
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() unexpected error: %v", err)
+	}
+}
+
+// TestQueueDrain verifies draining finishes queued work and then rejects new jobs.
+func TestQueueDrain(t *testing.T) {
+	var processed atomic.Int32
+	q := newQueue(1, 10, time.Second, func(j job) {
+		time.Sleep(5 * time.Millisecond)
+		processed.Add(1)
+	})
+
+	for range 5 {
+		if err := q.Submit(job{}); err != nil {
+			t.Fatalf("Submit() unexpected error: %v", err)
+		}
+	}
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() unexpected error: %v", err)
+	}
+	if got := processed.Load(); got != 5 {
+		t.Errorf("processed %d jobs before Drain returned, want 5", got)
+	}
+	if err := q.Submit(job{}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Submit() after Drain = %v, want ErrQueueClosed", err)
+	}
+}
+
+// TestQueueDrainTimeout verifies Drain gives up when ctx is done.
+func TestQueueDrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	q := newQueue(1, 1, time.Second, func(j job) { <-release })
+	if err := q.Submit(job{}); err != nil {
+		t.Fatalf("Submit() unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain() = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// TestQueueDrainWakesSubmitters verifies a Submit waiting for space on a full
+// queue gives up as soon as Drain starts, rather than holding Drain up.
+func TestQueueDrainWakesSubmitters(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	q := newQueue(1, 1, time.Minute, func(j job) { <-release })
+	for range 2 {
+		if err := q.Submit(job{}); err != nil {
+			t.Fatalf("Submit() unexpected error: %v", err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for q.Stats().InFlight == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	submitted := make(chan error)
+	go func() { submitted <- q.Submit(job{}) }()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain() = %v, want context.DeadlineExceeded", err)
+	}
+	select {
+	case err := <-submitted:
+		if !errors.Is(err, ErrQueueClosed) {
+			t.Errorf("waiting Submit() = %v, want ErrQueueClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting Submit() still blocked after Drain")
+	}
+}
+
+// TestQueueOfferShedsScreenings verifies screenings only fill half the queue
+// and never wait, leaving the rest to reports.
+func TestQueueOfferShedsScreenings(t *testing.T) {
+	release := make(chan struct{})
+	q := newQueue(1, 4, 20*time.Millisecond, func(j job) { <-release })
+	if err := q.Offer(job{screen: true}); err != nil {
+		t.Fatalf("Offer() unexpected error: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for q.Stats().InFlight == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	for range 2 {
+		if err := q.Offer(job{screen: true}); err != nil {
+			t.Fatalf("Offer() unexpected error: %v", err)
+		}
+	}
+	if err := q.Offer(job{screen: true}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Offer() on a half-full queue = %v, want ErrQueueFull", err)
+	}
+	for range 2 {
+		if err := q.Submit(job{}); err != nil {
+			t.Errorf("Submit() with screenings queued = %v, want room kept for reports", err)
+		}
+	}
+
+	stats := q.Stats()
+	if stats.Shed != 1 || stats.Rejected != 0 || stats.Submitted != 5 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	close(release)
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() unexpected error: %v", err)
+	}
+	if err := q.Offer(job{screen: true}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Offer() after Drain = %v, want ErrQueueClosed", err)
+	}
+}
+
+// TestQueueRecoversPanics verifies a panicking job doesn't take its worker down.
+func TestQueueRecoversPanics(t *testing.T) {
+	var processed atomic.Int32
+	q := newQueue(1, 10, time.Second, func(j job) {
+		processed.Add(1)
+		if j.message == "boom" {
+			panic("boom")
+		}
+	})
+	for _, msg := range []string{"boom", "fine"} {
+		if err := q.Submit(job{message: msg}); err != nil {
+			t.Fatalf("Submit() unexpected error: %v", err)
+		}
+	}
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() unexpected error: %v", err)
+	}
+	if got := processed.Load(); got != 2 {
+		t.Errorf("processed %d jobs, want 2", got)
+	}
+}
+
+// TestProcessSpamFeedMessageCoalescesAuthor verifies a burst of reports against
+// one author looks the author up once, removes every message in the burst and
+// is recorded as one case.
+func TestProcessSpamFeedMessageCoalescesAuthor(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.channel":                     "spam-feed",
+		"spam_feed.anomaly_scores.reported":     2,
+		"spam_feed.anomaly_scores.low_activity": 3,
+		"spam_feed.activity_low_watermark":      10,
+		"spam_feed.max_anomaly_score":           5,
+		"spam_feed.queue.coalesce_window":       "1m",
+	})
+
+	const opChan = "C02BZ36790B"
+	opMsgs := map[string]slack.Message{}
+	for _, ts := range []string{"1639843883.000100", "1639843884.000100", "1639843885.000100"} {
+		opMsgs[ts] = slack.Message{Msg: slack.Msg{Timestamp: ts, Channel: opChan, User: "U_BURST"}}
+	}
+
+	var mu sync.Mutex
+	var searches int
+	deleted := map[string]bool{}
+	mock := &slackclient.MockClient{
+		GetConversationInfoFn: func(input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+			return &slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{NameNormalized: "spam-feed"}}}, nil
+		},
+		JoinConversationFn: noopJoin,
+		GetConversationHistoryFn: func(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+			if params.ChannelID == opChan {
+				return &slack.GetConversationHistoryResponse{Messages: []slack.Message{opMsgs[params.Latest]}}, nil
+			}
+			return &slack.GetConversationHistoryResponse{Messages: []slack.Message{
+				{Msg: slack.Msg{Timestamp: params.Latest, Channel: params.ChannelID}},
+			}}, nil
+		},
+		PostMessageFn: noopPost,
+		AddReactionFn: func(name string, item slack.ItemRef) error { return nil },
+	}
+	userMock := &slackclient.MockClient{
+		SearchMessagesFn: func(query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+			mu.Lock()
+			searches++
+			mu.Unlock()
+			return &slack.SearchMessages{Pagination: slack.Pagination{TotalCount: 0}}, nil
+		},
+		DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+			mu.Lock()
+			deleted[messageTimestamp] = true
+			mu.Unlock()
+			return channel, messageTimestamp, nil
+		},
+	}
+
+	store := cases.NewMemoryStore()
+	UseCases(store)
+	t.Cleanup(func() { UseCases(nil) })
+
+	q := newQueue(3, 10, time.Second, processJob)
+	permalinks := []string{
+		"<https://orgname.slack.com/archives/C02BZ36790B/p1639843883000100>",
+		"<https://orgname.slack.com/archives/C02BZ36790B/p1639843884000100>",
+		"<https://orgname.slack.com/archives/C02BZ36790B/p1639843885000100>",
+	}
+	for i, permalink := range permalinks {
+		ev := slackevents.MessageEvent{SubType: BOT_MESSAGE_TYPE, Channel: "C_SPAM", TimeStamp: fmt.Sprintf("1111111111.00010%d", i)}
+		if err := q.Submit(job{router: router.Router{BotUID: "U_BOT"}, api: mock, userApi: userMock, ev: ev, message: permalink}); err != nil {
+			t.Fatalf("Submit() unexpected error: %v", err)
+		}
+	}
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() unexpected error: %v", err)
+	}
+
+	if searches != 1 {
+		t.Errorf("activity search ran %d times, want 1 for the whole burst", searches)
+	}
+	if len(deleted) != 3 {
+		t.Errorf("deleted %d messages, want all 3 in the burst", len(deleted))
+	}
+	if got := q.Stats().Coalesced; got < 2 {
+		t.Errorf("Coalesced = %d, want at least 2", got)
+	}
+	if _, err := store.Case(context.Background(), 2); !errors.Is(err, cases.ErrNotFound) {
+		t.Errorf("Case(2) = %v, want one case for the whole burst", err)
+	}
+	if reports := store.Reports(1); len(reports) != 2 {
+		t.Errorf("recorded %d reports under the burst's case, want 2", len(reports))
+	}
+}
+
+// TestCoalesceRescoresMessage verifies a later report in a burst reuses the
+// author's signals but is scored on its own message.
+func TestCoalesceRescoresMessage(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.anomaly_scores.reported":   2,
+		"spam_feed.anomaly_scores.duplicates": 3,
+		"spam_feed.duplicates.window":         time.Hour,
+		"spam_feed.duplicates.min_channels":   3,
+		"spam_feed.duplicates.max_distance":   6,
+		"spam_feed.duplicates.min_length":     20,
+	})
+	useRecentPosts(t)
+	ctx := config.NewContext(context.Background(), config.Current())
+
+	earlier := evaluation{score: 8, results: []signalResult{
+		{name: "reported", score: 2},
+		{name: "low_activity", score: 3},
+		{name: "duplicates", score: 3},
+	}}
+	op := slack.Message{Msg: slack.Msg{User: "U_BURST", Channel: "C1", Timestamp: "1639843890.000100", Text: "Has anyone tried the new release yet?"}}
+	eval := coalesce(ctx, earlier, op, nil, nil, zerolog.Nop())
+
+	if eval.score != 5 || !eval.coalesced {
+		t.Errorf("coalesce() = %+v, want the author's 3 and reported's 2 without the earlier duplicates", eval)
+	}
+	for _, r := range eval.results {
+		if r.reused != (r.name == "low_activity") {
+			t.Errorf("%s reused = %v, want only low_activity reused", r.name, r.reused)
+		}
+	}
+}
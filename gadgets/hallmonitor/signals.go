@@ -0,0 +1,234 @@
+package hallmonitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+	"github.com/xortim/penny/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Timeout policies for signals that don't finish before the deadline.
+const (
+	TIMEOUT_POLICY_ZERO  = "zero"  // the signal contributes nothing
+	TIMEOUT_POLICY_MAX   = "max"   // the signal contributes its full weight
+	TIMEOUT_POLICY_DEFER = "defer" // no removal; a human makes the call
+
+	defaultSignalDeadline = 10 * time.Second
+)
+
+// signal is one piece of evidence about a reported message.
+type signal struct {
+	name string
+	// detailed signals' debug lines say what they found, so the verdict
+	// shows them too.
+	detailed bool
+	// perMessage signals depend on the message rather than its author, so
+	// they're evaluated again for every report in a burst.
+	perMessage bool
+	// userToken signals call the API with the user token, so they're
+	// skipped when Penny runs without one.
+	userToken bool
+	// weight is the score the signal contributes when it fires.
+	weight func(cfg config.SpamFeed) int
+	// describe renders a non-zero contribution for the debug reply.
+	describe func(ctx context.Context, score int) string
+	// evaluate returns the signal's contribution. It must honour ctx.
+	evaluate func(ctx context.Context, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client) (int, error)
+}
+
+// signalResult is the outcome of evaluating one signal.
+type signalResult struct {
+	name     string
+	score    int
+	reason   string
+	err      error
+	timedOut bool
+	detailed bool
+	elapsed  time.Duration
+	reused   bool // from an earlier report in the author's burst
+	skipped  bool // needs the user token, which Penny runs without
+}
+
+// evaluation is the outcome of evaluating every signal for a reported message.
+type evaluation struct {
+	score     int
+	results   []signalResult
+	deferred  bool // a signal timed out under the defer policy
+	coalesced bool // author signals reused from an earlier report against the same author
+}
+
+// reasons returns the debug lines for every signal that contributed or timed out.
+func (e evaluation) reasons() []string {
+	reasons := make([]string, 0, len(e.results))
+	for _, r := range e.results {
+		if r.reason != "" {
+			reasons = append(reasons, r.reason)
+		}
+	}
+	return reasons
+}
+
+// spamSignals returns the signals evaluated for every report, in display order.
+// Signals backed by optional data are only included while it's loaded.
+func spamSignals() []signal {
+	signals := []signal{
+		{
+			name:       "reported",
+			perMessage: true,
+			weight:     func(cfg config.SpamFeed) int { return cfg.AnomalyScores.Reported },
+			describe: func(ctx context.Context, score int) string {
+				return text(ctx, messages.REASON_PREFIX+"reported", messages.Data{Score: score})
+			},
+			evaluate: func(ctx context.Context, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client) (int, error) {
+				return config.FromContext(ctx).SpamFeed.AnomalyScores.Reported, nil
+			},
+		},
+		{
+			name:      "low_activity",
+			userToken: true,
+			weight:    func(cfg config.SpamFeed) int { return cfg.AnomalyScores.LowActivity },
+			describe: func(ctx context.Context, score int) string {
+				return text(ctx, messages.REASON_PREFIX+"low_activity", messages.Data{Score: score})
+			},
+			evaluate: func(ctx context.Context, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client) (int, error) {
+				return userActivityScore(ctx, opMsg.User, userApi)
+			},
+		},
+		{
+			name:   "outside_tz",
+			weight: func(cfg config.SpamFeed) int { return cfg.AnomalyScores.OutsideTZ },
+			describe: func(ctx context.Context, score int) string {
+				return text(ctx, messages.REASON_PREFIX+"outside_tz", messages.Data{Score: score})
+			},
+			evaluate: func(ctx context.Context, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client) (int, error) {
+				return userTzScore(ctx, opMsg.User, api)
+			},
+		},
+		emailDomainSignal(),
+		impersonationSignal(),
+		duplicatesSignal(),
+	}
+	if blocklisted.Load() != nil {
+		signals = append(signals, blocklistSignal())
+	}
+	return signals
+}
+
+// signalDeadline is the overall time allowed for evaluating every signal.
+func signalDeadline(cfg config.SpamFeed) time.Duration {
+	if d := cfg.SignalDeadline; d > 0 {
+		return d
+	}
+	return defaultSignalDeadline
+}
+
+// evaluateSignals runs every signal concurrently under a shared deadline, each in
+// its own span. Signals still running at the deadline are resolved according to
+// spam_feed.signal_timeout_policy.
+func evaluateSignals(ctx context.Context, signals []signal, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client, logger zerolog.Logger) evaluation {
+	cfg := config.FromContext(ctx).SpamFeed
+	deadline := signalDeadline(cfg)
+	ctx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+
+	type indexed struct {
+		i int
+		signalResult
+	}
+	done := make(chan indexed, len(signals))
+	start := time.Now()
+	for i, s := range signals {
+		if s.userToken && userApi == nil {
+			done <- indexed{i, signalResult{name: s.name, skipped: true}}
+			continue
+		}
+		go func() {
+			ctx, span := tracing.Tracer(tracerName).Start(ctx, "signal "+s.name, trace.WithAttributes(attribute.String("penny.signal", s.name)))
+			defer span.End()
+			score, err := runSignal(ctx, s, opMsg, api, userApi)
+			span.SetAttributes(attribute.Int("penny.score", score))
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			done <- indexed{i, signalResult{name: s.name, score: score, err: err, elapsed: time.Since(start)}}
+		}()
+	}
+
+	results := make([]signalResult, len(signals))
+	finished := make([]bool, len(signals))
+	for remaining := len(signals); remaining > 0; remaining-- {
+		select {
+		case r := <-done:
+			results[r.i] = r.signalResult
+			finished[r.i] = true
+		case <-ctx.Done():
+			remaining = 0
+		}
+	}
+
+	policy := cfg.SignalTimeoutPolicy
+	var e evaluation
+	for i, s := range signals {
+		r := results[i]
+		if !finished[i] || (r.err != nil && errors.Is(r.err, context.DeadlineExceeded)) {
+			r = signalResult{name: s.name, timedOut: true, elapsed: deadline}
+		}
+
+		switch {
+		case r.skipped:
+			r.reason = text(ctx, messages.REASON_NO_USER_TOKEN, messages.Data{Signal: s.name})
+			logger.Debug().Str("signal", s.name).Msg("signal skipped, no user token")
+		case r.timedOut:
+			r.score = timedOutScore(policy, s.weight(cfg))
+			r.reason = text(ctx, messages.REASON_TIMED_OUT, messages.Data{Signal: s.name, Deadline: deadline, Policy: policy, Score: r.score})
+			if policy == TIMEOUT_POLICY_DEFER {
+				e.deferred = true
+			}
+			logger.Warn().Str("signal", s.name).Dur("deadline", deadline).Str("policy", policy).Msg("signal timed out")
+		case r.err != nil:
+			r.score = 0
+			logger.Error().Err(r.err).Str("signal", s.name).Msg("failed to evaluate signal")
+		case r.score != 0:
+			r.reason = s.describe(ctx, r.score)
+			r.detailed = s.detailed
+		}
+
+		e.score += r.score
+		e.results = append(e.results, r)
+		logger.Debug().Str("signal", s.name).Int("score", r.score).Dur("elapsed", r.elapsed).Msg("evaluated signal")
+	}
+
+	return e
+}
+
+// runSignal evaluates s, turning a panic into an error so that a broken signal
+// fails only itself rather than the process.
+func runSignal(ctx context.Context, s signal, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client) (score int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Interface("panic", r).Bytes("stack", debug.Stack()).Str("signal", s.name).Msg("signal panicked")
+			score, err = 0, fmt.Errorf("signal %s panicked: %v", s.name, r)
+		}
+	}()
+	return s.evaluate(ctx, opMsg, api, userApi)
+}
+
+func timedOutScore(policy string, weight int) int {
+	if policy == TIMEOUT_POLICY_MAX {
+		return weight
+	}
+	return 0
+}
@@ -0,0 +1,291 @@
+package hallmonitor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gadget-bot/gadget/router"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+// staticSignal returns a signal with a fixed weight that fires with score after delay.
+func staticSignal(name string, weight int, score int, delay time.Duration, err error) signal {
+	return signal{
+		name:     name,
+		weight:   func(config.SpamFeed) int { return weight },
+		describe: func(ctx context.Context, score int) string { return name },
+		evaluate: func(ctx context.Context, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client) (int, error) {
+			select {
+			case <-time.After(delay):
+				return score, err
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		},
+	}
+}
+
+// TestEvaluateSignals verifies concurrent evaluation and each timeout policy.
+func TestEvaluateSignals(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		signals      []signal
+		wantScore    int
+		wantDeferred bool
+		wantReasons  []string // substrings, in order
+	}{
+		{
+			name: "all signals finish",
+			signals: []signal{
+				staticSignal("fast", 2, 2, 0, nil),
+				staticSignal("slower", 1, 1, 10*time.Millisecond, nil),
+			},
+			wantScore:   3,
+			wantReasons: []string{"fast", "slower"},
+		},
+		{
+			name: "failing signal contributes nothing",
+			signals: []signal{
+				staticSignal("fast", 2, 2, 0, nil),
+				staticSignal("broken", 3, 3, 0, errors.New("boom")),
+			},
+			wantScore:   2,
+			wantReasons: []string{"fast"},
+		},
+		{
+			name:   "zero policy counts timed out signal as 0",
+			policy: TIMEOUT_POLICY_ZERO,
+			signals: []signal{
+				staticSignal("fast", 2, 2, 0, nil),
+				staticSignal("slow", 3, 3, time.Hour, nil),
+			},
+			wantScore:   2,
+			wantReasons: []string{"fast", "slow timed out after 50ms: counted as 0"},
+		},
+		{
+			name:   "empty policy behaves like zero",
+			policy: "",
+			signals: []signal{
+				staticSignal("slow", 3, 3, time.Hour, nil),
+			},
+			wantScore:   0,
+			wantReasons: []string{"counted as 0"},
+		},
+		{
+			name:   "max policy counts timed out signal at full weight",
+			policy: TIMEOUT_POLICY_MAX,
+			signals: []signal{
+				staticSignal("fast", 2, 2, 0, nil),
+				staticSignal("slow", 3, 0, time.Hour, nil),
+			},
+			wantScore:   5,
+			wantReasons: []string{"fast", "slow timed out after 50ms: counted as 3"},
+		},
+		{
+			name:   "defer policy marks the evaluation deferred",
+			policy: TIMEOUT_POLICY_DEFER,
+			signals: []signal{
+				staticSignal("fast", 2, 2, 0, nil),
+				staticSignal("slow", 3, 3, time.Hour, nil),
+			},
+			wantScore:    2,
+			wantDeferred: true,
+			wantReasons:  []string{"fast", "deferring removal to a human"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupViperConfig(t, map[string]interface{}{
+				"spam_feed.signal_deadline":       "50ms",
+				"spam_feed.signal_timeout_policy": tt.policy,
+			})
+
+			start := time.Now()
+			eval := evaluateSignals(context.Background(), tt.signals, slack.Message{}, &slackclient.MockClient{}, &slackclient.MockClient{}, zerolog.Nop())
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("evaluateSignals() took %s, expected it to stop at the deadline", elapsed)
+			}
+
+			if eval.score != tt.wantScore {
+				t.Errorf("score = %d, want %d", eval.score, tt.wantScore)
+			}
+			if eval.deferred != tt.wantDeferred {
+				t.Errorf("deferred = %v, want %v", eval.deferred, tt.wantDeferred)
+			}
+			reasons := eval.reasons()
+			if len(reasons) != len(tt.wantReasons) {
+				t.Fatalf("reasons = %v, want %d entries", reasons, len(tt.wantReasons))
+			}
+			for i, want := range tt.wantReasons {
+				if !strings.Contains(reasons[i], want) {
+					t.Errorf("reasons[%d] = %q, want it to contain %q", i, reasons[i], want)
+				}
+			}
+		})
+	}
+}
+
+// TestEvaluateSignalsRunsConcurrently verifies signals don't wait on each other.
+func TestEvaluateSignalsRunsConcurrently(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{"spam_feed.signal_deadline": "5s"})
+
+	signals := []signal{
+		staticSignal("a", 1, 1, 100*time.Millisecond, nil),
+		staticSignal("b", 1, 1, 100*time.Millisecond, nil),
+		staticSignal("c", 1, 1, 100*time.Millisecond, nil),
+	}
+	start := time.Now()
+	eval := evaluateSignals(context.Background(), signals, slack.Message{}, nil, nil, zerolog.Nop())
+	if elapsed := time.Since(start); elapsed >= 250*time.Millisecond {
+		t.Errorf("evaluateSignals() took %s, expected signals to run concurrently", elapsed)
+	}
+	if eval.score != 3 {
+		t.Errorf("score = %d, want 3", eval.score)
+	}
+}
+
+// TestEvaluateSignalsRecoversPanics verifies a panicking signal fails only itself.
+func TestEvaluateSignalsRecoversPanics(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{"spam_feed.signal_deadline": "5s"})
+
+	panicking := staticSignal("panicking", 3, 3, 0, nil)
+	panicking.evaluate = func(ctx context.Context, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client) (int, error) {
+		panic("boom")
+	}
+	eval := evaluateSignals(context.Background(), []signal{staticSignal("fast", 2, 2, 0, nil), panicking}, slack.Message{}, nil, nil, zerolog.Nop())
+	if eval.score != 2 {
+		t.Errorf("score = %d, want 2 from the signal that didn't panic", eval.score)
+	}
+	if err := eval.results[1].err; err == nil || !strings.Contains(err.Error(), "panicked") {
+		t.Errorf("panicking signal's error = %v, want the panic", err)
+	}
+}
+
+// TestEvaluateSignalsWithoutUserToken verifies signals that need the user
+// token are skipped, rather than called with a nil client, when Penny runs
+// without one.
+func TestEvaluateSignalsWithoutUserToken(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.anomaly_scores.reported":     2,
+		"spam_feed.anomaly_scores.low_activity": 3,
+		"spam_feed.activity_low_watermark":      10,
+	})
+	ctx := config.NewContext(context.Background(), config.Current())
+
+	op := slack.Message{Msg: slack.Msg{User: "U_OP", Channel: "C1", Timestamp: "1639843883.000100"}}
+	eval := evaluateSignals(ctx, spamSignals(), op, &slackclient.MockClient{}, nil, zerolog.Nop())
+	if eval.score != 2 {
+		t.Errorf("score = %d, want only reported's 2", eval.score)
+	}
+	for _, r := range eval.results {
+		if r.err != nil {
+			t.Errorf("%s failed with %v, want no errors", r.name, r.err)
+		}
+		if r.name == "low_activity" && (!r.skipped || !strings.Contains(r.reason, "no user token")) {
+			t.Errorf("low_activity = %+v, want it skipped for want of a user token", r)
+		}
+	}
+}
+
+// TestProcessSpamFeedMessageDeferred verifies a deferred evaluation never deletes.
+func TestProcessSpamFeedMessageDeferred(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.channel":                     "spam-feed",
+		"spam_feed.anomaly_scores.reported":     5,
+		"spam_feed.anomaly_scores.low_activity": 1,
+		"spam_feed.activity_low_watermark":      10,
+		"spam_feed.max_anomaly_score":           5,
+		"spam_feed.signal_deadline":             "50ms",
+		"spam_feed.signal_timeout_policy":       TIMEOUT_POLICY_DEFER,
+	})
+
+	spamFeedMsg := slack.Message{Msg: slack.Msg{Timestamp: "1111111111.000100", Channel: "C_SPAM"}}
+	opMsg := slack.Message{Msg: slack.Msg{Timestamp: "1639843883.000100", Channel: "C02BZ36790B", User: "U_OP"}}
+
+	var posts []string
+	mock := &slackclient.MockClient{
+		GetConversationInfoFn: func(input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+			return &slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{NameNormalized: "spam-feed"}}}, nil
+		},
+		JoinConversationFn: noopJoin,
+		GetConversationHistoryFn: historyFor(map[string]slack.Message{
+			"C_SPAM":      spamFeedMsg,
+			"C02BZ36790B": opMsg,
+		}),
+		PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+			posts = append(posts, channelID)
+			return channelID, "ts", nil
+		},
+		AddReactionFn: func(name string, item slack.ItemRef) error { return nil },
+	}
+	userMock := &slackclient.MockClient{
+		SearchMessagesContextFn: func(ctx context.Context, query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+			t.Errorf("expected DeleteMessage NOT to be called when removal is deferred")
+			return channel, messageTimestamp, nil
+		},
+	}
+
+	ev := slackevents.MessageEvent{SubType: BOT_MESSAGE_TYPE, Channel: "C_SPAM", TimeStamp: "1111111111.000100"}
+	ProcessSpamFeedMessage(router.Router{BotUID: "U_BOT"}, router.Route{}, mock, userMock, ev, "<https://orgname.slack.com/archives/C02BZ36790B/p1639843883000100>")
+
+	if len(posts) == 0 {
+		t.Errorf("expected the debug response to be posted")
+	}
+}
+
+// TestProcessSpamFeedMessageWithoutUserToken verifies a report scoring past the
+// threshold is held for review, rather than crashing, when Penny runs without
+// a user token to remove it with.
+func TestProcessSpamFeedMessageWithoutUserToken(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.channel":                     "spam-feed",
+		"spam_feed.anomaly_scores.reported":     5,
+		"spam_feed.anomaly_scores.low_activity": 3,
+		"spam_feed.activity_low_watermark":      10,
+		"spam_feed.max_anomaly_score":           5,
+	})
+	store := cases.NewMemoryStore()
+	UseCases(store)
+	t.Cleanup(func() { UseCases(nil) })
+
+	spamFeedMsg := slack.Message{Msg: slack.Msg{Timestamp: "1111111111.000100", Channel: "C_SPAM"}}
+	opMsg := slack.Message{Msg: slack.Msg{Timestamp: "1639843883.000100", Channel: "C02BZ36790B", User: "U_OP"}}
+	mock := &slackclient.MockClient{
+		GetConversationInfoFn: func(input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+			return &slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{NameNormalized: "spam-feed"}}}, nil
+		},
+		JoinConversationFn: noopJoin,
+		GetConversationHistoryFn: historyFor(map[string]slack.Message{
+			"C_SPAM":      spamFeedMsg,
+			"C02BZ36790B": opMsg,
+		}),
+		PostMessageFn: noopPost,
+		AddReactionFn: func(name string, item slack.ItemRef) error { return nil },
+	}
+
+	ev := slackevents.MessageEvent{SubType: BOT_MESSAGE_TYPE, Channel: "C_SPAM", TimeStamp: "1111111111.000100"}
+	ProcessSpamFeedMessage(router.Router{BotUID: "U_BOT"}, router.Route{}, mock, nil, ev, "<https://orgname.slack.com/archives/C02BZ36790B/p1639843883000100>")
+
+	c, err := store.Case(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Case(1) error = %v", err)
+	}
+	if c.Verdict != messages.VERDICT_HELD || c.Score != 5 {
+		t.Errorf("case = %+v, want held at 5 with low_activity skipped", c)
+	}
+}
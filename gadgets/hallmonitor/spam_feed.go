@@ -1,32 +1,61 @@
 package hallmonitor
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gadget-bot/gadget/router"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
-	"github.com/spf13/viper"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/config"
 	"github.com/xortim/penny/pkg/conversations"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/metrics"
 	"github.com/xortim/penny/pkg/parsers"
 	"github.com/xortim/penny/pkg/slackclient"
+	"github.com/xortim/penny/pkg/tracing"
+	"github.com/xortim/penny/pkg/webhooks"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	BOT_MESSAGE_TYPE = "bot_message"
 	REACJI_USERNAME  = "Reacji Channeler"
+
+	tracerName = "github.com/xortim/penny/gadgets/hallmonitor"
 )
 
-func removalReply() string {
-	message := "Your message was reported by the community as SPAM and I've removed this post."
+// removalReply is the notice posted on the OP's message, in locale, before it's removed.
+func removalReply(ctx context.Context, locale string) string {
+	cfg := config.FromContext(ctx).SpamFeed
+	return messages.Text(locale, messages.REMOVAL, messages.Data{AssistanceChannel: cfg.AssistanceChannelID})
+}
 
-	if len(viper.GetString("spam_feed.assistance_channel_id")) != 0 {
-		message = fmt.Sprintf("%s. Please join <#%s> if you have questions.", message, viper.GetString("spam_feed.assistance_channel_id"))
+// text renders message id in the workspace's locale.
+func text(ctx context.Context, id string, data messages.Data) string {
+	return messages.Text(config.FromContext(ctx).Messages.Locale, id, data)
+}
+
+// userLocale is the locale to message uid in: their own Slack locale if
+// messages.user_locale is on and there are messages for it, otherwise the
+// workspace's.
+func userLocale(ctx context.Context, uid string, api slackclient.Client) string {
+	cfg := config.FromContext(ctx).Messages
+	if !cfg.UserLocale {
+		return cfg.Locale
+	}
+	user, err := api.GetUserInfoContext(ctx, uid)
+	if err != nil || !messages.Current().Has(user.Locale) {
+		return cfg.Locale
 	}
-	return message
+	return user.Locale
 }
 
 func monitorSpamFeedMessages() *router.ChannelMessageRoute {
@@ -34,31 +63,71 @@ func monitorSpamFeedMessages() *router.ChannelMessageRoute {
 	pluginRoute.Name = "hallmonitor.monitorSpamFeed"
 	pluginRoute.Pattern = `.*`
 	pluginRoute.Plugin = func(ctx router.HandlerContext, ev slackevents.MessageEvent, message string) {
-		ProcessSpamFeedMessage(ctx.Router, ctx.Route, ctx.BotClient, ctx.UserClient, ev, message)
+		api, userApi := clientsFor(ctx)
+		if queue == nil {
+			ProcessSpamFeedMessage(ctx.Router, ctx.Route, api, userApi, ev, message)
+			return
+		}
+		// only queue the original, unfurled message; everything else is dropped by ProcessSpamFeedMessage anyway
+		if !repost(ctx.Router, ev) {
+			return
+		}
+		err := queue.Submit(job{router: ctx.Router, route: ctx.Route, api: api, userApi: userApi, ev: ev, message: message})
+		if err != nil {
+			ctx.Logger.Error().Err(err).Str("channel_id", ev.Channel).Str("event_ts", ev.TimeStamp).Msg("dropped spam feed message")
+		}
 	}
 	return &pluginRoute
 }
 
+// repost reports whether ev could be a report reposted to the spam feed. Penny's
+// own posts there, about messages it screened, aren't.
+func repost(r router.Router, ev slackevents.MessageEvent) bool {
+	if ev.User != "" && ev.User == r.BotUID {
+		return false
+	}
+	return ev.SubType == BOT_MESSAGE_TYPE || ev.Username == REACJI_USERNAME
+}
+
 // ProcessSpamFeedMessage contains the testable core logic extracted from handleSpamFeedMessage.
 // Exported so that integration tests can inject both API clients.
 func ProcessSpamFeedMessage(r router.Router, route router.Route, api slackclient.Client, userApi slackclient.Client, ev slackevents.MessageEvent, message string) {
+	// Settings are fixed for the whole event, even if the config is reloaded meanwhile.
+	settings := config.Current()
+	cfg := settings.SpamFeed
 	logger := log.With().Str("channel_id", ev.Channel).Str("event_ts", ev.TimeStamp).Logger()
 
 	// only look at the original, unfurled message
-	if ev.SubType != BOT_MESSAGE_TYPE && ev.Username != REACJI_USERNAME {
+	if !repost(r, ev) {
 		return
 	}
 
+	ctx, span := tracing.Tracer(tracerName).Start(context.Background(), "hallmonitor.ProcessSpamFeedMessage", trace.WithAttributes(
+		attribute.String("slack.channel", ev.Channel),
+		attribute.String("slack.event_ts", ev.TimeStamp),
+	))
+	defer span.End()
+	ctx = config.NewContext(ctx, settings)
+	logger = tracing.Logger(ctx, logger)
+	api = slackclient.NewTracingClient(ctx, api)
+	if userApi != nil {
+		userApi = slackclient.NewTracingClient(ctx, userApi)
+	}
+
 	// only look at messages in the correct channel.
 	channelInfo, err := api.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: ev.Channel})
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to get conversation info")
 	}
-	if channelInfo.NameNormalized != viper.GetString("spam_feed.channel") {
+	if channelInfo.NameNormalized != cfg.Channel {
 		return
 	}
 
 	logger.Info().Msg("processing spam feed message")
+	feed := channelInfo.NameNormalized
+	defer func(start time.Time) {
+		metrics.HandlerDuration.WithLabelValues(feed).Observe(time.Since(start).Seconds())
+	}(time.Now())
 
 	spamFeedMsgRef := slack.NewRefToMessage(ev.Channel, ev.TimeStamp)
 	spamFeedMsg, err := conversations.MsgRefToMessage(spamFeedMsgRef, api)
@@ -76,143 +145,341 @@ func ProcessSpamFeedMessage(r router.Router, route router.Route, api slackclient
 		opMsg, err = conversations.MsgRefToMessage(opMsgRef, api)
 	}
 	if err != nil {
-		_, _, _ = conversations.ThreadedReplyToMsg(spamFeedMsg, "I couldn't retrieve the original message from the Slack API.", api)
+		_, _, _ = conversations.ThreadedReplyToMsg(spamFeedMsg, text(ctx, messages.OP_NOT_FOUND, messages.Data{}), api)
 		return
 	}
 
-	reporters := conversations.WhoReactedWithAsMention(opMsg, viper.GetString("spam_feed.emoji"))
+	reporters := conversations.WhoReactedWithAsMention(opMsg, cfg.Emoji)
 
 	// acknowledge the users that reported message
-	ack := ""
-	if len(reporters) != 0 {
-		ack = fmt.Sprintf("Thanks %s! ", strings.Join(reporters, ","))
-	}
-	if len(viper.GetString("spam_feed.reacji_response")) != 0 {
-		ack = fmt.Sprintf("%s%s", ack, viper.GetString("spam_feed.reacji_response"))
-	}
+	ack := text(ctx, messages.ACK, messages.Data{Reporters: reporters, Response: cfg.ReacjiResponse})
 	_, _, err = conversations.ThreadedReplyToMsg(spamFeedMsg, ack, api)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to send acknowledgment reply")
 	}
 
 	if opMsg.User == r.BotUID {
-		_, _, err = conversations.ThreadedReplyToMsg(spamFeedMsg, "Hey! That's not nice.", api)
+		_, _, err = conversations.ThreadedReplyToMsg(spamFeedMsg, text(ctx, messages.SELF_REPORT, messages.Data{}), api)
 		if err != nil {
 			logger.Error().Err(err).Msg("failed to reply to spam feed message")
 		}
 		return
 	}
 
-	removed := false
-	score, reasons := anomalyScoreInternal(opMsg, api, userApi, logger)
+	// Reports against the same author are handled one at a time. Within the
+	// coalescing window the author's earlier signals are reused, only the
+	// message's own are evaluated again, and the report joins the burst's case.
+	burst := bursts.acquire(opMsg.User)
+	defer bursts.release(opMsg.User, burst)
+
+	earlier, burstCase, coalesced := bursts.recent(burst)
+	var eval evaluation
+	if coalesced {
+		logger.Info().Str("op_user", opMsg.User).Uint("case", burstCase).Msg("coalesced with an earlier report for the same author")
+		eval = coalesce(ctx, earlier, opMsg, api, userApi, logger)
+	} else {
+		eval = anomalyScoreInternal(ctx, opMsg, api, userApi, logger)
+	}
+	observeEvaluation(feed, eval)
+
+	threshold, probationary := reportThreshold(ctx, opMsg.User)
+	caseID := judge(ctx, report{
+		feed:        feed,
+		feedMsg:     spamFeedMsg,
+		op:          opMsg,
+		permalink:   strings.Trim(message, "<>"),
+		reporters:   reporters,
+		reporterIDs: conversations.WhoReactedWith(opMsg, cfg.Emoji),
+		threshold:   threshold,
+		probation:   probationary,
+		burstCase:   burstCase,
+	}, eval, api, userApi, logger)
+	if !coalesced {
+		bursts.remember(burst, eval, caseID)
+	}
+}
+
+// report is a message on its way to a verdict: reported by members through
+// the spam feed, or screened by Penny before anyone reported it.
+type report struct {
+	feed        string        // the spam feed's name
+	feedMsg     slack.Message // the spam-feed message the verdict is threaded on
+	op          slack.Message
+	permalink   string
+	reporters   []string // as mentions
+	reporterIDs []string
+	threshold   int  // the score at which op is removed
+	screened    bool // by Penny, rather than reported
+	probation   bool // the threshold is the stricter one for members on probation
+	burstCase   uint // the case of an earlier report in the same burst, if any
+}
+
+// judge acts on eval: it removes or keeps the OP, records the case, answers in
+// the spam feed and sends the webhooks, and returns the case's ID. Screened
+// messages never warn the OP and aren't escalated, since posting them to the
+// spam feed already asked the moderators to look. A report in a burst is
+// recorded under the burst's case rather than opening its own.
+func judge(ctx context.Context, rep report, eval evaluation, api slackclient.Client, userApi slackclient.Client, logger zerolog.Logger) uint {
+	cfg := config.FromContext(ctx).SpamFeed
+	opMsg, feed, score := rep.op, rep.feed, eval.score
+	removable := score >= rep.threshold && (!rep.screened || cfg.Proactive.RemoveScore > 0)
+	// A reported message never counts towards lifting probation.
+	probation.reported(opMsg.User, opMsg.Timestamp)
+
+	removed, held := false, false
+	if eval.deferred {
+		logger.Info().Int("score", score).Int("threshold", rep.threshold).Msg("removal deferred, signals timed out")
+	} else if removable && userApi == nil {
+		logger.Warn().Int("score", score).Int("threshold", rep.threshold).Msg("removal held, no user token to remove with")
+		metrics.Removals.WithLabelValues(feed, metrics.RESULT_HELD).Inc()
+		held = true
+	} else if removable && !removalAllowed(ctx, opMsg.Channel, api) {
+		logger.Warn().Int("score", score).Int("threshold", rep.threshold).Msg("removal held, review-only mode")
+		metrics.Removals.WithLabelValues(feed, metrics.RESULT_HELD).Inc()
+		held = true
+	} else if removable {
+		removed = true
+	}
+	outcome := outcomeOf(removed, held, eval)
+
+	// The case is recorded before acting on it, so notices can offer an appeal,
+	// and corrected if the removal fails. A report in a burst already has its
+	// case, so it's recorded once the outcome is known.
+	caseID := rep.burstCase
+	if caseID == 0 {
+		caseID = recordCase(ctx, &cases.Case{
+			Channel:         opMsg.Channel,
+			Timestamp:       opMsg.Timestamp,
+			ThreadTimestamp: opMsg.ThreadTimestamp,
+			Author:          opMsg.User,
+			Domain:          authorDomain(ctx, opMsg.User, api),
+			Text:            opMsg.Text,
+			Permalink:       rep.permalink,
+			Reporters:       strings.Join(rep.reporterIDs, ","),
+			Score:           score,
+			Threshold:       rep.threshold,
+			Verdict:         outcome,
+		})
+	}
+	appeal := uint(0)
+	if appealsEnabled(ctx) {
+		appeal = caseID
+	}
 
-	if score >= viper.GetInt("spam_feed.max_anomaly_score") {
-		logger.Info().Int("score", score).Int("threshold", viper.GetInt("spam_feed.max_anomaly_score")).Msg("message removed")
-		_, _, err = conversations.ThreadedReplyToMsg(opMsg, removalReply(), api)
+	var err error
+	actions := []string{}
+	if removed {
+		logger.Info().Int("score", score).Int("threshold", rep.threshold).Msg("message removed")
+		locale := userLocale(ctx, opMsg.User, api)
+		err = notifyOP(ctx, opMsg, removalReply(ctx, locale), true, locale, appeal, api)
 		if err != nil {
 			logger.Error().Err(err).Msg("failed to warn OP before removal")
+		} else {
+			actions = append(actions, webhooks.ACTION_NOTIFIED)
 		}
 		_, _, err = userApi.DeleteMessage(opMsg.Channel, opMsg.Timestamp)
 		if err != nil {
 			logger.Error().Err(err).Str("op_channel", opMsg.Channel).Str("op_ts", opMsg.Timestamp).Msg("failed to delete message")
+			metrics.Removals.WithLabelValues(feed, metrics.RESULT_ERROR).Inc()
+			breaker.release(opMsg.Channel)
+			// The message is still up, so it's held for the moderators.
+			removed, held = false, true
+			outcome = outcomeOf(removed, held, eval)
+			if rep.burstCase == 0 {
+				setVerdict(ctx, caseID, outcome)
+			}
+		} else {
+			metrics.Removals.WithLabelValues(feed, metrics.RESULT_OK).Inc()
+			actions = append(actions, webhooks.ACTION_REMOVED)
+			probation.reset(opMsg.User)
 		}
-		removed = true
-	} else {
-		logger.Info().Int("score", score).Int("threshold", viper.GetInt("spam_feed.max_anomaly_score")).Msg("below threshold")
-		if len(viper.GetString("spam_feed.op_warning")) != 0 {
-			_, _, err = conversations.ThreadedReplyToMsg(opMsg, viper.GetString("spam_feed.op_warning"), api)
+	} else if outcome == messages.VERDICT_KEPT && !rep.screened {
+		logger.Info().Int("score", score).Int("threshold", rep.threshold).Msg("below threshold")
+		if len(cfg.OpWarning) != 0 {
+			locale := ""
+			if appeal != 0 {
+				locale = userLocale(ctx, opMsg.User, api)
+			}
+			err = notifyOP(ctx, opMsg, cfg.OpWarning, false, locale, appeal, api)
 			if err != nil {
 				logger.Error().Err(err).Msg("failed to warn OP")
+			} else {
+				metrics.Warnings.WithLabelValues(feed).Inc()
+				actions = append(actions, webhooks.ACTION_NOTIFIED)
 			}
 		}
 	}
 
-	err = addAnomalyReaction(removed, spamFeedMsgRef, api)
+	if rep.burstCase != 0 {
+		recordReport(ctx, &cases.Report{
+			CaseID:          caseID,
+			Channel:         opMsg.Channel,
+			Timestamp:       opMsg.Timestamp,
+			ThreadTimestamp: opMsg.ThreadTimestamp,
+			Text:            opMsg.Text,
+			Permalink:       rep.permalink,
+			Reporters:       strings.Join(rep.reporterIDs, ","),
+			Score:           score,
+			Verdict:         outcome,
+		})
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("penny.op_user", opMsg.User),
+		attribute.Int("penny.score", score),
+		attribute.Bool("penny.coalesced", eval.coalesced),
+		attribute.Bool("penny.deferred", eval.deferred),
+		attribute.Bool("penny.held", held),
+		attribute.Bool("penny.removed", removed),
+		attribute.Bool("penny.screened", rep.screened),
+	)
+
+	err = addAnomalyReaction(ctx, removed, slack.NewRefToMessage(rep.feedMsg.Channel, rep.feedMsg.Timestamp), api)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to add anomaly reaction")
 	}
 
-	err = addDebugResponse(removed, score, reasons, spamFeedMsg, api)
+	v := verdict{
+		outcome:   outcome,
+		eval:      eval,
+		threshold: rep.threshold,
+		op:        opMsg,
+		permalink: rep.permalink,
+		reporters: rep.reporters,
+		screened:  rep.screened,
+		probation: rep.probation,
+	}
+	err = addDebugResponse(ctx, v, rep.feedMsg, api)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to post debug response")
 	}
-}
 
-func anomalyScoreInternal(opMsg slack.Message, api slackclient.Client, userApi slackclient.Client, logger zerolog.Logger) (int, []string) {
-	reasons := make([]string, 0)
-	score := viper.GetInt("spam_feed.anomaly_scores.reported")
-	if score != 0 {
-		reasons = append(reasons, fmt.Sprintf("reported by the community as being spammy: %d", viper.GetInt("spam_feed.anomaly_scores.reported")))
+	// A coalesced report was already escalated, if it needed to be.
+	if outcome == messages.VERDICT_KEPT && !rep.screened && !eval.coalesced && shouldEscalate(ctx, score, rep.threshold) && escalate(ctx, v, rep.feedMsg, feed, api) {
+		actions = append(actions, webhooks.ACTION_ESCALATED)
 	}
 
-	activityScore, err := userActivityScore(opMsg.User, userApi)
-	if err != nil {
-		logger.Error().Err(err).Msg("failed to retrieve user activity")
+	mirrored := webhookCase(caseID, v, rep.reporterIDs, actions)
+	if !eval.coalesced {
+		hooks.Send(webhooks.EVENT_CASE_CREATED, mirrored)
 	}
-	if activityScore != 0 {
-		score += activityScore
-		reasons = append(reasons, fmt.Sprintf("below the public activity low watermark: %d", activityScore))
-		logger.Debug().Int("anomaly_score", score).Msg("added activity score")
+	if slices.Contains(actions, webhooks.ACTION_REMOVED) {
+		hooks.Send(webhooks.EVENT_MESSAGE_REMOVED, mirrored)
 	}
+	return caseID
+}
 
-	tzScore, err := userTzScore(opMsg.User, api)
-	if err != nil {
-		logger.Error().Err(err).Msg("failed to check user timezone")
+// anomalyScoreInternal evaluates every spam signal concurrently for the OP's message.
+func anomalyScoreInternal(ctx context.Context, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client, logger zerolog.Logger) evaluation {
+	eval := evaluateSignals(ctx, spamSignals(), opMsg, api, userApi, logger)
+	logger.Info().Int("anomaly_score", eval.score).Int("reasons", len(eval.reasons())).Bool("deferred", eval.deferred).Msg("anomaly score calculated")
+	return eval
+}
+
+// coalesce judges another message in an author's burst: the author signals
+// of the earlier evaluation are reused, and the signals that depend on the
+// message itself are evaluated again.
+func coalesce(ctx context.Context, earlier evaluation, opMsg slack.Message, api slackclient.Client, userApi slackclient.Client, logger zerolog.Logger) evaluation {
+	var perMessage []signal
+	for _, s := range spamSignals() {
+		if s.perMessage {
+			perMessage = append(perMessage, s)
+		}
 	}
-	if tzScore != 0 {
-		score += tzScore
-		reasons = append(reasons, fmt.Sprintf("outside of the community timezone: %d", tzScore))
-		logger.Debug().Int("anomaly_score", score).Msg("added timezone score")
+	fresh := evaluateSignals(ctx, perMessage, opMsg, api, userApi, logger)
+	byName := make(map[string]signalResult, len(fresh.results))
+	for _, r := range fresh.results {
+		byName[r.name] = r
 	}
 
-	logger.Info().Int("anomaly_score", score).Int("reasons", len(reasons)).Msg("anomaly score calculated")
-	return score, reasons
+	eval := evaluation{deferred: fresh.deferred, coalesced: true}
+	for _, r := range earlier.results {
+		if f, ok := byName[r.name]; ok {
+			r = f
+		} else {
+			r.reused = true
+		}
+		eval.score += r.score
+		eval.results = append(eval.results, r)
+	}
+	logger.Info().Int("anomaly_score", eval.score).Bool("deferred", eval.deferred).Msg("anomaly score recalculated for the burst")
+	return eval
+}
+
+// observeEvaluation records a report's score and each signal's contribution.
+// Signals reused from an earlier report were already counted with it.
+func observeEvaluation(feed string, eval evaluation) {
+	metrics.ReportsProcessed.WithLabelValues(feed).Inc()
+	metrics.AnomalyScores.WithLabelValues(feed).Observe(float64(eval.score))
+	for _, r := range eval.results {
+		if r.reused {
+			continue
+		}
+		if r.timedOut {
+			metrics.SignalTimeouts.WithLabelValues(feed, r.name).Inc()
+		}
+		if r.score != 0 {
+			metrics.SignalContributions.WithLabelValues(feed, r.name).Add(float64(r.score))
+		}
+	}
 }
 
 // userActivityScore performs a public activity search for the specified user and returns
 // the configured anomaly score if the total results are below the low watermark.
-func userActivityScore(uid string, api slackclient.Client) (int, error) {
-	if viper.GetInt("spam_feed.activity_low_watermark") == 0 {
+func userActivityScore(ctx context.Context, uid string, api slackclient.Client) (int, error) {
+	cfg := config.FromContext(ctx).SpamFeed
+	if cfg.ActivityLowWatermark == 0 {
 		return 0, nil
 	}
 
-	searchQuery := fmt.Sprintf("after:2021/12/01 from:<@%s>", uid)
-
-	results, err := api.SearchMessages(searchQuery, slack.NewSearchParameters())
+	count, err := publicMessages(ctx, uid, api)
 	if err != nil {
 		return 0, err
 	}
 
-	if results.TotalCount < viper.GetInt("spam_feed.activity_low_watermark") {
-		return viper.GetInt("spam_feed.anomaly_scores.low_activity"), nil
+	if count < cfg.ActivityLowWatermark {
+		return cfg.AnomalyScores.LowActivity, nil
 	}
 
 	return 0, nil
 }
 
-func userTzScore(uid string, api slackclient.Client) (int, error) {
-	if len(viper.GetString("spam_feed.local_timezone")) == 0 {
+// publicMessages counts the user's messages that a public activity search finds.
+func publicMessages(ctx context.Context, uid string, api slackclient.Client) (int, error) {
+	searchQuery := fmt.Sprintf("after:2021/12/01 from:<@%s>", uid)
+
+	results, err := api.SearchMessagesContext(ctx, searchQuery, slack.NewSearchParameters())
+	if err != nil {
+		return 0, err
+	}
+	return results.TotalCount, nil
+}
+
+func userTzScore(ctx context.Context, uid string, api slackclient.Client) (int, error) {
+	cfg := config.FromContext(ctx).SpamFeed
+	if len(cfg.LocalTimezone) == 0 {
 		return 0, nil
 	}
 
-	user, err := api.GetUserInfo(uid)
+	user, err := api.GetUserInfoContext(ctx, uid)
 	if err != nil {
 		return 0, err
 	}
 
-	if user.TZ != viper.GetString("spam_feed.local_timezone") {
-		return viper.GetInt("spam_feed.anomaly_scores.outside_tz"), nil
+	if user.TZ != cfg.LocalTimezone {
+		return cfg.AnomalyScores.OutsideTZ, nil
 	}
 	return 0, nil
 }
 
-func addAnomalyReaction(removed bool, msgRef slack.ItemRef, api slackclient.Client) error {
-	emoji_conf := "spam_feed.reaction_emoji_miss"
+func addAnomalyReaction(ctx context.Context, removed bool, msgRef slack.ItemRef, api slackclient.Client) error {
+	cfg := config.FromContext(ctx).SpamFeed
+	emoji := cfg.ReactionEmojiMiss
 	if removed {
-		emoji_conf = "spam_feed.reaction_emoji_hit"
+		emoji = cfg.ReactionEmojiHit
 	}
-	if len(viper.GetString(emoji_conf)) != 0 {
-		err := api.AddReaction(viper.GetString(emoji_conf), msgRef)
+	if len(emoji) != 0 {
+		err := api.AddReaction(emoji, msgRef)
 		if err != nil {
 			return err
 		}
@@ -220,19 +487,34 @@ func addAnomalyReaction(removed bool, msgRef slack.ItemRef, api slackclient.Clie
 	return nil
 }
 
-func addDebugResponse(removed bool, score int, reasons []string, msg slack.Message, api slackclient.Client) error {
-	var err error
-	if len(reasons) != 0 {
-		debugResponse := "This is what I found about the OP:\n"
-		for _, v := range reasons {
-			debugResponse += fmt.Sprintf("- %s\n", v)
-		}
-		if removed {
-			debugResponse += fmt.Sprintf("I removed the OP since the final anomaly score (%d/%d) was suspect enough.", score, viper.GetInt("spam_feed.max_anomaly_score"))
-		} else {
-			debugResponse += fmt.Sprintf("The final anomaly score (%d/%d) didn't result in a removal.", score, viper.GetInt("spam_feed.max_anomaly_score"))
-		}
-		_, _, err = conversations.ThreadedReplyToMsg(msg, debugResponse, api)
+// removalAllowed asks the removal breaker whether a removal in channel may go
+// ahead, counting it if so, and records the trip and alerting the admins if this removal is the one
+// that trips it.
+func removalAllowed(ctx context.Context, channel string, api slackclient.Client) bool {
+	allowed, tripped := breaker.allow(channel)
+	if tripped {
+		trip, _ := breaker.status()
+		log.Warn().Str("trip", trip.String()).Msg("removal breaker tripped, entering review-only mode")
+		savePause(ctx, trip)
+		alertAdmins(trip, api)
+	}
+	return allowed
+}
+
+// addDebugResponse posts v to the spam feed thread as Block Kit, with the
+// plain-text verdict as its fallback.
+func addDebugResponse(ctx context.Context, v verdict, msg slack.Message, api slackclient.Client) error {
+	reasons := v.eval.reasons()
+	if len(reasons) == 0 {
+		return nil
 	}
+	fallback := text(ctx, messages.DEBUG, messages.Data{
+		Reasons:   reasons,
+		Verdict:   v.outcome,
+		Coalesced: v.eval.coalesced,
+		Score:     v.eval.score,
+		Threshold: v.threshold,
+	})
+	_, _, err := conversations.ThreadedBlocksReplyToMsg(msg, fallback, verdictBlocks(ctx, v), api)
 	return err
 }
@@ -1,27 +1,43 @@
 package hallmonitor
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/url"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gadget-bot/gadget/router"
 	"github.com/rs/zerolog"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"github.com/spf13/viper"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/messages"
 	"github.com/xortim/penny/pkg/slackclient"
+	"github.com/xortim/penny/pkg/webhooks"
 )
 
 // setupViperConfig sets the given keys in the global viper instance and registers
-// a cleanup that calls viper.Reset() after each test.
+// the typed config loaded from them, with a cleanup that resets both.
 func setupViperConfig(t *testing.T, cfg map[string]interface{}) {
 	t.Helper()
 	for k, v := range cfg {
 		viper.Set(k, v)
 	}
-	t.Cleanup(viper.Reset)
+	loaded, err := config.Load(viper.GetViper())
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	config.Store(loaded)
+	t.Cleanup(func() {
+		viper.Reset()
+		config.Store(nil)
+	})
 }
 
 // noopJoin is a JoinConversation stub that always succeeds.
@@ -46,11 +62,21 @@ func historyFor(byChannel map[string]slack.Message) func(*slack.GetConversationH
 	}
 }
 
+// evaluationWithReasons builds an evaluation whose debug reasons are exactly reasons.
+func evaluationWithReasons(score int, reasons []string) evaluation {
+	eval := evaluation{score: score}
+	for _, r := range reasons {
+		eval.results = append(eval.results, signalResult{reason: r})
+	}
+	return eval
+}
+
 // TestRemovalReply verifies the removal reply message with and without an assistance channel configured.
 func TestRemovalReply(t *testing.T) {
 	tests := []struct {
 		name              string
 		assistanceChannel string
+		locale            string
 		want              string
 	}{
 		{
@@ -62,6 +88,17 @@ func TestRemovalReply(t *testing.T) {
 			assistanceChannel: "CEXAMPLE",
 			want:              "Your message was reported by the community as SPAM and I've removed this post.. Please join <#CEXAMPLE> if you have questions.",
 		},
+		{
+			name:              "Spanish",
+			assistanceChannel: "CEXAMPLE",
+			locale:            "es-ES",
+			want:              "La comunidad reportó tu mensaje como SPAM y lo he eliminado. Si tienes preguntas, únete a <#CEXAMPLE>.",
+		},
+		{
+			name:   "Locale without messages falls back to English",
+			locale: "xx-YY",
+			want:   "Your message was reported by the community as SPAM and I've removed this post.",
+		},
 	}
 
 	for _, tt := range tests {
@@ -69,7 +106,7 @@ func TestRemovalReply(t *testing.T) {
 			setupViperConfig(t, map[string]interface{}{
 				"spam_feed.assistance_channel_id": tt.assistanceChannel,
 			})
-			got := removalReply()
+			got := removalReply(context.Background(), tt.locale)
 			if got != tt.want {
 				t.Errorf("removalReply() = %q, want %q", got, tt.want)
 			}
@@ -77,6 +114,61 @@ func TestRemovalReply(t *testing.T) {
 	}
 }
 
+// TestUserLocale verifies that the OP's own locale is only used when enabled
+// and when there are messages for it.
+func TestUserLocale(t *testing.T) {
+	tests := []struct {
+		name       string
+		userLocale bool
+		slack      string
+		err        error
+		want       string
+	}{
+		{name: "Disabled uses the workspace locale", slack: "es-ES", want: "en"},
+		{name: "Enabled uses the user's locale", userLocale: true, slack: "es-ES", want: "es-ES"},
+		{name: "Locale without messages", userLocale: true, slack: "ja-JP", want: "en"},
+		{name: "GetUserInfo error", userLocale: true, err: errors.New("user_not_found"), want: "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupViperConfig(t, map[string]interface{}{
+				"messages.locale":      "en",
+				"messages.user_locale": tt.userLocale,
+			})
+			api := &slackclient.MockClient{
+				GetUserInfoFn: func(user string) (*slack.User, error) {
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &slack.User{ID: user, Locale: tt.slack}, nil
+				},
+			}
+			if got := userLocale(context.Background(), "U123", api); got != tt.want {
+				t.Errorf("userLocale() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRemovalReplyKeepsEventConfig verifies that a reload doesn't change the
+// settings of an event already being handled.
+func TestRemovalReplyKeepsEventConfig(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{"spam_feed.assistance_channel_id": "CBEFORE"})
+	ctx := config.NewContext(context.Background(), config.Current())
+
+	reloaded := *config.Current()
+	reloaded.SpamFeed.AssistanceChannelID = "CAFTER"
+	config.Store(&reloaded)
+
+	if got := removalReply(ctx, ""); !strings.Contains(got, "<#CBEFORE>") {
+		t.Errorf("removalReply() = %q, want the channel from when the event started", got)
+	}
+	if got := removalReply(context.Background(), ""); !strings.Contains(got, "<#CAFTER>") {
+		t.Errorf("removalReply() for a new event = %q, want the reloaded channel", got)
+	}
+}
+
 // TestUserTzScore verifies timezone anomaly scoring.
 func TestUserTzScore(t *testing.T) {
 	tests := []struct {
@@ -134,7 +226,7 @@ func TestUserTzScore(t *testing.T) {
 				},
 			}
 
-			got, err := userTzScore("U123", mock)
+			got, err := userTzScore(context.Background(), "U123", mock)
 			if tt.wantErr && err == nil {
 				t.Errorf("userTzScore() expected error, got nil")
 			}
@@ -205,7 +297,7 @@ func TestUserActivityScore(t *testing.T) {
 				},
 			}
 
-			got, err := userActivityScore("U123", mock)
+			got, err := userActivityScore(context.Background(), "U123", mock)
 			if tt.wantErr && err == nil {
 				t.Errorf("userActivityScore() expected error, got nil")
 			}
@@ -276,7 +368,7 @@ func TestAddAnomalyReaction(t *testing.T) {
 				},
 			}
 
-			err := addAnomalyReaction(tt.removed, msgRef, mock)
+			err := addAnomalyReaction(context.Background(), tt.removed, msgRef, mock)
 			if tt.wantErr && err == nil {
 				t.Errorf("addAnomalyReaction() expected error, got nil")
 			}
@@ -342,20 +434,33 @@ func TestAddDebugResponse(t *testing.T) {
 			setupViperConfig(t, tt.config)
 
 			called := false
+			var values url.Values
 			mock := &slackclient.MockClient{
 				PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
 					called = true
+					_, values, _ = slack.UnsafeApplyMsgOptions("", channelID, "", options...)
 					return channelID, "ts", nil
 				},
 			}
 
-			err := addDebugResponse(tt.removed, tt.score, tt.reasons, spamFeedMsg, mock)
+			eval := evaluationWithReasons(tt.score, tt.reasons)
+			v := verdict{outcome: outcomeOf(tt.removed, false, eval), eval: eval, threshold: 5}
+			err := addDebugResponse(context.Background(), v, spamFeedMsg, mock)
 			if err != nil {
 				t.Fatalf("addDebugResponse() unexpected error: %v", err)
 			}
 			if called != tt.wantPostCall {
 				t.Errorf("addDebugResponse() PostMessage called = %v, want %v", called, tt.wantPostCall)
 			}
+			if !called {
+				return
+			}
+			if got := values.Get("text"); !strings.Contains(got, tt.wantContains) {
+				t.Errorf("addDebugResponse() fallback = %q, want it to contain %q", got, tt.wantContains)
+			}
+			if values.Get("blocks") == "" {
+				t.Error("addDebugResponse() posted no blocks")
+			}
 		})
 	}
 }
@@ -490,9 +595,10 @@ func TestAnomalyScoreInternal(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			setupViperConfig(t, tt.config)
 
-			score, reasons := anomalyScoreInternal(opMsg, tt.api, tt.userApi, zerolog.Nop())
-			if score != tt.wantScore {
-				t.Errorf("anomalyScoreInternal() score = %d, want %d", score, tt.wantScore)
+			eval := anomalyScoreInternal(context.Background(), opMsg, tt.api, tt.userApi, zerolog.Nop())
+			reasons := eval.reasons()
+			if eval.score != tt.wantScore {
+				t.Errorf("anomalyScoreInternal() score = %d, want %d", eval.score, tt.wantScore)
 			}
 			if len(reasons) != tt.wantReasons {
 				t.Errorf("anomalyScoreInternal() reasons count = %d, want %d (reasons: %v)", len(reasons), tt.wantReasons, reasons)
@@ -560,6 +666,14 @@ func TestProcessSpamFeedMessage(t *testing.T) {
 		// Pass: no panics (no API calls made)
 	})
 
+	t.Run("Early return for Penny's own posts", func(t *testing.T) {
+		setupViperConfig(t, baseConfig)
+		// No API methods should be called
+		mock := &slackclient.MockClient{}
+		ev := slackevents.MessageEvent{SubType: BOT_MESSAGE_TYPE, User: botUID, Channel: spamChan}
+		ProcessSpamFeedMessage(baseRouter, baseRoute, mock, mock, ev, opPermalink)
+	})
+
 	t.Run("Early return when channel name does not match", func(t *testing.T) {
 		setupViperConfig(t, baseConfig)
 		mock := &slackclient.MockClient{
@@ -948,6 +1062,113 @@ func TestProcessSpamFeedMessage(t *testing.T) {
 			t.Errorf("expected hit emoji 'no_entry', got %q", reactionEmoji)
 		}
 	})
+
+	t.Run("Removal records a case and offers an appeal", func(t *testing.T) {
+		cfg := map[string]interface{}{
+			"spam_feed.channel":                 chanName,
+			"spam_feed.anomaly_scores.reported": 5,
+			"spam_feed.activity_low_watermark":  0,
+			"spam_feed.local_timezone":          "",
+			"spam_feed.max_anomaly_score":       5,
+			"spam_feed.appeals.channel_id":      "C_MODS",
+		}
+		setupViperConfig(t, cfg)
+		store := cases.NewMemoryStore()
+		UseCases(store)
+		t.Cleanup(func() { UseCases(nil) })
+
+		appealOffered := false
+		mock := &slackclient.MockClient{
+			GetConversationInfoFn: channelInfoOK,
+			JoinConversationFn:    noopJoin,
+			GetConversationHistoryFn: historyFor(map[string]slack.Message{
+				spamChan: spamFeedMsg,
+				opChan:   opMsg,
+			}),
+			PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+				_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+				if channelID == opChan && strings.Contains(values.Get("blocks"), ACTION_APPEAL) {
+					appealOffered = true
+				}
+				return channelID, "ts", nil
+			},
+		}
+		userMock := &slackclient.MockClient{
+			DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+				return channel, messageTimestamp, nil
+			},
+		}
+
+		ev := slackevents.MessageEvent{
+			SubType:   BOT_MESSAGE_TYPE,
+			Channel:   spamChan,
+			TimeStamp: spamTS,
+		}
+		ProcessSpamFeedMessage(baseRouter, baseRoute, mock, userMock, ev, opPermalink)
+
+		c, err := store.Case(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("no case recorded: %v", err)
+		}
+		if c.Author != opUser || c.Timestamp != opTS || c.Verdict != messages.VERDICT_REMOVED || c.Score != 5 {
+			t.Errorf("case = %+v, want %s's message removed with a score of 5", c, opUser)
+		}
+		if !appealOffered {
+			t.Errorf("expected the removal notice to offer an appeal")
+		}
+	})
+
+	t.Run("Removal is mirrored to webhooks", func(t *testing.T) {
+		cfg := map[string]interface{}{
+			"spam_feed.channel":                 chanName,
+			"spam_feed.anomaly_scores.reported": 5,
+			"spam_feed.activity_low_watermark":  0,
+			"spam_feed.local_timezone":          "",
+			"spam_feed.max_anomaly_score":       5,
+		}
+		setupViperConfig(t, cfg)
+		srv, wait := useWebhookServer(t)
+
+		mock := &slackclient.MockClient{
+			GetConversationInfoFn: channelInfoOK,
+			JoinConversationFn:    noopJoin,
+			GetConversationHistoryFn: historyFor(map[string]slack.Message{
+				spamChan: spamFeedMsg,
+				opChan:   opMsg,
+			}),
+			PostMessageFn: noopPost,
+		}
+		userMock := &slackclient.MockClient{
+			DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+				return channel, messageTimestamp, nil
+			},
+		}
+
+		ev := slackevents.MessageEvent{
+			SubType:   BOT_MESSAGE_TYPE,
+			Channel:   spamChan,
+			TimeStamp: spamTS,
+		}
+		ProcessSpamFeedMessage(baseRouter, baseRoute, mock, userMock, ev, opPermalink)
+		wait()
+
+		got := map[string]webhooks.Case{}
+		for _, d := range srv.Deliveries() {
+			got[d.Payload.Event] = d.Payload.Case
+		}
+		for _, event := range []string{webhooks.EVENT_CASE_CREATED, webhooks.EVENT_MESSAGE_REMOVED} {
+			c, ok := got[event]
+			if !ok {
+				t.Fatalf("%s wasn't delivered; got %v", event, got)
+			}
+			if c.Author != opUser || c.Channel != opChan || c.Score != 5 || len(c.Signals) == 0 {
+				t.Errorf("%s case = %+v, want %s's message with its score breakdown", event, c, opUser)
+			}
+			if !slices.Equal(c.Actions, []string{webhooks.ACTION_NOTIFIED, webhooks.ACTION_REMOVED}) {
+				t.Errorf("%s actions = %v, want the author notified and the message removed", event, c.Actions)
+			}
+		}
+	})
 }
 
 // TestReacjiUsernameTriggersHandler ensures the REACJI_USERNAME constant triggers the handler path.
@@ -1019,3 +1240,53 @@ func TestDebugResponseFormat(t *testing.T) {
 		})
 	}
 }
+
+// TestProcessSpamFeedMessageDeleteFails verifies a removal whose delete fails
+// is recorded as held, since the message is still up, and gives its slot in
+// the removal breaker back.
+func TestProcessSpamFeedMessageDeleteFails(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.channel":                 "spam-feed",
+		"spam_feed.anomaly_scores.reported": 5,
+		"spam_feed.max_anomaly_score":       5,
+		"spam_feed.breaker.max_removals":    1,
+	})
+	useBreaker(t, newRemovalBreaker(time.Now))
+	store := cases.NewMemoryStore()
+	UseCases(store)
+	t.Cleanup(func() { UseCases(nil) })
+
+	spamFeedMsg := slack.Message{Msg: slack.Msg{Timestamp: "1111111111.000100", Channel: "C_SPAM"}}
+	opMsg := slack.Message{Msg: slack.Msg{Timestamp: "1639843883.000100", Channel: "C02BZ36790B", User: "U_OP"}}
+	mock := &slackclient.MockClient{
+		GetConversationInfoFn: func(input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+			return &slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{NameNormalized: "spam-feed"}}}, nil
+		},
+		JoinConversationFn: noopJoin,
+		GetConversationHistoryFn: historyFor(map[string]slack.Message{
+			"C_SPAM":      spamFeedMsg,
+			"C02BZ36790B": opMsg,
+		}),
+		PostMessageFn: noopPost,
+		AddReactionFn: func(name string, item slack.ItemRef) error { return nil },
+	}
+	userMock := &slackclient.MockClient{
+		DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+			return "", "", errors.New("cant_delete_message")
+		},
+	}
+
+	ev := slackevents.MessageEvent{SubType: BOT_MESSAGE_TYPE, Channel: "C_SPAM", TimeStamp: "1111111111.000100"}
+	ProcessSpamFeedMessage(router.Router{BotUID: "U_BOT"}, router.Route{}, mock, userMock, ev, "<https://orgname.slack.com/archives/C02BZ36790B/p1639843883000100>")
+
+	c, err := store.Case(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Case(1) error = %v", err)
+	}
+	if c.Verdict != messages.VERDICT_HELD {
+		t.Errorf("case verdict = %q, want held after the delete failed", c.Verdict)
+	}
+	if allowed, _ := breaker.allow("C02BZ36790B"); !allowed {
+		t.Error("allow() = false, want the failed removal's slot given back")
+	}
+}
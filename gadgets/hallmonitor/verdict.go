@@ -0,0 +1,98 @@
+package hallmonitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/messages"
+)
+
+const (
+	// gaugeWidth is the number of cells in a verdict's score gauge.
+	gaugeWidth = 10
+	// maxSectionFields is Block Kit's limit on fields in one section.
+	maxSectionFields = 10
+)
+
+// verdict is what Penny decided about a report, as shown to moderators.
+type verdict struct {
+	outcome   string // one of messages.VERDICT_*
+	eval      evaluation
+	threshold int
+	op        slack.Message
+	permalink string
+	reporters []string // as mentions
+	screened  bool
+	probation bool
+}
+
+// outcomeOf names the outcome of a report.
+func outcomeOf(removed, held bool, eval evaluation) string {
+	switch {
+	case removed:
+		return messages.VERDICT_REMOVED
+	case held:
+		return messages.VERDICT_HELD
+	case eval.deferred:
+		return messages.VERDICT_DEFERRED
+	default:
+		return messages.VERDICT_KEPT
+	}
+}
+
+// verdictBlocks renders v for the spam feed: a header with the outcome and a
+// score gauge, each signal's contribution, and who posted and reported the OP.
+func verdictBlocks(ctx context.Context, v verdict) []slack.Block {
+	header := text(ctx, messages.VERDICT_HEADER, messages.Data{Verdict: v.outcome}) +
+		"   " + gauge(v.eval.score, v.threshold)
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, header, true, false)),
+	}
+
+	fields := make([]*slack.TextBlockObject, 0, len(v.eval.results))
+	for _, r := range v.eval.results {
+		data := messages.Data{Signal: r.name, Score: r.score}
+		if r.timedOut || r.detailed {
+			data.Reasons = []string{r.reason}
+		}
+		fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, text(ctx, messages.VERDICT_SIGNAL, data), false, false))
+	}
+	for len(fields) > 0 {
+		n := min(len(fields), maxSectionFields)
+		blocks = append(blocks, slack.NewSectionBlock(nil, fields[:n], nil))
+		fields = fields[n:]
+	}
+
+	posted := text(ctx, messages.VERDICT_POSTED, messages.Data{
+		OP:        fmt.Sprintf("<@%s>", v.op.User),
+		Channel:   v.op.Channel,
+		Permalink: v.permalink,
+	})
+	elements := []slack.MixedElement{slack.NewTextBlockObject(slack.MarkdownType, posted, false, false)}
+	if len(v.reporters) != 0 {
+		reporters := text(ctx, messages.VERDICT_REPORTERS, messages.Data{Reporters: v.reporters})
+		elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, reporters, false, false))
+	}
+	if v.screened {
+		elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, text(ctx, messages.VERDICT_SCREENED, messages.Data{}), false, false))
+	}
+	if v.probation {
+		elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, text(ctx, messages.VERDICT_PROBATION, messages.Data{}), false, false))
+	}
+	if v.eval.coalesced {
+		elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, text(ctx, messages.VERDICT_COALESCED, messages.Data{}), false, false))
+	}
+	return append(blocks, slack.NewContextBlock("", elements...))
+}
+
+// gauge draws score against threshold, e.g. ▰▰▰▰▰▰▱▱▱▱ 3/5. A score at or past
+// the threshold fills the gauge.
+func gauge(score, threshold int) string {
+	filled := gaugeWidth
+	if threshold > 0 && score < threshold {
+		filled = max(score, 0) * gaugeWidth / threshold
+	}
+	return strings.Repeat("▰", filled) + strings.Repeat("▱", gaugeWidth-filled) + fmt.Sprintf(" %d/%d", score, threshold)
+}
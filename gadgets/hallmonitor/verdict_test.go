@@ -0,0 +1,101 @@
+package hallmonitor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/messages"
+)
+
+func TestVerdictBlocks(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{})
+	v := verdict{
+		outcome: messages.VERDICT_REMOVED,
+		eval: evaluation{
+			score: 5,
+			results: []signalResult{
+				{name: "reported", score: 2, reason: "reported by the community as being spammy: 2"},
+				{name: "low_activity", score: 0},
+				{name: "outside_tz", score: 3, timedOut: true, reason: "outside_tz timed out after 10s: counted as 3"},
+				{name: "email_domain", score: 2, detailed: true, reason: "email at mailinator.com, a disposable email domain: 2"},
+			},
+		},
+		threshold: 5,
+		op:        slack.Message{Msg: slack.Msg{User: "U_OP", Channel: "C_OP"}},
+		permalink: "https://example.slack.com/archives/C_OP/p1639843883000100",
+		reporters: []string{"<@U1>", "<@U2>"},
+	}
+
+	blocks := verdictBlocks(context.Background(), v)
+	if len(blocks) != 3 {
+		t.Fatalf("verdictBlocks() returned %d blocks, want header, fields and context", len(blocks))
+	}
+
+	header := blocks[0].(*slack.HeaderBlock).Text.Text
+	if want := ":no_good: Removed   ▰▰▰▰▰▰▰▰▰▰ 5/5"; header != want {
+		t.Errorf("header = %q, want %q", header, want)
+	}
+
+	var fields []string
+	for _, f := range blocks[1].(*slack.SectionBlock).Fields {
+		fields = append(fields, f.Text)
+	}
+	wantFields := []string{"*reported* +2", "*low_activity* +0", "*outside_tz* +3\noutside_tz timed out after 10s: counted as 3",
+		"*email_domain* +2\nemail at mailinator.com, a disposable email domain: 2"}
+	if strings.Join(fields, "|") != strings.Join(wantFields, "|") {
+		t.Errorf("fields = %q, want %q", fields, wantFields)
+	}
+
+	var elements []string
+	for _, e := range blocks[2].(*slack.ContextBlock).ContextElements.Elements {
+		elements = append(elements, e.(*slack.TextBlockObject).Text)
+	}
+	wantContext := []string{
+		"Posted by <@U_OP> in <#C_OP> · <https://example.slack.com/archives/C_OP/p1639843883000100|view message>",
+		"Reported by <@U1>, <@U2>",
+	}
+	if strings.Join(elements, "|") != strings.Join(wantContext, "|") {
+		t.Errorf("context = %q, want %q", elements, wantContext)
+	}
+}
+
+// TestVerdictBlocksSplitsFields verifies that signals past Block Kit's limit
+// of 10 fields go into another section.
+func TestVerdictBlocksSplitsFields(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{})
+	v := verdict{outcome: messages.VERDICT_KEPT, threshold: 5}
+	for range 12 {
+		v.eval.results = append(v.eval.results, signalResult{name: "s"})
+	}
+
+	blocks := verdictBlocks(context.Background(), v)
+	var sections []int
+	for _, b := range blocks {
+		if s, ok := b.(*slack.SectionBlock); ok {
+			sections = append(sections, len(s.Fields))
+		}
+	}
+	if len(sections) != 2 || sections[0] != 10 || sections[1] != 2 {
+		t.Errorf("section field counts = %v, want [10 2]", sections)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	tests := []struct {
+		score, threshold int
+		want             string
+	}{
+		{0, 5, "▱▱▱▱▱▱▱▱▱▱ 0/5"},
+		{3, 5, "▰▰▰▰▰▰▱▱▱▱ 3/5"},
+		{7, 5, "▰▰▰▰▰▰▰▰▰▰ 7/5"},
+		{-1, 5, "▱▱▱▱▱▱▱▱▱▱ -1/5"},
+		{1, 0, "▰▰▰▰▰▰▰▰▰▰ 1/0"},
+	}
+	for _, tt := range tests {
+		if got := gauge(tt.score, tt.threshold); got != tt.want {
+			t.Errorf("gauge(%d, %d) = %q, want %q", tt.score, tt.threshold, got, tt.want)
+		}
+	}
+}
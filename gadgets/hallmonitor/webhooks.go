@@ -0,0 +1,49 @@
+package hallmonitor
+
+import (
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/webhooks"
+)
+
+var hooks *webhooks.Sender
+
+// UseWebhooks mirrors cases to the webhooks s delivers to. Call it before serving.
+func UseWebhooks(s *webhooks.Sender) {
+	hooks = s
+}
+
+// webhookCase describes a report Penny just processed, with what it did about it.
+func webhookCase(id uint, v verdict, reporters []string, actions []string) webhooks.Case {
+	signals := make([]webhooks.Signal, 0, len(v.eval.results))
+	for _, r := range v.eval.results {
+		signals = append(signals, webhooks.Signal{Name: r.name, Score: r.score, TimedOut: r.timedOut})
+	}
+	return webhooks.Case{
+		ID:        id,
+		Verdict:   v.outcome,
+		Score:     v.eval.score,
+		Threshold: v.threshold,
+		Signals:   signals,
+		Author:    v.op.User,
+		Channel:   v.op.Channel,
+		Permalink: v.permalink,
+		Reporters: reporters,
+		Actions:   actions,
+	}
+}
+
+// storedWebhookCase describes a case recorded earlier, which no longer has its
+// score breakdown.
+func storedWebhookCase(c *cases.Case, actions []string) webhooks.Case {
+	return webhooks.Case{
+		ID:        c.ID,
+		Verdict:   c.Verdict,
+		Score:     c.Score,
+		Threshold: c.Threshold,
+		Author:    c.Author,
+		Channel:   c.Channel,
+		Permalink: c.Permalink,
+		Reporters: c.ReporterIDs(),
+		Actions:   actions,
+	}
+}
@@ -0,0 +1,89 @@
+package hallmonitor
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/cases"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/slackclient"
+	"github.com/xortim/penny/pkg/webhooks"
+	"github.com/xortim/penny/pkg/webhooks/webhookstest"
+)
+
+// useWebhookServer points hallmonitor at a fresh webhook receiver. Call the
+// returned func to wait for deliveries before inspecting them.
+func useWebhookServer(t *testing.T) (*webhookstest.Server, func()) {
+	t.Helper()
+	srv := webhookstest.NewServer("s3cret")
+	t.Cleanup(srv.Close)
+	s := webhooks.NewSender(webhooks.Options{URLs: []string{srv.URL}, Secret: "s3cret", Backoff: time.Millisecond})
+	UseWebhooks(s)
+	t.Cleanup(func() { UseWebhooks(nil) })
+	return srv, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Close(ctx); err != nil {
+			t.Fatalf("webhooks didn't finish: %v", err)
+		}
+	}
+}
+
+func TestWebhookCase(t *testing.T) {
+	v := verdict{
+		outcome: messages.VERDICT_REMOVED,
+		eval: evaluation{score: 6, results: []signalResult{
+			{name: "reported", score: 5},
+			{name: "user_activity", score: 1},
+			{name: "user_tz", timedOut: true},
+		}},
+		threshold: 5,
+		op:        slack.Message{Msg: slack.Msg{Channel: "C_OP", User: "U_OP"}},
+		permalink: "https://example.slack.com/archives/C_OP/p1",
+	}
+
+	c := webhookCase(7, v, []string{"U_R1"}, []string{webhooks.ACTION_REMOVED})
+	if c.ID != 7 || c.Verdict != messages.VERDICT_REMOVED || c.Score != 6 || c.Threshold != 5 || c.Author != "U_OP" || c.Channel != "C_OP" || c.Permalink != v.permalink {
+		t.Errorf("webhookCase() = %+v, want the verdict's details", c)
+	}
+	if len(c.Signals) != 3 || c.Signals[0] != (webhooks.Signal{Name: "reported", Score: 5}) || !c.Signals[2].TimedOut {
+		t.Errorf("webhookCase() signals = %+v, want the score breakdown", c.Signals)
+	}
+}
+
+func TestDecideAppealSendsWebhook(t *testing.T) {
+	setupViperConfig(t, map[string]interface{}{
+		"spam_feed.appeals.channel_id": "C_MODS",
+		"slack.global_admins":          []string{"U_MOD"},
+	})
+	store, c := useMemoryCases(t)
+	srv, wait := useWebhookServer(t)
+	ctx := context.Background()
+	a := &cases.Appeal{CaseID: c.ID, Author: "U_OP"}
+	if err := store.CreateAppeal(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+
+	mock := &slackclient.MockClient{
+		UpdateMessageFn: func(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+			return channelID, timestamp, "", nil
+		},
+		PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+			return channelID, "ts", nil
+		},
+	}
+	decideAppeal(ctx, cases.APPEAL_OVERTURNED, reviewCallback("U_MOD"), &slack.BlockAction{ActionID: ACTION_OVERTURN, Value: "1"}, mock)
+	wait()
+
+	got := srv.Deliveries()
+	if len(got) != 1 || got[0].Payload.Event != webhooks.EVENT_CASE_OVERTURNED {
+		t.Fatalf("delivered %+v, want one %s", got, webhooks.EVENT_CASE_OVERTURNED)
+	}
+	oc := got[0].Payload.Case
+	if oc.ID != c.ID || oc.Verdict != messages.VERDICT_REMOVED || oc.Author != "U_OP" || !slices.Equal(oc.Actions, []string{webhooks.ACTION_OVERTURNED, webhooks.ACTION_NOTIFIED}) {
+		t.Errorf("overturned case = %+v, want case %d overturned and its author told", oc, c.ID)
+	}
+}
@@ -1,11 +1,10 @@
 package help
 
 import (
-	"fmt"
-
 	"github.com/gadget-bot/gadget/router"
 	"github.com/slack-go/slack"
-	"github.com/spf13/viper"
+	"github.com/xortim/penny/pkg/config"
+	"github.com/xortim/penny/pkg/messages"
 )
 
 // GetSlashCommandRoutes returns the slash command routes for the help gadget.
@@ -26,18 +25,11 @@ func GetSlashCommandRoutes() []router.SlashCommandRoute {
 	}
 }
 
-// formatHelp builds the help text from viper config.
+// formatHelp renders the help text in the workspace's locale.
 func formatHelp() string {
-	emoji := viper.GetString("spam_feed.emoji")
-
-	text := fmt.Sprintf(
-		"*Penny* is a community moderation bot that monitors for the :%s: reaction to detect and remove spam messages.",
-		emoji,
-	)
-
-	if channelID := viper.GetString("spam_feed.assistance_channel_id"); channelID != "" {
-		text += fmt.Sprintf("\n\nNeed help or have questions? Visit <#%s>.", channelID)
-	}
-
-	return text
+	cfg := config.Current()
+	return messages.Text(cfg.Messages.Locale, messages.HELP, messages.Data{
+		Emoji:             cfg.SpamFeed.Emoji,
+		AssistanceChannel: cfg.SpamFeed.AssistanceChannelID,
+	})
 }
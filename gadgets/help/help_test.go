@@ -5,13 +5,22 @@ import (
 	"testing"
 
 	"github.com/spf13/viper"
+	"github.com/xortim/penny/pkg/config"
 )
 
 func setupViperConfig(t *testing.T, cfg map[string]interface{}) {
 	for k, v := range cfg {
 		viper.Set(k, v)
 	}
-	t.Cleanup(viper.Reset)
+	loaded, err := config.Load(viper.GetViper())
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	config.Store(loaded)
+	t.Cleanup(func() {
+		viper.Reset()
+		config.Store(nil)
+	})
 }
 
 func TestFormatHelp(t *testing.T) {
@@ -57,6 +66,21 @@ func TestFormatHelp(t *testing.T) {
 				"no_entry_sign",
 			},
 		},
+		{
+			name: "workspace locale",
+			config: map[string]interface{}{
+				"spam_feed.emoji":                 "no_entry_sign",
+				"spam_feed.assistance_channel_id": "C12345ABC",
+				"messages.locale":                 "es",
+			},
+			wantContains: []string{
+				"bot de moderación comunitaria",
+				"Visita <#C12345ABC>",
+			},
+			wantAbsent: []string{
+				"Need help",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -9,6 +9,7 @@ import (
 
 	"github.com/slack-go/slack"
 	"github.com/spf13/viper"
+	"github.com/xortim/penny/pkg/config"
 )
 
 const (
@@ -51,7 +52,15 @@ func setupViper(t *testing.T, overrides map[string]interface{}) {
 	for k, v := range overrides {
 		viper.Set(k, v)
 	}
-	t.Cleanup(viper.Reset)
+	loaded, err := config.Load(viper.GetViper())
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	config.Store(loaded)
+	t.Cleanup(func() {
+		viper.Reset()
+		config.Store(nil)
+	})
 }
 
 // buildEventPayload constructs a Slack event_callback JSON payload.
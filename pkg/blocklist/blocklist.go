@@ -0,0 +1,184 @@
+// Package blocklist shares confirmed spammers between communities. A feed is a
+// JSON list of spammer identities signed with its publisher's ed25519 key.
+// Emails and display names are only ever shared as HMAC-SHA256 hashes keyed
+// with a salt the communities agree on, so a feed reveals nothing about anyone
+// who isn't already known to its reader.
+package blocklist
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/emaildomains"
+)
+
+// ErrBadSignature is returned by Parse when a feed isn't signed by any trusted key.
+var ErrBadSignature = errors.New("feed is not signed by a trusted key")
+
+// Fields of an Identity an Entry can match, as reported by Match.
+const (
+	FIELD_USER_ID      = "user_id"
+	FIELD_EMAIL        = "email"
+	FIELD_DISPLAY_NAME = "display_name"
+	FIELD_DOMAIN       = "domain"
+)
+
+// Feed is a publisher's list of confirmed spammers.
+type Feed struct {
+	Issuer    string    `json:"issuer"`
+	Generated time.Time `json:"generated"`
+	Entries   []Entry   `json:"entries"`
+}
+
+// Entry is one spammer. Any field may be empty.
+type Entry struct {
+	UserID          string `json:"user_id,omitempty"`
+	EmailHash       string `json:"email_hash,omitempty"`
+	DisplayNameHash string `json:"display_name_hash,omitempty"`
+	// Domain is the spammer's email domain, which is left out for the big
+	// email providers everyone uses.
+	Domain string `json:"domain,omitempty"`
+}
+
+// Identity is what a community knows about an author.
+type Identity struct {
+	UserID      string
+	Email       string
+	DisplayName string
+}
+
+// IdentityOf is what a feed can hold about user: their ID, email and display
+// name, or real name if they have no display name. The email needs the
+// users:read.email scope.
+func IdentityOf(user *slack.User) Identity {
+	name := user.Profile.DisplayName
+	if name == "" {
+		name = user.RealName
+	}
+	return Identity{UserID: user.ID, Email: user.Profile.Email, DisplayName: name}
+}
+
+// NewEntry is the shareable form of id, hashed with salt.
+func NewEntry(salt string, id Identity) Entry {
+	e := Entry{UserID: id.UserID}
+	if id.Email != "" {
+		e.EmailHash = HashEmail(salt, id.Email)
+		if d := Domain(id.Email); !emaildomains.IsFree(d) {
+			e.Domain = d
+		}
+	}
+	if id.DisplayName != "" {
+		e.DisplayNameHash = HashName(salt, id.DisplayName)
+	}
+	return e
+}
+
+// HashEmail hashes email with salt, ignoring case and surrounding space.
+func HashEmail(salt, email string) string {
+	return hash(salt, strings.ToLower(strings.TrimSpace(email)))
+}
+
+// HashName hashes a display name with salt, ignoring case and spacing.
+func HashName(salt, name string) string {
+	return hash(salt, strings.Join(strings.Fields(strings.ToLower(name)), " "))
+}
+
+func hash(salt, s string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Domain is the lower-cased domain of email, or "" if it has none.
+func Domain(email string) string {
+	return emaildomains.Of(email)
+}
+
+// signed is a feed as published: the feed's JSON and its signature.
+type signed struct {
+	Feed      json.RawMessage `json:"feed"`
+	Signature string          `json:"signature"`
+}
+
+// Sign encodes f signed with key, ready to publish.
+func Sign(f Feed, key ed25519.PrivateKey) ([]byte, error) {
+	body, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(signed{
+		Feed:      body,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, body)),
+	}, "", "  ")
+}
+
+// Parse decodes a published feed, checking it was signed by one of keys.
+func Parse(data []byte, keys []ed25519.PublicKey) (*Feed, error) {
+	var s signed
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("feed is not valid JSON: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil {
+		return nil, ErrBadSignature
+	}
+	// The signature covers the feed's compact JSON, so reformatting the
+	// file doesn't break it.
+	var body bytes.Buffer
+	if err := json.Compact(&body, s.Feed); err != nil {
+		return nil, fmt.Errorf("feed is not valid JSON: %w", err)
+	}
+	trusted := false
+	for _, k := range keys {
+		if ed25519.Verify(k, body.Bytes(), sig) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return nil, ErrBadSignature
+	}
+	var f Feed
+	if err := json.Unmarshal(s.Feed, &f); err != nil {
+		return nil, fmt.Errorf("feed is not valid JSON: %w", err)
+	}
+	return &f, nil
+}
+
+// GenerateKey returns a new publisher key pair, base64 encoded for the config.
+func GenerateKey() (public, private string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(pub), base64.StdEncoding.EncodeToString(priv), nil
+}
+
+// ParsePublicKey decodes a base64 public key from GenerateKey.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, errors.New("not a base64 ed25519 public key")
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// ParsePrivateKey decodes a base64 private key from GenerateKey.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) != ed25519.PrivateKeySize {
+		return nil, errors.New("not a base64 ed25519 private key")
+	}
+	return ed25519.PrivateKey(b), nil
+}
@@ -0,0 +1,165 @@
+package blocklist
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const salt = "shared-salt"
+
+func testKeys(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := ParsePublicKey(pub)
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+	sk, err := ParsePrivateKey(priv)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey() error = %v", err)
+	}
+	return pk, sk
+}
+
+var spammer = Identity{UserID: "U_SPAM", Email: "Spam@Spam-Domain.example", DisplayName: "Crypto  Giveaway"}
+
+func TestSignAndParse(t *testing.T) {
+	pub, priv := testKeys(t)
+	otherPub, otherPriv := testKeys(t)
+	f := Feed{Issuer: "community-a", Generated: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Entries: []Entry{NewEntry(salt, spammer)}}
+
+	data, err := Sign(f, priv)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if !strings.Contains(string(data), "spam-domain.example") || strings.Contains(strings.ToLower(string(data)), "spam@") {
+		t.Errorf("signed feed = %s, want the domain but not the email", data)
+	}
+
+	got, err := Parse(data, []ed25519.PublicKey{otherPub, pub})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got.Issuer != "community-a" || len(got.Entries) != 1 || got.Entries[0] != f.Entries[0] {
+		t.Errorf("Parse() = %+v, want %+v", got, f)
+	}
+
+	if _, err := Parse(data, []ed25519.PublicKey{otherPub}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Parse() with an untrusted key error = %v, want ErrBadSignature", err)
+	}
+	forged, _ := Sign(f, otherPriv)
+	tampered := strings.Replace(string(data), "community-a", "community-b", 1)
+	for name, data := range map[string]string{"forged": string(forged), "tampered": tampered} {
+		if _, err := Parse([]byte(data), []ed25519.PublicKey{pub}); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("Parse() of a %s feed error = %v, want ErrBadSignature", name, err)
+		}
+	}
+}
+
+func TestNewEntryLeavesOutFreemail(t *testing.T) {
+	e := NewEntry(salt, Identity{Email: "someone@Gmail.com"})
+	if e.Domain != "" || e.EmailHash == "" {
+		t.Errorf("NewEntry() = %+v, want the email hashed and gmail.com left out", e)
+	}
+}
+
+func TestListMatch(t *testing.T) {
+	l := NewList(salt, &Feed{Issuer: "community-a", Entries: []Entry{NewEntry(salt, spammer)}})
+	if l.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", l.Len())
+	}
+
+	tests := []struct {
+		name      string
+		id        Identity
+		wantField string
+	}{
+		{name: "user ID", id: Identity{UserID: "U_SPAM"}, wantField: FIELD_USER_ID},
+		{name: "email ignores case", id: Identity{UserID: "U_NEW", Email: "spam@spam-domain.example"}, wantField: FIELD_EMAIL},
+		{name: "display name ignores case and spacing", id: Identity{UserID: "U_NEW", DisplayName: "crypto giveaway"}, wantField: FIELD_DISPLAY_NAME},
+		{name: "domain", id: Identity{UserID: "U_NEW", Email: "other@spam-domain.example"}, wantField: FIELD_DOMAIN},
+		{name: "no match", id: Identity{UserID: "U_NEW", Email: "someone@example.com", DisplayName: "Someone"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, ok := l.Match(tt.id)
+			if ok != (tt.wantField != "") || m.Field != tt.wantField {
+				t.Fatalf("Match() = %+v, %v, want %q", m, ok, tt.wantField)
+			}
+			if ok && m.Issuer != "community-a" {
+				t.Errorf("Match() issuer = %q, want community-a", m.Issuer)
+			}
+		})
+	}
+
+	if m, _ := NewList("other-salt", &Feed{Entries: []Entry{NewEntry(salt, spammer)}}).Match(Identity{Email: spammer.Email}); m.Field == FIELD_EMAIL {
+		t.Error("Match() with another salt matched the email")
+	}
+	free := NewList(salt, &Feed{Entries: []Entry{{Domain: "gmail.com"}}})
+	if _, ok := free.Match(Identity{UserID: "U_NEW", Email: "someone@gmail.com"}); ok {
+		t.Error("Match() matched a big provider's domain")
+	}
+	var none *List
+	if _, ok := none.Match(spammer); ok || none.Len() != 0 {
+		t.Error("a nil List matched")
+	}
+}
+
+func TestImporter(t *testing.T) {
+	pub, priv := testKeys(t)
+	fromFile, _ := Sign(Feed{Issuer: "file", Entries: []Entry{{UserID: "U_FILE"}}}, priv)
+	fromURL, _ := Sign(Feed{Issuer: "url", Entries: []Entry{{UserID: "U_URL"}}}, priv)
+
+	path := filepath.Join(t.TempDir(), "feed.json")
+	if err := os.WriteFile(path, fromFile, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var down atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed.json" || down.Load() {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(fromURL)
+	}))
+	defer srv.Close()
+
+	i := NewImporter(Source{
+		Feeds: []string{path, srv.URL + "/feed.json", srv.URL + "/missing.json"},
+		Keys:  []ed25519.PublicKey{pub},
+		Salt:  salt,
+	})
+	if i.List() != nil {
+		t.Fatal("List() before Import() isn't nil")
+	}
+	l, err := i.Import(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "missing.json") {
+		t.Errorf("Import() error = %v, want the missing feed reported", err)
+	}
+	for _, uid := range []string{"U_FILE", "U_URL"} {
+		if _, ok := l.Match(Identity{UserID: uid}); !ok {
+			t.Errorf("Import() didn't keep %s despite the missing feed", uid)
+		}
+	}
+
+	down.Store(true)
+	l, err = i.Import(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "/feed.json") {
+		t.Errorf("Import() error = %v, want the unreachable feed reported", err)
+	}
+	if _, ok := l.Match(Identity{UserID: "U_URL"}); !ok || i.List() != l {
+		t.Error("Import() dropped the last good copy of an unreachable feed")
+	}
+}
@@ -0,0 +1,193 @@
+package blocklist
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/xortim/penny/pkg/emaildomains"
+)
+
+// maxFeedSize bounds how much of a feed is read.
+const maxFeedSize = 32 << 20
+
+// Match says which field of an Identity matched an entry, and whose feed it
+// was in.
+type Match struct {
+	Field  string // one of FIELD_*
+	Issuer string
+}
+
+// List is the entries of every feed, indexed for matching.
+type List struct {
+	salt    string
+	size    int
+	users   map[string]string // values are issuers
+	emails  map[string]string
+	names   map[string]string
+	domains map[string]string
+}
+
+// NewList indexes the entries of feeds, whose hashes were made with salt.
+func NewList(salt string, feeds ...*Feed) *List {
+	l := &List{
+		salt:    salt,
+		users:   map[string]string{},
+		emails:  map[string]string{},
+		names:   map[string]string{},
+		domains: map[string]string{},
+	}
+	for _, f := range feeds {
+		for _, e := range f.Entries {
+			l.size++
+			add(l.users, e.UserID, f.Issuer)
+			add(l.emails, e.EmailHash, f.Issuer)
+			add(l.names, e.DisplayNameHash, f.Issuer)
+			add(l.domains, strings.ToLower(e.Domain), f.Issuer)
+		}
+	}
+	return l
+}
+
+func add(index map[string]string, key, issuer string) {
+	if _, ok := index[key]; key != "" && !ok {
+		index[key] = issuer
+	}
+}
+
+// Len is the number of entries in l. It's 0 for a nil List.
+func (l *List) Len() int {
+	if l == nil {
+		return 0
+	}
+	return l.size
+}
+
+// Match reports whether id is listed, checking the most specific fields
+// first. Big providers like gmail.com never match by domain, even if a feed
+// lists them. A nil List matches nothing.
+func (l *List) Match(id Identity) (Match, bool) {
+	if l == nil {
+		return Match{}, false
+	}
+	if issuer, ok := l.users[id.UserID]; ok && id.UserID != "" {
+		return Match{FIELD_USER_ID, issuer}, true
+	}
+	if id.Email != "" {
+		if issuer, ok := l.emails[HashEmail(l.salt, id.Email)]; ok {
+			return Match{FIELD_EMAIL, issuer}, true
+		}
+	}
+	if id.DisplayName != "" {
+		if issuer, ok := l.names[HashName(l.salt, id.DisplayName)]; ok {
+			return Match{FIELD_DISPLAY_NAME, issuer}, true
+		}
+	}
+	if d := Domain(id.Email); d != "" && !emaildomains.IsFree(d) {
+		if issuer, ok := l.domains[d]; ok {
+			return Match{FIELD_DOMAIN, issuer}, true
+		}
+	}
+	return Match{}, false
+}
+
+// Source is where feeds are imported from.
+type Source struct {
+	// Feeds are file paths or http(s) URLs.
+	Feeds []string
+	// Keys are the publishers whose feeds are trusted.
+	Keys []ed25519.PublicKey
+	Salt string
+	// Client fetches the URLs; http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Importer imports a Source's feeds, keeping the last good copy of each, so a
+// feed that can't be fetched or verified on a refresh keeps its entries.
+type Importer struct {
+	src Source
+
+	mu    sync.Mutex
+	feeds map[string]*Feed
+	list  *List
+}
+
+// NewImporter returns an Importer for src that hasn't imported anything yet.
+func NewImporter(src Source) *Importer {
+	return &Importer{src: src, feeds: map[string]*Feed{}}
+}
+
+// Import fetches and verifies every feed and returns the List of them all.
+// Feeds that fail are reported in the error, and their last good copy, if
+// any, is used instead.
+func (i *Importer) Import(ctx context.Context) (*List, error) {
+	var errs []error
+	fetched := map[string]*Feed{}
+	for _, src := range i.src.Feeds {
+		data, err := i.src.fetch(ctx, src)
+		if err == nil {
+			var f *Feed
+			if f, err = Parse(data, i.src.Keys); err == nil {
+				fetched[src] = f
+				continue
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", src, err))
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	feeds := make([]*Feed, 0, len(i.src.Feeds))
+	for _, src := range i.src.Feeds {
+		if f, ok := fetched[src]; ok {
+			i.feeds[src] = f
+		}
+		if f, ok := i.feeds[src]; ok {
+			feeds = append(feeds, f)
+		}
+	}
+	i.list = NewList(i.src.Salt, feeds...)
+	return i.list, errors.Join(errs...)
+}
+
+// List is the List from the last Import, or nil before the first.
+func (i *Importer) List() *List {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.list
+}
+
+func (s Source) fetch(ctx context.Context, src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		f, err := os.Open(src)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(io.LimitReader(f, maxFeedSize))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responded %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+}
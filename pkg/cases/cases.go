@@ -0,0 +1,141 @@
+// Package cases records what Penny decided about each report, the appeals
+// authors file against those decisions, and when removals were paused, so all
+// of them survive restarts.
+package cases
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Appeal statuses.
+const (
+	APPEAL_PENDING    = "pending"
+	APPEAL_UPHELD     = "upheld"
+	APPEAL_OVERTURNED = "overturned"
+)
+
+var (
+	ErrNotFound       = errors.New("not found")
+	ErrAppealExists   = errors.New("this case has already been appealed")
+	ErrAlreadyDecided = errors.New("this appeal has already been decided")
+)
+
+// Case is one report and Penny's verdict on it.
+type Case struct {
+	gorm.Model
+	// Channel and Timestamp identify the OP's message, and ThreadTimestamp
+	// its thread, if it's a reply.
+	Channel         string `gorm:"index:idx_case_message"`
+	Timestamp       string `gorm:"index:idx_case_message"`
+	ThreadTimestamp string
+	Author          string `gorm:"index"`
+	Text            string `gorm:"type:text"`
+	Permalink       string
+	// Reporters is a comma separated list of user IDs.
+	Reporters string
+	Score     int
+	Threshold int
+	// Verdict is one of messages.VERDICT_*.
+	Verdict string
+	// Domain is the author's email domain, if it was known.
+	Domain string `gorm:"index"`
+}
+
+// Report is a later report against a Case's author within the same burst,
+// judged on its own message but recorded under the burst's case.
+type Report struct {
+	gorm.Model
+	CaseID          uint `gorm:"index"`
+	Channel         string
+	Timestamp       string
+	ThreadTimestamp string
+	Text            string `gorm:"type:text"`
+	Permalink       string
+	// Reporters is a comma separated list of user IDs.
+	Reporters string
+	Score     int
+	// Verdict is one of messages.VERDICT_*.
+	Verdict string
+}
+
+// ReporterIDs returns the IDs in Reporters.
+func (c Case) ReporterIDs() []string {
+	if c.Reporters == "" {
+		return nil
+	}
+	return strings.Split(c.Reporters, ",")
+}
+
+// Appeal is an author's request for a moderator to reconsider a Case.
+type Appeal struct {
+	gorm.Model
+	CaseID        uint `gorm:"uniqueIndex"`
+	Case          Case
+	Author        string
+	Justification string `gorm:"type:text"`
+	Status        string `gorm:"index"`
+	// ReviewChannel and ReviewTimestamp locate the moderators' review message.
+	ReviewChannel   string
+	ReviewTimestamp string
+	Moderator       string
+	DecidedAt       *time.Time
+}
+
+// Pause is a trip of the removal breaker. Removals stay paused until a global
+// admin resumes them.
+type Pause struct {
+	gorm.Model
+	// Channel is where the per-channel cap was hit, or empty for the global cap.
+	Channel string
+	Count   int
+	Limit   int
+	Window  time.Duration
+	// ResumedBy is the admin who resumed removals, and ResumedAt when.
+	ResumedBy string
+	ResumedAt *time.Time `gorm:"index"`
+}
+
+// Models are the tables a Store needs, for migrations.
+func Models() []any {
+	return []any{&Case{}, &Report{}, &Appeal{}, &Pause{}}
+}
+
+// Store persists cases and appeals.
+type Store interface {
+	CreateCase(ctx context.Context, c *Case) error
+	Case(ctx context.Context, id uint) (*Case, error)
+	// SetVerdict changes the case's verdict, when acting on it didn't go as
+	// recorded.
+	SetVerdict(ctx context.Context, id uint, verdict string) error
+	// AddReport records r under its case. A removed report makes the case's
+	// verdict removed too, so the author counts as removed.
+	AddReport(ctx context.Context, r *Report) error
+	// CreateAppeal files a as pending. It returns ErrAppealExists if its case
+	// already has one.
+	CreateAppeal(ctx context.Context, a *Appeal) error
+	// Appeal returns the appeal with its Case.
+	Appeal(ctx context.Context, id uint) (*Appeal, error)
+	// SetReview records where the moderators review the appeal.
+	SetReview(ctx context.Context, id uint, channel, timestamp string) error
+	// DecideAppeal moves a pending appeal to status. It returns
+	// ErrAlreadyDecided if another moderator got there first.
+	DecideAppeal(ctx context.Context, id uint, status, moderator string) (*Appeal, error)
+	// Authors returns the authors of cases with verdict created since then,
+	// leaving out cases whose appeal was overturned.
+	Authors(ctx context.Context, verdict string, since time.Time) ([]string, error)
+	// CountByDomain counts the cases with verdict created since then whose
+	// author had the email domain, leaving out cases whose appeal was
+	// overturned.
+	CountByDomain(ctx context.Context, domain, verdict string, since time.Time) (int, error)
+	// Pause records that removals were paused.
+	Pause(ctx context.Context, p *Pause) error
+	// ActivePause returns the latest pause nobody resumed, or ErrNotFound.
+	ActivePause(ctx context.Context) (*Pause, error)
+	// Resume marks every active pause as resumed by admin.
+	Resume(ctx context.Context, admin string) error
+}
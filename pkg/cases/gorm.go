@@ -0,0 +1,163 @@
+package cases
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/xortim/penny/pkg/messages"
+	"gorm.io/gorm"
+)
+
+// GormStore is a Store in penny's database.
+type GormStore struct {
+	db *gorm.DB
+}
+
+var _ Store = (*GormStore)(nil)
+
+// NewGormStore returns a Store backed by db, creating its tables if needed.
+func NewGormStore(db *gorm.DB) (*GormStore, error) {
+	if err := db.AutoMigrate(Models()...); err != nil {
+		return nil, err
+	}
+	return &GormStore{db: db}, nil
+}
+
+func (s *GormStore) CreateCase(ctx context.Context, c *Case) error {
+	return s.db.WithContext(ctx).Create(c).Error
+}
+
+func (s *GormStore) Case(ctx context.Context, id uint) (*Case, error) {
+	var c Case
+	if err := s.db.WithContext(ctx).First(&c, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &c, nil
+}
+
+func (s *GormStore) SetVerdict(ctx context.Context, id uint, verdict string) error {
+	return s.db.WithContext(ctx).Model(&Case{}).Where("id = ?", id).Update("verdict", verdict).Error
+}
+
+func (s *GormStore) AddReport(ctx context.Context, r *Report) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(r).Error; err != nil {
+			return err
+		}
+		if r.Verdict != messages.VERDICT_REMOVED {
+			return nil
+		}
+		return tx.Model(&Case{}).Where("id = ?", r.CaseID).Update("verdict", r.Verdict).Error
+	})
+}
+
+func (s *GormStore) CreateAppeal(ctx context.Context, a *Appeal) error {
+	a.Status = APPEAL_PENDING
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&Appeal{}).Where("case_id = ?", a.CaseID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n != 0 {
+			return ErrAppealExists
+		}
+		// The unique index on case_id settles a race between two submissions.
+		if err := tx.Omit("Case").Create(a).Error; err != nil {
+			if isDuplicate(err) {
+				return ErrAppealExists
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// mysqlDuplicateEntry is MySQL's ER_DUP_ENTRY.
+const mysqlDuplicateEntry = 1062
+
+// isDuplicate reports whether err is a unique index violation. Gorm only
+// returns ErrDuplicatedKey when the DB was opened with TranslateError, which
+// penny's isn't, so MySQL's own error is checked too.
+func isDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.Is(err, gorm.ErrDuplicatedKey) || (errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry)
+}
+
+func (s *GormStore) Appeal(ctx context.Context, id uint) (*Appeal, error) {
+	var a Appeal
+	if err := s.db.WithContext(ctx).Preload("Case").First(&a, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &a, nil
+}
+
+func (s *GormStore) SetReview(ctx context.Context, id uint, channel, timestamp string) error {
+	return s.db.WithContext(ctx).Model(&Appeal{}).Where("id = ?", id).
+		Updates(map[string]any{"review_channel": channel, "review_timestamp": timestamp}).Error
+}
+
+func (s *GormStore) DecideAppeal(ctx context.Context, id uint, status, moderator string) (*Appeal, error) {
+	now := time.Now()
+	res := s.db.WithContext(ctx).Model(&Appeal{}).
+		Where("id = ? AND status = ?", id, APPEAL_PENDING).
+		Updates(map[string]any{"status": status, "moderator": moderator, "decided_at": &now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	a, err := s.Appeal(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		return a, ErrAlreadyDecided
+	}
+	return a, nil
+}
+
+func (s *GormStore) Authors(ctx context.Context, verdict string, since time.Time) ([]string, error) {
+	db := s.db.WithContext(ctx)
+	overturned := db.Model(&Appeal{}).Select("case_id").Where("status = ?", APPEAL_OVERTURNED)
+	var authors []string
+	err := db.Model(&Case{}).
+		Where("verdict = ? AND created_at >= ?", verdict, since).
+		Where("id NOT IN (?)", overturned).
+		Distinct().Order("author").Pluck("author", &authors).Error
+	return authors, err
+}
+
+func (s *GormStore) CountByDomain(ctx context.Context, domain, verdict string, since time.Time) (int, error) {
+	db := s.db.WithContext(ctx)
+	overturned := db.Model(&Appeal{}).Select("case_id").Where("status = ?", APPEAL_OVERTURNED)
+	var n int64
+	err := db.Model(&Case{}).
+		Where("domain = ? AND verdict = ? AND created_at >= ?", domain, verdict, since).
+		Where("id NOT IN (?)", overturned).
+		Count(&n).Error
+	return int(n), err
+}
+
+func (s *GormStore) Pause(ctx context.Context, p *Pause) error {
+	return s.db.WithContext(ctx).Create(p).Error
+}
+
+func (s *GormStore) ActivePause(ctx context.Context) (*Pause, error) {
+	var p Pause
+	if err := s.db.WithContext(ctx).Where("resumed_at IS NULL").Order("id DESC").First(&p).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &p, nil
+}
+
+func (s *GormStore) Resume(ctx context.Context, admin string) error {
+	return s.db.WithContext(ctx).Model(&Pause{}).Where("resumed_at IS NULL").
+		Updates(map[string]any{"resumed_by": admin, "resumed_at": time.Now()}).Error
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,32 @@
+package cases
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestIsDuplicate(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"mysql duplicate entry", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'idx_appeals_case_id'"}, true},
+		{"wrapped duplicate entry", fmt.Errorf("creating appeal: %w", &mysql.MySQLError{Number: 1062}), true},
+		{"translated by gorm", gorm.ErrDuplicatedKey, true},
+		{"other mysql error", &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, false},
+		{"other error", errors.New("connection refused"), false},
+		{"no error", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDuplicate(tt.err); got != tt.want {
+				t.Errorf("isDuplicate(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package cases
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/xortim/penny/pkg/messages"
+)
+
+// MemoryStore is a Store that keeps everything in memory, for tests and for
+// running without a database.
+type MemoryStore struct {
+	mu      sync.Mutex
+	cases   []Case
+	reports []Report
+	appeals []Appeal
+	pauses  []Pause
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) CreateCase(_ context.Context, c *Case) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.ID = uint(len(s.cases) + 1)
+	c.CreatedAt = time.Now()
+	c.UpdatedAt = c.CreatedAt
+	s.cases = append(s.cases, *c)
+	return nil
+}
+
+func (s *MemoryStore) Case(_ context.Context, id uint) (*Case, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == 0 || int(id) > len(s.cases) {
+		return nil, ErrNotFound
+	}
+	c := s.cases[id-1]
+	return &c, nil
+}
+
+func (s *MemoryStore) SetVerdict(_ context.Context, id uint, verdict string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == 0 || int(id) > len(s.cases) {
+		return ErrNotFound
+	}
+	s.cases[id-1].Verdict = verdict
+	return nil
+}
+
+func (s *MemoryStore) AddReport(_ context.Context, r *Report) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.CaseID == 0 || int(r.CaseID) > len(s.cases) {
+		return ErrNotFound
+	}
+	r.ID = uint(len(s.reports) + 1)
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = r.CreatedAt
+	s.reports = append(s.reports, *r)
+	if r.Verdict == messages.VERDICT_REMOVED {
+		s.cases[r.CaseID-1].Verdict = r.Verdict
+	}
+	return nil
+}
+
+// Reports returns the reports recorded under the case.
+func (s *MemoryStore) Reports(caseID uint) []Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reports []Report
+	for _, r := range s.reports {
+		if r.CaseID == caseID {
+			reports = append(reports, r)
+		}
+	}
+	return reports
+}
+
+func (s *MemoryStore) CreateAppeal(_ context.Context, a *Appeal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.appeals {
+		if existing.CaseID == a.CaseID {
+			return ErrAppealExists
+		}
+	}
+	a.ID = uint(len(s.appeals) + 1)
+	a.Status = APPEAL_PENDING
+	a.CreatedAt = time.Now()
+	a.UpdatedAt = a.CreatedAt
+	s.appeals = append(s.appeals, *a)
+	return nil
+}
+
+func (s *MemoryStore) Appeal(_ context.Context, id uint) (*Appeal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.appeal(id)
+}
+
+// appeal returns a copy of the appeal with its case. s.mu must be held.
+func (s *MemoryStore) appeal(id uint) (*Appeal, error) {
+	if id == 0 || int(id) > len(s.appeals) {
+		return nil, ErrNotFound
+	}
+	a := s.appeals[id-1]
+	if int(a.CaseID) <= len(s.cases) && a.CaseID != 0 {
+		a.Case = s.cases[a.CaseID-1]
+	}
+	return &a, nil
+}
+
+func (s *MemoryStore) SetReview(_ context.Context, id uint, channel, timestamp string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == 0 || int(id) > len(s.appeals) {
+		return ErrNotFound
+	}
+	s.appeals[id-1].ReviewChannel = channel
+	s.appeals[id-1].ReviewTimestamp = timestamp
+	return nil
+}
+
+func (s *MemoryStore) DecideAppeal(_ context.Context, id uint, status, moderator string) (*Appeal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.appeal(id)
+	if err != nil {
+		return nil, err
+	}
+	if a.Status != APPEAL_PENDING {
+		return a, ErrAlreadyDecided
+	}
+	now := time.Now()
+	stored := &s.appeals[id-1]
+	stored.Status, stored.Moderator, stored.DecidedAt, stored.UpdatedAt = status, moderator, &now, now
+	return s.appeal(id)
+}
+
+func (s *MemoryStore) Authors(_ context.Context, verdict string, since time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	overturned := map[uint]bool{}
+	for _, a := range s.appeals {
+		if a.Status == APPEAL_OVERTURNED {
+			overturned[a.CaseID] = true
+		}
+	}
+	var authors []string
+	for _, c := range s.cases {
+		if c.Verdict == verdict && !c.CreatedAt.Before(since) && !overturned[c.ID] && !slices.Contains(authors, c.Author) {
+			authors = append(authors, c.Author)
+		}
+	}
+	slices.Sort(authors)
+	return authors, nil
+}
+
+func (s *MemoryStore) CountByDomain(_ context.Context, domain, verdict string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	overturned := map[uint]bool{}
+	for _, a := range s.appeals {
+		if a.Status == APPEAL_OVERTURNED {
+			overturned[a.CaseID] = true
+		}
+	}
+	n := 0
+	for _, c := range s.cases {
+		if c.Domain == domain && c.Verdict == verdict && !c.CreatedAt.Before(since) && !overturned[c.ID] {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryStore) Pause(_ context.Context, p *Pause) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.ID = uint(len(s.pauses) + 1)
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	s.pauses = append(s.pauses, *p)
+	return nil
+}
+
+func (s *MemoryStore) ActivePause(_ context.Context) (*Pause, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.pauses) - 1; i >= 0; i-- {
+		if s.pauses[i].ResumedAt == nil {
+			p := s.pauses[i]
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) Resume(_ context.Context, admin string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for i := range s.pauses {
+		if s.pauses[i].ResumedAt == nil {
+			s.pauses[i].ResumedBy, s.pauses[i].ResumedAt, s.pauses[i].UpdatedAt = admin, &now, now
+		}
+	}
+	return nil
+}
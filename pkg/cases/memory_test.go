@@ -0,0 +1,140 @@
+package cases
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	c := &Case{Channel: "C1", Timestamp: "1.1", Author: "U_OP", Reporters: "U1,U2", Verdict: "removed"}
+	if err := s.CreateCase(ctx, c); err != nil {
+		t.Fatalf("CreateCase() error = %v", err)
+	}
+	if c.ID == 0 {
+		t.Fatal("CreateCase() didn't assign an ID")
+	}
+	got, err := s.Case(ctx, c.ID)
+	if err != nil || got.Author != "U_OP" {
+		t.Fatalf("Case() = %+v, %v, want the created case", got, err)
+	}
+	if ids := got.ReporterIDs(); len(ids) != 2 || ids[1] != "U2" {
+		t.Errorf("ReporterIDs() = %v, want [U1 U2]", ids)
+	}
+	if _, err := s.Case(ctx, 99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Case(99) error = %v, want ErrNotFound", err)
+	}
+	if err := s.SetVerdict(ctx, c.ID, "held"); err != nil {
+		t.Fatalf("SetVerdict() error = %v", err)
+	}
+	if got, _ := s.Case(ctx, c.ID); got.Verdict != "held" {
+		t.Errorf("verdict after SetVerdict() = %q, want held", got.Verdict)
+	}
+	if err := s.SetVerdict(ctx, 99, "held"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetVerdict(99) error = %v, want ErrNotFound", err)
+	}
+	c.Verdict = "removed"
+	s.SetVerdict(ctx, c.ID, c.Verdict)
+
+	a := &Appeal{CaseID: c.ID, Author: "U_OP", Justification: "it was a job post"}
+	if err := s.CreateAppeal(ctx, a); err != nil {
+		t.Fatalf("CreateAppeal() error = %v", err)
+	}
+	if a.Status != APPEAL_PENDING {
+		t.Errorf("CreateAppeal() status = %q, want %q", a.Status, APPEAL_PENDING)
+	}
+	if err := s.CreateAppeal(ctx, &Appeal{CaseID: c.ID, Author: "U_OP"}); !errors.Is(err, ErrAppealExists) {
+		t.Errorf("second CreateAppeal() error = %v, want ErrAppealExists", err)
+	}
+	if err := s.SetReview(ctx, a.ID, "C_MODS", "2.2"); err != nil {
+		t.Fatalf("SetReview() error = %v", err)
+	}
+
+	decided, err := s.DecideAppeal(ctx, a.ID, APPEAL_OVERTURNED, "U_MOD")
+	if err != nil {
+		t.Fatalf("DecideAppeal() error = %v", err)
+	}
+	if decided.Status != APPEAL_OVERTURNED || decided.Moderator != "U_MOD" || decided.DecidedAt == nil {
+		t.Errorf("DecideAppeal() = %+v, want overturned by U_MOD", decided)
+	}
+	if decided.ReviewTimestamp != "2.2" || decided.Case.Channel != "C1" {
+		t.Errorf("DecideAppeal() = %+v, want its review and case", decided)
+	}
+
+	again, err := s.DecideAppeal(ctx, a.ID, APPEAL_UPHELD, "U_MOD2")
+	if !errors.Is(err, ErrAlreadyDecided) {
+		t.Fatalf("second DecideAppeal() error = %v, want ErrAlreadyDecided", err)
+	}
+	if again.Status != APPEAL_OVERTURNED || again.Moderator != "U_MOD" {
+		t.Errorf("second DecideAppeal() = %+v, want the first decision", again)
+	}
+
+	for _, c := range []*Case{
+		{Author: "U_SPAM", Domain: "spam.example", Verdict: "removed"},
+		{Author: "U_SPAM", Domain: "spam.example", Verdict: "removed"},
+		{Author: "U_KEPT", Domain: "spam.example", Verdict: "kept"},
+	} {
+		if err := s.CreateCase(ctx, c); err != nil {
+			t.Fatalf("CreateCase() error = %v", err)
+		}
+	}
+	authors, err := s.Authors(ctx, "removed", time.Time{})
+	if err != nil || !slices.Equal(authors, []string{"U_SPAM"}) {
+		t.Errorf("Authors() = %v, %v, want only U_SPAM; U_OP's removal was overturned", authors, err)
+	}
+	if authors, _ := s.Authors(ctx, "removed", time.Now().Add(time.Hour)); len(authors) != 0 {
+		t.Errorf("Authors() since the future = %v, want none", authors)
+	}
+	if n, err := s.CountByDomain(ctx, "spam.example", "removed", time.Time{}); err != nil || n != 2 {
+		t.Errorf("CountByDomain() = %d, %v, want 2", n, err)
+	}
+}
+
+func TestMemoryStoreReports(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	kept := &Case{Channel: "C1", Timestamp: "1.2", Author: "U_BURST", Verdict: "kept"}
+	if err := s.CreateCase(ctx, kept); err != nil {
+		t.Fatalf("CreateCase() error = %v", err)
+	}
+	if err := s.AddReport(ctx, &Report{CaseID: kept.ID, Channel: "C2", Timestamp: "1.3", Verdict: "removed"}); err != nil {
+		t.Fatalf("AddReport() error = %v", err)
+	}
+	if reports := s.Reports(kept.ID); len(reports) != 1 || reports[0].Timestamp != "1.3" {
+		t.Errorf("Reports() = %+v, want the added report", reports)
+	}
+	if got, _ := s.Case(ctx, kept.ID); got.Verdict != "removed" {
+		t.Errorf("case verdict after a removed report = %q, want removed", got.Verdict)
+	}
+	if err := s.AddReport(ctx, &Report{CaseID: 99}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AddReport() to a missing case error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryStorePauses(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	if _, err := s.ActivePause(ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ActivePause() before any pause error = %v, want ErrNotFound", err)
+	}
+	if err := s.Pause(ctx, &Pause{Channel: "C1", Count: 3, Limit: 3, Window: time.Hour}); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	p, err := s.ActivePause(ctx)
+	if err != nil || p.Channel != "C1" || p.Window != time.Hour {
+		t.Fatalf("ActivePause() = %+v, %v, want the pause in C1", p, err)
+	}
+	if err := s.Resume(ctx, "U_ADMIN"); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if _, err := s.ActivePause(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ActivePause() after Resume() error = %v, want ErrNotFound", err)
+	}
+}
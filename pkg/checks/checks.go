@@ -0,0 +1,244 @@
+// Package checks verifies the things penny depends on: its database, its Slack
+// tokens and their scopes, and its membership in the spam-feed channel. The same
+// checks back the /readyz endpoint and `penny doctor`.
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/slackclient"
+	"gorm.io/gorm"
+)
+
+// DefaultSlackAPIURL is where token checks send auth.test.
+const DefaultSlackAPIURL = slack.APIURL
+
+// Check is a single named dependency check.
+type Check struct {
+	Name string
+	// Hint tells an operator how to fix a failure.
+	Hint string
+	Run  func(ctx context.Context) (detail string, err error)
+}
+
+// Result is the outcome of one Check. A degraded check failed in a way penny
+// can run with, so it doesn't fail the Report.
+type Result struct {
+	Name     string        `json:"name"`
+	OK       bool          `json:"ok"`
+	Degraded bool          `json:"degraded,omitempty"`
+	Detail   string        `json:"detail,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Hint     string        `json:"hint,omitempty"`
+	Elapsed  time.Duration `json:"elapsed_ns"`
+}
+
+// Report is the outcome of running a set of checks. It's OK unless a check
+// failed outright, and Degraded if any check was.
+type Report struct {
+	OK       bool     `json:"ok"`
+	Degraded bool     `json:"degraded,omitempty"`
+	Checks   []Result `json:"checks"`
+}
+
+// degradedError is a check failure penny can run with.
+type degradedError struct{ error }
+
+func (e degradedError) Unwrap() error { return e.error }
+
+// Degraded marks err as degrading readiness rather than failing it: penny
+// serves, with the features that need the check's dependency turned off.
+func Degraded(err error) error {
+	return degradedError{err}
+}
+
+// Run runs every check concurrently and reports them in the order given.
+func Run(ctx context.Context, checks []Check) Report {
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			detail, err := c.Run(ctx)
+			results[i] = Result{Name: c.Name, OK: err == nil, Detail: detail, Elapsed: time.Since(start)}
+			if err != nil {
+				results[i].Degraded = errors.As(err, new(degradedError))
+				results[i].Error = err.Error()
+				results[i].Hint = c.Hint
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := Report{OK: true, Checks: results}
+	for _, r := range results {
+		report.OK = report.OK && (r.OK || r.Degraded)
+		report.Degraded = report.Degraded || r.Degraded
+	}
+	return report
+}
+
+// Pinger is satisfied by *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Database checks that the database answers a ping.
+func Database(db Pinger) Check {
+	return Check{
+		Name: "database",
+		Hint: "Check db.hostname, db.name, db.username and db.password.",
+		Run: func(ctx context.Context) (string, error) {
+			if db == nil {
+				return "", errors.New("no database connection")
+			}
+			return "", db.PingContext(ctx)
+		},
+	}
+}
+
+// SlackToken checks that the token is valid with auth.test and that it was
+// granted every scope required. Both are read when the check runs, so it
+// follows config reloads. An optional token that isn't configured only
+// degrades readiness. Slack lists a token's scopes in the X-OAuth-Scopes
+// header, which slack-go doesn't expose, so the call is made directly.
+func SlackToken(name, apiURL string, token func() string, required func() []string, optional bool) Check {
+	return Check{
+		Name: name,
+		Hint: "Add any missing scopes under OAuth & Permissions (manifest.yaml lists them all), reinstall the app and copy the new token.",
+		Run: func(ctx context.Context) (string, error) {
+			token := token()
+			if token == "" {
+				if optional {
+					return "", Degraded(errors.New("no token configured"))
+				}
+				return "", errors.New("no token configured")
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"auth.test", nil)
+			if err != nil {
+				return "", err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return "", fmt.Errorf("auth.test returned %s", resp.Status)
+			}
+			var auth struct {
+				slack.SlackResponse
+				User string `json:"user"`
+				Team string `json:"team"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+				return "", fmt.Errorf("decoding auth.test: %w", err)
+			}
+			if !auth.Ok {
+				return "", fmt.Errorf("auth.test: %s", auth.Error)
+			}
+
+			granted := strings.Split(resp.Header.Get("X-OAuth-Scopes"), ",")
+			for i := range granted {
+				granted[i] = strings.TrimSpace(granted[i])
+			}
+			var missing []string
+			for _, scope := range required() {
+				if !slices.Contains(granted, scope) {
+					missing = append(missing, scope)
+				}
+			}
+			detail := fmt.Sprintf("%s on %s", auth.User, auth.Team)
+			if len(missing) != 0 {
+				return detail, fmt.Errorf("missing scopes: %s", strings.Join(missing, ", "))
+			}
+			return detail, nil
+		},
+	}
+}
+
+// ChannelMember checks that the bot is a member of the channel named by name,
+// which is read when the check runs. resolve finds the channel's ID, which it
+// should cache, so each run costs only a conversations.info.
+func ChannelMember(api slackclient.Client, name func() string, resolve func(ctx context.Context, name string) (string, error)) Check {
+	return Check{
+		Name: "spam_feed_channel",
+		Hint: "Create the spam_feed.channel if needed and invite Penny with `/invite @penny`.",
+		Run: func(ctx context.Context) (string, error) {
+			name := name()
+			if name == "" {
+				return "no spam-feed channel configured", nil
+			}
+			id, err := resolve(ctx, name)
+			if err != nil {
+				return "", err
+			}
+			ch, err := api.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: id})
+			if err != nil {
+				return "", fmt.Errorf("getting #%s: %w", name, err)
+			}
+			if !ch.IsMember {
+				return "", fmt.Errorf("not a member of #%s", name)
+			}
+			return "#" + name, nil
+		},
+	}
+}
+
+// Timezone checks that name is a valid IANA time zone.
+func Timezone(name string) Check {
+	return Check{
+		Name: "local_timezone",
+		Hint: "Use a TZ database name such as America/New_York, or leave it empty.",
+		Run: func(ctx context.Context) (string, error) {
+			if name == "" {
+				return "not enforced", nil
+			}
+			if _, err := time.LoadLocation(name); err != nil {
+				return "", fmt.Errorf("unknown time zone %q", name)
+			}
+			return name, nil
+		},
+	}
+}
+
+// Migrations checks that the tables for models exist. db is pinged first, since
+// the migrator can't tell a missing table from an unreachable database.
+func Migrations(db Pinger, migrator gorm.Migrator, models ...any) Check {
+	return Check{
+		Name: "migrations",
+		Hint: "Run `penny serve` once against this database to create the tables.",
+		Run: func(ctx context.Context) (string, error) {
+			if db == nil || migrator == nil {
+				return "", errors.New("no database connection")
+			}
+			if err := db.PingContext(ctx); err != nil {
+				return "", fmt.Errorf("database unreachable: %w", err)
+			}
+			var missing []string
+			for _, m := range models {
+				if !migrator.HasTable(m) {
+					missing = append(missing, fmt.Sprintf("%T", m))
+				}
+			}
+			if len(missing) != 0 {
+				return "", fmt.Errorf("missing tables for %s", strings.Join(missing, ", "))
+			}
+			return fmt.Sprintf("%d tables", len(models)), nil
+		},
+	}
+}
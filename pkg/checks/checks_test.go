@@ -0,0 +1,299 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/xortim/penny/pkg/slackclient"
+	"gorm.io/gorm"
+)
+
+type pingerFunc func(ctx context.Context) error
+
+func (f pingerFunc) PingContext(ctx context.Context) error { return f(ctx) }
+
+func okCheck(name string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) (string, error) { return "fine", nil }}
+}
+
+func failingCheck(name string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) (string, error) { return "", errors.New("broken") }}
+}
+
+func TestRun(t *testing.T) {
+	report := Run(context.Background(), []Check{okCheck("a"), failingCheck("b"), okCheck("c")})
+	if report.OK {
+		t.Errorf("Run() OK = true, want false when a check fails")
+	}
+	for i, want := range []string{"a", "b", "c"} {
+		if report.Checks[i].Name != want {
+			t.Errorf("Checks[%d].Name = %q, want %q", i, report.Checks[i].Name, want)
+		}
+	}
+	if report.Checks[1].Error != "broken" || report.Checks[0].Detail != "fine" {
+		t.Errorf("unexpected results %+v", report.Checks)
+	}
+
+	if report := Run(context.Background(), []Check{okCheck("a")}); !report.OK {
+		t.Errorf("Run() OK = false, want true")
+	}
+
+	degraded := Check{Name: "d", Run: func(ctx context.Context) (string, error) {
+		return "", Degraded(errors.New("optional"))
+	}}
+	report = Run(context.Background(), []Check{okCheck("a"), degraded})
+	if !report.OK || !report.Degraded || !report.Checks[1].Degraded || report.Checks[1].OK {
+		t.Errorf("Run() with a degraded check = %+v, want OK and degraded", report)
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	if _, err := Database(pingerFunc(func(ctx context.Context) error { return nil })).Run(context.Background()); err != nil {
+		t.Errorf("Database() with a healthy db returned %v", err)
+	}
+	if _, err := Database(pingerFunc(func(ctx context.Context) error { return errors.New("refused") })).Run(context.Background()); err == nil {
+		t.Errorf("Database() with a failing db returned nil")
+	}
+	if _, err := Database(nil).Run(context.Background()); err == nil {
+		t.Errorf("Database(nil) returned nil")
+	}
+}
+
+func TestSlackToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer xoxb-good":
+			w.Header().Set("X-OAuth-Scopes", "chat:write, channels:read,reactions:write")
+			_, _ = w.Write([]byte(`{"ok":true,"user":"penny","team":"Community"}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		token      string
+		required   []string
+		optional   bool
+		wantErr    string
+		wantDetail string
+		degraded   bool
+	}{
+		{
+			name:       "valid token with every scope",
+			token:      "xoxb-good",
+			required:   []string{"chat:write", "channels:read"},
+			wantDetail: "penny on Community",
+		},
+		{
+			name:     "missing scopes",
+			token:    "xoxb-good",
+			required: []string{"chat:write", "search:read", "users:read"},
+			wantErr:  "missing scopes: search:read, users:read",
+		},
+		{
+			name:    "invalid token",
+			token:   "xoxb-bad",
+			wantErr: "auth.test: invalid_auth",
+		},
+		{
+			name:    "no token",
+			wantErr: "no token configured",
+		},
+		{
+			name:     "no optional token",
+			optional: true,
+			wantErr:  "no token configured",
+			degraded: true,
+		},
+		{
+			name:     "invalid optional token",
+			token:    "xoxp-bad",
+			optional: true,
+			wantErr:  "auth.test: invalid_auth",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := SlackToken("bot_token", srv.URL+"/",
+				func() string { return tt.token }, func() []string { return tt.required }, tt.optional)
+			detail, err := check.Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("SlackToken() error = %v, want %q", err, tt.wantErr)
+				}
+				if got := errors.As(err, new(degradedError)); got != tt.degraded {
+					t.Errorf("SlackToken() degraded = %v, want %v", got, tt.degraded)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SlackToken() unexpected error: %v", err)
+			}
+			if detail != tt.wantDetail {
+				t.Errorf("SlackToken() detail = %q, want %q", detail, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestChannelMember(t *testing.T) {
+	ids := map[string]string{"spam-feed": "C_FEED", "lurking": "C_LURK"}
+	resolve := func(ctx context.Context, name string) (string, error) {
+		if id, ok := ids[name]; ok {
+			return id, nil
+		}
+		return "", errors.New("not found")
+	}
+	mock := &slackclient.MockClient{
+		GetConversationInfoFn: func(input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+			var ch slack.Channel
+			ch.ID = input.ChannelID
+			ch.IsMember = input.ChannelID == "C_FEED"
+			return &ch, nil
+		},
+	}
+
+	tests := []struct {
+		name    string
+		channel string
+		wantErr bool
+	}{
+		{"member", "spam-feed", false},
+		{"not a member", "lurking", true},
+		{"missing channel", "nope", true},
+		{"nothing configured", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ChannelMember(mock, func() string { return tt.channel }, resolve).Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ChannelMember(%q) error = %v, wantErr %v", tt.channel, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	runs := 0
+	healthy := false
+	check := Check{Name: "flaky", Run: func(ctx context.Context) (string, error) {
+		runs++
+		if !healthy {
+			return "", errors.New("not yet")
+		}
+		return "", nil
+	}}
+	h := ReadyHandler([]Check{check}, time.Second, time.Hour)
+
+	get := func() (int, Report) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var report Report
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Fatalf("decoding /readyz: %v", err)
+		}
+		return rec.Code, report
+	}
+
+	code, report := get()
+	if code != http.StatusServiceUnavailable || report.OK || report.Checks[0].Error != "not yet" {
+		t.Errorf("/readyz = %d %+v, want 503 with the failing check", code, report)
+	}
+
+	healthy = true
+	if code, _ := get(); code != http.StatusServiceUnavailable || runs != 1 {
+		t.Errorf("/readyz within the ttl = %d after %d runs, want the cached 503 after 1", code, runs)
+	}
+}
+
+func TestLiveHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	LiveHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ok"`) {
+		t.Errorf("/healthz = %d %s, want 200 ok", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	tests := []struct {
+		name    string
+		tz      string
+		wantErr bool
+	}{
+		{"valid zone", "America/New_York", false},
+		{"not enforced", "", false},
+		{"unknown zone", "Mars/Olympus_Mons", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Timezone(tt.tz).Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Timezone(%q) error = %v, wantErr %v", tt.tz, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeMigrator reports the tables it was given as present.
+type fakeMigrator struct {
+	gorm.Migrator
+	tables map[string]bool
+}
+
+func (m fakeMigrator) HasTable(value any) bool { return m.tables[fmt.Sprintf("%T", value)] }
+
+type widget struct{}
+type gizmo struct{}
+
+func TestMigrations(t *testing.T) {
+	healthy := pingerFunc(func(ctx context.Context) error { return nil })
+	down := pingerFunc(func(ctx context.Context) error { return errors.New("refused") })
+	migrated := fakeMigrator{tables: map[string]bool{"*checks.widget": true}}
+
+	tests := []struct {
+		name     string
+		db       Pinger
+		migrator gorm.Migrator
+		wantErr  string
+	}{
+		{name: "all tables present", db: healthy, migrator: fakeMigrator{tables: map[string]bool{"*checks.widget": true, "*checks.gizmo": true}}},
+		{name: "missing table", db: healthy, migrator: migrated, wantErr: "missing tables for *checks.gizmo"},
+		{name: "database down", db: down, migrator: migrated, wantErr: "database unreachable: refused"},
+		{name: "no connection", wantErr: "no database connection"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Migrations(tt.db, tt.migrator, &widget{}, &gizmo{}).Run(context.Background())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Migrations() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Migrations() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunHints(t *testing.T) {
+	ok, bad := okCheck("a"), failingCheck("b")
+	ok.Hint, bad.Hint = "unused", "fix it"
+	report := Run(context.Background(), []Check{ok, bad})
+	if report.Checks[0].Hint != "" || report.Checks[1].Hint != "fix it" {
+		t.Errorf("hints = %q, %q; want only the failing check's", report.Checks[0].Hint, report.Checks[1].Hint)
+	}
+}
@@ -0,0 +1,57 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// LiveHandler answers /healthz: the process is up and serving HTTP.
+func LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+// ReadyHandler answers /readyz with a JSON Report of checks, returning 503 when
+// any check fails. Each run is limited to timeout and reused for ttl so that
+// frequent probes don't spend the Slack API budget.
+func ReadyHandler(checks []Check, timeout, ttl time.Duration) http.Handler {
+	var (
+		mu      sync.Mutex
+		last    Report
+		checked time.Time
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if checked.IsZero() || time.Since(checked) >= ttl {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			last = Run(ctx, checks)
+			cancel()
+			checked = time.Now()
+			if !last.OK {
+				log.Warn().Interface("checks", last.Checks).Msg("readiness check failed")
+			}
+		}
+
+		status := http.StatusOK
+		if !last.OK {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, last)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("failed to write check response")
+	}
+}
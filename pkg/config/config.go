@@ -0,0 +1,381 @@
+// Package config is penny's typed configuration. It is unmarshalled once from
+// viper, so flags, PENNY_* environment variables and the YAML file all land in
+// one validated struct instead of being read key by key.
+package config
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Config is everything penny can be configured with. The mapstructure tags are
+// the viper keys.
+type Config struct {
+	Log       Log       `mapstructure:"log"`
+	DB        DB        `mapstructure:"db"`
+	Slack     Slack     `mapstructure:"slack"`
+	Server    Server    `mapstructure:"server"`
+	Tracing   Tracing   `mapstructure:"tracing"`
+	Secrets   Secrets   `mapstructure:"secrets"`
+	Messages  Messages  `mapstructure:"messages"`
+	SpamFeed  SpamFeed  `mapstructure:"spam_feed"`
+	Webhooks  Webhooks  `mapstructure:"webhooks"`
+	Blocklist Blocklist `mapstructure:"blocklist"`
+}
+
+type Log struct {
+	Level string `mapstructure:"level"`
+}
+
+type DB struct {
+	Hostname     string `mapstructure:"hostname"`
+	Name         string `mapstructure:"name"`
+	Username     string `mapstructure:"username"`
+	Password     string `mapstructure:"password"`
+	PasswordFile string `mapstructure:"password_file"`
+}
+
+type Slack struct {
+	BotOAuthToken      string   `mapstructure:"bot_oauth_token"`
+	BotOAuthTokenFile  string   `mapstructure:"bot_oauth_token_file"`
+	UserOAuthToken     string   `mapstructure:"user_oauth_token"`
+	UserOAuthTokenFile string   `mapstructure:"user_oauth_token_file"`
+	SigningSecret      string   `mapstructure:"signing_secret"`
+	SigningSecretFile  string   `mapstructure:"signing_secret_file"`
+	GlobalAdmins       []string `mapstructure:"global_admins"`
+	AdminChannelID     string   `mapstructure:"admin_channel_id"`
+}
+
+type Server struct {
+	Port            int           `mapstructure:"port"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+}
+
+type Tracing struct {
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+type Secrets struct {
+	// LocalStore is a YAML file that stands in for Vault; see secrets.LocalStore.
+	LocalStore string `mapstructure:"local_store"`
+}
+
+type Messages struct {
+	// Locale is the workspace's locale, such as en or es-ES.
+	Locale string `mapstructure:"locale"`
+	// UserLocale sends the OP messages in their own Slack locale when Penny has
+	// a bundle for it.
+	UserLocale bool `mapstructure:"user_locale"`
+	// Dir holds <locale>.yaml bundles laid over the built-in ones.
+	Dir string `mapstructure:"dir"`
+}
+
+type SpamFeed struct {
+	Channel             string `mapstructure:"channel"`
+	AssistanceChannelID string `mapstructure:"assistance_channel_id"`
+	Emoji               string `mapstructure:"emoji"`
+	ReactionEmojiHit    string `mapstructure:"reaction_emoji_hit"`
+	ReactionEmojiMiss   string `mapstructure:"reaction_emoji_miss"`
+	ReacjiResponse      string `mapstructure:"reacji_response"`
+	OpWarning           string `mapstructure:"op_warning"`
+	OpNoticeDelivery    string `mapstructure:"op_notice_delivery"`
+	LocalTimezone       string `mapstructure:"local_timezone"`
+
+	ActivityLowWatermark int           `mapstructure:"activity_low_watermark"`
+	MaxAnomalyScore      int           `mapstructure:"max_anomaly_score"`
+	AnomalyScores        AnomalyScores `mapstructure:"anomaly_scores"`
+
+	SignalDeadline      time.Duration `mapstructure:"signal_deadline"`
+	SignalTimeoutPolicy string        `mapstructure:"signal_timeout_policy"`
+
+	Queue      Queue      `mapstructure:"queue"`
+	Breaker    Breaker    `mapstructure:"breaker"`
+	Appeals    Appeals    `mapstructure:"appeals"`
+	Escalation Escalation `mapstructure:"escalation"`
+
+	EmailDomain   EmailDomain   `mapstructure:"email_domain"`
+	Impersonation Impersonation `mapstructure:"impersonation"`
+	Duplicates    Duplicates    `mapstructure:"duplicates"`
+
+	Proactive Proactive `mapstructure:"proactive"`
+	Probation Probation `mapstructure:"probation"`
+}
+
+type AnomalyScores struct {
+	Reported    int `mapstructure:"reported"`
+	LowActivity int `mapstructure:"low_activity"`
+	OutsideTZ   int `mapstructure:"outside_tz"`
+	Blocklisted int `mapstructure:"blocklisted"`
+	// BlocklistedName and BlocklistedDomain replace Blocklisted when only the
+	// display name or the email domain matched, which strangers can share.
+	BlocklistedName   int `mapstructure:"blocklisted_name"`
+	BlocklistedDomain int `mapstructure:"blocklisted_domain"`
+	EmailDomain       int `mapstructure:"email_domain"`
+	Impersonation     int `mapstructure:"impersonation"`
+	Duplicates        int `mapstructure:"duplicates"`
+}
+
+type Queue struct {
+	Workers        int           `mapstructure:"workers"`
+	Capacity       int           `mapstructure:"capacity"`
+	EnqueueTimeout time.Duration `mapstructure:"enqueue_timeout"`
+	CoalesceWindow time.Duration `mapstructure:"coalesce_window"`
+}
+
+type Breaker struct {
+	Window             time.Duration `mapstructure:"window"`
+	MaxRemovals        int           `mapstructure:"max_removals"`
+	MaxChannelRemovals int           `mapstructure:"max_channel_removals"`
+	AlertChannelID     string        `mapstructure:"alert_channel_id"`
+}
+
+type Escalation struct {
+	// Band escalates reports scoring within Band of max_anomaly_score that
+	// weren't removed. 0 disables escalation.
+	Band        int      `mapstructure:"band"`
+	UserGroupID string   `mapstructure:"user_group_id"`
+	Moderators  []string `mapstructure:"moderators"`
+	// Notify is how the moderators are pinged: thread, dm or both.
+	Notify string `mapstructure:"notify"`
+	// MaxPings caps the pings within Window. 0 disables the cap.
+	MaxPings int           `mapstructure:"max_pings"`
+	Window   time.Duration `mapstructure:"window"`
+}
+
+type EmailDomain struct {
+	// DisposableList is a file of disposable domains, one per line, checked
+	// as well as the bundled list.
+	DisposableList string `mapstructure:"disposable_list"`
+	// RecentRemovals is how many removals from a domain within RecentWindow
+	// make it suspect. 0 disables the check.
+	RecentRemovals int           `mapstructure:"recent_removals"`
+	RecentWindow   time.Duration `mapstructure:"recent_window"`
+	// Expected are the domains members of a corporate community sign up
+	// with. Any other domain is suspect; none are if it's empty.
+	Expected []string `mapstructure:"expected"`
+}
+
+type Impersonation struct {
+	// ProtectedUsers are members whose names are protected as well as the
+	// workspace's admins and owners.
+	ProtectedUsers []string `mapstructure:"protected_users"`
+	// ProtectedNames are names protected as is, like the community's own.
+	ProtectedNames []string `mapstructure:"protected_names"`
+	// MaxDistance is how many edits apart a name can be and still resemble a
+	// protected one.
+	MaxDistance int `mapstructure:"max_distance"`
+}
+
+type Duplicates struct {
+	// Window is how long each author's posts are remembered.
+	Window time.Duration `mapstructure:"window"`
+	// MinChannels is how many channels copies of a message must be in.
+	MinChannels int `mapstructure:"min_channels"`
+	// MaxDistance is how many bits apart two fingerprints can be and still
+	// be copies, out of 64.
+	MaxDistance int `mapstructure:"max_distance"`
+	// MinLength is the fewest letters and digits a message without links
+	// needs to be fingerprinted, so short replies like "thanks!" aren't.
+	MinLength int `mapstructure:"min_length"`
+}
+
+type Proactive struct {
+	// Enabled screens low-trust authors' new messages before anyone reports them.
+	Enabled bool `mapstructure:"enabled"`
+	// ChannelIDs are the channels screened. Every channel Penny is in but the
+	// spam feed is screened if it's empty.
+	ChannelIDs []string `mapstructure:"channel_ids"`
+	// Signals name the signals a screened message is scored on.
+	Signals []string `mapstructure:"signals"`
+	// RemoveScore removes a screened message outright. 0 never does.
+	RemoveScore int `mapstructure:"remove_score"`
+	// ReviewScore posts a screened message to the spam feed for moderators.
+	ReviewScore int `mapstructure:"review_score"`
+	// MaxMessages makes authors with fewer public messages low-trust. 0
+	// disables the check.
+	MaxMessages int `mapstructure:"max_messages"`
+	// MaxMemberAge makes members who joined within it low-trust. 0 disables
+	// the check.
+	MaxMemberAge time.Duration `mapstructure:"max_member_age"`
+}
+
+type Probation struct {
+	// Enabled puts new members on probation, judging reports against them by
+	// MaxAnomalyScore instead of spam_feed.max_anomaly_score.
+	Enabled         bool `mapstructure:"enabled"`
+	MaxAnomalyScore int  `mapstructure:"max_anomaly_score"`
+	// Messages is how many accepted messages lift probation. Members joining
+	// a channel with fewer public messages are put on probation too. 0
+	// leaves it to Duration.
+	Messages int `mapstructure:"messages"`
+	// Duration lifts probation this long after it started. 0 leaves it to
+	// Messages.
+	Duration time.Duration `mapstructure:"duration"`
+	// Grace is how long a message must stand without being reported before
+	// it's accepted, when there's no ApprovalChannelID.
+	Grace time.Duration `mapstructure:"grace"`
+	// ApprovalChannelID is where moderators approve or remove the messages of
+	// members on probation. Only approved messages are accepted if it's set;
+	// otherwise every message that stands for Grace without being reported is.
+	ApprovalChannelID string `mapstructure:"approval_channel_id"`
+}
+
+type Appeals struct {
+	// ChannelID is where moderators review appeals. Appeals are off if it's empty.
+	ChannelID string `mapstructure:"channel_id"`
+}
+
+type Webhooks struct {
+	URLs       []string `mapstructure:"urls"`
+	Secret     string   `mapstructure:"secret"`
+	SecretFile string   `mapstructure:"secret_file"`
+	// Events limits the events sent; all of them are sent if it's empty.
+	Events      []string      `mapstructure:"events"`
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	Backoff     time.Duration `mapstructure:"backoff"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+	// DeadLetter is a file that undeliverable payloads are appended to.
+	DeadLetter string `mapstructure:"dead_letter"`
+}
+
+type Blocklist struct {
+	// Feeds are the file paths or http(s) URLs of signed feeds to import.
+	Feeds []string `mapstructure:"feeds"`
+	// PublicKeys are the base64 ed25519 keys of the publishers whose feeds
+	// are trusted.
+	PublicKeys []string `mapstructure:"public_keys"`
+	// Salt keys the email and display name hashes. Every community sharing
+	// feeds must use the same one.
+	Salt     string `mapstructure:"salt"`
+	SaltFile string `mapstructure:"salt_file"`
+	// PrivateKey signs the feeds penny blocklist export writes.
+	PrivateKey     string        `mapstructure:"private_key"`
+	PrivateKeyFile string        `mapstructure:"private_key_file"`
+	Issuer         string        `mapstructure:"issuer"`
+	Refresh        time.Duration `mapstructure:"refresh"`
+}
+
+// Aliases maps the older key names penny still accepts to their current ones.
+var Aliases = map[string]string{
+	"db.host":     "db.hostname",
+	"db.user":     "db.username",
+	"db.pass":     "db.password",
+	"listen.port": "server.port",
+}
+
+// Load unmarshals v into a Config and resolves its secrets. It doesn't
+// validate; see Config.Validate.
+func Load(v *viper.Viper) (*Config, error) {
+	var c Config
+	if err := v.Unmarshal(&c); err != nil {
+		return nil, err
+	}
+	for i := range c.Slack.GlobalAdmins {
+		c.Slack.GlobalAdmins[i] = strings.TrimSpace(c.Slack.GlobalAdmins[i])
+	}
+	for i := range c.SpamFeed.EmailDomain.Expected {
+		c.SpamFeed.EmailDomain.Expected[i] = strings.ToLower(strings.TrimSpace(c.SpamFeed.EmailDomain.Expected[i]))
+	}
+	for i := range c.SpamFeed.Impersonation.ProtectedUsers {
+		c.SpamFeed.Impersonation.ProtectedUsers[i] = strings.TrimSpace(c.SpamFeed.Impersonation.ProtectedUsers[i])
+	}
+	for i := range c.SpamFeed.Proactive.ChannelIDs {
+		c.SpamFeed.Proactive.ChannelIDs[i] = strings.TrimSpace(c.SpamFeed.Proactive.ChannelIDs[i])
+	}
+	for i := range c.SpamFeed.Proactive.Signals {
+		c.SpamFeed.Proactive.Signals[i] = strings.TrimSpace(c.SpamFeed.Proactive.Signals[i])
+	}
+	for i := range c.SpamFeed.Escalation.Moderators {
+		c.SpamFeed.Escalation.Moderators[i] = strings.TrimSpace(c.SpamFeed.Escalation.Moderators[i])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+	defer cancel()
+	if err := c.resolveSecrets(ctx); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// walk calls fn with the key and value of every setting in c.
+func walk(c *Config, fn func(key string, v reflect.Value)) {
+	var visit func(prefix string, v reflect.Value)
+	visit = func(prefix string, v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			key := prefix + v.Type().Field(i).Tag.Get("mapstructure")
+			if f := v.Field(i); f.Kind() == reflect.Struct {
+				visit(key+".", f)
+			} else {
+				fn(key, f)
+			}
+		}
+	}
+	visit("", reflect.ValueOf(c).Elem())
+}
+
+// Keys returns every key Config understands, sorted.
+func Keys() []string {
+	var keys []string
+	walk(&Config{}, func(key string, _ reflect.Value) {
+		keys = append(keys, key)
+	})
+	slices.Sort(keys)
+	return keys
+}
+
+// UnknownKeys returns the keys set in v that Config doesn't understand, which
+// are usually typos.
+func UnknownKeys(v *viper.Viper) []string {
+	known := Keys()
+	var unknown []string
+	for _, key := range v.AllKeys() {
+		if _, alias := Aliases[key]; alias || slices.Contains(known, key) {
+			continue
+		}
+		unknown = append(unknown, key)
+	}
+	slices.Sort(unknown)
+	return unknown
+}
+
+var current atomic.Pointer[Config]
+
+// Current returns the configuration in effect. Callers handling an event should
+// take it once, so a reload can't change settings halfway through.
+func Current() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return &Config{}
+}
+
+// Store makes c the configuration in effect.
+func Store(c *Config) {
+	current.Store(c)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying c, so everything handling one event
+// sees the same configuration.
+func NewContext(ctx context.Context, c *Config) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the configuration carried by ctx, or Current if it has
+// none.
+func FromContext(ctx context.Context) *Config {
+	if c, ok := ctx.Value(contextKey{}).(*Config); ok {
+		return c
+	}
+	return Current()
+}
@@ -0,0 +1,325 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// newViper returns a viper holding a valid configuration, with overrides applied.
+func newViper(t *testing.T, overrides map[string]interface{}) *viper.Viper {
+	t.Helper()
+	v := viper.New()
+	for alias, key := range Aliases {
+		v.RegisterAlias(alias, key)
+	}
+	for k, val := range map[string]interface{}{
+		"log.level":                              "info",
+		"db.hostname":                            "localhost",
+		"db.name":                                "penny",
+		"db.username":                            "penny",
+		"slack.bot_oauth_token":                  "xoxb-1",
+		"slack.user_oauth_token":                 "xoxp-1",
+		"slack.signing_secret":                   "secret",
+		"server.port":                            3000,
+		"spam_feed.channel":                      "spam-feed",
+		"spam_feed.emoji":                        "no_entry_sign",
+		"spam_feed.max_anomaly_score":            5,
+		"spam_feed.anomaly_scores.reported":      2,
+		"spam_feed.anomaly_scores.low_activity":  1,
+		"spam_feed.anomaly_scores.outside_tz":    2,
+		"spam_feed.signal_timeout_policy":        "zero",
+		"spam_feed.queue.workers":                4,
+		"spam_feed.queue.capacity":               100,
+		"spam_feed.breaker.max_channel_removals": 10,
+		"webhooks.max_attempts":                  5,
+	} {
+		v.Set(k, val)
+	}
+	for k, val := range overrides {
+		v.Set(k, val)
+	}
+	return v
+}
+
+func TestLoad(t *testing.T) {
+	v := newViper(t, map[string]interface{}{
+		"spam_feed.signal_deadline": "5s",
+		"slack.global_admins":       "U0123ABCD, U0456EFGH",
+		"db.host":                   "db.internal",
+	})
+	c, err := Load(v)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if c.SpamFeed.SignalDeadline != 5*time.Second {
+		t.Errorf("SignalDeadline = %s, want 5s", c.SpamFeed.SignalDeadline)
+	}
+	if want := []string{"U0123ABCD", "U0456EFGH"}; !slices.Equal(c.Slack.GlobalAdmins, want) {
+		t.Errorf("GlobalAdmins = %q, want %q", c.Slack.GlobalAdmins, want)
+	}
+	if c.DB.Hostname != "db.internal" {
+		t.Errorf("DB.Hostname = %q, want the db.host alias", c.DB.Hostname)
+	}
+	if c.SpamFeed.Breaker.MaxChannelRemovals != 10 {
+		t.Errorf("Breaker.MaxChannelRemovals = %d, want 10", c.SpamFeed.Breaker.MaxChannelRemovals)
+	}
+
+	if _, err := Load(newViper(t, map[string]interface{}{"spam_feed.max_anomaly_score": "lots"})); err == nil {
+		t.Errorf("Load() with a non-numeric max_anomaly_score returned nil")
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	v := newViper(t, map[string]interface{}{
+		"spam_feed.max_anomoly_score": 3,
+		"db.pass":                     "hunter2",
+	})
+	if got, want := UnknownKeys(v), []string{"spam_feed.max_anomoly_score"}; !slices.Equal(got, want) {
+		t.Errorf("UnknownKeys() = %q, want %q", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]interface{}
+		wantPaths []string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:      "threshold left at zero",
+			overrides: map[string]interface{}{"spam_feed.max_anomaly_score": 0},
+			wantPaths: []string{"spam_feed.max_anomaly_score"},
+		},
+		{
+			name: "missing and mistyped tokens",
+			overrides: map[string]interface{}{
+				"slack.bot_oauth_token":  "xoxp-swapped",
+				"slack.user_oauth_token": "",
+				"slack.signing_secret":   "",
+			},
+			wantPaths: []string{"slack.bot_oauth_token", "slack.user_oauth_token", "slack.signing_secret"},
+		},
+		{
+			name: "user token optional without a spam feed",
+			overrides: map[string]interface{}{
+				"spam_feed.channel":      "",
+				"slack.user_oauth_token": "",
+			},
+		},
+		{
+			name: "locale and messages dir",
+			overrides: map[string]interface{}{
+				"messages.locale": "ja",
+				"messages.dir":    "/nonexistent/messages",
+			},
+			wantPaths: []string{"messages.dir"},
+		},
+		{
+			name:      "locale without messages",
+			overrides: map[string]interface{}{"messages.locale": "ja"},
+			wantPaths: []string{"messages.locale"},
+		},
+		{
+			name:      "locale with messages for its language",
+			overrides: map[string]interface{}{"messages.locale": "es-MX"},
+		},
+		{
+			name:      "unknown notice delivery",
+			overrides: map[string]interface{}{"spam_feed.op_notice_delivery": "email"},
+			wantPaths: []string{"spam_feed.op_notice_delivery"},
+		},
+		{
+			name: "escalation targets",
+			overrides: map[string]interface{}{
+				"spam_feed.escalation.user_group_id": "@moderators",
+				"spam_feed.escalation.moderators":    []string{"U0123ABCD", "@alice"},
+				"spam_feed.escalation.notify":        "email",
+			},
+			wantPaths: []string{"spam_feed.escalation.user_group_id", "spam_feed.escalation.moderators[1]", "spam_feed.escalation.notify"},
+		},
+		{
+			name: "webhooks",
+			overrides: map[string]interface{}{
+				"webhooks.urls":   []string{"https://hooks.example.com/penny", "hooks.example.com"},
+				"webhooks.events": []string{"case.created", "case.deleted"},
+			},
+			wantPaths: []string{"webhooks.urls[1]", "webhooks.secret", "webhooks.events[1]"},
+		},
+		{
+			name: "blocklist",
+			overrides: map[string]interface{}{
+				"blocklist.feeds":       []string{"/etc/penny/feed.json", "https://intel.example.com/feed.json", "ftp://intel.example.com/feed.json"},
+				"blocklist.public_keys": []string{"MCowBQYDK2VwAyEA"},
+				"blocklist.private_key": "not-a-key",
+			},
+			wantPaths: []string{"blocklist.private_key", "blocklist.feeds[2]", "blocklist.public_keys[0]", "blocklist.salt"},
+		},
+		{
+			name: "email domain",
+			overrides: map[string]interface{}{
+				"spam_feed.email_domain.disposable_list": "/nonexistent/disposable.txt",
+				"spam_feed.email_domain.recent_removals": -1,
+				"spam_feed.email_domain.expected":        []string{"Example.com", "someone@example.com"},
+			},
+			wantPaths: []string{"spam_feed.email_domain.disposable_list", "spam_feed.email_domain.recent_removals", "spam_feed.email_domain.expected[1]"},
+		},
+		{
+			name: "impersonation",
+			overrides: map[string]interface{}{
+				"spam_feed.impersonation.protected_users": []string{"U0123ABCD", "@admin"},
+				"spam_feed.impersonation.max_distance":    -1,
+			},
+			wantPaths: []string{"spam_feed.impersonation.protected_users[1]", "spam_feed.impersonation.max_distance"},
+		},
+		{
+			name: "duplicates",
+			overrides: map[string]interface{}{
+				"spam_feed.anomaly_scores.duplicates": 3,
+				"spam_feed.duplicates.min_channels":   1,
+				"spam_feed.duplicates.max_distance":   65,
+			},
+			wantPaths: []string{"spam_feed.duplicates.min_channels", "spam_feed.duplicates.max_distance"},
+		},
+		{
+			name: "proactive",
+			overrides: map[string]interface{}{
+				"spam_feed.proactive.enabled":      true,
+				"spam_feed.proactive.channel_ids":  []string{"C0123ABCD", "#general"},
+				"spam_feed.proactive.signals":      []string{"low_activity", "reported"},
+				"spam_feed.proactive.review_score": 4,
+				"spam_feed.proactive.remove_score": 2,
+			},
+			wantPaths: []string{
+				"spam_feed.proactive.channel_ids[1]",
+				"spam_feed.proactive.signals[1]",
+				"spam_feed.proactive.remove_score",
+				"spam_feed.proactive",
+			},
+		},
+		{
+			name: "probation",
+			overrides: map[string]interface{}{
+				"spam_feed.probation.enabled":             true,
+				"spam_feed.probation.approval_channel_id": "#moderators",
+				"spam_feed.probation.grace":               "-1h",
+			},
+			wantPaths: []string{
+				"spam_feed.probation.grace",
+				"spam_feed.probation.approval_channel_id",
+				"spam_feed.probation.max_anomaly_score",
+				"spam_feed.probation",
+			},
+		},
+		{
+			name:      "appeals channel name instead of ID",
+			overrides: map[string]interface{}{"spam_feed.appeals.channel_id": "#moderators"},
+			wantPaths: []string{"spam_feed.appeals.channel_id"},
+		},
+		{
+			name:      "unknown time zone",
+			overrides: map[string]interface{}{"spam_feed.local_timezone": "America/Nowhere"},
+			wantPaths: []string{"spam_feed.local_timezone"},
+		},
+		{
+			name: "emoji with colons",
+			overrides: map[string]interface{}{
+				"spam_feed.emoji":              ":no_entry_sign:",
+				"spam_feed.reaction_emoji_hit": "thumbsup::skin-tone-3",
+			},
+			wantPaths: []string{"spam_feed.emoji"},
+		},
+		{
+			name:      "bad global admin",
+			overrides: map[string]interface{}{"slack.global_admins": []string{"U0123ABCD", "@bob"}},
+			wantPaths: []string{"slack.global_admins[1]"},
+		},
+		{
+			name: "out of range",
+			overrides: map[string]interface{}{
+				"server.port":                     70000,
+				"spam_feed.queue.workers":         0,
+				"spam_feed.signal_timeout_policy": "ignore",
+				"tracing.sample_ratio":            1.5,
+			},
+			wantPaths: []string{"server.port", "tracing.sample_ratio", "spam_feed.signal_timeout_policy", "spam_feed.queue.workers"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Load(newViper(t, tt.overrides))
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			err = c.Validate()
+			if len(tt.wantPaths) == 0 {
+				if err != nil {
+					t.Errorf("Validate() unexpected error:\n%v", err)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Validate() = %v, want Errors", err)
+			}
+			var paths []string
+			for _, fe := range errs {
+				paths = append(paths, fe.Path)
+			}
+			if !slices.Equal(paths, tt.wantPaths) {
+				t.Errorf("Validate() paths = %q, want %q", paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	c, _ := Load(newViper(t, map[string]interface{}{"spam_feed.max_anomaly_score": 9}))
+	warnings := c.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "can never be reached") {
+		t.Errorf("Warnings() = %v, want the unreachable threshold", warnings)
+	}
+
+	c, _ = Load(newViper(t, map[string]interface{}{"spam_feed.escalation.band": 1}))
+	warnings = c.Warnings()
+	if len(warnings) != 1 || warnings[0].Path != "spam_feed.escalation.band" {
+		t.Errorf("Warnings() = %v, want no one to escalate to", warnings)
+	}
+
+	c, _ = Load(newViper(t, map[string]interface{}{
+		"spam_feed.max_anomaly_score":           3,
+		"spam_feed.probation.enabled":           true,
+		"spam_feed.probation.max_anomaly_score": 3,
+	}))
+	warnings = c.Warnings()
+	if len(warnings) != 1 || warnings[0].Path != "spam_feed.probation.max_anomaly_score" {
+		t.Errorf("Warnings() = %v, want probation no stricter than the threshold", warnings)
+	}
+
+	c, _ = Load(newViper(t, nil))
+	if warnings := c.Warnings(); len(warnings) != 0 {
+		t.Errorf("Warnings() = %v, want none", warnings)
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	t.Cleanup(func() { Store(nil) })
+
+	if Current() == nil {
+		t.Fatalf("Current() = nil before Store, want the zero Config")
+	}
+	c := &Config{}
+	c.SpamFeed.Channel = "spam-feed"
+	Store(c)
+	if Current().SpamFeed.Channel != "spam-feed" {
+		t.Errorf("Current() didn't return the stored config")
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// REDACTED stands in for secret values in diffs and output.
+const REDACTED = "[redacted]"
+
+// restartOnly are the spam_feed keys read once at startup.
+var restartOnly = []string{
+	"spam_feed.queue.capacity",
+	"spam_feed.queue.enqueue_timeout",
+	"spam_feed.queue.workers",
+}
+
+// Reloadable reports whether a change to key takes effect without a restart.
+func Reloadable(key string) bool {
+	return strings.HasPrefix(key, "spam_feed.") && !slices.Contains(restartOnly, key)
+}
+
+// Change is a setting that differs between two configurations. Secret values
+// are redacted.
+type Change struct {
+	Key      string
+	Old, New string
+	// Restart is set when the change won't take effect until penny restarts.
+	Restart bool
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s: %s → %s", c.Key, c.Old, c.New)
+	if c.Restart {
+		s += " (needs a restart)"
+	}
+	return s
+}
+
+// Diff returns the settings that differ between prev and next, in key order.
+func Diff(prev, next *Config) []Change {
+	before := map[string]reflect.Value{}
+	walk(prev, func(key string, v reflect.Value) { before[key] = v })
+
+	var changes []Change
+	walk(next, func(key string, v reflect.Value) {
+		if equal(before[key], v) {
+			return
+		}
+		changes = append(changes, Change{
+			Key:     key,
+			Old:     display(key, before[key]),
+			New:     display(key, v),
+			Restart: !Reloadable(key),
+		})
+	})
+	slices.SortFunc(changes, func(a, b Change) int { return strings.Compare(a.Key, b.Key) })
+	return changes
+}
+
+func equal(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// display formats v for a person, redacting secrets.
+func display(key string, v reflect.Value) string {
+	if IsSecret(key) && !v.IsZero() {
+		return REDACTED
+	}
+	if v.Kind() == reflect.String {
+		return fmt.Sprintf("%q", v.String())
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Reload loads v and swaps in its reloadable settings, leaving the rest as they
+// are until restart. The result is validated as a whole first; if it's invalid,
+// the current configuration stays in effect and the error says why. Events
+// already being handled keep the configuration they started with.
+func Reload(v *viper.Viper) ([]Change, error) {
+	loaded, err := Load(v)
+	if err != nil {
+		return nil, err
+	}
+
+	prev := Current()
+	next := *prev
+	next.SpamFeed = loaded.SpamFeed
+	next.SpamFeed.Queue.Workers = prev.SpamFeed.Queue.Workers
+	next.SpamFeed.Queue.Capacity = prev.SpamFeed.Queue.Capacity
+	next.SpamFeed.Queue.EnqueueTimeout = prev.SpamFeed.Queue.EnqueueTimeout
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	changes := Diff(prev, loaded)
+	Store(&next)
+	return changes, nil
+}
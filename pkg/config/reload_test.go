@@ -0,0 +1,81 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old, _ := Load(newViper(t, nil))
+	next, _ := Load(newViper(t, map[string]interface{}{
+		"spam_feed.max_anomaly_score": 4,
+		"slack.bot_oauth_token":       "xoxb-rotated",
+		"server.port":                 8080,
+	}))
+
+	changes := Diff(old, next)
+	want := []string{
+		`server.port: 3000 → 8080 (needs a restart)`,
+		`slack.bot_oauth_token: [redacted] → [redacted] (needs a restart)`,
+		`spam_feed.max_anomaly_score: 5 → 4`,
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Diff() = %v, want %d changes", changes, len(want))
+	}
+	for i, c := range changes {
+		if c.String() != want[i] {
+			t.Errorf("changes[%d] = %q, want %q", i, c.String(), want[i])
+		}
+	}
+
+	if changes := Diff(old, old); len(changes) != 0 {
+		t.Errorf("Diff() of a config with itself = %v, want none", changes)
+	}
+}
+
+func TestReload(t *testing.T) {
+	t.Cleanup(func() { Store(nil) })
+	initial, _ := Load(newViper(t, nil))
+	Store(initial)
+
+	// An event that started before the reload keeps its settings.
+	ctx := NewContext(context.Background(), Current())
+
+	changes, err := Reload(newViper(t, map[string]interface{}{
+		"spam_feed.max_anomaly_score": 3,
+		"spam_feed.queue.workers":     16,
+		"db.hostname":                 "elsewhere",
+	}))
+	if err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Errorf("Reload() changes = %v, want 3", changes)
+	}
+
+	c := Current()
+	if c.SpamFeed.MaxAnomalyScore != 3 {
+		t.Errorf("MaxAnomalyScore = %d, want the reloaded 3", c.SpamFeed.MaxAnomalyScore)
+	}
+	if c.SpamFeed.Queue.Workers != 4 || c.DB.Hostname != "localhost" {
+		t.Errorf("restart-only settings changed: workers %d, db.hostname %q", c.SpamFeed.Queue.Workers, c.DB.Hostname)
+	}
+	if got := FromContext(ctx).SpamFeed.MaxAnomalyScore; got != 5 {
+		t.Errorf("in-flight event sees MaxAnomalyScore %d, want 5", got)
+	}
+}
+
+func TestReloadRejectsInvalid(t *testing.T) {
+	t.Cleanup(func() { Store(nil) })
+	initial, _ := Load(newViper(t, nil))
+	Store(initial)
+
+	_, err := Reload(newViper(t, map[string]interface{}{"spam_feed.max_anomaly_score": 0}))
+	if err == nil || !strings.Contains(err.Error(), "spam_feed.max_anomaly_score") {
+		t.Fatalf("Reload() error = %v, want the invalid key", err)
+	}
+	if Current() != initial {
+		t.Errorf("Reload() replaced the config despite the error")
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"context"
+	"time"
+
+	"github.com/xortim/penny/pkg/secrets"
+)
+
+// secretsTimeout bounds resolving every secret reference during Load.
+const secretsTimeout = 10 * time.Second
+
+// secretField is a credential, which may also be given as a file.
+type secretField struct {
+	key   string
+	value *string
+	file  string
+}
+
+func (c *Config) secretFields() []secretField {
+	return []secretField{
+		{"db.password", &c.DB.Password, c.DB.PasswordFile},
+		{"slack.bot_oauth_token", &c.Slack.BotOAuthToken, c.Slack.BotOAuthTokenFile},
+		{"slack.signing_secret", &c.Slack.SigningSecret, c.Slack.SigningSecretFile},
+		{"slack.user_oauth_token", &c.Slack.UserOAuthToken, c.Slack.UserOAuthTokenFile},
+		{"webhooks.secret", &c.Webhooks.Secret, c.Webhooks.SecretFile},
+		{"blocklist.salt", &c.Blocklist.Salt, c.Blocklist.SaltFile},
+		{"blocklist.private_key", &c.Blocklist.PrivateKey, c.Blocklist.PrivateKeyFile},
+	}
+}
+
+// IsSecret reports whether key holds a credential, whose value is never logged
+// or shown.
+func IsSecret(key string) bool {
+	for _, f := range (&Config{}).secretFields() {
+		if f.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSecrets replaces each credential with the contents of its _file
+// variant, if set, and then resolves it if it's a reference such as
+// vault://secret/data/penny#bot_token.
+func (c *Config) resolveSecrets(ctx context.Context) error {
+	var v validator
+	resolvers := secrets.Defaults()
+	if c.Secrets.LocalStore != "" {
+		store, err := secrets.NewLocalStore(c.Secrets.LocalStore)
+		if err != nil {
+			v.add("secrets.local_store", "%v", err)
+			return v.errs
+		}
+		resolvers["vault"] = store
+	}
+
+	for _, f := range c.secretFields() {
+		if f.file != "" {
+			if *f.value != "" {
+				v.add(f.key+"_file", "can't be set along with %s", f.key)
+				continue
+			}
+			secret, err := secrets.ReadFile(f.file)
+			if err != nil {
+				v.add(f.key+"_file", "%v", err)
+				continue
+			}
+			*f.value = secret
+		}
+		secret, err := resolvers.Resolve(ctx, *f.value)
+		if err != nil {
+			v.add(f.key, "%v", err)
+			continue
+		}
+		*f.value = secret
+	}
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
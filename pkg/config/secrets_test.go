@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSecrets(t *testing.T) {
+	dir := t.TempDir()
+	token := filepath.Join(dir, "bot_token")
+	store := filepath.Join(dir, "store.yaml")
+	if err := os.WriteFile(token, []byte("xoxb-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(store, []byte("secret/data/penny:\n  signing_secret: from-store\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PENNY_TEST_USER_TOKEN", "xoxp-env")
+
+	tests := []struct {
+		name      string
+		overrides map[string]interface{}
+		wantErr   string
+		check     func(t *testing.T, c *Config)
+	}{
+		{
+			name: "file and references",
+			overrides: map[string]interface{}{
+				"slack.bot_oauth_token":      "",
+				"slack.bot_oauth_token_file": token,
+				"slack.user_oauth_token":     "env://PENNY_TEST_USER_TOKEN",
+				"slack.signing_secret":       "vault://secret/data/penny#signing_secret",
+				"secrets.local_store":        store,
+			},
+			check: func(t *testing.T, c *Config) {
+				if c.Slack.BotOAuthToken != "xoxb-file" {
+					t.Errorf("BotOAuthToken = %q, want xoxb-file", c.Slack.BotOAuthToken)
+				}
+				if c.Slack.UserOAuthToken != "xoxp-env" {
+					t.Errorf("UserOAuthToken = %q, want xoxp-env", c.Slack.UserOAuthToken)
+				}
+				if c.Slack.SigningSecret != "from-store" {
+					t.Errorf("SigningSecret = %q, want from-store", c.Slack.SigningSecret)
+				}
+			},
+		},
+		{
+			name:      "value and file",
+			overrides: map[string]interface{}{"slack.bot_oauth_token_file": token},
+			wantErr:   "slack.bot_oauth_token_file",
+		},
+		{
+			name:      "missing file",
+			overrides: map[string]interface{}{"db.password_file": filepath.Join(dir, "missing")},
+			wantErr:   "db.password_file",
+		},
+		{
+			name:      "unresolvable reference",
+			overrides: map[string]interface{}{"slack.signing_secret": "env://PENNY_TEST_UNSET"},
+			wantErr:   "slack.signing_secret",
+		},
+		{
+			name:      "missing local store",
+			overrides: map[string]interface{}{"secrets.local_store": filepath.Join(dir, "missing.yaml")},
+			wantErr:   "secrets.local_store",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Load(newViper(t, tt.overrides))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() error: %v", err)
+				}
+				tt.check(t, c)
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Path != tt.wantErr {
+				t.Errorf("Load() error = %v, want one error for %s", err, tt.wantErr)
+			}
+		})
+	}
+}
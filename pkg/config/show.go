@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Where a setting's value came from, in viper's order of precedence.
+const (
+	SOURCE_FLAG    = "flag"
+	SOURCE_ENV     = "env"
+	SOURCE_FILE    = "file"
+	SOURCE_DEFAULT = "default"
+)
+
+// Setting is one effective value and where it came from.
+type Setting struct {
+	Key    string `json:"-"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// Settings returns every setting in c in key order, secrets redacted, with the
+// source reported by sourceOf.
+func Settings(c *Config, sourceOf func(key string) string) []Setting {
+	var settings []Setting
+	walk(c, func(key string, v reflect.Value) {
+		var value any
+		switch {
+		case IsSecret(key) && !v.IsZero():
+			value = REDACTED
+		case v.Type() == reflect.TypeOf(time.Duration(0)):
+			value = v.Interface().(time.Duration).String()
+		default:
+			value = v.Interface()
+		}
+		settings = append(settings, Setting{Key: key, Value: value, Source: sourceOf(key)})
+	})
+	return settings
+}
+
+// WriteYAML writes settings as a config file would hold them, with each
+// source as a comment.
+func WriteYAML(w io.Writer, settings []Setting) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range settings {
+		parent := root
+		path := strings.Split(s.Key, ".")
+		for _, name := range path[:len(path)-1] {
+			parent = yamlChild(parent, name)
+		}
+		var value yaml.Node
+		if err := value.Encode(s.Value); err != nil {
+			return fmt.Errorf("encoding %s: %w", s.Key, err)
+		}
+		value.LineComment = s.Source
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: path[len(path)-1]}, &value)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// yamlChild returns the mapping under name in parent, adding it if needed.
+func yamlChild(parent *yaml.Node, name string) *yaml.Node {
+	for i := 0; i < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == name {
+			return parent.Content[i+1]
+		}
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, child)
+	return child
+}
+
+// WriteJSON writes settings as nested objects, with each setting as its value
+// and source.
+func WriteJSON(w io.Writer, settings []Setting) error {
+	root := map[string]any{}
+	for _, s := range settings {
+		parent := root
+		path := strings.Split(s.Key, ".")
+		for _, name := range path[:len(path)-1] {
+			child, ok := parent[name].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				parent[name] = child
+			}
+			parent = child
+		}
+		parent[path[len(path)-1]] = s
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(root)
+}
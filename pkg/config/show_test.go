@@ -0,0 +1,80 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSettings(t *testing.T) {
+	c, _ := Load(newViper(t, map[string]interface{}{"spam_feed.signal_deadline": "5s"}))
+	sourceOf := func(key string) string {
+		if key == "spam_feed.max_anomaly_score" {
+			return SOURCE_FLAG + " --max_anomaly_score"
+		}
+		return SOURCE_DEFAULT
+	}
+
+	byKey := map[string]Setting{}
+	for _, s := range Settings(c, sourceOf) {
+		byKey[s.Key] = s
+	}
+	tests := []struct {
+		key        string
+		wantValue  any
+		wantSource string
+	}{
+		{"slack.bot_oauth_token", REDACTED, SOURCE_DEFAULT},
+		{"db.password", "", SOURCE_DEFAULT},
+		{"spam_feed.signal_deadline", "5s", SOURCE_DEFAULT},
+		{"spam_feed.max_anomaly_score", 5, "flag --max_anomaly_score"},
+	}
+	for _, tt := range tests {
+		s, ok := byKey[tt.key]
+		if !ok {
+			t.Errorf("Settings() is missing %s", tt.key)
+			continue
+		}
+		if s.Value != tt.wantValue || s.Source != tt.wantSource {
+			t.Errorf("%s = %v (%s), want %v (%s)", tt.key, s.Value, s.Source, tt.wantValue, tt.wantSource)
+		}
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	settings := []Setting{
+		{Key: "slack.signing_secret", Value: REDACTED, Source: "env SLACK_SIGNING_SECRET"},
+		{Key: "spam_feed.queue.workers", Value: 4, Source: SOURCE_DEFAULT},
+		{Key: "spam_feed.emoji", Value: "spam", Source: "file penny.yaml"},
+	}
+	var buf bytes.Buffer
+	if err := WriteYAML(&buf, settings); err != nil {
+		t.Fatalf("WriteYAML() error: %v", err)
+	}
+	want := `slack:
+  signing_secret: '[redacted]' # env SLACK_SIGNING_SECRET
+spam_feed:
+  queue:
+    workers: 4 # default
+  emoji: spam # file penny.yaml
+`
+	if buf.String() != want {
+		t.Errorf("WriteYAML() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteJSON(&buf, []Setting{{Key: "spam_feed.queue.workers", Value: 4, Source: SOURCE_DEFAULT}})
+	if err != nil {
+		t.Fatalf("WriteJSON() error: %v", err)
+	}
+	var got map[string]map[string]map[string]Setting
+	if err := json.NewDecoder(strings.NewReader(buf.String())).Decode(&got); err != nil {
+		t.Fatalf("decoding %s: %v", buf.String(), err)
+	}
+	if s := got["spam_feed"]["queue"]["workers"]; s.Value != float64(4) || s.Source != SOURCE_DEFAULT {
+		t.Errorf("spam_feed.queue.workers = %+v, want 4 from default", s)
+	}
+}
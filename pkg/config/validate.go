@@ -0,0 +1,381 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/xortim/penny/pkg/blocklist"
+	"github.com/xortim/penny/pkg/messages"
+	"github.com/xortim/penny/pkg/tracing"
+	"github.com/xortim/penny/pkg/webhooks"
+)
+
+var (
+	// emojiName is a reaction name as the API takes it: no colons, with an
+	// optional skin tone.
+	emojiName = regexp.MustCompile(`^[a-z0-9_+'-]+(::skin-tone-[2-6])?$`)
+	channelID = regexp.MustCompile(`^[CG][A-Z0-9]{6,}$`)
+	userID    = regexp.MustCompile(`^[UW][A-Z0-9]{6,}$`)
+	groupID   = regexp.MustCompile(`^S[A-Z0-9]{6,}$`)
+)
+
+// FieldError is a problem with the value at Path, a viper key.
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Errors is every FieldError Validate found.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, fe := range e {
+		lines[i] = fe.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validator collects FieldErrors.
+type validator struct {
+	errs Errors
+}
+
+func (v *validator) add(path, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(path, value string) bool {
+	if value == "" {
+		v.add(path, "is required")
+		return false
+	}
+	return true
+}
+
+func (v *validator) prefix(path, value, prefix string) {
+	if value != "" && !strings.HasPrefix(value, prefix) {
+		v.add(path, "should start with %q", prefix)
+	}
+}
+
+func (v *validator) atLeast(path string, value, min int) {
+	if value < min {
+		v.add(path, "must be at least %d (got %d)", min, value)
+	}
+}
+
+func (v *validator) nonNegative(path string, d time.Duration) {
+	if d < 0 {
+		v.add(path, "must not be negative (got %s)", d)
+	}
+}
+
+func (v *validator) oneOf(path, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(path, "must be one of %s (got %q)", strings.Join(allowed, ", "), value)
+}
+
+func (v *validator) match(path, value string, re *regexp.Regexp, what string) {
+	if value != "" && !re.MatchString(value) {
+		v.add(path, "%q is not %s", value, what)
+	}
+}
+
+func (v *validator) emoji(path, value string) {
+	v.match(path, value, emojiName, "an emoji name (no colons, e.g. no_entry_sign)")
+}
+
+// Validate reports every problem with c at once. It returns nil or Errors.
+func (c *Config) Validate() error {
+	var v validator
+
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil {
+		v.add("log.level", "must be one of trace, debug, info, warn, error, fatal (got %q)", c.Log.Level)
+	}
+
+	v.required("db.hostname", c.DB.Hostname)
+	v.required("db.name", c.DB.Name)
+	v.required("db.username", c.DB.Username)
+
+	if v.required("slack.bot_oauth_token", c.Slack.BotOAuthToken) {
+		v.prefix("slack.bot_oauth_token", c.Slack.BotOAuthToken, "xoxb-")
+	}
+	// The user token backs the activity signal, which only the spam feed uses.
+	if c.SpamFeed.Channel == "" || v.required("slack.user_oauth_token", c.Slack.UserOAuthToken) {
+		v.prefix("slack.user_oauth_token", c.Slack.UserOAuthToken, "xoxp-")
+	}
+	v.required("slack.signing_secret", c.Slack.SigningSecret)
+	v.match("slack.admin_channel_id", c.Slack.AdminChannelID, channelID, "a Slack channel ID (e.g. C0123ABCD)")
+	for i, id := range c.Slack.GlobalAdmins {
+		v.match(fmt.Sprintf("slack.global_admins[%d]", i), id, userID, "a Slack user ID (e.g. U0123ABCD)")
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		v.add("server.port", "must be between 1 and 65535 (got %d)", c.Server.Port)
+	}
+	v.nonNegative("server.shutdown_timeout", c.Server.ShutdownTimeout)
+
+	if c.Tracing.Exporter != "" {
+		v.oneOf("tracing.exporter", c.Tracing.Exporter, tracing.EXPORTER_NONE, tracing.EXPORTER_STDOUT, tracing.EXPORTER_OTLP)
+	}
+	if r := c.Tracing.SampleRatio; r < 0 || r > 1 {
+		v.add("tracing.sample_ratio", "must be between 0 and 1 (got %g)", r)
+	}
+
+	c.Messages.validate(&v)
+	c.SpamFeed.validate(&v)
+	c.Webhooks.validate(&v)
+	c.Blocklist.validate(&v)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (s *SpamFeed) validate(v *validator) {
+	if s.Channel == "" {
+		return
+	}
+	if strings.HasPrefix(s.Channel, "#") {
+		v.add("spam_feed.channel", "should be the channel name without the # (got %q)", s.Channel)
+	}
+	v.match("spam_feed.assistance_channel_id", s.AssistanceChannelID, channelID, "a Slack channel ID (e.g. C0123ABCD)")
+	if v.required("spam_feed.emoji", s.Emoji) {
+		v.emoji("spam_feed.emoji", s.Emoji)
+	}
+	v.emoji("spam_feed.reaction_emoji_hit", s.ReactionEmojiHit)
+	v.emoji("spam_feed.reaction_emoji_miss", s.ReactionEmojiMiss)
+	if s.OpNoticeDelivery != "" {
+		// hallmonitor.DELIVERY_*, which can't be imported from here.
+		v.oneOf("spam_feed.op_notice_delivery", s.OpNoticeDelivery, "thread", "ephemeral", "dm")
+	}
+	if s.LocalTimezone != "" {
+		if _, err := time.LoadLocation(s.LocalTimezone); err != nil {
+			v.add("spam_feed.local_timezone", "%q is not a TZ database name (e.g. America/New_York)", s.LocalTimezone)
+		}
+	}
+
+	v.atLeast("spam_feed.activity_low_watermark", s.ActivityLowWatermark, 0)
+	v.atLeast("spam_feed.max_anomaly_score", s.MaxAnomalyScore, 1)
+	v.atLeast("spam_feed.anomaly_scores.reported", s.AnomalyScores.Reported, 0)
+	v.atLeast("spam_feed.anomaly_scores.low_activity", s.AnomalyScores.LowActivity, 0)
+	v.atLeast("spam_feed.anomaly_scores.outside_tz", s.AnomalyScores.OutsideTZ, 0)
+	v.atLeast("spam_feed.anomaly_scores.blocklisted", s.AnomalyScores.Blocklisted, 0)
+	v.atLeast("spam_feed.anomaly_scores.blocklisted_name", s.AnomalyScores.BlocklistedName, 0)
+	v.atLeast("spam_feed.anomaly_scores.blocklisted_domain", s.AnomalyScores.BlocklistedDomain, 0)
+	v.atLeast("spam_feed.anomaly_scores.email_domain", s.AnomalyScores.EmailDomain, 0)
+	v.atLeast("spam_feed.anomaly_scores.impersonation", s.AnomalyScores.Impersonation, 0)
+	v.atLeast("spam_feed.anomaly_scores.duplicates", s.AnomalyScores.Duplicates, 0)
+
+	v.nonNegative("spam_feed.signal_deadline", s.SignalDeadline)
+	// hallmonitor.TIMEOUT_POLICY_*, which can't be imported from here.
+	v.oneOf("spam_feed.signal_timeout_policy", s.SignalTimeoutPolicy, "zero", "max", "defer")
+
+	v.atLeast("spam_feed.queue.workers", s.Queue.Workers, 1)
+	v.atLeast("spam_feed.queue.capacity", s.Queue.Capacity, 1)
+	v.nonNegative("spam_feed.queue.enqueue_timeout", s.Queue.EnqueueTimeout)
+	v.nonNegative("spam_feed.queue.coalesce_window", s.Queue.CoalesceWindow)
+
+	v.nonNegative("spam_feed.breaker.window", s.Breaker.Window)
+	v.atLeast("spam_feed.breaker.max_removals", s.Breaker.MaxRemovals, 0)
+	v.atLeast("spam_feed.breaker.max_channel_removals", s.Breaker.MaxChannelRemovals, 0)
+	v.match("spam_feed.breaker.alert_channel_id", s.Breaker.AlertChannelID, channelID, "a Slack channel ID (e.g. C0123ABCD)")
+
+	v.match("spam_feed.appeals.channel_id", s.Appeals.ChannelID, channelID, "a Slack channel ID (e.g. C0123ABCD)")
+
+	e := s.Escalation
+	v.atLeast("spam_feed.escalation.band", e.Band, 0)
+	v.match("spam_feed.escalation.user_group_id", e.UserGroupID, groupID, "a Slack user group ID (e.g. S0123ABCD)")
+	for i, id := range e.Moderators {
+		v.match(fmt.Sprintf("spam_feed.escalation.moderators[%d]", i), id, userID, "a Slack user ID (e.g. U0123ABCD)")
+	}
+	if e.Notify != "" {
+		// hallmonitor.ESCALATE_*, which can't be imported from here.
+		v.oneOf("spam_feed.escalation.notify", e.Notify, "thread", "dm", "both")
+	}
+	v.atLeast("spam_feed.escalation.max_pings", e.MaxPings, 0)
+	v.nonNegative("spam_feed.escalation.window", e.Window)
+
+	d := s.EmailDomain
+	if d.DisposableList != "" {
+		if _, err := os.Stat(d.DisposableList); err != nil {
+			v.add("spam_feed.email_domain.disposable_list", "can't be read: %v", err)
+		}
+	}
+	v.atLeast("spam_feed.email_domain.recent_removals", d.RecentRemovals, 0)
+	v.nonNegative("spam_feed.email_domain.recent_window", d.RecentWindow)
+	for i, domain := range d.Expected {
+		if domain == "" || strings.ContainsAny(domain, "@ ") || !strings.Contains(domain, ".") {
+			v.add(fmt.Sprintf("spam_feed.email_domain.expected[%d]", i), "should be a domain like example.com (got %q)", domain)
+		}
+	}
+
+	for i, id := range s.Impersonation.ProtectedUsers {
+		v.match(fmt.Sprintf("spam_feed.impersonation.protected_users[%d]", i), id, userID, "a Slack user ID (e.g. U0123ABCD)")
+	}
+	v.atLeast("spam_feed.impersonation.max_distance", s.Impersonation.MaxDistance, 0)
+
+	dup := s.Duplicates
+	v.nonNegative("spam_feed.duplicates.window", dup.Window)
+	if s.AnomalyScores.Duplicates > 0 {
+		// One channel would score every message.
+		v.atLeast("spam_feed.duplicates.min_channels", dup.MinChannels, 2)
+	}
+	v.atLeast("spam_feed.duplicates.max_distance", dup.MaxDistance, 0)
+	if dup.MaxDistance > 64 {
+		v.add("spam_feed.duplicates.max_distance", "must be at most 64 (got %d)", dup.MaxDistance)
+	}
+	v.atLeast("spam_feed.duplicates.min_length", dup.MinLength, 0)
+
+	p := s.Proactive
+	for i, id := range p.ChannelIDs {
+		v.match(fmt.Sprintf("spam_feed.proactive.channel_ids[%d]", i), id, channelID, "a Slack channel ID (e.g. C0123ABCD)")
+	}
+	for i, name := range p.Signals {
+		// The names of hallmonitor's signals, which can't be imported from
+		// here. A screened message wasn't reported, so "reported" isn't one.
+		v.oneOf(fmt.Sprintf("spam_feed.proactive.signals[%d]", i), name, "low_activity", "outside_tz", "email_domain", "impersonation", "duplicates", "blocklisted")
+	}
+	v.atLeast("spam_feed.proactive.remove_score", p.RemoveScore, 0)
+	v.atLeast("spam_feed.proactive.max_messages", p.MaxMessages, 0)
+	v.nonNegative("spam_feed.proactive.max_member_age", p.MaxMemberAge)
+	if p.Enabled {
+		v.atLeast("spam_feed.proactive.review_score", p.ReviewScore, 1)
+		if p.RemoveScore > 0 && p.RemoveScore < p.ReviewScore {
+			v.add("spam_feed.proactive.remove_score", "must be at least review_score (%d) or 0 (got %d)", p.ReviewScore, p.RemoveScore)
+		}
+		if len(p.Signals) == 0 {
+			v.add("spam_feed.proactive.signals", "needs at least one signal")
+		}
+		if p.MaxMessages == 0 && p.MaxMemberAge == 0 {
+			v.add("spam_feed.proactive", "needs max_messages or max_member_age; without either no author is low-trust")
+		}
+	}
+
+	pr := s.Probation
+	v.atLeast("spam_feed.probation.messages", pr.Messages, 0)
+	v.nonNegative("spam_feed.probation.duration", pr.Duration)
+	v.nonNegative("spam_feed.probation.grace", pr.Grace)
+	v.match("spam_feed.probation.approval_channel_id", pr.ApprovalChannelID, channelID, "a Slack channel ID (e.g. C0123ABCD)")
+	if pr.Enabled {
+		v.atLeast("spam_feed.probation.max_anomaly_score", pr.MaxAnomalyScore, 1)
+		if pr.Messages == 0 && pr.Duration == 0 {
+			v.add("spam_feed.probation", "needs messages or duration; without either probation is never lifted")
+		}
+	}
+}
+
+// Warnings reports settings that are valid but probably not what was meant.
+func (c *Config) Warnings() []FieldError {
+	var warnings []FieldError
+	s := c.SpamFeed
+	if s.Channel != "" && s.MaxAnomalyScore > 0 {
+		total := s.AnomalyScores.Reported + s.AnomalyScores.LowActivity + s.AnomalyScores.OutsideTZ
+		if len(c.Blocklist.Feeds) != 0 {
+			total += max(s.AnomalyScores.Blocklisted, s.AnomalyScores.BlocklistedName, s.AnomalyScores.BlocklistedDomain)
+		}
+		total += s.AnomalyScores.EmailDomain + s.AnomalyScores.Impersonation + s.AnomalyScores.Duplicates
+		if total < s.MaxAnomalyScore {
+			warnings = append(warnings, FieldError{
+				Path:    "spam_feed.max_anomaly_score",
+				Message: fmt.Sprintf("%d can never be reached; the anomaly scores add up to %d, so nothing will be removed", s.MaxAnomalyScore, total),
+			})
+		}
+	}
+	if s.Channel != "" && s.MaxAnomalyScore > 0 && s.AnomalyScores.Reported >= s.MaxAnomalyScore {
+		warnings = append(warnings, FieldError{
+			Path:    "spam_feed.anomaly_scores.reported",
+			Message: "reaches max_anomaly_score on its own, so every report is removed",
+		})
+	}
+	if p := s.Probation; p.Enabled && p.MaxAnomalyScore >= s.MaxAnomalyScore {
+		warnings = append(warnings, FieldError{
+			Path:    "spam_feed.probation.max_anomaly_score",
+			Message: fmt.Sprintf("isn't stricter than max_anomaly_score (%d), so probation only changes which messages are approved", s.MaxAnomalyScore),
+		})
+	}
+	if e := s.Escalation; s.Channel != "" && e.Band > 0 {
+		if e.UserGroupID == "" && len(e.Moderators) == 0 {
+			warnings = append(warnings, FieldError{
+				Path:    "spam_feed.escalation.band",
+				Message: "is set but there's no user_group_id or moderators to ping",
+			})
+		} else if e.Notify == "dm" && len(e.Moderators) == 0 {
+			warnings = append(warnings, FieldError{
+				Path:    "spam_feed.escalation.notify",
+				Message: "is dm but there are no moderators to message; the user group is only mentioned in the thread",
+			})
+		}
+	}
+	return warnings
+}
+
+func (w Webhooks) validate(v *validator) {
+	if len(w.URLs) == 0 {
+		return
+	}
+	for i, raw := range w.URLs {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.add(fmt.Sprintf("webhooks.urls[%d]", i), "%q is not an http(s) URL", raw)
+		}
+	}
+	v.required("webhooks.secret", w.Secret)
+	for i, e := range w.Events {
+		v.oneOf(fmt.Sprintf("webhooks.events[%d]", i), e, webhooks.Events...)
+	}
+	v.atLeast("webhooks.max_attempts", w.MaxAttempts, 1)
+	v.nonNegative("webhooks.backoff", w.Backoff)
+	v.nonNegative("webhooks.timeout", w.Timeout)
+}
+
+func (b Blocklist) validate(v *validator) {
+	if b.PrivateKey != "" {
+		if _, err := blocklist.ParsePrivateKey(b.PrivateKey); err != nil {
+			v.add("blocklist.private_key", "%v", err)
+		}
+	}
+	if len(b.Feeds) == 0 {
+		return
+	}
+	for i, feed := range b.Feeds {
+		if u, err := url.Parse(feed); err == nil && u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			v.add(fmt.Sprintf("blocklist.feeds[%d]", i), "%q is not a file path or an http(s) URL", feed)
+		}
+	}
+	if len(b.PublicKeys) == 0 {
+		v.add("blocklist.public_keys", "is required to trust the feeds")
+	}
+	for i, key := range b.PublicKeys {
+		if _, err := blocklist.ParsePublicKey(key); err != nil {
+			v.add(fmt.Sprintf("blocklist.public_keys[%d]", i), "%v", err)
+		}
+	}
+	v.required("blocklist.salt", b.Salt)
+	v.nonNegative("blocklist.refresh", b.Refresh)
+}
+
+func (m Messages) validate(v *validator) {
+	catalog, err := messages.Load(m.Dir)
+	if err != nil {
+		v.add("messages.dir", "%v", err)
+		return
+	}
+	if m.Locale != "" && !catalog.Has(m.Locale) {
+		v.add("messages.locale", "no messages for %q; have %s", m.Locale, strings.Join(catalog.Locales(), ", "))
+	}
+}
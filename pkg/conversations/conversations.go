@@ -85,22 +85,36 @@ func MsgRefToMessage(ref slack.ItemRef, api slackclient.Client) (slack.Message,
 }
 
 func ThreadedReplyToMsg(msg slack.Message, reply string, api slackclient.Client) (string, string, error) {
+	// https://api.slack.com/methods/chat.postMessage#args
+	return api.PostMessage(
+		msg.Channel,
+		slack.MsgOptionTS(threadTS(msg)),
+		slack.MsgOptionText(reply, false),
+	)
+}
+
+// ThreadedBlocksReplyToMsg replies to msg's thread with Block Kit blocks. fallback
+// is shown in notifications and by clients that can't render the blocks.
+func ThreadedBlocksReplyToMsg(msg slack.Message, fallback string, blocks []slack.Block, api slackclient.Client) (string, string, error) {
+	return api.PostMessage(
+		msg.Channel,
+		slack.MsgOptionTS(threadTS(msg)),
+		slack.MsgOptionText(fallback, false),
+		slack.MsgOptionBlocks(blocks...),
+	)
+}
+
+// threadTS returns the timestamp to reply to msg's thread with.
+func threadTS(msg slack.Message) string {
 	// Use the correct timestamp for starting or posting to a
 	// thread. Otherwise the bot _could_ modify the message
 	// which causes it to show up in the top-level conversation.
 	// This happens if you try to reply to a message already in
 	// a thread.
-	ts := msg.Timestamp
 	if len(msg.ThreadTimestamp) != 0 {
-		ts = msg.ThreadTimestamp
+		return msg.ThreadTimestamp
 	}
-
-	// https://api.slack.com/methods/chat.postMessage#args
-	return api.PostMessage(
-		msg.Channel,
-		slack.MsgOptionTS(ts),
-		slack.MsgOptionText(reply, false),
-	)
+	return msg.Timestamp
 }
 
 // WhoReactedWith returns the list of users which have applied the specified reaction to the provided message
@@ -2,7 +2,9 @@ package conversations
 
 import (
 	"errors"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/slack-go/slack"
@@ -383,6 +385,34 @@ func TestThreadedReplyToMsg(t *testing.T) {
 	}
 }
 
+func TestThreadedBlocksReplyToMsg(t *testing.T) {
+	msg := slack.Message{
+		Msg: slack.Msg{Timestamp: "1111111111.000100", ThreadTimestamp: "1111111110.000100", Channel: "C_TEST"},
+	}
+	blocks := []slack.Block{slack.NewDividerBlock()}
+
+	var values url.Values
+	mock := &slackclient.MockClient{
+		PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+			_, values, _ = slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+			return channelID, "1111111111.000200", nil
+		},
+	}
+
+	if _, _, err := ThreadedBlocksReplyToMsg(msg, "fallback", blocks, mock); err != nil {
+		t.Fatalf("ThreadedBlocksReplyToMsg() unexpected error: %v", err)
+	}
+	if got := values.Get("thread_ts"); got != "1111111110.000100" {
+		t.Errorf("thread_ts = %q, want the thread's timestamp", got)
+	}
+	if got := values.Get("text"); got != "fallback" {
+		t.Errorf("text = %q, want the fallback", got)
+	}
+	if got := values.Get("blocks"); !strings.Contains(got, `"type":"divider"`) {
+		t.Errorf("blocks = %s, want the divider", got)
+	}
+}
+
 func TestThreadReplyToMessage(t *testing.T) {
 	const (
 		channelID = "C123"
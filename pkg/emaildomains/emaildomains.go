@@ -0,0 +1,83 @@
+// Package emaildomains classifies email domains: the big providers everyone
+// uses, and disposable ones spammers sign up with.
+package emaildomains
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"strings"
+)
+
+//go:embed disposable.txt
+var bundled string
+
+var disposable = Parse(strings.NewReader(bundled))
+
+// free are domains too widely used to hold against anyone.
+var free = Set{
+	"aol.com": true, "gmail.com": true, "googlemail.com": true, "hotmail.com": true,
+	"icloud.com": true, "live.com": true, "mail.com": true, "me.com": true,
+	"msn.com": true, "outlook.com": true, "proton.me": true, "protonmail.com": true,
+	"yahoo.com": true, "yandex.com": true, "zoho.com": true,
+}
+
+// Set is a set of lower-cased domains.
+type Set map[string]bool
+
+// Parse reads a Set from r, one domain per line. Blank lines and lines
+// starting with # are skipped.
+func Parse(r io.Reader) Set {
+	s := Set{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line != "" && !strings.HasPrefix(line, "#") {
+			s[line] = true
+		}
+	}
+	return s
+}
+
+// ReadFile reads a Set from the file at path; see Parse.
+func ReadFile(path string) (Set, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f), nil
+}
+
+// Contains reports whether domain, or a domain it's a subdomain of, is in s.
+func (s Set) Contains(domain string) bool {
+	domain = strings.ToLower(domain)
+	for domain != "" {
+		if s[domain] {
+			return true
+		}
+		_, domain, _ = strings.Cut(domain, ".")
+	}
+	return false
+}
+
+// IsFree reports whether domain belongs to one of the big email providers.
+func IsFree(domain string) bool {
+	return free.Contains(domain)
+}
+
+// IsDisposable reports whether domain is on the bundled list of disposable
+// email domains.
+func IsDisposable(domain string) bool {
+	return disposable.Contains(domain)
+}
+
+// Of is the lower-cased domain of email, or "" if it has none.
+func Of(email string) string {
+	_, domain, ok := strings.Cut(strings.TrimSpace(email), "@")
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(domain)
+}
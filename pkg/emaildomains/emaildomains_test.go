@@ -0,0 +1,51 @@
+package emaildomains
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		domain         string
+		wantFree       bool
+		wantDisposable bool
+	}{
+		{domain: "gmail.com", wantFree: true},
+		{domain: "Mailinator.com", wantDisposable: true},
+		{domain: "eu.mailinator.com", wantDisposable: true},
+		{domain: "notmailinator.com"},
+		{domain: "example.com"},
+		{domain: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := IsFree(tt.domain); got != tt.wantFree {
+				t.Errorf("IsFree(%q) = %v, want %v", tt.domain, got, tt.wantFree)
+			}
+			if got := IsDisposable(tt.domain); got != tt.wantDisposable {
+				t.Errorf("IsDisposable(%q) = %v, want %v", tt.domain, got, tt.wantDisposable)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	s := Parse(strings.NewReader("# extra throwaway domains\n\n  Throwaway.Example \nspam.test\n"))
+	if len(s) != 2 || !s.Contains("throwaway.example") || !s.Contains("mx.spam.test") {
+		t.Errorf("Parse() = %v, want throwaway.example and spam.test", s)
+	}
+}
+
+func TestOf(t *testing.T) {
+	for email, want := range map[string]string{
+		"Someone@Example.COM": "example.com",
+		" a@b.test ":          "b.test",
+		"no-at-sign":          "",
+		"":                    "",
+	} {
+		if got := Of(email); got != want {
+			t.Errorf("Of(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// Listener receives a parsed inner event. Listeners run on the HTTP request
+// goroutine before Slack has been acknowledged, so they must not block.
+type Listener func(ev slackevents.EventsAPIInnerEvent)
+
+// Dispatcher fans Events API callbacks out to listeners by inner event type.
+// Gadget only routes mentions and channel messages; the Dispatcher lets penny
+// react to everything else Slack sends to the same request URL.
+type Dispatcher struct {
+	mu        sync.RWMutex
+	listeners map[string][]Listener
+}
+
+// NewDispatcher returns an empty Dispatcher.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{listeners: make(map[string][]Listener)}
+}
+
+// On registers l for inner events of the given type, e.g. "user_change".
+func (d *Dispatcher) On(eventType string, l Listener) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listeners[eventType] = append(d.listeners[eventType], l)
+}
+
+// Dispatch calls every listener registered for ev.Type.
+func (d *Dispatcher) Dispatch(ev slackevents.EventsAPIInnerEvent) {
+	d.mu.RLock()
+	listeners := d.listeners[ev.Type]
+	d.mu.RUnlock()
+
+	for _, l := range listeners {
+		l(ev)
+	}
+}
+
+// Middleware returns a handler that verifies the request signature, dispatches
+// callback events to listeners, and then passes the untouched request to next.
+// Requests that fail verification are not dispatched; next is left to reject them.
+func (d *Dispatcher) Middleware(signingSecret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if verify(r.Header, body, signingSecret) {
+			d.dispatchBody(body)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d *Dispatcher) dispatchBody(body []byte) {
+	eventsAPIEvent, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to parse event for dispatch")
+		return
+	}
+	if eventsAPIEvent.Type != slackevents.CallbackEvent {
+		return
+	}
+	d.Dispatch(eventsAPIEvent.InnerEvent)
+}
+
+func verify(header http.Header, body []byte, signingSecret string) bool {
+	sv, err := slack.NewSecretsVerifier(header, signingSecret)
+	if err != nil {
+		return false
+	}
+	if _, err := sv.Write(body); err != nil {
+		return false
+	}
+	return sv.Ensure() == nil
+}
@@ -0,0 +1,114 @@
+package events
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack/slackevents"
+)
+
+const testSigningSecret = "test-signing-secret"
+
+func signedRequest(t *testing.T, body []byte, secret string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/gadget", bytes.NewReader(body))
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("v0:%s:%s", ts, body)))
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", fmt.Sprintf("v0=%x", mac.Sum(nil)))
+	return req
+}
+
+const userChangeBody = `{
+	"type": "event_callback",
+	"team_id": "T1",
+	"event": {"type": "user_change", "user": {"id": "U123", "name": "spammer"}}
+}`
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		secret       string
+		body         string
+		wantDispatch bool
+	}{
+		{
+			name:         "signed callback is dispatched",
+			secret:       testSigningSecret,
+			body:         userChangeBody,
+			wantDispatch: true,
+		},
+		{
+			name:   "bad signature is not dispatched",
+			secret: "wrong-secret",
+			body:   userChangeBody,
+		},
+		{
+			name:   "url verification is not dispatched",
+			secret: testSigningSecret,
+			body:   `{"type": "url_verification", "challenge": "abc"}`,
+		},
+		{
+			name:   "unparseable body is not dispatched",
+			secret: testSigningSecret,
+			body:   `not json`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDispatcher()
+			var got []string
+			d.On("user_change", func(ev slackevents.EventsAPIInnerEvent) {
+				if uc, ok := ev.Data.(*slackevents.UserChangeEvent); ok {
+					got = append(got, uc.User.ID)
+				}
+			})
+
+			var nextBody []byte
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nextBody, _ = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusTeapot)
+			})
+
+			rec := httptest.NewRecorder()
+			d.Middleware(testSigningSecret, next).ServeHTTP(rec, signedRequest(t, []byte(tt.body), tt.secret))
+
+			if rec.Code != http.StatusTeapot {
+				t.Errorf("next handler not called, status = %d", rec.Code)
+			}
+			if string(nextBody) != tt.body {
+				t.Errorf("next handler body = %q, want the original body", nextBody)
+			}
+			if tt.wantDispatch && (len(got) != 1 || got[0] != "U123") {
+				t.Errorf("expected user_change for U123 to be dispatched, got %v", got)
+			}
+			if !tt.wantDispatch && len(got) != 0 {
+				t.Errorf("expected no dispatch, got %v", got)
+			}
+		})
+	}
+}
+
+func TestDispatchByType(t *testing.T) {
+	d := NewDispatcher()
+	calls := map[string]int{}
+	d.On("team_join", func(ev slackevents.EventsAPIInnerEvent) { calls["a"]++ })
+	d.On("team_join", func(ev slackevents.EventsAPIInnerEvent) { calls["b"]++ })
+	d.On("channel_rename", func(ev slackevents.EventsAPIInnerEvent) { calls["c"]++ })
+
+	d.Dispatch(slackevents.EventsAPIInnerEvent{Type: "team_join"})
+
+	if calls["a"] != 1 || calls["b"] != 1 || calls["c"] != 0 {
+		t.Errorf("unexpected listener calls: %v", calls)
+	}
+}
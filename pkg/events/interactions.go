@@ -0,0 +1,111 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+)
+
+// ActionHandler handles a click on a Block Kit element. It runs after Slack
+// has been acknowledged, on its own goroutine.
+type ActionHandler func(cb slack.InteractionCallback, action *slack.BlockAction)
+
+// ViewHandler handles a modal submission before Slack is acknowledged, so it
+// must be quick. A non-nil response is sent back to Slack, e.g. to show errors
+// in the modal; nil closes it.
+type ViewHandler func(cb slack.InteractionCallback) *slack.ViewSubmissionResponse
+
+// Interactions routes Slack's interactivity payloads, which Gadget doesn't
+// handle, by action ID and view callback ID.
+type Interactions struct {
+	mu      sync.RWMutex
+	actions map[string]ActionHandler
+	views   map[string]ViewHandler
+}
+
+// NewInteractions returns an Interactions with no handlers.
+func NewInteractions() *Interactions {
+	return &Interactions{actions: make(map[string]ActionHandler), views: make(map[string]ViewHandler)}
+}
+
+// OnAction registers h for clicks on elements with actionID.
+func (i *Interactions) OnAction(actionID string, h ActionHandler) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.actions[actionID] = h
+}
+
+// OnViewSubmission registers h for submissions of modals with callbackID.
+func (i *Interactions) OnViewSubmission(callbackID string, h ViewHandler) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.views[callbackID] = h
+}
+
+// Handler serves Slack's interactivity request URL, rejecting requests that
+// fail signature verification.
+func (i *Interactions) Handler(signingSecret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !verify(r.Header, body, signingSecret) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var cb slack.InteractionCallback
+		if err := json.Unmarshal([]byte(form.Get("payload")), &cb); err != nil {
+			log.Debug().Err(err).Msg("failed to parse interaction payload")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch cb.Type {
+		case slack.InteractionTypeBlockActions:
+			i.mu.RLock()
+			for _, action := range cb.ActionCallback.BlockActions {
+				if h, ok := i.actions[action.ActionID]; ok {
+					go runAction(h, cb, action)
+				}
+			}
+			i.mu.RUnlock()
+		case slack.InteractionTypeViewSubmission:
+			i.mu.RLock()
+			h, ok := i.views[cb.View.CallbackID]
+			i.mu.RUnlock()
+			if !ok {
+				break
+			}
+			if resp := h(cb); resp != nil {
+				w.Header().Set("Content-Type", "application/json")
+				if err := json.NewEncoder(w).Encode(resp); err != nil {
+					log.Error().Err(err).Str("callback_id", cb.View.CallbackID).Msg("failed to write view submission response")
+				}
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// runAction runs h, logging rather than crashing on a panic.
+func runAction(h ActionHandler, cb slack.InteractionCallback, action *slack.BlockAction) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Interface("panic", r).Str("action_id", action.ActionID).Msg("interaction handler panicked")
+		}
+	}()
+	h(cb, action)
+}
@@ -0,0 +1,121 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func interactionBody(t *testing.T, payload string) []byte {
+	t.Helper()
+	return []byte(url.Values{"payload": {payload}}.Encode())
+}
+
+func TestInteractionsAction(t *testing.T) {
+	i := NewInteractions()
+	got := make(chan string, 1)
+	i.OnAction("appeal.open", func(cb slack.InteractionCallback, action *slack.BlockAction) {
+		got <- cb.User.ID + " " + action.Value
+	})
+
+	tests := []struct {
+		name       string
+		secret     string
+		payload    string
+		wantStatus int
+		want       string
+	}{
+		{
+			name:       "registered action",
+			secret:     testSigningSecret,
+			payload:    `{"type":"block_actions","user":{"id":"U1"},"actions":[{"block_id":"b1","action_id":"appeal.open","value":"42"}]}`,
+			wantStatus: http.StatusOK,
+			want:       "U1 42",
+		},
+		{
+			name:       "unregistered action",
+			secret:     testSigningSecret,
+			payload:    `{"type":"block_actions","user":{"id":"U1"},"actions":[{"block_id":"b1","action_id":"other","value":"42"}]}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "bad signature",
+			secret:     "wrong-secret",
+			payload:    `{"type":"block_actions","user":{"id":"U1"},"actions":[{"block_id":"b1","action_id":"appeal.open","value":"42"}]}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "malformed payload",
+			secret:     testSigningSecret,
+			payload:    `{`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			i.Handler(testSigningSecret).ServeHTTP(rec, signedRequest(t, interactionBody(t, tt.payload), tt.secret))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			select {
+			case g := <-got:
+				if g != tt.want {
+					t.Errorf("handler got %q, want %q", g, tt.want)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.want != "" {
+					t.Errorf("handler not called, want %q", tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestInteractionsViewSubmission(t *testing.T) {
+	i := NewInteractions()
+	i.OnViewSubmission("appeal.submit", func(cb slack.InteractionCallback) *slack.ViewSubmissionResponse {
+		if cb.View.PrivateMetadata == "bad" {
+			return slack.NewErrorsViewSubmissionResponse(map[string]string{"justification": "nope"})
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name       string
+		metadata   string
+		wantErrors map[string]string
+	}{
+		{name: "accepted closes the modal"},
+		{name: "rejected shows errors", metadata: "bad", wantErrors: map[string]string{"justification": "nope"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := `{"type":"view_submission","user":{"id":"U1"},"view":{"callback_id":"appeal.submit","private_metadata":"` + tt.metadata + `"}}`
+			rec := httptest.NewRecorder()
+			i.Handler(testSigningSecret).ServeHTTP(rec, signedRequest(t, interactionBody(t, payload), testSigningSecret))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if tt.wantErrors == nil {
+				if rec.Body.Len() != 0 {
+					t.Errorf("body = %s, want none", rec.Body)
+				}
+				return
+			}
+			var resp slack.ViewSubmissionResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding %s: %v", rec.Body, err)
+			}
+			if resp.ResponseAction != slack.RAErrors || resp.Errors["justification"] != "nope" {
+				t.Errorf("response = %+v, want errors %v", resp, tt.wantErrors)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package fingerprint recognizes the same message pasted into many channels,
+// even with its spacing, case, mentions or a few words changed. A message's
+// Fingerprint is a SimHash of its normalized words and the URLs it links to;
+// near copies have fingerprints a few bits apart.
+package fingerprint
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// shingle is how many consecutive words make up one feature of the text.
+const shingle = 2
+
+// urlWeight is how much more a linked URL counts than a run of words, since
+// spam that varies its text still sends everyone to the same place.
+const urlWeight = 4
+
+var (
+	// links are Slack's <url> and <url|label> markup.
+	links = regexp.MustCompile(`(?i)<((?:https?|mailto):[^|>]+)(?:\|[^>]*)?>`)
+	// bareURLs are URLs as typed, in text that wasn't through Slack's markup.
+	bareURLs = regexp.MustCompile(`(?i)https?://[^\s<>]+`)
+	// markup are mentions like <@U0123ABCD> and <#C0123ABCD|general>.
+	markup = regexp.MustCompile(`<[@#!][^>]*>`)
+)
+
+// Fingerprint is a message's SimHash.
+type Fingerprint uint64
+
+// Distance is how many bits f and g differ by; 0 for identical text.
+func (f Fingerprint) Distance(g Fingerprint) int {
+	return bits.OnesCount64(uint64(f ^ g))
+}
+
+// Normalize splits text into its words, lower-cased and stripped of
+// punctuation, emoji, mentions and links, and the URLs it links to, lower-cased
+// and without their query strings and fragments, sorted.
+func Normalize(text string) (words, urls []string) {
+	for _, m := range links.FindAllStringSubmatch(text, -1) {
+		urls = append(urls, canonicalURL(m[1]))
+	}
+	text = links.ReplaceAllString(text, " ")
+	for _, m := range bareURLs.FindAllString(text, -1) {
+		urls = append(urls, canonicalURL(m))
+	}
+	text = bareURLs.ReplaceAllString(text, " ")
+	text = markup.ReplaceAllString(text, " ")
+
+	words = strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	slices.Sort(urls)
+	return words, slices.Compact(urls)
+}
+
+func canonicalURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return strings.ToLower(raw)
+	}
+	u.RawQuery, u.Fragment = "", ""
+	u.Host = strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	return strings.ToLower(strings.TrimSuffix(u.String(), "/"))
+}
+
+// Of fingerprints text. ok is false for text too short to tell a copy from a
+// coincidence: fewer than minChars letters and digits, and no links.
+func Of(text string, minChars int) (f Fingerprint, ok bool) {
+	words, urls := Normalize(text)
+	chars := 0
+	for _, w := range words {
+		chars += len([]rune(w))
+	}
+	if len(urls) == 0 && (chars == 0 || chars < minChars) {
+		return 0, false
+	}
+
+	var v [64]int
+	add := func(feature string, weight int) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := range v {
+			if sum&(1<<i) != 0 {
+				v[i] += weight
+			} else {
+				v[i] -= weight
+			}
+		}
+	}
+	// A message shorter than a shingle is one feature.
+	for i := range max(len(words)-shingle+1, min(len(words), 1)) {
+		add(strings.Join(words[i:min(i+shingle, len(words))], " "), 1)
+	}
+	for _, u := range urls {
+		add(u, urlWeight)
+	}
+
+	for i, n := range v {
+		if n > 0 {
+			f |= 1 << i
+		}
+	}
+	return f, true
+}
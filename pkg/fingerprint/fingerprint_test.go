@@ -0,0 +1,88 @@
+package fingerprint
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+const spam = "Hey everyone!! Check out this amazing crypto giveaway, limited spots: <https://scam.example/claim?ref=123|claim here>"
+
+func TestNormalize(t *testing.T) {
+	words, urls := Normalize("Hi <@U0123ABCD>, see <https://WWW.Scam.example/claim/?ref=1|this> and http://other.example/x#top in <#C0123ABCD|general>!")
+	if want := []string{"hi", "see", "and", "in"}; !slices.Equal(words, want) {
+		t.Errorf("Normalize() words = %q, want %q", words, want)
+	}
+	if want := []string{"http://other.example/x", "https://scam.example/claim"}; !slices.Equal(urls, want) {
+		t.Errorf("Normalize() urls = %q, want %q", urls, want)
+	}
+}
+
+func TestOf(t *testing.T) {
+	f, ok := Of(spam, 20)
+	if !ok {
+		t.Fatal("Of() = not ok for a long message")
+	}
+
+	tests := []struct {
+		name  string
+		text  string
+		near  bool
+		short bool
+	}{
+		{name: "identical", text: spam, near: true},
+		{name: "spacing, case and ref changed", text: "hey   EVERYONE check out this amazing crypto giveaway, limited spots: <https://scam.example/claim?ref=999>", near: true},
+		{name: "a mention added", text: "<@U0123ABCD> " + spam, near: true},
+		{name: "a word changed", text: "Hey everyone!! Check out this awesome crypto giveaway, limited spots: <https://scam.example/claim>", near: true},
+		{name: "another link", text: "Hey everyone!! Check out this amazing crypto giveaway, limited spots: <https://other.example/claim>"},
+		{name: "different message", text: "Does anyone know a good book about distributed systems? Looking for recommendations."},
+		{name: "too short", text: "thanks!", short: true},
+		{name: "short with a link", text: "<https://docs.example/guide>"},
+		{name: "only emoji", text: ":tada: :tada:", short: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, ok := Of(tt.text, 20)
+			if ok == tt.short {
+				t.Fatalf("Of() ok = %v, want %v", ok, !tt.short)
+			}
+			if tt.short {
+				return
+			}
+			if d := f.Distance(g); (d <= 6) != tt.near {
+				t.Errorf("Distance() = %d, want near = %v", d, tt.near)
+			}
+		})
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker()
+	f, _ := Of(spam, 20)
+	other, _ := Of("Does anyone know a good book about distributed systems? Looking for recommendations.", 20)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := time.Hour
+
+	tr.Add("U_SPAM", Post{Channel: "C1", Timestamp: "1.1", Fingerprint: f, At: start}, window)
+	tr.Add("U_SPAM", Post{Channel: "C2", Timestamp: "2.1", Fingerprint: f, At: start.Add(10 * time.Minute)}, window)
+	tr.Add("U_SPAM", Post{Channel: "C2", Timestamp: "2.1", Fingerprint: f, At: start.Add(10 * time.Minute)}, window)
+	tr.Add("U_SPAM", Post{Channel: "C2", Timestamp: "2.2", Fingerprint: f, At: start.Add(5 * time.Minute)}, window)
+	tr.Add("U_SPAM", Post{Channel: "C3", Timestamp: "3.1", Fingerprint: other, At: start.Add(20 * time.Minute)}, window)
+	tr.Add("U_OTHER", Post{Channel: "C4", Timestamp: "4.1", Fingerprint: f, At: start}, window)
+	if tr.Len() != 5 {
+		t.Errorf("Len() = %d, want 5 with the repeated event dropped", tr.Len())
+	}
+
+	copies, channels := tr.Copies("U_SPAM", f, 6, window, start.Add(30*time.Minute))
+	if len(copies) != 3 || channels != 2 {
+		t.Errorf("Copies() = %d copies in %d channels, want 3 in 2", len(copies), channels)
+	}
+	if copies, _ := tr.Copies("U_SPAM", f, 6, window, start.Add(time.Hour+7*time.Minute)); len(copies) != 1 {
+		t.Errorf("Copies() later = %d, want only the copy still in the window", len(copies))
+	}
+
+	tr.Add("U_NEW", Post{Channel: "C5", Timestamp: "5.1", Fingerprint: other, At: start.Add(3 * time.Hour)}, window)
+	if tr.Len() != 1 {
+		t.Errorf("Len() = %d after the window passed, want only the newest post", tr.Len())
+	}
+}
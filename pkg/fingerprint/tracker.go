@@ -0,0 +1,115 @@
+package fingerprint
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxPerAuthor bounds how many recent posts are kept for each author; the
+// oldest go first.
+const maxPerAuthor = 100
+
+// Post is one fingerprinted message.
+type Post struct {
+	Channel     string
+	Timestamp   string
+	Fingerprint Fingerprint
+	At          time.Time
+}
+
+// Tracker keeps a rolling window of each author's recent posts.
+type Tracker struct {
+	mu      sync.Mutex
+	posts   map[string][]Post
+	latest  time.Time // of the newest post added
+	swept   time.Time
+	entries int
+}
+
+// NewTracker returns an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{posts: map[string][]Post{}}
+}
+
+// Add records author's post, dropping posts older than window before the
+// newest one, p included. A post already recorded, by channel and timestamp,
+// isn't added again.
+func (t *Tracker) Add(author string, p Post, window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p.At.After(t.latest) {
+		t.latest = p.At
+	}
+	cutoff := t.latest.Add(-window)
+	if p.At.Before(cutoff) {
+		return
+	}
+	if t.latest.Sub(t.swept) >= window {
+		t.sweep(cutoff)
+		t.swept = t.latest
+	}
+
+	posts := t.recent(author, cutoff)
+	for _, q := range posts {
+		if q.Channel == p.Channel && q.Timestamp == p.Timestamp {
+			return
+		}
+	}
+	if len(posts) == maxPerAuthor {
+		posts = posts[1:]
+		t.entries--
+	}
+	// Events can arrive out of order; keep each author's posts oldest first.
+	posts = append(posts, p)
+	slices.SortStableFunc(posts, func(a, b Post) int { return a.At.Compare(b.At) })
+	t.posts[author] = posts
+	t.entries++
+}
+
+// Copies returns author's posts since now minus window that are within
+// maxDistance bits of f, and how many channels they're in.
+func (t *Tracker) Copies(author string, f Fingerprint, maxDistance int, window time.Duration, now time.Time) (copies []Post, channels int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seen := map[string]bool{}
+	for _, p := range t.recent(author, now.Add(-window)) {
+		if p.Fingerprint.Distance(f) <= maxDistance {
+			copies = append(copies, p)
+			seen[p.Channel] = true
+		}
+	}
+	return copies, len(seen)
+}
+
+// Len is how many posts are being tracked.
+func (t *Tracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.entries
+}
+
+// recent drops author's posts from before cutoff and returns the rest.
+func (t *Tracker) recent(author string, cutoff time.Time) []Post {
+	posts := t.posts[author]
+	i := 0
+	for i < len(posts) && posts[i].At.Before(cutoff) {
+		i++
+	}
+	t.entries -= i
+	posts = posts[i:]
+	if len(posts) == 0 {
+		delete(t.posts, author)
+	} else {
+		t.posts[author] = posts
+	}
+	return posts
+}
+
+// sweep drops every author's posts from before cutoff, so authors who went
+// quiet don't linger.
+func (t *Tracker) sweep(cutoff time.Time) {
+	for author := range t.posts {
+		t.recent(author, cutoff)
+	}
+}
@@ -0,0 +1,110 @@
+// Package lookalike compares names the way a reader skims them, so that
+// "Adm1n Ｓupport 🛡️" and "admin support" come out the same.
+package lookalike
+
+import (
+	"strings"
+	"unicode"
+)
+
+// homoglyphs maps characters to the ASCII letter they're mistaken for. i, l,
+// 1 and I are all read as l. Upper case entries are applied before case
+// folding.
+var homoglyphs = map[rune]rune{
+	// digits and symbols
+	'0': 'o', '1': 'l', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's', '|': 'l', '!': 'l', 'I': 'l', 'i': 'l',
+	// Latin with diacritics
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a', 'ą': 'a',
+	'ç': 'c', 'ć': 'c', 'č': 'c', 'ď': 'd', 'đ': 'd',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ę': 'e', 'ě': 'e',
+	'ì': 'l', 'í': 'l', 'î': 'l', 'ï': 'l', 'ī': 'l', 'ı': 'l',
+	'ł': 'l', 'ñ': 'n', 'ń': 'n', 'ň': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o', 'ō': 'o',
+	'ř': 'r', 'ś': 's', 'š': 's', 'ş': 's', 'ť': 't',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u', 'ů': 'u',
+	'ý': 'y', 'ÿ': 'y', 'ź': 'z', 'ż': 'z', 'ž': 'z',
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'і': 'l', 'ј': 'j', 'к': 'k', 'м': 'm',
+	'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's',
+	'ԁ': 'd', 'ӏ': 'l', 'ɡ': 'g', 'һ': 'h', 'ԛ': 'q', 'ԝ': 'w',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'l', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+}
+
+// digraphs are letter pairs that read as one letter, applied after folding.
+var digraphs = strings.NewReplacer("rn", "m", "vv", "w", "cl", "d")
+
+// Fold reduces name to its skeleton: homoglyphs replaced by the letters they
+// look like, case folded, and everything but letters and digits, like spaces,
+// punctuation and emoji, dropped.
+func Fold(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		r = plain(r)
+		if g, ok := homoglyphs[r]; ok {
+			r = g
+		}
+		r = unicode.ToLower(r)
+		if g, ok := homoglyphs[r]; ok {
+			r = g
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return digraphs.Replace(b.String())
+}
+
+// plain maps the fullwidth and mathematical styled forms of ASCII letters and
+// digits, like Ｓ and 𝐒, to the plain ones.
+func plain(r rune) rune {
+	switch {
+	case r >= 0xFF01 && r <= 0xFF5E: // fullwidth ASCII
+		return r - 0xFEE0
+	case r >= 0x1D400 && r <= 0x1D6A3: // mathematical letters, 52 per style
+		i := (r - 0x1D400) % 52
+		if i < 26 {
+			return 'A' + i
+		}
+		return 'a' + i - 26
+	case r >= 0x1D7CE && r <= 0x1D7FF: // mathematical digits, 10 per style
+		return '0' + (r-0x1D7CE)%10
+	}
+	return r
+}
+
+// Distance is the Levenshtein distance between a and b, in runes.
+func Distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(t)]
+}
+
+// Resembles reports whether name looks like target: their skeletons are
+// within maxDistance edits, allowing one edit per four characters of the
+// target's so short names have to match exactly.
+func Resembles(name, target string, maxDistance int) bool {
+	n, t := Fold(name), Fold(target)
+	if n == "" || t == "" {
+		return false
+	}
+	allowed := min(maxDistance, len([]rune(t))/4)
+	return Distance(n, t) <= allowed
+}
@@ -0,0 +1,58 @@
+package lookalike
+
+import "testing"
+
+func TestFold(t *testing.T) {
+	for name, want := range map[string]string{
+		"Admin Support":     "admlnsupport",
+		"Adm1n  Ｓupport 🛡️": "admlnsupport",
+		"АdmІn":             "admln", // Cyrillic А and І
+		"𝐀𝐝𝐦𝐢𝐧":             "admln",
+		"Zoë Kärnä":         "zoekama",
+		"🙂 !!":              "ll",
+	} {
+		if got := Fold(name); got != want {
+			t.Errorf("Fold(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"timothy", "tlmothy", 1},
+		{"añb", "anb", 1},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestResembles(t *testing.T) {
+	tests := []struct {
+		name, target string
+		want         bool
+	}{
+		{"Tim Ortiz", "Tim Ortiz", true},
+		{"T1m 0rtiz ✅", "Tim Ortiz", true},
+		{"Tim Ortíz", "Tim Ortiz", true},
+		{"Tim Orti", "Tim Ortiz", true},
+		{"Kim Ortega", "Tim Ortiz", false},
+		{"Bob", "Rob", false}, // too short for an edit
+		{"B0b", "Bob", true},
+		{"", "Bob", false},
+		{"🙂", "🙂", false},
+	}
+	for _, tt := range tests {
+		if got := Resembles(tt.name, tt.target, 2); got != tt.want {
+			t.Errorf("Resembles(%q, %q) = %v, want %v", tt.name, tt.target, got, tt.want)
+		}
+	}
+}
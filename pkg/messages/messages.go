@@ -0,0 +1,330 @@
+// Package messages renders Penny's user-facing text from a catalogue of
+// text/template messages, with one bundle per locale. The built-in bundles live
+// in locales/; a directory of <locale>.yaml files can add locales or override
+// single messages.
+package messages
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.yaml.in/yaml/v3"
+)
+
+// DEFAULT_LOCALE is used for any message a locale doesn't have.
+const DEFAULT_LOCALE = "en"
+
+// Message IDs.
+const (
+	ACK          = "ack"
+	OP_NOT_FOUND = "op_not_found"
+	SELF_REPORT  = "self_report"
+	REMOVAL      = "removal"
+	REMOVAL_COPY = "removal.copy"
+	DEBUG        = "debug"
+	ESCALATION   = "escalation"
+	SCREENED     = "screened"
+	HELP         = "help"
+
+	// The parts of a verdict's Block Kit rendering.
+	VERDICT_HEADER    = "verdict.header"
+	VERDICT_SIGNAL    = "verdict.signal"
+	VERDICT_POSTED    = "verdict.posted"
+	VERDICT_REPORTERS = "verdict.reporters"
+	VERDICT_COALESCED = "verdict.coalesced"
+	VERDICT_SCREENED  = "verdict.screened"
+	VERDICT_PROBATION = "verdict.probation"
+
+	// The appeal workflow.
+	APPEAL_BUTTON          = "appeal.button"
+	APPEAL_TITLE           = "appeal.title"
+	APPEAL_LABEL           = "appeal.label"
+	APPEAL_SUBMIT          = "appeal.submit"
+	APPEAL_FILED           = "appeal.filed"
+	APPEAL_NOT_YOURS       = "appeal.not_yours"
+	APPEAL_EXISTS          = "appeal.exists"
+	APPEAL_REVIEW          = "appeal.review"
+	APPEAL_UPHOLD          = "appeal.uphold"
+	APPEAL_OVERTURN        = "appeal.overturn"
+	APPEAL_OWN             = "appeal.own"
+	APPEAL_NOT_MODERATOR   = "appeal.not_moderator"
+	APPEAL_DECIDED         = "appeal.decided"
+	APPEAL_ALREADY_DECIDED = "appeal.already_decided"
+	APPEAL_OUTCOME         = "appeal.outcome"
+
+	// Approving the messages of members on probation.
+	PROBATION_REVIEW          = "probation.review"
+	PROBATION_APPROVE         = "probation.approve"
+	PROBATION_REMOVE          = "probation.remove"
+	PROBATION_DECIDED         = "probation.decided"
+	PROBATION_OWN             = "probation.own"
+	PROBATION_NOT_MODERATOR   = "probation.not_moderator"
+	PROBATION_ALREADY_DECIDED = "probation.already_decided"
+	PROBATION_GONE            = "probation.gone"
+	PROBATION_REMOVAL         = "probation.removal"
+
+	// The removal breaker and its `/penny` subcommands.
+	BREAKER_TRIP       = "breaker.trip"
+	BREAKER_ALERT      = "breaker.alert"
+	BREAKER_RUNNING    = "breaker.running"
+	BREAKER_PAUSED     = "breaker.paused"
+	BREAKER_NOT_PAUSED = "breaker.not_paused"
+	BREAKER_RESUMED    = "breaker.resumed"
+	COMMAND_UNKNOWN    = "command.unknown"
+
+	// REASON_PREFIX plus a signal's name is the ID of its debug line.
+	REASON_PREFIX        = "reason."
+	REASON_TIMED_OUT     = "reason.timed_out"
+	REASON_NO_USER_TOKEN = "reason.no_user_token"
+)
+
+// Verdicts a debug reply can report.
+const (
+	VERDICT_REMOVED  = "removed"
+	VERDICT_HELD     = "held"
+	VERDICT_DEFERRED = "deferred"
+	VERDICT_KEPT     = "kept"
+)
+
+// Data is what a message template can use. Each message uses the fields that
+// make sense for it.
+type Data struct {
+	// Reporters and OP are user mentions, e.g. <@U0123ABCD>.
+	Reporters []string
+	OP        string
+	// Text is the OP's message.
+	Text string
+	// Channel is the OP's channel ID and Permalink links to their message.
+	Channel   string
+	Permalink string
+
+	Score     int
+	Threshold int
+	Reasons   []string
+	Verdict   string
+	Coalesced bool
+
+	// AssistanceChannel is a channel ID; templates link it as <#{{.AssistanceChannel}}>.
+	AssistanceChannel string
+	Emoji             string
+	// Response is the configured spam_feed.reacji_response.
+	Response string
+
+	// Signal, Deadline and Policy describe a signal that timed out.
+	Signal   string
+	Deadline time.Duration
+	Policy   string
+
+	// Case is the ID of an appealed case, and Justification the author's
+	// reason for appealing it. Moderator is a mention of who decided the
+	// appeal, and Decision is cases.APPEAL_UPHELD or cases.APPEAL_OVERTURNED.
+	Case          uint
+	Justification string
+	Moderator     string
+	Decision      string
+
+	// Mentions are who an escalation pings, and Suppressed is how many
+	// escalations were held back by the rate limit since the last one.
+	Mentions   []string
+	Suppressed int
+
+	// Domain is an author's email domain, and Removals how many messages
+	// from authors with it were removed recently.
+	Domain   string
+	Removals int
+
+	// Name is the protected name an author's resembles.
+	Name string
+
+	// Copies of a message were posted in Channels channels within Window.
+	Copies   int
+	Channels int
+	Window   time.Duration
+
+	// The removal breaker tripped Since, after Removals removals in Channel
+	// (every channel if it's empty) within Window hit its Limit. Trip is
+	// that rendered as breaker.trip.
+	Limit int
+	Since time.Time
+	Trip  string
+
+	// Command is a `/penny` subcommand Penny doesn't know.
+	Command string
+}
+
+//go:embed locales/*.yaml
+var builtin embed.FS
+
+var funcs = template.FuncMap{
+	"join": func(elems []string, sep string) string { return strings.Join(elems, sep) },
+}
+
+// Catalog is every message in every locale.
+type Catalog struct {
+	locales map[string]*template.Template
+}
+
+// Builtin returns the catalogue of built-in bundles.
+func Builtin() *Catalog {
+	c, err := Load("")
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Load returns the built-in bundles with the bundles in dir, if given, laid
+// over them message by message.
+func Load(dir string) (*Catalog, error) {
+	sources := map[string]map[string]string{}
+	if err := readBundles(builtin, "locales", sources); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := readBundles(os.DirFS(dir), ".", sources); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", dir, err)
+		}
+	}
+	if _, ok := sources[DEFAULT_LOCALE]; !ok {
+		return nil, fmt.Errorf("no %s bundle", DEFAULT_LOCALE)
+	}
+
+	c := &Catalog{locales: map[string]*template.Template{}}
+	for locale, msgs := range sources {
+		t := template.New(locale).Funcs(funcs).Option("missingkey=error")
+		for id, text := range msgs {
+			if _, err := t.New(id).Parse(text); err != nil {
+				return nil, fmt.Errorf("%s: %w", locale, err)
+			}
+			// Catch references to fields Data doesn't have now, not mid-event.
+			if err := t.ExecuteTemplate(new(bytes.Buffer), id, Data{}); err != nil {
+				return nil, fmt.Errorf("%s: %w", locale, err)
+			}
+		}
+		c.locales[locale] = t
+	}
+	return c, nil
+}
+
+// readBundles merges every <locale>.yaml in dir of fsys into sources.
+func readBundles(fsys fs.FS, dir string, sources map[string]map[string]string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".yaml" {
+			continue
+		}
+		file := path.Join(dir, entry.Name())
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		var msgs map[string]string
+		if err := yaml.Unmarshal(b, &msgs); err != nil {
+			return fmt.Errorf("parsing %s: %w", entry.Name(), err)
+		}
+		locale := normalize(strings.TrimSuffix(entry.Name(), ".yaml"))
+		if sources[locale] == nil {
+			sources[locale] = map[string]string{}
+		}
+		for id, text := range msgs {
+			sources[locale][id] = text
+		}
+	}
+	return nil
+}
+
+// normalize turns Slack's locales (en-US) and POSIX ones (en_US) into one form.
+func normalize(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
+
+// candidates returns the locales to try for locale, most specific first.
+func candidates(locale string) []string {
+	locale = normalize(locale)
+	var cs []string
+	if locale != "" {
+		cs = append(cs, locale)
+		if lang, _, found := strings.Cut(locale, "-"); found {
+			cs = append(cs, lang)
+		}
+	}
+	return append(cs, DEFAULT_LOCALE)
+}
+
+// Has reports whether c has a bundle for locale or its language.
+func (c *Catalog) Has(locale string) bool {
+	cs := candidates(locale)
+	for _, l := range cs[:len(cs)-1] {
+		if _, ok := c.locales[l]; ok {
+			return true
+		}
+	}
+	return normalize(locale) == DEFAULT_LOCALE
+}
+
+// Locales returns the locales c has bundles for, sorted.
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.locales))
+	for l := range c.locales {
+		locales = append(locales, l)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// Render renders message id in locale, falling back to its language and then
+// to DEFAULT_LOCALE for a message the locale doesn't have.
+func (c *Catalog) Render(locale, id string, data Data) (string, error) {
+	for _, l := range candidates(locale) {
+		t, ok := c.locales[l]
+		if !ok || t.Lookup(id) == nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := t.ExecuteTemplate(&buf, id, data); err != nil {
+			return "", fmt.Errorf("%s: %w", l, err)
+		}
+		return buf.String(), nil
+	}
+	return "", fmt.Errorf("no message %q", id)
+}
+
+var current atomic.Pointer[Catalog]
+
+// Use makes c the catalogue Text renders from. Call it before serving.
+func Use(c *Catalog) {
+	current.Store(c)
+}
+
+// Current returns the catalogue set with Use, or the built-in one.
+func Current() *Catalog {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	c := Builtin()
+	current.CompareAndSwap(nil, c)
+	return current.Load()
+}
+
+// Text renders message id in locale from the current catalogue. A message
+// that fails to render is logged and left empty rather than failing the event.
+func Text(locale, id string, data Data) string {
+	s, err := Current().Render(locale, id, data)
+	if err != nil {
+		log.Error().Err(err).Str("locale", locale).Str("message", id).Msg("failed to render message")
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+package messages
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	reasons := []string{"reported by the community as being spammy: 2", "outside of the community timezone: 2"}
+	tests := []struct {
+		name   string
+		locale string
+		id     string
+		data   Data
+		want   string
+	}{
+		{
+			name: "ack with reporters",
+			id:   ACK,
+			data: Data{Reporters: []string{"<@U1>", "<@U2>"}, Response: "On it."},
+			want: "Thanks <@U1>,<@U2>! On it.",
+		},
+		{
+			name: "ack without reporters",
+			id:   ACK,
+			data: Data{Response: "On it."},
+			want: "On it.",
+		},
+		{
+			name: "debug removed",
+			id:   DEBUG,
+			data: Data{Reasons: reasons, Verdict: VERDICT_REMOVED, Score: 4, Threshold: 4},
+			want: "This is what I found about the OP:\n- " + reasons[0] + "\n- " + reasons[1] + "\n" +
+				"I removed the OP since the final anomaly score (4/4) was suspect enough.",
+		},
+		{
+			name: "debug coalesced and kept",
+			id:   DEBUG,
+			data: Data{Reasons: reasons[:1], Verdict: VERDICT_KEPT, Coalesced: true, Score: 2, Threshold: 5},
+			want: "I already looked into this author recently, so I reused what I found:\n- " + reasons[0] + "\n" +
+				"The final anomaly score (2/5) didn't result in a removal.",
+		},
+		{
+			name: "timed out signal",
+			id:   REASON_TIMED_OUT,
+			data: Data{Signal: "low_activity", Deadline: 10_000_000_000, Policy: "defer"},
+			want: "low_activity timed out after 10s: deferring removal to a human",
+		},
+		{
+			name:   "Slack locale falls back to its language",
+			locale: "es-LA",
+			id:     SELF_REPORT,
+			want:   "¡Oye! Eso no está bien.",
+		},
+		{
+			name:   "POSIX locale",
+			locale: "es_ES",
+			id:     HELP,
+			data:   Data{Emoji: "no_entry_sign"},
+			want:   "*Penny* es un bot de moderación comunitaria que vigila la reacción :no_entry_sign: para detectar y eliminar mensajes de spam.",
+		},
+		{
+			name:   "unknown locale falls back to English",
+			locale: "xx",
+			id:     HELP,
+			data:   Data{Emoji: "no_entry_sign", AssistanceChannel: "C123"},
+			want:   "*Penny* is a community moderation bot that monitors for the :no_entry_sign: reaction to detect and remove spam messages.\n\nNeed help or have questions? Visit <#C123>.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Builtin().Render(tt.locale, tt.id, tt.data)
+			if err != nil {
+				t.Fatalf("Render() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBundlesComplete verifies that every built-in locale has every message.
+func TestBundlesComplete(t *testing.T) {
+	c := Builtin()
+	want := c.locales[DEFAULT_LOCALE].Templates()
+	for _, locale := range c.Locales() {
+		for _, tmpl := range want {
+			if c.locales[locale].Lookup(tmpl.Name()) == nil {
+				t.Errorf("%s is missing %s", locale, tmpl.Name())
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+		check   func(t *testing.T, c *Catalog)
+	}{
+		{
+			name:  "override one message",
+			files: map[string]string{"en.yaml": "self_report: Nice try."},
+			check: func(t *testing.T, c *Catalog) {
+				if got, _ := c.Render("en", SELF_REPORT, Data{}); got != "Nice try." {
+					t.Errorf("self_report = %q, want the override", got)
+				}
+				if got, _ := c.Render("en", OP_NOT_FOUND, Data{}); !strings.Contains(got, "Slack API") {
+					t.Errorf("op_not_found = %q, want the built-in message", got)
+				}
+			},
+		},
+		{
+			name:  "add a locale",
+			files: map[string]string{"fr.yaml": "self_report: Pas sympa !"},
+			check: func(t *testing.T, c *Catalog) {
+				if !c.Has("fr-FR") {
+					t.Error("Has(fr-FR) = false, want true")
+				}
+				if got, _ := c.Render("fr", SELF_REPORT, Data{}); got != "Pas sympa !" {
+					t.Errorf("self_report = %q, want the French message", got)
+				}
+				if got, _ := c.Render("fr", OP_NOT_FOUND, Data{}); !strings.Contains(got, "Slack API") {
+					t.Errorf("op_not_found = %q, want the English fallback", got)
+				}
+			},
+		},
+		{
+			name:    "bad template",
+			files:   map[string]string{"en.yaml": "removal: '{{if .OP}}'"},
+			wantErr: "removal",
+		},
+		{
+			name:    "unknown field",
+			files:   map[string]string{"en.yaml": "removal: '{{.Author}}'"},
+			wantErr: "Author",
+		},
+		{
+			name:    "not YAML",
+			files:   map[string]string{"en.yaml": "- a list"},
+			wantErr: "en.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c, err := Load(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			tt.check(t, c)
+		})
+	}
+}
+
+func TestHas(t *testing.T) {
+	c := Builtin()
+	for locale, want := range map[string]bool{"en": true, "en-US": true, "es-ES": true, "ES": true, "ja-JP": false, "": false} {
+		if got := c.Has(locale); got != want {
+			t.Errorf("Has(%q) = %v, want %v", locale, got, want)
+		}
+	}
+}
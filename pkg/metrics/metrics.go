@@ -0,0 +1,194 @@
+// Package metrics holds penny's Prometheus collectors and serves them on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/xortim/penny/pkg/slackclient"
+)
+
+const namespace = "penny"
+
+// Outcomes of a removal, an escalation or a webhook delivery, used as the
+// result label of Removals, Escalations and WebhookDeliveries.
+const (
+	RESULT_OK         = "ok"
+	RESULT_ERROR      = "error"
+	RESULT_HELD       = "held"       // the removal breaker is in review-only mode
+	RESULT_SUPPRESSED = "suppressed" // the escalation limit was reached
+)
+
+// Outcomes of screening a low-trust author's message, used as the result label
+// of Screened.
+const (
+	SCREEN_PASSED  = "passed"  // scored below the review score
+	SCREEN_FLAGGED = "flagged" // posted to the spam feed
+)
+
+// Registry holds every penny collector, plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// ReportsProcessed counts spam-feed reports that reached scoring.
+	ReportsProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reports_processed_total",
+		Help:      "Spam-feed reports processed.",
+	}, []string{"feed_channel"})
+
+	// Removals counts removal attempts by result.
+	Removals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "removals_total",
+		Help:      "Reported messages Penny tried to remove, by result (ok, error, held).",
+	}, []string{"feed_channel", "result"})
+
+	// Warnings counts OPs warned instead of removed.
+	Warnings = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "warnings_total",
+		Help:      "Reported messages whose author was warned instead of removed.",
+	}, []string{"feed_channel"})
+
+	// Escalations counts reports escalated to moderators by result.
+	Escalations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "escalations_total",
+		Help:      "Reports just below the removal threshold escalated to moderators, by result (ok, error, suppressed).",
+	}, []string{"feed_channel", "result"})
+
+	// Screened counts low-trust authors' messages screened before anyone reported them.
+	Screened = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_screened_total",
+		Help:      "Low-trust authors' messages screened before anyone reported them, by result (passed, flagged).",
+	}, []string{"result"})
+
+	// WebhookDeliveries counts webhook deliveries by event and result.
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Events delivered to webhooks, by event and result (ok, error). Errors failed every attempt.",
+	}, []string{"event", "result"})
+
+	// SignalContributions sums the score each signal contributed.
+	SignalContributions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signal_score_total",
+		Help:      "Anomaly score contributed by each signal.",
+	}, []string{"feed_channel", "signal"})
+
+	// SignalTimeouts counts signals that missed the evaluation deadline.
+	SignalTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signal_timeouts_total",
+		Help:      "Signals that didn't finish before the signal deadline.",
+	}, []string{"feed_channel", "signal"})
+
+	// AnomalyScores is the distribution of final anomaly scores.
+	AnomalyScores = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "anomaly_score",
+		Help:      "Final anomaly score of processed reports.",
+		Buckets:   prometheus.LinearBuckets(0, 1, 11),
+	}, []string{"feed_channel"})
+
+	// HandlerDuration is how long processing a report took end to end.
+	HandlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent processing a spam-feed report.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"feed_channel"})
+
+	// SlackAPICalls counts Slack API attempts by method and status.
+	SlackAPICalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_api_calls_total",
+		Help:      "Slack API attempts by method and status (ok, rate_limited, HTTP code or Slack error).",
+	}, []string{"method", "status"})
+
+	// SlackAPIDuration is the latency of Slack API attempts.
+	SlackAPIDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "slack_api_duration_seconds",
+		Help:      "Latency of Slack API attempts.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// SlackRateLimited counts HTTP 429 responses from Slack.
+	SlackRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_rate_limited_total",
+		Help:      "Slack API attempts rejected with HTTP 429.",
+	}, []string{"method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ReportsProcessed,
+		Removals,
+		Warnings,
+		Escalations,
+		Screened,
+		WebhookDeliveries,
+		SignalContributions,
+		SignalTimeouts,
+		AnomalyScores,
+		HandlerDuration,
+		SlackAPICalls,
+		SlackAPIDuration,
+		SlackRateLimited,
+	)
+}
+
+// ObserveWebhookDelivery counts a webhook delivery; it fits
+// webhooks.Options.OnDelivery.
+func ObserveWebhookDelivery(event string, ok bool) {
+	result := RESULT_OK
+	if !ok {
+		result = RESULT_ERROR
+	}
+	WebhookDeliveries.WithLabelValues(event, result).Inc()
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveSlackAttempt records one Slack API attempt. It matches
+// slackclient.RateLimitOptions.OnAttempt.
+func ObserveSlackAttempt(method string, err error, elapsed time.Duration) {
+	status := slackclient.ErrorStatus(err)
+	SlackAPICalls.WithLabelValues(method, status).Inc()
+	SlackAPIDuration.WithLabelValues(method).Observe(elapsed.Seconds())
+	if status == "rate_limited" {
+		SlackRateLimited.WithLabelValues(method).Inc()
+	}
+}
+
+// GaugeFunc registers a gauge whose value is read from fn at scrape time.
+func GaugeFunc(name, help string, fn func() float64) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+// CounterFunc registers a counter whose value is read from fn at scrape time.
+// fn must never decrease.
+func CounterFunc(name, help string, fn func() float64) {
+	Registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/slack-go/slack"
+)
+
+func TestObserveSlackAttempt(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		wantStatus      string
+		wantRateLimited float64
+	}{
+		{"ok", nil, "ok", 0},
+		{"rate limited", &slack.RateLimitedError{RetryAfter: time.Second}, "rate_limited", 1},
+		{"slack error", slack.SlackErrorResponse{Err: "message_not_found"}, "message_not_found", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := "test." + strings.ReplaceAll(tt.name, " ", "_")
+			ObserveSlackAttempt(method, tt.err, 10*time.Millisecond)
+
+			if got := testutil.ToFloat64(SlackAPICalls.WithLabelValues(method, tt.wantStatus)); got != 1 {
+				t.Errorf("slack_api_calls_total{status=%q} = %v, want 1", tt.wantStatus, got)
+			}
+			if got := testutil.ToFloat64(SlackRateLimited.WithLabelValues(method)); got != tt.wantRateLimited {
+				t.Errorf("slack_rate_limited_total = %v, want %v", got, tt.wantRateLimited)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	Removals.WithLabelValues("spam-feed", RESULT_OK).Inc()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`penny_removals_total{feed_channel="spam-feed",result="ok"}`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics missing %q", want)
+		}
+	}
+}
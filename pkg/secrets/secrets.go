@@ -0,0 +1,116 @@
+// Package secrets resolves references to credentials kept outside penny's
+// config, such as env://SLACK_OAUTH_TOKEN or vault://secret/data/penny#bot_token.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Ref is a parsed reference, scheme://path#key.
+type Ref struct {
+	Scheme string
+	Path   string
+	// Key picks one value out of a secret that holds several.
+	Key string
+}
+
+func (r Ref) String() string {
+	s := r.Scheme + "://" + r.Path
+	if r.Key != "" {
+		s += "#" + r.Key
+	}
+	return s
+}
+
+// Resolver looks up the secret a reference points to.
+type Resolver interface {
+	Resolve(ctx context.Context, ref Ref) (string, error)
+}
+
+// ResolverFunc adapts a function to a Resolver.
+type ResolverFunc func(ctx context.Context, ref Ref) (string, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, ref Ref) (string, error) {
+	return f(ctx, ref)
+}
+
+// Resolvers maps reference schemes to the Resolver for each.
+type Resolvers map[string]Resolver
+
+var (
+	mu       sync.RWMutex
+	builtins = Resolvers{
+		"env":   ResolverFunc(resolveEnv),
+		"file":  ResolverFunc(resolveFile),
+		"vault": &Vault{},
+	}
+)
+
+// Register makes r the default resolver for scheme.
+func Register(scheme string, r Resolver) {
+	mu.Lock()
+	defer mu.Unlock()
+	builtins[scheme] = r
+}
+
+// Defaults returns a copy of the registered resolvers.
+func Defaults() Resolvers {
+	mu.RLock()
+	defer mu.RUnlock()
+	rs := make(Resolvers, len(builtins))
+	for scheme, r := range builtins {
+		rs[scheme] = r
+	}
+	return rs
+}
+
+// Parse splits value into a Ref. ok is false unless value is scheme://path
+// with a scheme rs can resolve, so ordinary values pass through untouched.
+func (rs Resolvers) Parse(value string) (ref Ref, ok bool) {
+	scheme, rest, found := strings.Cut(value, "://")
+	if !found || rs[scheme] == nil {
+		return Ref{}, false
+	}
+	path, key, _ := strings.Cut(rest, "#")
+	return Ref{Scheme: scheme, Path: path, Key: key}, true
+}
+
+// Resolve returns the secret value points to, or value itself if it isn't a
+// reference.
+func (rs Resolvers) Resolve(ctx context.Context, value string) (string, error) {
+	ref, ok := rs.Parse(value)
+	if !ok {
+		return value, nil
+	}
+	secret, err := rs[ref.Scheme].Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", ref, err)
+	}
+	return secret, nil
+}
+
+// ReadFile returns the contents of a secret file, such as a Docker or
+// Kubernetes secret, without the trailing newline.
+func ReadFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+func resolveEnv(_ context.Context, ref Ref) (string, error) {
+	value, ok := os.LookupEnv(ref.Path)
+	if !ok {
+		return "", fmt.Errorf("%s is not set", ref.Path)
+	}
+	return value, nil
+}
+
+func resolveFile(_ context.Context, ref Ref) (string, error) {
+	return ReadFile(ref.Path)
+}
@@ -0,0 +1,117 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("PENNY_TEST_SECRET", "from-env")
+	file := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plain value", "xoxb-123", "xoxb-123", false},
+		{"unknown scheme", "https://example.com", "https://example.com", false},
+		{"env", "env://PENNY_TEST_SECRET", "from-env", false},
+		{"env unset", "env://PENNY_TEST_UNSET", "", true},
+		{"file", "file://" + file, "from-file", false},
+		{"missing file", "file://" + file + ".missing", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Defaults().Resolve(context.Background(), tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	ref, ok := Defaults().Parse("vault://secret/data/penny#bot_token")
+	want := Ref{Scheme: "vault", Path: "secret/data/penny", Key: "bot_token"}
+	if !ok || ref != want {
+		t.Errorf("Parse() = %+v, %v, want %+v, true", ref, ok, want)
+	}
+	if ref.String() != "vault://secret/data/penny#bot_token" {
+		t.Errorf("String() = %q", ref.String())
+	}
+}
+
+func TestVault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/penny":
+			_, _ = w.Write([]byte(`{"data":{"data":{"bot_token":"xoxb-v2"}}}`))
+		case "/v1/kv/penny":
+			_, _ = w.Write([]byte(`{"data":{"bot_token":"xoxb-v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	v := &Vault{Address: srv.URL, Token: "s.token"}
+	tests := []struct {
+		name    string
+		ref     Ref
+		want    string
+		wantErr bool
+	}{
+		{"kv v2", Ref{Scheme: "vault", Path: "secret/data/penny", Key: "bot_token"}, "xoxb-v2", false},
+		{"kv v1", Ref{Scheme: "vault", Path: "kv/penny", Key: "bot_token"}, "xoxb-v1", false},
+		{"missing key", Ref{Scheme: "vault", Path: "secret/data/penny", Key: "nope"}, "", true},
+		{"no key", Ref{Scheme: "vault", Path: "secret/data/penny"}, "", true},
+		{"missing path", Ref{Scheme: "vault", Path: "secret/data/other", Key: "bot_token"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Resolve(context.Background(), tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve(%s) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%s) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.yaml")
+	if err := os.WriteFile(path, []byte("secret/data/penny:\n  bot_token: xoxb-local\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewLocalStore(path)
+	if err != nil {
+		t.Fatalf("NewLocalStore() error: %v", err)
+	}
+
+	rs := Resolvers{"vault": store}
+	got, err := rs.Resolve(context.Background(), "vault://secret/data/penny#bot_token")
+	if err != nil || got != "xoxb-local" {
+		t.Errorf("Resolve() = %q, %v, want xoxb-local", got, err)
+	}
+	if _, err := rs.Resolve(context.Background(), "vault://secret/data/other#bot_token"); err == nil {
+		t.Error("Resolve() of a missing path should fail")
+	}
+}
@@ -0,0 +1,111 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Vault resolves vault://path#key from a HashiCorp Vault KV secrets engine,
+// version 1 or 2. For version 2 the path includes data/, as in
+// vault://secret/data/penny#bot_token.
+type Vault struct {
+	// Address and Token default to VAULT_ADDR and VAULT_TOKEN.
+	Address string
+	Token   string
+	Client  *http.Client
+}
+
+func (v *Vault) Resolve(ctx context.Context, ref Ref) (string, error) {
+	if ref.Key == "" {
+		return "", errors.New("no #key given")
+	}
+	addr, token := v.Address, v.Token
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if addr == "" {
+		return "", errors.New("no Vault address; set VAULT_ADDR")
+	}
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(ref.Path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding vault response: %w", err)
+	}
+	data := body.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		data = nested
+	}
+	return lookup(data, ref.Key)
+}
+
+// LocalStore stands in for Vault during development and tests. It resolves the
+// same vault://path#key references from a YAML file of paths to keys to values:
+//
+//	secret/data/penny:
+//	  bot_token: xoxb-...
+type LocalStore struct {
+	secrets map[string]map[string]any
+}
+
+// NewLocalStore reads the store at path.
+func NewLocalStore(path string) (*LocalStore, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s LocalStore
+	if err := yaml.Unmarshal(b, &s.secrets); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+func (s *LocalStore) Resolve(_ context.Context, ref Ref) (string, error) {
+	data, ok := s.secrets[strings.TrimLeft(ref.Path, "/")]
+	if !ok {
+		return "", fmt.Errorf("no secret at %s", ref.Path)
+	}
+	return lookup(data, ref.Key)
+}
+
+func lookup(data map[string]any, key string) (string, error) {
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("no key %q", key)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("key %q is not a string", key)
+	}
+	return s, nil
+}
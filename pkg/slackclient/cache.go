@@ -0,0 +1,236 @@
+package slackclient
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// CacheOptions configures a CachingClient. Zero values use the defaults.
+type CacheOptions struct {
+	// UserTTL is how long users.info results are reused. Default 10m.
+	UserTTL time.Duration
+	// UserMaxEntries bounds the number of cached users. Default 1000.
+	UserMaxEntries int
+	// ConversationTTL is how long conversations.info results are reused. Default 10m.
+	ConversationTTL time.Duration
+	// ConversationMaxEntries bounds the number of cached conversations. Default 500.
+	ConversationMaxEntries int
+
+	// now is swapped out in tests.
+	now func() time.Time
+}
+
+// CacheStats counts cache outcomes for a single Slack API method.
+type CacheStats struct {
+	Hits          int64
+	Misses        int64
+	Evictions     int64 // entries dropped to stay within the size bound
+	Invalidations int64 // entries dropped because Slack told us they changed
+}
+
+// CachingClient decorates a Client with read-through caches for users.info and
+// conversations.info. Entries expire after a per-method TTL, are bounded in
+// number (least recently used first out), and can be invalidated when Slack
+// sends user_change or channel_rename events. All other calls pass straight through.
+type CachingClient struct {
+	Client
+
+	users         *lruCache[*slack.User]
+	conversations *lruCache[*slack.Channel]
+}
+
+var _ Client = (*CachingClient)(nil)
+
+// NewCachingClient wraps next with read-through caches.
+func NewCachingClient(next Client, opts CacheOptions) *CachingClient {
+	if opts.UserTTL == 0 {
+		opts.UserTTL = 10 * time.Minute
+	}
+	if opts.UserMaxEntries == 0 {
+		opts.UserMaxEntries = 1000
+	}
+	if opts.ConversationTTL == 0 {
+		opts.ConversationTTL = 10 * time.Minute
+	}
+	if opts.ConversationMaxEntries == 0 {
+		opts.ConversationMaxEntries = 500
+	}
+	if opts.now == nil {
+		opts.now = time.Now
+	}
+
+	return &CachingClient{
+		Client:        next,
+		users:         newLRUCache[*slack.User](opts.UserTTL, opts.UserMaxEntries, opts.now),
+		conversations: newLRUCache[*slack.Channel](opts.ConversationTTL, opts.ConversationMaxEntries, opts.now),
+	}
+}
+
+// WithContext returns a client sharing c's caches whose misses are looked up
+// with ctx bound to the wrapped client.
+func (c *CachingClient) WithContext(ctx context.Context) Client {
+	bound := *c
+	bound.Client = WithContext(ctx, c.Client)
+	return &bound
+}
+
+// GetUserInfo returns a cached copy of the user if one is fresh.
+func (c *CachingClient) GetUserInfo(user string) (*slack.User, error) {
+	return c.getUser(user, c.Client.GetUserInfo)
+}
+
+// GetUserInfoContext returns a cached copy of the user if one is fresh.
+func (c *CachingClient) GetUserInfoContext(ctx context.Context, user string) (*slack.User, error) {
+	return c.getUser(user, func(user string) (*slack.User, error) {
+		return c.Client.GetUserInfoContext(ctx, user)
+	})
+}
+
+func (c *CachingClient) getUser(user string, lookup func(string) (*slack.User, error)) (*slack.User, error) {
+	if u, ok := c.users.get(user); ok {
+		copied := *u
+		return &copied, nil
+	}
+
+	u, err := lookup(user)
+	if err != nil {
+		return u, err
+	}
+	copied := *u
+	c.users.put(user, &copied)
+	return u, nil
+}
+
+// GetConversationInfo returns a cached copy of the channel if one is fresh. Requests
+// for optional fields (locale, member count) always go to Slack.
+func (c *CachingClient) GetConversationInfo(input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+	if input.IncludeLocale || input.IncludeNumMembers {
+		return c.Client.GetConversationInfo(input)
+	}
+
+	if ch, ok := c.conversations.get(input.ChannelID); ok {
+		copied := *ch
+		return &copied, nil
+	}
+
+	ch, err := c.Client.GetConversationInfo(input)
+	if err != nil {
+		return ch, err
+	}
+	copied := *ch
+	c.conversations.put(input.ChannelID, &copied)
+	return ch, nil
+}
+
+// InvalidateUser drops the cached users.info result for the user, if any.
+func (c *CachingClient) InvalidateUser(userID string) {
+	c.users.invalidate(userID)
+}
+
+// InvalidateConversation drops the cached conversations.info result for the channel, if any.
+func (c *CachingClient) InvalidateConversation(channelID string) {
+	c.conversations.invalidate(channelID)
+}
+
+// Stats returns a snapshot of cache statistics keyed by Slack API method.
+func (c *CachingClient) Stats() map[string]CacheStats {
+	return map[string]CacheStats{
+		MethodUsersInfo:         c.users.snapshot(),
+		MethodConversationsInfo: c.conversations.snapshot(),
+	}
+}
+
+// lruCache is a size bounded, TTL expiring, least recently used cache.
+type lruCache[V any] struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+	stats   CacheStats
+}
+
+type lruEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+func newLRUCache[V any](ttl time.Duration, maxEntries int, now func() time.Time) *lruCache[V] {
+	return &lruCache[V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        now,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (l *lruCache[V]) get(key string) (V, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var zero V
+	el, ok := l.entries[key]
+	if !ok {
+		l.stats.Misses++
+		return zero, false
+	}
+
+	entry := el.Value.(*lruEntry[V])
+	if !l.now().Before(entry.expires) {
+		l.order.Remove(el)
+		delete(l.entries, key)
+		l.stats.Misses++
+		return zero, false
+	}
+
+	l.order.MoveToFront(el)
+	l.stats.Hits++
+	return entry.value, true
+}
+
+func (l *lruCache[V]) put(key string, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expires := l.now().Add(l.ttl)
+	if el, ok := l.entries[key]; ok {
+		entry := el.Value.(*lruEntry[V])
+		entry.value = value
+		entry.expires = expires
+		l.order.MoveToFront(el)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&lruEntry[V]{key: key, value: value, expires: expires})
+	for l.order.Len() > l.maxEntries {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry[V]).key)
+		l.stats.Evictions++
+	}
+}
+
+func (l *lruCache[V]) invalidate(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.entries[key]; ok {
+		l.order.Remove(el)
+		delete(l.entries, key)
+		l.stats.Invalidations++
+	}
+}
+
+func (l *lruCache[V]) snapshot() CacheStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
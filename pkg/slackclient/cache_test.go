@@ -0,0 +1,154 @@
+package slackclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// fakeClock is a manually advanced clock for TTL tests.
+type fakeClock struct{ t time.Time }
+
+func (f *fakeClock) now() time.Time          { return f.t }
+func (f *fakeClock) advance(d time.Duration) { f.t = f.t.Add(d) }
+
+func TestCachingClientGetUserInfo(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	lookups := map[string]int{}
+	var lookupErr error
+	mock := &MockClient{
+		GetUserInfoFn: func(user string) (*slack.User, error) {
+			lookups[user]++
+			if lookupErr != nil {
+				return nil, lookupErr
+			}
+			return &slack.User{ID: user, TZ: "America/New_York"}, nil
+		},
+	}
+	c := NewCachingClient(mock, CacheOptions{UserTTL: time.Minute, UserMaxEntries: 2, now: clock.now})
+
+	get := func(user string) *slack.User {
+		t.Helper()
+		u, err := c.GetUserInfo(user)
+		if err != nil {
+			t.Fatalf("GetUserInfo(%q) unexpected error: %v", user, err)
+		}
+		return u
+	}
+
+	t.Run("repeat lookups are served from cache", func(t *testing.T) {
+		get("U1")
+		get("U1")
+		if lookups["U1"] != 1 {
+			t.Errorf("users.info called %d times, want 1", lookups["U1"])
+		}
+	})
+
+	t.Run("cached values are copies", func(t *testing.T) {
+		get("U1").TZ = "mutated"
+		if got := get("U1").TZ; got != "America/New_York" {
+			t.Errorf("cached user was mutated by a caller: TZ = %q", got)
+		}
+	})
+
+	t.Run("entries expire after the TTL", func(t *testing.T) {
+		clock.advance(time.Minute)
+		get("U1")
+		if lookups["U1"] != 2 {
+			t.Errorf("users.info called %d times, want 2", lookups["U1"])
+		}
+	})
+
+	t.Run("invalidation forces a lookup", func(t *testing.T) {
+		c.InvalidateUser("U1")
+		get("U1")
+		if lookups["U1"] != 3 {
+			t.Errorf("users.info called %d times, want 3", lookups["U1"])
+		}
+	})
+
+	t.Run("least recently used entry is evicted", func(t *testing.T) {
+		get("U2")
+		get("U1") // U1 is now most recent
+		get("U3") // evicts U2
+		get("U1")
+		get("U2")
+		if lookups["U1"] != 3 || lookups["U2"] != 2 {
+			t.Errorf("unexpected lookups %v", lookups)
+		}
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		lookupErr = errors.New("user_not_found")
+		if _, err := c.GetUserInfo("U4"); err == nil {
+			t.Fatalf("expected error")
+		}
+		lookupErr = nil
+		get("U4")
+		if lookups["U4"] != 2 {
+			t.Errorf("users.info called %d times, want 2", lookups["U4"])
+		}
+	})
+
+	stats := c.Stats()[MethodUsersInfo]
+	if stats.Hits == 0 || stats.Misses == 0 || stats.Evictions == 0 || stats.Invalidations != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCachingClientGetConversationInfo(t *testing.T) {
+	lookups := 0
+	mock := &MockClient{
+		GetConversationInfoFn: func(input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+			lookups++
+			ch := &slack.Channel{}
+			ch.ID = input.ChannelID
+			ch.NameNormalized = "spam-feed"
+			return ch, nil
+		},
+	}
+	c := NewCachingClient(mock, CacheOptions{})
+
+	for range 3 {
+		if _, err := c.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: "C1"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("conversations.info called %d times, want 1", lookups)
+	}
+
+	if _, err := c.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: "C1", IncludeNumMembers: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lookups != 2 {
+		t.Errorf("expected optional-field request to bypass the cache, lookups = %d", lookups)
+	}
+
+	c.InvalidateConversation("C1")
+	if _, err := c.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: "C1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lookups != 3 {
+		t.Errorf("expected lookup after invalidation, lookups = %d", lookups)
+	}
+}
+
+func TestCachingClientPassesThrough(t *testing.T) {
+	deleted := false
+	mock := &MockClient{
+		DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+			deleted = true
+			return channel, messageTimestamp, nil
+		},
+	}
+	c := NewCachingClient(mock, CacheOptions{})
+	if _, _, err := c.DeleteMessage("C1", "1.0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !deleted {
+		t.Errorf("expected DeleteMessage to reach the wrapped client")
+	}
+}
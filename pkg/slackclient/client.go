@@ -1,6 +1,10 @@
 package slackclient
 
-import "github.com/slack-go/slack"
+import (
+	"context"
+
+	"github.com/slack-go/slack"
+)
 
 // Client is an interface over the Slack API methods used by penny.
 // *slack.Client satisfies this interface at compile time.
@@ -10,9 +14,31 @@ type Client interface {
 	GetConversationHistory(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error)
 	GetConversationReplies(params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error)
 	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+	GetPermalinkContext(ctx context.Context, params *slack.PermalinkParameters) (string, error)
+	PostEphemeral(channelID, userID string, options ...slack.MsgOption) (string, error)
+	UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error)
+	OpenView(triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error)
+	OpenConversation(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error)
 	AddReaction(name string, item slack.ItemRef) error
 	GetUserInfo(user string) (*slack.User, error)
+	GetUserInfoContext(ctx context.Context, user string) (*slack.User, error)
+	GetUsersContext(ctx context.Context, options ...slack.GetUsersOption) ([]slack.User, error)
 	SearchMessages(query string, params slack.SearchParameters) (*slack.SearchMessages, error)
+	SearchMessagesContext(ctx context.Context, query string, params slack.SearchParameters) (*slack.SearchMessages, error)
 	DeleteMessage(channel, messageTimestamp string) (string, string, error)
 	GetConversations(params *slack.GetConversationsParameters) ([]slack.Channel, string, error)
 }
+
+// ContextBinder is implemented by decorators that can bind a context to the
+// methods of Client that don't take one.
+type ContextBinder interface {
+	WithContext(ctx context.Context) Client
+}
+
+// WithContext binds ctx to c if c supports it, and returns c unchanged otherwise.
+func WithContext(ctx context.Context, c Client) Client {
+	if b, ok := c.(ContextBinder); ok {
+		return b.WithContext(ctx)
+	}
+	return c
+}
@@ -1,18 +1,31 @@
 package slackclient
 
-import "github.com/slack-go/slack"
+import (
+	"context"
+
+	"github.com/slack-go/slack"
+)
 
 // MockClient is a configurable mock of Client for use in tests.
 // Set only the Fn fields you need; unconfigured methods panic to signal unexpected calls.
+// Context variants fall back to their plain Fn when their own Fn is unset.
 type MockClient struct {
 	GetConversationInfoFn    func(input *slack.GetConversationInfoInput) (*slack.Channel, error)
 	JoinConversationFn       func(channelID string) (*slack.Channel, string, []string, error)
 	GetConversationHistoryFn func(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error)
 	GetConversationRepliesFn func(params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error)
 	PostMessageFn            func(channelID string, options ...slack.MsgOption) (string, string, error)
+	GetPermalinkContextFn    func(ctx context.Context, params *slack.PermalinkParameters) (string, error)
+	PostEphemeralFn          func(channelID, userID string, options ...slack.MsgOption) (string, error)
+	OpenConversationFn       func(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error)
+	UpdateMessageFn          func(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error)
+	OpenViewFn               func(triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error)
 	AddReactionFn            func(name string, item slack.ItemRef) error
 	GetUserInfoFn            func(user string) (*slack.User, error)
+	GetUserInfoContextFn     func(ctx context.Context, user string) (*slack.User, error)
+	GetUsersContextFn        func(ctx context.Context, options ...slack.GetUsersOption) ([]slack.User, error)
 	SearchMessagesFn         func(query string, params slack.SearchParameters) (*slack.SearchMessages, error)
+	SearchMessagesContextFn  func(ctx context.Context, query string, params slack.SearchParameters) (*slack.SearchMessages, error)
 	DeleteMessageFn          func(channel, messageTimestamp string) (string, string, error)
 	GetConversationsFn       func(params *slack.GetConversationsParameters) ([]slack.Channel, string, error)
 }
@@ -37,6 +50,26 @@ func (m *MockClient) PostMessage(channelID string, options ...slack.MsgOption) (
 	return m.PostMessageFn(channelID, options...)
 }
 
+func (m *MockClient) GetPermalinkContext(ctx context.Context, params *slack.PermalinkParameters) (string, error) {
+	return m.GetPermalinkContextFn(ctx, params)
+}
+
+func (m *MockClient) PostEphemeral(channelID, userID string, options ...slack.MsgOption) (string, error) {
+	return m.PostEphemeralFn(channelID, userID, options...)
+}
+
+func (m *MockClient) OpenConversation(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error) {
+	return m.OpenConversationFn(params)
+}
+
+func (m *MockClient) UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	return m.UpdateMessageFn(channelID, timestamp, options...)
+}
+
+func (m *MockClient) OpenView(triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error) {
+	return m.OpenViewFn(triggerID, view)
+}
+
 func (m *MockClient) AddReaction(name string, item slack.ItemRef) error {
 	return m.AddReactionFn(name, item)
 }
@@ -45,10 +78,28 @@ func (m *MockClient) GetUserInfo(user string) (*slack.User, error) {
 	return m.GetUserInfoFn(user)
 }
 
+func (m *MockClient) GetUserInfoContext(ctx context.Context, user string) (*slack.User, error) {
+	if m.GetUserInfoContextFn == nil {
+		return m.GetUserInfoFn(user)
+	}
+	return m.GetUserInfoContextFn(ctx, user)
+}
+
+func (m *MockClient) GetUsersContext(ctx context.Context, options ...slack.GetUsersOption) ([]slack.User, error) {
+	return m.GetUsersContextFn(ctx, options...)
+}
+
 func (m *MockClient) SearchMessages(query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
 	return m.SearchMessagesFn(query, params)
 }
 
+func (m *MockClient) SearchMessagesContext(ctx context.Context, query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+	if m.SearchMessagesContextFn == nil {
+		return m.SearchMessagesFn(query, params)
+	}
+	return m.SearchMessagesContextFn(ctx, query, params)
+}
+
 func (m *MockClient) DeleteMessage(channel, messageTimestamp string) (string, string, error) {
 	return m.DeleteMessageFn(channel, messageTimestamp)
 }
@@ -0,0 +1,534 @@
+package slackclient
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Slack Web API method names, used to key budgets and stats.
+const (
+	MethodConversationsInfo    = "conversations.info"
+	MethodConversationsJoin    = "conversations.join"
+	MethodConversationsHistory = "conversations.history"
+	MethodConversationsReplies = "conversations.replies"
+	MethodConversationsList    = "conversations.list"
+	MethodConversationsOpen    = "conversations.open"
+	MethodChatPostMessage      = "chat.postMessage"
+	MethodChatPostEphemeral    = "chat.postEphemeral"
+	MethodChatGetPermalink     = "chat.getPermalink"
+	MethodChatUpdate           = "chat.update"
+	MethodViewsOpen            = "views.open"
+	MethodChatDelete           = "chat.delete"
+	MethodReactionsAdd         = "reactions.add"
+	MethodUsersInfo            = "users.info"
+	MethodUsersList            = "users.list"
+	MethodSearchMessages       = "search.messages"
+)
+
+// DefaultTierBudgets are the per-minute call budgets for each method, taken from
+// the tiers in Slack's rate limit documentation (https://api.slack.com/docs/rate-limits).
+// chat.postMessage is "special" (roughly one per second per channel), so it's
+// budgeted as Tier 3-ish for the whole workspace.
+var DefaultTierBudgets = map[string]int{
+	MethodConversationsInfo:    50,  // Tier 3
+	MethodConversationsJoin:    50,  // Tier 3
+	MethodConversationsHistory: 50,  // Tier 3
+	MethodConversationsReplies: 50,  // Tier 3
+	MethodConversationsList:    20,  // Tier 2
+	MethodConversationsOpen:    50,  // Tier 3
+	MethodChatPostMessage:      60,  // Special
+	MethodChatPostEphemeral:    100, // Tier 4
+	MethodChatGetPermalink:     100, // Special
+	MethodChatUpdate:           50,  // Tier 3
+	MethodViewsOpen:            100, // Tier 4
+	MethodChatDelete:           50,  // Tier 3
+	MethodReactionsAdd:         50,  // Tier 3
+	MethodUsersInfo:            100, // Tier 4
+	MethodUsersList:            20,  // Tier 2
+	MethodSearchMessages:       20,  // Tier 2
+}
+
+// RateLimitOptions configures a RateLimitedClient. Zero values use the defaults.
+type RateLimitOptions struct {
+	// MaxRetries is the number of retries after the first attempt. Default 3.
+	MaxRetries int
+	// BaseDelay is the initial backoff for transient failures. Default 500ms.
+	BaseDelay time.Duration
+	// MaxDelay caps backoff, and is the longest Retry-After that's waited
+	// for; calls asked to wait longer fail. Default 30s.
+	MaxDelay time.Duration
+	// Budgets overrides DefaultTierBudgets (calls per minute) for individual methods.
+	Budgets map[string]int
+	// OnAttempt, if set, is called after every HTTP attempt with its outcome.
+	OnAttempt func(method string, err error, elapsed time.Duration)
+
+	// sleep is swapped out in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// MethodStats counts the outcomes of calls to a single Slack API method.
+type MethodStats struct {
+	Calls       int64 // logical calls made by penny
+	Attempts    int64 // HTTP attempts, including retries
+	Retries     int64
+	RateLimited int64 // responses rejected with HTTP 429
+	Failures    int64 // calls that ultimately returned an error
+	Throttled   time.Duration
+}
+
+// RateLimitedClient decorates a Client so that every call stays inside Slack's
+// per-method tier budget, honours Retry-After on HTTP 429, and retries idempotent
+// calls that fail with a transient 5xx using jittered exponential backoff.
+type RateLimitedClient struct {
+	*rateLimiter
+
+	next Client
+	// ctx is used by methods that don't take a context; see WithContext.
+	ctx context.Context
+}
+
+// rateLimiter is the budget and stats shared by a RateLimitedClient and every
+// client bound from it with WithContext.
+type rateLimiter struct {
+	opts RateLimitOptions
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	stats   map[string]*MethodStats
+}
+
+var _ Client = (*RateLimitedClient)(nil)
+
+// NewRateLimitedClient wraps next with rate limiting and retries.
+func NewRateLimitedClient(next Client, opts RateLimitOptions) *RateLimitedClient {
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.BaseDelay == 0 {
+		opts.BaseDelay = 500 * time.Millisecond
+	}
+	if opts.MaxDelay == 0 {
+		opts.MaxDelay = 30 * time.Second
+	}
+	if opts.sleep == nil {
+		opts.sleep = sleepContext
+	}
+
+	l := &rateLimiter{
+		opts:    opts,
+		buckets: make(map[string]*bucket),
+		stats:   make(map[string]*MethodStats),
+	}
+	for method, perMinute := range DefaultTierBudgets {
+		l.buckets[method] = newBucket(perMinute)
+	}
+	for method, perMinute := range opts.Budgets {
+		l.buckets[method] = newBucket(perMinute)
+	}
+	return &RateLimitedClient{rateLimiter: l, next: next, ctx: context.Background()}
+}
+
+// WithContext returns a client sharing c's budgets whose methods without a
+// context use ctx, so waits are cancelled and retries are recorded on ctx's span.
+func (c *RateLimitedClient) WithContext(ctx context.Context) Client {
+	return &RateLimitedClient{rateLimiter: c.rateLimiter, next: WithContext(ctx, c.next), ctx: ctx}
+}
+
+// Stats returns a snapshot of call statistics keyed by Slack API method.
+func (c *RateLimitedClient) Stats() map[string]MethodStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]MethodStats, len(c.stats))
+	for method, s := range c.stats {
+		out[method] = *s
+	}
+	return out
+}
+
+// do runs fn under the method's budget, retrying as allowed by idempotent.
+// Non-idempotent calls are only retried when Slack rejected them outright (429),
+// since a 5xx doesn't tell us whether the side effect happened. Waiting, whether
+// for budget or backoff, stops as soon as ctx is done. Throttling and retries are
+// recorded as events on ctx's span, if any.
+func (c *rateLimiter) do(ctx context.Context, method string, idempotent bool, fn func() error) error {
+	c.record(method, func(s *MethodStats) { s.Calls++ })
+	span := trace.SpanFromContext(ctx)
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if wait := c.reserve(method); wait > 0 {
+			c.record(method, func(s *MethodStats) { s.Throttled += wait })
+			span.AddEvent("throttled", trace.WithAttributes(attribute.Int64("slack.wait_ms", wait.Milliseconds())))
+			if err = c.opts.sleep(ctx, wait); err != nil {
+				break
+			}
+		}
+
+		c.record(method, func(s *MethodStats) { s.Attempts++ })
+		start := time.Now()
+		err = fn()
+		if c.opts.OnAttempt != nil {
+			c.opts.OnAttempt(method, err, time.Since(start))
+		}
+		if err == nil {
+			return nil
+		}
+
+		delay, retryable := c.retryDelay(method, attempt, idempotent, err)
+		if !retryable || attempt >= c.opts.MaxRetries {
+			break
+		}
+
+		log.Warn().Err(err).Str("method", method).Int("attempt", attempt+1).Dur("delay", delay).Msg("retrying slack api call")
+		c.record(method, func(s *MethodStats) { s.Retries++ })
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("slack.attempt", attempt+1),
+			attribute.String("slack.status", ErrorStatus(err)),
+			attribute.Int64("slack.retry_delay_ms", delay.Milliseconds()),
+		))
+		span.SetAttributes(attribute.Int("slack.retries", attempt+1))
+		if delay > 0 {
+			if err = c.opts.sleep(ctx, delay); err != nil {
+				break
+			}
+		}
+	}
+
+	c.record(method, func(s *MethodStats) { s.Failures++ })
+	return err
+}
+
+// retryDelay classifies err and returns how long to back off before the next attempt.
+// Retry-After is not returned as a delay; it pauses the method's bucket instead so
+// that concurrent callers of the same method wait it out too.
+func (c *rateLimiter) retryDelay(method string, attempt int, idempotent bool, err error) (time.Duration, bool) {
+	var rateLimited *slack.RateLimitedError
+	if errors.As(err, &rateLimited) {
+		c.record(method, func(s *MethodStats) { s.RateLimited++ })
+		c.pause(method, rateLimited.RetryAfter)
+		// Retrying before Retry-After is up only earns another 429, so a
+		// wait longer than MaxDelay gives up instead.
+		return 0, rateLimited.RetryAfter <= c.opts.MaxDelay
+	}
+
+	var statusErr slack.StatusCodeError
+	if idempotent && errors.As(err, &statusErr) && statusErr.Retryable() {
+		return c.backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// backoff returns a "full jitter" exponential delay for the given attempt.
+func (c *rateLimiter) backoff(attempt int) time.Duration {
+	d := c.opts.BaseDelay << attempt
+	if d <= 0 || d > c.opts.MaxDelay {
+		d = c.opts.MaxDelay
+	}
+	return d/2 + rand.N(d/2+1) //nolint:gosec // jitter does not need a CSPRNG
+}
+
+func (c *rateLimiter) record(method string, fn func(*MethodStats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.stats[method]
+	if !ok {
+		s = &MethodStats{}
+		c.stats[method] = s
+	}
+	fn(s)
+}
+
+// reserve takes a token from the method's bucket and returns how long the caller must wait for it.
+func (c *rateLimiter) reserve(method string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.buckets[method]
+	if !ok {
+		return 0
+	}
+	return b.reserve(time.Now())
+}
+
+// pause holds every caller of method until Slack's Retry-After has elapsed.
+func (c *rateLimiter) pause(method string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b, ok := c.buckets[method]; ok {
+		b.pauseUntil(time.Now().Add(d))
+	}
+}
+
+// ErrorStatus summarises the outcome of a Slack API call for logs and metrics:
+// "ok", "rate_limited", the HTTP status code, Slack's error string, or "error".
+func ErrorStatus(err error) string {
+	var (
+		rateLimited *slack.RateLimitedError
+		statusErr   slack.StatusCodeError
+		slackErr    slack.SlackErrorResponse
+	)
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.As(err, &rateLimited):
+		return "rate_limited"
+	case errors.As(err, &statusErr):
+		return strconv.Itoa(statusErr.Code)
+	case errors.As(err, &slackErr):
+		return slackErr.Err
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// bucket is a token bucket refilled at perMinute/60 tokens per second.
+type bucket struct {
+	capacity float64
+	tokens   float64
+	perSec   float64
+	last     time.Time
+	paused   time.Time
+}
+
+func newBucket(perMinute int) *bucket {
+	perMinute = max(perMinute, 1)
+	// allow short bursts of up to a tenth of the budget
+	capacity := max(float64(perMinute)/10, 1)
+	return &bucket{
+		capacity: capacity,
+		tokens:   capacity,
+		perSec:   float64(perMinute) / 60,
+	}
+}
+
+// reserve consumes a token, going into debt if necessary, and returns the wait
+// until that token is actually available.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	if !b.last.IsZero() {
+		b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.perSec)
+	}
+	b.last = now
+	b.tokens--
+
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.perSec * float64(time.Second))
+	}
+	if pausedFor := b.paused.Sub(now); pausedFor > wait {
+		wait = pausedFor
+	}
+	return wait
+}
+
+func (b *bucket) pauseUntil(t time.Time) {
+	if t.After(b.paused) {
+		b.paused = t
+	}
+}
+
+func (c *RateLimitedClient) GetConversationInfo(input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+	var out *slack.Channel
+	err := c.do(c.ctx, MethodConversationsInfo, true, func() (err error) {
+		out, err = c.next.GetConversationInfo(input)
+		return err
+	})
+	return out, err
+}
+
+func (c *RateLimitedClient) JoinConversation(channelID string) (*slack.Channel, string, []string, error) {
+	var (
+		channel  *slack.Channel
+		warning  string
+		warnings []string
+	)
+	err := c.do(c.ctx, MethodConversationsJoin, true, func() (err error) {
+		channel, warning, warnings, err = c.next.JoinConversation(channelID)
+		return err
+	})
+	return channel, warning, warnings, err
+}
+
+func (c *RateLimitedClient) GetConversationHistory(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+	var out *slack.GetConversationHistoryResponse
+	err := c.do(c.ctx, MethodConversationsHistory, true, func() (err error) {
+		out, err = c.next.GetConversationHistory(params)
+		return err
+	})
+	return out, err
+}
+
+func (c *RateLimitedClient) GetConversationReplies(params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error) {
+	var (
+		msgs    []slack.Message
+		hasMore bool
+		cursor  string
+	)
+	err := c.do(c.ctx, MethodConversationsReplies, true, func() (err error) {
+		msgs, hasMore, cursor, err = c.next.GetConversationReplies(params)
+		return err
+	})
+	return msgs, hasMore, cursor, err
+}
+
+// PostMessage is not idempotent: a transient 5xx may still have posted the message.
+func (c *RateLimitedClient) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	var channel, ts string
+	err := c.do(c.ctx, MethodChatPostMessage, false, func() (err error) {
+		channel, ts, err = c.next.PostMessage(channelID, options...)
+		return err
+	})
+	return channel, ts, err
+}
+
+func (c *RateLimitedClient) GetPermalinkContext(ctx context.Context, params *slack.PermalinkParameters) (string, error) {
+	var out string
+	err := c.do(ctx, MethodChatGetPermalink, true, func() (err error) {
+		out, err = c.next.GetPermalinkContext(ctx, params)
+		return err
+	})
+	return out, err
+}
+
+// PostEphemeral is not idempotent, like PostMessage.
+func (c *RateLimitedClient) PostEphemeral(channelID, userID string, options ...slack.MsgOption) (string, error) {
+	var ts string
+	err := c.do(c.ctx, MethodChatPostEphemeral, false, func() (err error) {
+		ts, err = c.next.PostEphemeral(channelID, userID, options...)
+		return err
+	})
+	return ts, err
+}
+
+// UpdateMessage is retried on 5xx: it replaces the message, so a repeat is harmless.
+func (c *RateLimitedClient) UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	var channel, ts, text string
+	err := c.do(c.ctx, MethodChatUpdate, true, func() (err error) {
+		channel, ts, text, err = c.next.UpdateMessage(channelID, timestamp, options...)
+		return err
+	})
+	return channel, ts, text, err
+}
+
+// OpenView is not retried: its trigger ID expires within seconds and can only be used once.
+func (c *RateLimitedClient) OpenView(triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error) {
+	var out *slack.ViewResponse
+	err := c.do(c.ctx, MethodViewsOpen, false, func() (err error) {
+		out, err = c.next.OpenView(triggerID, view)
+		return err
+	})
+	return out, err
+}
+
+// OpenConversation is retried on 5xx: opening a conversation that's already
+// open returns it again.
+func (c *RateLimitedClient) OpenConversation(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error) {
+	var (
+		channel           *slack.Channel
+		noOp, alreadyOpen bool
+	)
+	err := c.do(c.ctx, MethodConversationsOpen, true, func() (err error) {
+		channel, noOp, alreadyOpen, err = c.next.OpenConversation(params)
+		return err
+	})
+	return channel, noOp, alreadyOpen, err
+}
+
+func (c *RateLimitedClient) AddReaction(name string, item slack.ItemRef) error {
+	return c.do(c.ctx, MethodReactionsAdd, true, func() error {
+		return c.next.AddReaction(name, item)
+	})
+}
+
+func (c *RateLimitedClient) GetUserInfo(user string) (*slack.User, error) {
+	var out *slack.User
+	err := c.do(c.ctx, MethodUsersInfo, true, func() (err error) {
+		out, err = c.next.GetUserInfo(user)
+		return err
+	})
+	return out, err
+}
+
+func (c *RateLimitedClient) GetUserInfoContext(ctx context.Context, user string) (*slack.User, error) {
+	var out *slack.User
+	err := c.do(ctx, MethodUsersInfo, true, func() (err error) {
+		out, err = c.next.GetUserInfoContext(ctx, user)
+		return err
+	})
+	return out, err
+}
+
+// GetUsersContext counts as one call however many pages it takes; slack-go
+// pages through users.list itself, waiting out any rate limiting.
+func (c *RateLimitedClient) GetUsersContext(ctx context.Context, options ...slack.GetUsersOption) ([]slack.User, error) {
+	var out []slack.User
+	err := c.do(ctx, MethodUsersList, true, func() (err error) {
+		out, err = c.next.GetUsersContext(ctx, options...)
+		return err
+	})
+	return out, err
+}
+
+func (c *RateLimitedClient) SearchMessages(query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+	var out *slack.SearchMessages
+	err := c.do(c.ctx, MethodSearchMessages, true, func() (err error) {
+		out, err = c.next.SearchMessages(query, params)
+		return err
+	})
+	return out, err
+}
+
+func (c *RateLimitedClient) SearchMessagesContext(ctx context.Context, query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+	var out *slack.SearchMessages
+	err := c.do(ctx, MethodSearchMessages, true, func() (err error) {
+		out, err = c.next.SearchMessagesContext(ctx, query, params)
+		return err
+	})
+	return out, err
+}
+
+// DeleteMessage is retried on 5xx: deleting an already deleted message fails
+// harmlessly with message_not_found.
+func (c *RateLimitedClient) DeleteMessage(channel, messageTimestamp string) (string, string, error) {
+	var ch, ts string
+	err := c.do(c.ctx, MethodChatDelete, true, func() (err error) {
+		ch, ts, err = c.next.DeleteMessage(channel, messageTimestamp)
+		return err
+	})
+	return ch, ts, err
+}
+
+func (c *RateLimitedClient) GetConversations(params *slack.GetConversationsParameters) ([]slack.Channel, string, error) {
+	var (
+		channels []slack.Channel
+		cursor   string
+	)
+	err := c.do(c.ctx, MethodConversationsList, true, func() (err error) {
+		channels, cursor, err = c.next.GetConversations(params)
+		return err
+	})
+	return channels, cursor, err
+}
@@ -0,0 +1,341 @@
+package slackclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// newTestRateLimitedClient returns a client whose sleeps are recorded instead of slept.
+func newTestRateLimitedClient(next Client, opts RateLimitOptions) (*RateLimitedClient, *[]time.Duration) {
+	var slept []time.Duration
+	opts.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	return NewRateLimitedClient(next, opts), &slept
+}
+
+func TestRateLimitedClientRetries(t *testing.T) {
+	serverErr := slack.StatusCodeError{Code: http.StatusBadGateway, Status: "502 Bad Gateway"}
+	clientErr := slack.StatusCodeError{Code: http.StatusBadRequest, Status: "400 Bad Request"}
+
+	tests := []struct {
+		name         string
+		errs         []error // returned by successive attempts; nil afterwards
+		call         func(c Client) error
+		wantAttempts int
+		wantErr      bool
+		wantSlept    []time.Duration // checked only when non-nil
+	}{
+		{
+			name: "success needs no retry",
+			call: func(c Client) error {
+				_, err := c.GetUserInfo("U123")
+				return err
+			},
+			wantAttempts: 1,
+		},
+		{
+			name: "Retry-After is honoured on idempotent calls",
+			errs: []error{&slack.RateLimitedError{RetryAfter: 7 * time.Second}},
+			call: func(c Client) error {
+				_, err := c.SearchMessages("q", slack.NewSearchParameters())
+				return err
+			},
+			wantAttempts: 2,
+			wantSlept:    []time.Duration{7 * time.Second},
+		},
+		{
+			name: "Retry-After is honoured on PostMessage",
+			errs: []error{&slack.RateLimitedError{RetryAfter: 2 * time.Second}},
+			call: func(c Client) error {
+				_, _, err := c.PostMessage("C123")
+				return err
+			},
+			wantAttempts: 2,
+			wantSlept:    []time.Duration{2 * time.Second},
+		},
+		{
+			name: "Retry-After past MaxDelay gives up",
+			errs: []error{&slack.RateLimitedError{RetryAfter: time.Hour}},
+			call: func(c Client) error {
+				_, _, err := c.DeleteMessage("C123", "1.0")
+				return err
+			},
+			wantAttempts: 1,
+			wantErr:      true,
+			wantSlept:    []time.Duration{},
+		},
+		{
+			name: "5xx is retried on DeleteMessage",
+			errs: []error{serverErr, serverErr},
+			call: func(c Client) error {
+				_, _, err := c.DeleteMessage("C123", "1.0")
+				return err
+			},
+			wantAttempts: 3,
+		},
+		{
+			name: "5xx is not retried on PostMessage",
+			errs: []error{serverErr},
+			call: func(c Client) error {
+				_, _, err := c.PostMessage("C123")
+				return err
+			},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name: "4xx is not retried",
+			errs: []error{clientErr},
+			call: func(c Client) error {
+				_, err := c.GetUserInfo("U123")
+				return err
+			},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name: "Slack API errors are not retried",
+			errs: []error{errors.New("user_not_found")},
+			call: func(c Client) error {
+				_, err := c.GetUserInfo("U123")
+				return err
+			},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name: "gives up after MaxRetries",
+			errs: []error{serverErr, serverErr, serverErr, serverErr, serverErr},
+			call: func(c Client) error {
+				_, err := c.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: "C123"})
+				return err
+			},
+			wantAttempts: 4,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			next := func() error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			}
+			mock := &MockClient{
+				GetUserInfoFn: func(user string) (*slack.User, error) { return &slack.User{}, next() },
+				SearchMessagesFn: func(query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+					return &slack.SearchMessages{}, next()
+				},
+				PostMessageFn: func(channelID string, options ...slack.MsgOption) (string, string, error) {
+					return channelID, "ts", next()
+				},
+				DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+					return channel, messageTimestamp, next()
+				},
+				GetConversationInfoFn: func(input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+					return &slack.Channel{}, next()
+				},
+			}
+
+			c, slept := newTestRateLimitedClient(mock, RateLimitOptions{})
+			err := tt.call(c)
+			if tt.wantErr && err == nil {
+				t.Errorf("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.wantSlept != nil {
+				if len(*slept) != len(tt.wantSlept) {
+					t.Fatalf("slept %v, want %v", *slept, tt.wantSlept)
+				}
+				// Retry-After waits are measured against the wall clock, so allow some slack.
+				for i, d := range tt.wantSlept {
+					if got := (*slept)[i]; got > d || got < d-100*time.Millisecond {
+						t.Errorf("sleep[%d] = %s, want ~%s", i, got, d)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitedClientBackoff(t *testing.T) {
+	c, _ := newTestRateLimitedClient(&MockClient{}, RateLimitOptions{BaseDelay: time.Second, MaxDelay: 10 * time.Second})
+
+	for attempt, ceiling := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		for range 20 {
+			d := c.backoff(attempt)
+			if d < ceiling/2 || d > ceiling {
+				t.Fatalf("backoff(%d) = %s, want within [%s, %s]", attempt, d, ceiling/2, ceiling)
+			}
+		}
+	}
+}
+
+func TestRateLimitedClientBudget(t *testing.T) {
+	mock := &MockClient{
+		SearchMessagesFn: func(query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+			return &slack.SearchMessages{}, nil
+		},
+	}
+	// 60/min allows a burst of 6, then one call per second.
+	c, slept := newTestRateLimitedClient(mock, RateLimitOptions{Budgets: map[string]int{MethodSearchMessages: 60}})
+
+	for range 6 {
+		if _, err := c.SearchMessages("q", slack.NewSearchParameters()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(*slept) != 0 {
+		t.Fatalf("burst should not be throttled, slept %v", *slept)
+	}
+
+	if _, err := c.SearchMessages("q", slack.NewSearchParameters()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*slept) != 1 || (*slept)[0] < 900*time.Millisecond || (*slept)[0] > time.Second {
+		t.Errorf("expected ~1s throttle once the burst is spent, slept %v", *slept)
+	}
+}
+
+func TestRateLimitedClientPauseAfterRetryAfter(t *testing.T) {
+	calls := 0
+	mock := &MockClient{
+		GetUserInfoFn: func(user string) (*slack.User, error) {
+			calls++
+			if calls == 1 {
+				return nil, &slack.RateLimitedError{RetryAfter: 5 * time.Second}
+			}
+			return &slack.User{}, nil
+		},
+	}
+	c, slept := newTestRateLimitedClient(mock, RateLimitOptions{})
+
+	if _, err := c.GetUserInfo("U1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The retry above slept the Retry-After; the bucket stays paused for later callers too.
+	*slept = nil
+	if _, err := c.GetUserInfo("U2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*slept) != 1 || (*slept)[0] < 4*time.Second {
+		t.Errorf("expected subsequent call to wait out Retry-After, slept %v", *slept)
+	}
+}
+
+func TestRateLimitedClientStats(t *testing.T) {
+	calls := 0
+	mock := &MockClient{
+		DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+			calls++
+			switch calls {
+			case 1:
+				return "", "", &slack.RateLimitedError{RetryAfter: time.Second}
+			case 2:
+				return "", "", slack.StatusCodeError{Code: http.StatusServiceUnavailable}
+			}
+			return channel, messageTimestamp, nil
+		},
+	}
+	c, _ := newTestRateLimitedClient(mock, RateLimitOptions{})
+
+	if _, _, err := c.DeleteMessage("C1", "1.0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := c.Stats()[MethodChatDelete]
+	want := MethodStats{Calls: 1, Attempts: 3, Retries: 2, RateLimited: 1}
+	got.Throttled = 0
+	if got != want {
+		t.Errorf("Stats()[%s] = %+v, want %+v", MethodChatDelete, got, want)
+	}
+}
+
+func TestRateLimitedClientContextCancelled(t *testing.T) {
+	calls := 0
+	mock := &MockClient{
+		SearchMessagesContextFn: func(ctx context.Context, query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+			calls++
+			return nil, &slack.RateLimitedError{RetryAfter: 10 * time.Second}
+		},
+	}
+	c, _ := newTestRateLimitedClient(mock, RateLimitOptions{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.SearchMessagesContext(ctx, "q", slack.NewSearchParameters())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected no retry once the context is done, got %d calls", calls)
+	}
+}
+
+func TestRateLimitedClientOnAttempt(t *testing.T) {
+	calls := 0
+	mock := &MockClient{
+		GetUserInfoFn: func(user string) (*slack.User, error) {
+			calls++
+			if calls == 1 {
+				return nil, &slack.RateLimitedError{RetryAfter: time.Millisecond}
+			}
+			return &slack.User{ID: user}, nil
+		},
+	}
+
+	var statuses []string
+	c, _ := newTestRateLimitedClient(mock, RateLimitOptions{
+		OnAttempt: func(method string, err error, elapsed time.Duration) {
+			statuses = append(statuses, method+" "+ErrorStatus(err))
+		},
+	})
+	if _, err := c.GetUserInfo("U123"); err != nil {
+		t.Fatalf("GetUserInfo() unexpected error: %v", err)
+	}
+
+	want := []string{"users.info rate_limited", "users.info ok"}
+	if len(statuses) != len(want) || statuses[0] != want[0] || statuses[1] != want[1] {
+		t.Errorf("OnAttempt saw %v, want %v", statuses, want)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, "ok"},
+		{"rate limited", &slack.RateLimitedError{RetryAfter: time.Second}, "rate_limited"},
+		{"http status", slack.StatusCodeError{Code: http.StatusBadGateway, Status: "502 Bad Gateway"}, "502"},
+		{"slack error", slack.SlackErrorResponse{Err: "message_not_found"}, "message_not_found"},
+		{"deadline", context.DeadlineExceeded, "timeout"},
+		{"canceled", context.Canceled, "canceled"},
+		{"other", errors.New("boom"), "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorStatus(tt.err); got != tt.want {
+				t.Errorf("ErrorStatus(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package slackclient
+
+import (
+	"context"
+
+	"github.com/slack-go/slack"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/xortim/penny/pkg/slackclient"
+
+// TracingClient decorates a Client with a span per call, parented to the
+// context bound at construction (or the call's own context, where it takes one).
+// Each span records the method, channel and outcome; a RateLimitedClient
+// underneath adds its throttling and retries to the same span.
+type TracingClient struct {
+	next   Client
+	ctx    context.Context
+	tracer trace.Tracer
+}
+
+var _ Client = (*TracingClient)(nil)
+
+// NewTracingClient wraps next so that its calls become children of ctx's span.
+func NewTracingClient(ctx context.Context, next Client) *TracingClient {
+	return &TracingClient{next: next, ctx: ctx, tracer: otel.Tracer(tracerName)}
+}
+
+// WithContext returns a client whose calls become children of ctx's span.
+func (c *TracingClient) WithContext(ctx context.Context) Client {
+	return &TracingClient{next: c.next, ctx: ctx, tracer: c.tracer}
+}
+
+// start opens the span for a call and returns its context along with the
+// wrapped client bound to it.
+func (c *TracingClient) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, Client, trace.Span) {
+	attrs = append(attrs, attribute.String("slack.method", method))
+	ctx, span := c.tracer.Start(ctx, "slack "+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, WithContext(ctx, c.next), span
+}
+
+func end(span trace.Span, err error) {
+	span.SetAttributes(attribute.String("slack.status", ErrorStatus(err)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func channelAttr(channel string) attribute.KeyValue {
+	return attribute.String("slack.channel", channel)
+}
+
+func (c *TracingClient) GetConversationInfo(input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+	_, next, span := c.start(c.ctx, MethodConversationsInfo, channelAttr(input.ChannelID))
+	out, err := next.GetConversationInfo(input)
+	end(span, err)
+	return out, err
+}
+
+func (c *TracingClient) JoinConversation(channelID string) (*slack.Channel, string, []string, error) {
+	_, next, span := c.start(c.ctx, MethodConversationsJoin, channelAttr(channelID))
+	channel, warning, warnings, err := next.JoinConversation(channelID)
+	end(span, err)
+	return channel, warning, warnings, err
+}
+
+func (c *TracingClient) GetConversationHistory(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+	_, next, span := c.start(c.ctx, MethodConversationsHistory, channelAttr(params.ChannelID))
+	out, err := next.GetConversationHistory(params)
+	end(span, err)
+	return out, err
+}
+
+func (c *TracingClient) GetConversationReplies(params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error) {
+	_, next, span := c.start(c.ctx, MethodConversationsReplies, channelAttr(params.ChannelID))
+	msgs, hasMore, cursor, err := next.GetConversationReplies(params)
+	end(span, err)
+	return msgs, hasMore, cursor, err
+}
+
+func (c *TracingClient) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	_, next, span := c.start(c.ctx, MethodChatPostMessage, channelAttr(channelID))
+	channel, ts, err := next.PostMessage(channelID, options...)
+	end(span, err)
+	return channel, ts, err
+}
+
+func (c *TracingClient) GetPermalinkContext(ctx context.Context, params *slack.PermalinkParameters) (string, error) {
+	ctx, next, span := c.start(ctx, MethodChatGetPermalink, channelAttr(params.Channel))
+	out, err := next.GetPermalinkContext(ctx, params)
+	end(span, err)
+	return out, err
+}
+
+func (c *TracingClient) PostEphemeral(channelID, userID string, options ...slack.MsgOption) (string, error) {
+	_, next, span := c.start(c.ctx, MethodChatPostEphemeral, channelAttr(channelID), attribute.String("slack.user", userID))
+	ts, err := next.PostEphemeral(channelID, userID, options...)
+	end(span, err)
+	return ts, err
+}
+
+func (c *TracingClient) UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	_, next, span := c.start(c.ctx, MethodChatUpdate, channelAttr(channelID))
+	channel, ts, text, err := next.UpdateMessage(channelID, timestamp, options...)
+	end(span, err)
+	return channel, ts, text, err
+}
+
+func (c *TracingClient) OpenView(triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error) {
+	_, next, span := c.start(c.ctx, MethodViewsOpen, attribute.String("slack.view", view.CallbackID))
+	out, err := next.OpenView(triggerID, view)
+	end(span, err)
+	return out, err
+}
+
+func (c *TracingClient) OpenConversation(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error) {
+	_, next, span := c.start(c.ctx, MethodConversationsOpen, attribute.StringSlice("slack.users", params.Users))
+	channel, noOp, alreadyOpen, err := next.OpenConversation(params)
+	end(span, err)
+	return channel, noOp, alreadyOpen, err
+}
+
+func (c *TracingClient) AddReaction(name string, item slack.ItemRef) error {
+	_, next, span := c.start(c.ctx, MethodReactionsAdd, channelAttr(item.Channel), attribute.String("slack.reaction", name))
+	err := next.AddReaction(name, item)
+	end(span, err)
+	return err
+}
+
+func (c *TracingClient) GetUserInfo(user string) (*slack.User, error) {
+	return c.GetUserInfoContext(c.ctx, user)
+}
+
+func (c *TracingClient) GetUserInfoContext(ctx context.Context, user string) (*slack.User, error) {
+	ctx, next, span := c.start(ctx, MethodUsersInfo, attribute.String("slack.user", user))
+	out, err := next.GetUserInfoContext(ctx, user)
+	end(span, err)
+	return out, err
+}
+
+func (c *TracingClient) GetUsersContext(ctx context.Context, options ...slack.GetUsersOption) ([]slack.User, error) {
+	ctx, next, span := c.start(ctx, MethodUsersList)
+	out, err := next.GetUsersContext(ctx, options...)
+	end(span, err)
+	return out, err
+}
+
+func (c *TracingClient) SearchMessages(query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+	return c.SearchMessagesContext(c.ctx, query, params)
+}
+
+func (c *TracingClient) SearchMessagesContext(ctx context.Context, query string, params slack.SearchParameters) (*slack.SearchMessages, error) {
+	ctx, next, span := c.start(ctx, MethodSearchMessages)
+	out, err := next.SearchMessagesContext(ctx, query, params)
+	end(span, err)
+	return out, err
+}
+
+func (c *TracingClient) DeleteMessage(channel, messageTimestamp string) (string, string, error) {
+	_, next, span := c.start(c.ctx, MethodChatDelete, channelAttr(channel))
+	ch, ts, err := next.DeleteMessage(channel, messageTimestamp)
+	end(span, err)
+	return ch, ts, err
+}
+
+func (c *TracingClient) GetConversations(params *slack.GetConversationsParameters) ([]slack.Channel, string, error) {
+	_, next, span := c.start(c.ctx, MethodConversationsList)
+	channels, cursor, err := next.GetConversations(params)
+	end(span, err)
+	return channels, cursor, err
+}
@@ -0,0 +1,102 @@
+package slackclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func attrValue(span sdktrace.ReadOnlySpan, key string) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTracingClient(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	parentCtx, parent := provider.Tracer("test").Start(context.Background(), "event")
+
+	deletes := 0
+	mock := &MockClient{
+		DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+			deletes++
+			if deletes == 1 {
+				return "", "", &slack.RateLimitedError{RetryAfter: time.Millisecond}
+			}
+			return channel, messageTimestamp, nil
+		},
+		GetUserInfoContextFn: func(ctx context.Context, user string) (*slack.User, error) {
+			return nil, slack.SlackErrorResponse{Err: "user_not_found"}
+		},
+	}
+	limited, _ := newTestRateLimitedClient(mock, RateLimitOptions{})
+	c := NewTracingClient(parentCtx, limited)
+	c.tracer = provider.Tracer(tracerName)
+
+	if _, _, err := c.DeleteMessage("C123", "1.0"); err != nil {
+		t.Fatalf("DeleteMessage() unexpected error: %v", err)
+	}
+	if _, err := c.GetUserInfo("U123"); err == nil {
+		t.Fatalf("GetUserInfo() expected error")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("recorded %d spans, want 3", len(spans))
+	}
+
+	del := spans[0]
+	if del.Name() != "slack chat.delete" {
+		t.Errorf("span name = %q, want %q", del.Name(), "slack chat.delete")
+	}
+	if del.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("chat.delete span is not a child of the event span")
+	}
+	if v, _ := attrValue(del, "slack.channel"); v.AsString() != "C123" {
+		t.Errorf("slack.channel = %q, want C123", v.AsString())
+	}
+	if v, _ := attrValue(del, "slack.retries"); v.AsInt64() != 1 {
+		t.Errorf("slack.retries = %d, want 1", v.AsInt64())
+	}
+	if v, _ := attrValue(del, "slack.status"); v.AsString() != "ok" {
+		t.Errorf("slack.status = %q, want ok", v.AsString())
+	}
+
+	user := spans[1]
+	if v, _ := attrValue(user, "slack.status"); v.AsString() != "user_not_found" {
+		t.Errorf("users.info slack.status = %q, want user_not_found", v.AsString())
+	}
+	if user.Status().Code.String() != "Error" {
+		t.Errorf("users.info span status = %v, want Error", user.Status().Code)
+	}
+}
+
+func TestWithContextBindsDecorators(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mock := &MockClient{
+		DeleteMessageFn: func(channel, messageTimestamp string) (string, string, error) {
+			return "", "", &slack.RateLimitedError{RetryAfter: time.Second}
+		},
+	}
+	limited, _ := newTestRateLimitedClient(mock, RateLimitOptions{})
+	bound := WithContext(ctx, NewCachingClient(limited, CacheOptions{}))
+
+	if _, _, err := bound.DeleteMessage("C123", "1.0"); err != context.Canceled {
+		t.Errorf("DeleteMessage() on a cancelled context = %v, want context.Canceled", err)
+	}
+	if got := limited.Stats()[MethodChatDelete].Attempts; got != 1 {
+		t.Errorf("Attempts = %d, want 1 before the cancelled wait", got)
+	}
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+	}{
+		{"disabled by default", "", false},
+		{"none", EXPORTER_NONE, false},
+		{"stdout", EXPORTER_STDOUT, false},
+		{"unknown exporter", "zipkin", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), Options{Exporter: tt.exporter, ServiceName: "penny"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if err := shutdown(context.Background()); err != nil {
+					t.Errorf("shutdown() unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	untraced := Logger(context.Background(), logger)
+	untraced.Info().Msg("untraced")
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Errorf("untraced log line has a trace_id: %s", buf.String())
+	}
+
+	buf.Reset()
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "event")
+	defer span.End()
+	traced := Logger(ctx, logger)
+	traced.Info().Msg("traced")
+	if !strings.Contains(buf.String(), `"trace_id":"`+span.SpanContext().TraceID().String()+`"`) {
+		t.Errorf("traced log line is missing the trace_id: %s", buf.String())
+	}
+}
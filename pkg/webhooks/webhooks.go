@@ -0,0 +1,330 @@
+// Package webhooks mirrors moderation events to outside systems. Each event is
+// POSTed as JSON to every configured URL, signed with HMAC-SHA256, retried with
+// exponential backoff, and written to a dead-letter log if it can't be delivered.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Events a webhook can receive.
+const (
+	EVENT_CASE_CREATED    = "case.created"
+	EVENT_MESSAGE_REMOVED = "message.removed"
+	EVENT_CASE_OVERTURNED = "case.overturned"
+)
+
+// Events is every event, for validation.
+var Events = []string{EVENT_CASE_CREATED, EVENT_MESSAGE_REMOVED, EVENT_CASE_OVERTURNED}
+
+// What Penny did about a case, as listed in Case.Actions.
+const (
+	ACTION_NOTIFIED   = "notified_author"
+	ACTION_REMOVED    = "removed_message"
+	ACTION_ESCALATED  = "escalated"
+	ACTION_OVERTURNED = "overturned"
+)
+
+// Headers sent with every delivery. The signature is "sha256=" and the hex
+// HMAC-SHA256 of the timestamp, a dot and the body; see Sign.
+const (
+	HEADER_EVENT     = "X-Penny-Event"
+	HEADER_DELIVERY  = "X-Penny-Delivery"
+	HEADER_TIMESTAMP = "X-Penny-Timestamp"
+	HEADER_SIGNATURE = "X-Penny-Signature"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBackoff     = time.Second
+	defaultTimeout     = 10 * time.Second
+)
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	// ID is unique to the event, and the same across its retries and URLs.
+	ID    string    `json:"id"`
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Case  Case      `json:"case"`
+}
+
+// Case is what Penny decided about a report.
+type Case struct {
+	ID        uint   `json:"id"`
+	Verdict   string `json:"verdict"`
+	Score     int    `json:"score"`
+	Threshold int    `json:"threshold"`
+	// Signals is the score breakdown. Events sent after the report was
+	// processed, like case.overturned, don't have it.
+	Signals   []Signal `json:"signals,omitempty"`
+	Author    string   `json:"author"`
+	Channel   string   `json:"channel"`
+	Permalink string   `json:"permalink"`
+	Reporters []string `json:"reporters,omitempty"`
+	Actions   []string `json:"actions"`
+}
+
+// Signal is one signal's contribution to a case's score.
+type Signal struct {
+	Name     string `json:"name"`
+	Score    int    `json:"score"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+}
+
+// Options configure a Sender.
+type Options struct {
+	URLs   []string
+	Secret string
+	// Events limits what is sent; everything is sent if it's empty.
+	Events []string
+	// MaxAttempts is how many times a delivery is tried, and Backoff the wait
+	// before the first retry, doubling after each.
+	MaxAttempts int
+	Backoff     time.Duration
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+	// DeadLetter receives a JSON line for each delivery that failed every
+	// attempt. Failures are only logged if it's nil.
+	DeadLetter io.Writer
+	// OnDelivery, if set, is called once per delivery with its event and
+	// whether it succeeded.
+	OnDelivery func(event string, ok bool)
+}
+
+// Sender delivers events to webhooks in the background.
+type Sender struct {
+	opts   Options
+	events map[string]bool
+	client *http.Client
+	// sleep waits between attempts; tests replace it.
+	sleep func(ctx context.Context, d time.Duration) error
+	now   func() time.Time
+
+	// ctx is cancelled by Close to abandon retries.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex // guards writes to opts.DeadLetter
+}
+
+// NewSender returns a Sender for opts, filling in defaults.
+func NewSender(opts Options) *Sender {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	s := &Sender{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		sleep:  sleep,
+		now:    time.Now,
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if len(opts.Events) != 0 {
+		s.events = make(map[string]bool, len(opts.Events))
+		for _, e := range opts.Events {
+			s.events[e] = true
+		}
+	}
+	return s
+}
+
+// Wants reports whether event is sent anywhere, so callers can skip building
+// payloads no one will receive.
+func (s *Sender) Wants(event string) bool {
+	if s == nil || len(s.opts.URLs) == 0 {
+		return false
+	}
+	return s.events == nil || s.events[event]
+}
+
+// Send delivers c as event to every URL in the background. It's safe to call
+// on a nil Sender, which sends nothing.
+func (s *Sender) Send(event string, c Case) {
+	if !s.Wants(event) {
+		return
+	}
+	p := Payload{ID: newID(), Event: event, Time: s.now().UTC(), Case: c}
+	body, err := json.Marshal(p)
+	if err != nil {
+		log.Error().Err(err).Str("event", event).Msg("failed to encode webhook payload")
+		return
+	}
+	for _, url := range s.opts.URLs {
+		s.wg.Add(1)
+		go func(url string) {
+			defer s.wg.Done()
+			s.deliver(s.ctx, url, p, body)
+		}(url)
+	}
+}
+
+// Close waits for deliveries in flight to finish until ctx is done, then
+// abandons the rest, dead-lettering them. Nothing may be sent after Close.
+func (s *Sender) Close(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	s.cancel()
+	<-done
+	return err
+}
+
+// deliver tries to POST body to url, retrying failures that might pass on a
+// later attempt, and dead-letters it if it never gets through.
+func (s *Sender) deliver(ctx context.Context, url string, p Payload, body []byte) {
+	logger := log.With().Str("url", url).Str("event", p.Event).Str("delivery", p.ID).Logger()
+	var err error
+	attempts := 0
+	backoff := s.opts.Backoff
+	for attempts < s.opts.MaxAttempts {
+		if attempts > 0 {
+			if err := s.sleep(ctx, backoff); err != nil {
+				break
+			}
+			backoff *= 2
+		}
+		attempts++
+		var retry bool
+		retry, err = s.post(ctx, url, p, body)
+		if err == nil {
+			logger.Debug().Int("attempts", attempts).Msg("delivered webhook")
+			s.observe(p.Event, true)
+			return
+		}
+		logger.Warn().Err(err).Int("attempt", attempts).Msg("webhook delivery failed")
+		if !retry {
+			break
+		}
+	}
+	logger.Error().Err(err).Int("attempts", attempts).Msg("giving up on webhook delivery")
+	s.observe(p.Event, false)
+	s.deadLetter(url, p, attempts, err)
+}
+
+// post makes one attempt. retry reports whether a failure is worth retrying:
+// network errors, 429s and 5xxs are, other responses aren't.
+func (s *Sender) post(ctx context.Context, url string, p Payload, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "penny-webhooks")
+	req.Header.Set(HEADER_EVENT, p.Event)
+	req.Header.Set(HEADER_DELIVERY, p.ID)
+	req.Header.Set(HEADER_TIMESTAMP, timestamp)
+	req.Header.Set(HEADER_SIGNATURE, Sign(s.opts.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("%s responded %s", url, resp.Status)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+func (s *Sender) observe(event string, ok bool) {
+	if s.opts.OnDelivery != nil {
+		s.opts.OnDelivery(event, ok)
+	}
+}
+
+// deadLetterEntry is one line of the dead-letter log.
+type deadLetterEntry struct {
+	Time     time.Time `json:"time"`
+	URL      string    `json:"url"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Payload  Payload   `json:"payload"`
+}
+
+func (s *Sender) deadLetter(url string, p Payload, attempts int, err error) {
+	if s.opts.DeadLetter == nil {
+		return
+	}
+	entry := deadLetterEntry{Time: s.now().UTC(), URL: url, Attempts: attempts, Payload: p}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	line, mErr := json.Marshal(entry)
+	if mErr != nil {
+		log.Error().Err(mErr).Str("delivery", p.ID).Msg("failed to encode dead letter")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, wErr := s.opts.DeadLetter.Write(append(line, '\n')); wErr != nil {
+		log.Error().Err(wErr).Str("delivery", p.ID).Msg("failed to write dead letter")
+	}
+}
+
+// Sign returns the signature header for body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is body's signature at timestamp. Receivers
+// should also reject timestamps too far from their own clock.
+func Verify(secret, timestamp, signature string, body []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,226 @@
+package webhooks_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xortim/penny/pkg/webhooks"
+	"github.com/xortim/penny/pkg/webhooks/webhookstest"
+)
+
+const secret = "s3cret"
+
+var testCase = webhooks.Case{
+	ID:        42,
+	Verdict:   "removed",
+	Score:     5,
+	Threshold: 4,
+	Signals:   []webhooks.Signal{{Name: "reported", Score: 3}, {Name: "low_activity", Score: 2}},
+	Author:    "U_OP",
+	Channel:   "C_OP",
+	Permalink: "https://example.slack.com/archives/C_OP/p1",
+	Actions:   []string{webhooks.ACTION_NOTIFIED, webhooks.ACTION_REMOVED},
+}
+
+// syncBuffer is a bytes.Buffer safe for the Sender's goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func closeSender(t *testing.T, s *webhooks.Sender) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"case.created"}`)
+	sig := webhooks.Sign(secret, "1700000000", body)
+	if !strings.HasPrefix(sig, "sha256=") || len(sig) != len("sha256=")+64 {
+		t.Fatalf("Sign() = %q, want sha256= and 64 hex digits", sig)
+	}
+	if !webhooks.Verify(secret, "1700000000", sig, body) {
+		t.Error("Verify() = false for its own signature")
+	}
+	for name, ok := range map[string]bool{
+		"other secret":    webhooks.Verify("other", "1700000000", sig, body),
+		"other timestamp": webhooks.Verify(secret, "1700000001", sig, body),
+		"other body":      webhooks.Verify(secret, "1700000000", sig, []byte(`{}`)),
+	} {
+		if ok {
+			t.Errorf("Verify() with %s = true, want false", name)
+		}
+	}
+}
+
+func TestSenderDelivers(t *testing.T) {
+	srv := webhookstest.NewServer(secret)
+	defer srv.Close()
+	other := webhookstest.NewServer(secret)
+	defer other.Close()
+
+	var delivered []bool
+	var mu sync.Mutex
+	s := webhooks.NewSender(webhooks.Options{
+		URLs:   []string{srv.URL, other.URL},
+		Secret: secret,
+		OnDelivery: func(event string, ok bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			delivered = append(delivered, ok)
+		},
+	})
+	s.Send(webhooks.EVENT_CASE_CREATED, testCase)
+	closeSender(t, s)
+
+	for _, r := range []*webhookstest.Server{srv, other} {
+		got := r.Deliveries()
+		if len(got) != 1 {
+			t.Fatalf("got %d deliveries, want 1", len(got))
+		}
+		d := got[0]
+		if d.Header.Get(webhooks.HEADER_EVENT) != webhooks.EVENT_CASE_CREATED || d.Payload.Event != webhooks.EVENT_CASE_CREATED {
+			t.Errorf("delivery event = %q/%q, want %q", d.Header.Get(webhooks.HEADER_EVENT), d.Payload.Event, webhooks.EVENT_CASE_CREATED)
+		}
+		if d.Payload.ID == "" || d.Header.Get(webhooks.HEADER_DELIVERY) != d.Payload.ID {
+			t.Errorf("delivery ID header = %q, payload = %q, want them equal and set", d.Header.Get(webhooks.HEADER_DELIVERY), d.Payload.ID)
+		}
+		c := d.Payload.Case
+		if c.ID != 42 || c.Verdict != "removed" || len(c.Signals) != 2 || c.Signals[1].Name != "low_activity" || len(c.Actions) != 2 {
+			t.Errorf("delivered case = %+v, want %+v", c, testCase)
+		}
+	}
+	if srv.Deliveries()[0].Payload.ID != other.Deliveries()[0].Payload.ID {
+		t.Error("the same event has different IDs at each URL")
+	}
+	if len(delivered) != 2 || !delivered[0] || !delivered[1] {
+		t.Errorf("OnDelivery saw %v, want two successes", delivered)
+	}
+}
+
+func TestSenderRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     []int
+		receiver     string
+		wantAttempts int
+		wantDead     bool
+	}{
+		{name: "server errors and rate limits are retried", failures: []int{500, 429, 503}, wantAttempts: 4},
+		{name: "gives up after max attempts", failures: []int{500, 500, 500, 500}, wantAttempts: 4, wantDead: true},
+		{name: "client errors aren't retried", failures: []int{400}, wantAttempts: 1, wantDead: true},
+		{name: "bad signatures aren't retried", receiver: "other", wantAttempts: 1, wantDead: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := tt.receiver
+			if receiver == "" {
+				receiver = secret
+			}
+			srv := webhookstest.NewServer(receiver)
+			defer srv.Close()
+			srv.FailWith(tt.failures...)
+
+			dead := &syncBuffer{}
+			s := webhooks.NewSender(webhooks.Options{
+				URLs:        []string{srv.URL},
+				Secret:      secret,
+				MaxAttempts: 4,
+				Backoff:     time.Millisecond,
+				DeadLetter:  dead,
+			})
+			s.Send(webhooks.EVENT_MESSAGE_REMOVED, testCase)
+			closeSender(t, s)
+
+			if got := srv.Attempts(); got != tt.wantAttempts {
+				t.Errorf("made %d attempts, want %d", got, tt.wantAttempts)
+			}
+			if got := len(srv.Deliveries()) == 1; got == tt.wantDead {
+				t.Errorf("delivered = %v, want %v", got, !tt.wantDead)
+			}
+			if !tt.wantDead {
+				if dead.String() != "" {
+					t.Errorf("dead letters = %q, want none", dead.String())
+				}
+				return
+			}
+
+			var entry struct {
+				URL      string           `json:"url"`
+				Attempts int              `json:"attempts"`
+				Error    string           `json:"error"`
+				Payload  webhooks.Payload `json:"payload"`
+			}
+			if err := json.Unmarshal([]byte(dead.String()), &entry); err != nil {
+				t.Fatalf("dead letter %q isn't a JSON line: %v", dead.String(), err)
+			}
+			if entry.URL != srv.URL || entry.Attempts != tt.wantAttempts || entry.Error == "" || entry.Payload.Case.ID != 42 {
+				t.Errorf("dead letter = %+v, want the payload after %d attempts", entry, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestSenderEvents(t *testing.T) {
+	s := webhooks.NewSender(webhooks.Options{URLs: []string{"http://127.0.0.1:1"}, Events: []string{webhooks.EVENT_CASE_OVERTURNED}})
+	if s.Wants(webhooks.EVENT_CASE_CREATED) || !s.Wants(webhooks.EVENT_CASE_OVERTURNED) {
+		t.Error("Wants() ignored the events filter")
+	}
+
+	var none *webhooks.Sender
+	if none.Wants(webhooks.EVENT_CASE_CREATED) {
+		t.Error("Wants() on a nil Sender = true")
+	}
+	none.Send(webhooks.EVENT_CASE_CREATED, testCase)
+	if err := none.Close(context.Background()); err != nil {
+		t.Errorf("Close() on a nil Sender = %v", err)
+	}
+}
+
+func TestSenderCloseAbandonsRetries(t *testing.T) {
+	srv := webhookstest.NewServer(secret)
+	defer srv.Close()
+	srv.FailWith(http.StatusServiceUnavailable)
+
+	dead := &syncBuffer{}
+	s := webhooks.NewSender(webhooks.Options{
+		URLs:       []string{srv.URL},
+		Secret:     secret,
+		Backoff:    time.Hour,
+		DeadLetter: dead,
+	})
+	s.Send(webhooks.EVENT_CASE_CREATED, testCase)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want the deadline", err)
+	}
+	if !strings.Contains(dead.String(), `"id":42`) {
+		t.Errorf("dead letters = %q, want the abandoned delivery", dead.String())
+	}
+}
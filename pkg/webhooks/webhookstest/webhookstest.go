@@ -0,0 +1,104 @@
+// Package webhookstest is a local webhook receiver for testing deliveries
+// end to end.
+package webhookstest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/xortim/penny/pkg/webhooks"
+)
+
+// Delivery is a request the Server accepted.
+type Delivery struct {
+	Header  http.Header
+	Payload webhooks.Payload
+}
+
+// Server is an httptest.Server that checks each request's signature against
+// its secret and records the deliveries it accepts. Requests with a bad
+// signature get a 401.
+type Server struct {
+	*httptest.Server
+	secret string
+
+	mu         sync.Mutex
+	deliveries []Delivery
+	attempts   int
+	failures   []int
+	notify     chan struct{}
+}
+
+// NewServer starts a Server verifying signatures with secret. Close it when done.
+func NewServer(secret string) *Server {
+	s := &Server{secret: secret, notify: make(chan struct{}, 100)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// FailWith makes the next requests fail with the given status codes, in order.
+func (s *Server) FailWith(codes ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, codes...)
+}
+
+// Deliveries returns the deliveries accepted so far.
+func (s *Server) Deliveries() []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Delivery(nil), s.deliveries...)
+}
+
+// Attempts returns how many requests were made, accepted or not.
+func (s *Server) Attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts
+}
+
+// Accepted is signalled after each accepted delivery.
+func (s *Server) Accepted() <-chan struct{} {
+	return s.notify
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.attempts++
+	if len(s.failures) != 0 {
+		code := s.failures[0]
+		s.failures = s.failures[1:]
+		s.mu.Unlock()
+		w.WriteHeader(code)
+		return
+	}
+	s.mu.Unlock()
+
+	if !webhooks.Verify(s.secret, r.Header.Get(webhooks.HEADER_TIMESTAMP), r.Header.Get(webhooks.HEADER_SIGNATURE), body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var p webhooks.Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.deliveries = append(s.deliveries, Delivery{Header: r.Header.Clone(), Payload: p})
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}